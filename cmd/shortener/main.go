@@ -4,20 +4,13 @@ import (
 	"context"
 	"log"
 	"log/slog"
-	"net/http"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/gennadis/shorturl/internal/app"
 	"github.com/gennadis/shorturl/internal/app/config"
 )
 
-// Server graceful shutdown timeout.
-const (
-	serverShutdownTimeout = time.Second * 5
-)
-
 // To set buildVersion, buildDate, and buildCommit at compile time, use the
 // `-ldflags` option with go run or go build. This allows embedding version
 // information directly into the binary. By default, these values are set to "N/A".
@@ -49,51 +42,9 @@ func main() {
 	log.Printf("Build date: %s\n", buildDate)
 	log.Printf("Build commit: %s\n", buildCommit)
 
-	// Start HTTP server
-	srv := &http.Server{
-		Addr:    cfg.ServerAddress,
-		Handler: app.Handler.Router,
-	}
-
-	// Run the background deleter in a separate goroutine.
-	wg := app.BackgroundDeleter.Run(ctx)
-	go func() {
-		defer close(app.BackgroundDeleter.DeleteChan)
-		defer close(app.BackgroundDeleter.ErrorChan)
-		wg.Wait()
-	}()
-
-	// Set up graceful shutdown
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		<-ctx.Done()
-		slog.Info("application received shutdown signal")
-		shutdownTimeoutCtx, cancelShutdownTimeoutCtx := context.WithTimeout(context.Background(), serverShutdownTimeout)
-		defer cancelShutdownTimeoutCtx()
-		if err := srv.Shutdown(shutdownTimeoutCtx); err != nil {
-			slog.Error("server shutdown", slog.Any("error", err))
-		}
-	}()
-
-	// Start the HTTP server and listen for incoming requests.
-	var srvErr error
-
-	switch cfg.EnableHTTPS {
-	case true:
-		srvErr = srv.ListenAndServeTLS(
-			"internal/app/config/localhost.crt",
-			"internal/app/config/localhost.key",
-		)
-	default:
-		srvErr = srv.ListenAndServe()
+	// Run the application until a shutdown signal is received or a component fails.
+	if err := app.Run(ctx); err != nil {
+		log.Fatalf("application error: %v", err)
 	}
-
-	if srvErr != nil && srvErr != http.ErrServerClosed {
-		log.Fatalf("server error: %v", srvErr)
-	}
-
-	// Wait for all background tasks to finish before shutdown.
-	wg.Wait()
 	slog.Info("application shutdown completed")
 }
@@ -3,13 +3,48 @@ package app
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/gennadis/shorturl/internal/app/config"
 	"github.com/gennadis/shorturl/internal/app/deleter"
 	"github.com/gennadis/shorturl/internal/app/handlers"
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/gennadis/shorturl/internal/app/lifecycle"
 	"github.com/gennadis/shorturl/internal/app/logger"
+	"github.com/gennadis/shorturl/internal/app/metrics"
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/moderation"
+	"github.com/gennadis/shorturl/internal/app/oidc"
+	"github.com/gennadis/shorturl/internal/app/policy"
 	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/gennadis/shorturl/internal/app/validator"
+	"github.com/gennadis/shorturl/internal/app/webhooks"
+	"github.com/gennadis/shorturl/internal/app/workspaces"
+)
+
+// Paths of the certificate and key the server uses in HTTPS mode.
+const (
+	tlsCertFile = "internal/app/config/localhost.crt"
+	tlsKeyFile  = "internal/app/config/localhost.key"
+)
+
+// Names of the components started and stopped by the lifecycle supervisor.
+const (
+	componentMetrics    = "metrics"
+	componentRepository = "repository"
+	componentPolicy     = "policy"
+	componentRateLimit  = "ratelimit"
+	componentJobs       = "jobs"
+	componentHTTP       = "http"
 )
 
 // App represents the main application structure.
@@ -23,6 +58,20 @@ type App struct {
 	Handler *handlers.Handler
 	// BackgroundDeleter handles background URL deletions.
 	BackgroundDeleter *deleter.BackgroundDeleter
+	// Jobs runs the background jobs, starting with the URL deletions.
+	Jobs *jobs.Scheduler
+	// RateLimiter limits the requests per route class.
+	RateLimiter *middlewares.RateLimiter
+	// Policy checks destination URLs against the allow and deny lists.
+	Policy *policy.Engine
+	// Server is the HTTP server serving the handler.
+	Server *http.Server
+	// Metrics collects the Prometheus metrics, or is nil if metrics are disabled.
+	Metrics *metrics.Metrics
+	// MetricsServer is the separate HTTP server serving the metrics, or nil if they are served by Server.
+	MetricsServer *http.Server
+	// Lifecycle starts and stops the components of the application and reports its readiness.
+	Lifecycle *lifecycle.Supervisor
 	// context is the application context.
 	context context.Context
 }
@@ -41,18 +90,394 @@ func NewApp(ctx context.Context, cfg config.Config) (*App, error) {
 		return nil, err
 	}
 
-	// Create a new background deleter associated with the repository.
-	backgroundDeleter := deleter.NewBackgroundDeleter(repo)
+	// Create the metrics if enabled, observing every repository call.
+	var appMetrics *metrics.Metrics
+	if cfg.MetricsEnabled {
+		appMetrics = metrics.New()
+		repo = repository.NewInstrumentedRepository(repo, appMetrics.ObserveRepositoryCall)
+	}
+
+	// Create the webhook service and the dispatcher delivering its events.
+	webhookService := webhooks.NewService(repo, cfg.BaseURL)
+	webhookService.AllowPrivateNetworks = cfg.WebhookAllowPrivateNetworks
+	webhookDispatcher := webhooks.NewDispatcher(webhookService, webhooks.DispatcherConfig{
+		MaxAttempts:    cfg.WebhookMaxAttempts,
+		InitialBackoff: cfg.WebhookInitialBackoff.Duration(),
+		MaxBackoff:     cfg.WebhookMaxBackoff.Duration(),
+		PollInterval:   cfg.WebhookPollInterval.Duration(),
+		Timeout:        cfg.WebhookTimeout.Duration(),
+		Concurrency:    cfg.WebhookConcurrency,
+	})
+	clickCounter := webhooks.NewClickCounter(webhookService, cfg.WebhookClickFlushInterval.Duration())
+
+	// Create a new background deleter associated with the repository, notifying webhooks of applied deletions.
+	backgroundDeleter := deleter.NewBackgroundDeleter(repo, deleter.Config{
+		QueueSize:      cfg.DeleteQueueSize,
+		BatchSize:      cfg.DeleteBatchSize,
+		FlushInterval:  cfg.DeleteFlushInterval.Duration(),
+		MaxRetries:     cfg.DeleteMaxRetries,
+		InitialBackoff: cfg.DeleteInitialBackoff.Duration(),
+		MaxBackoff:     cfg.DeleteMaxBackoff.Duration(),
+		JobRetention:   cfg.DeletionJobRetention.Duration(),
+		PruneInterval:  cfg.DeletionJobPruneInterval.Duration(),
+	})
+	backgroundDeleter.Notifier = webhookService
+	if appMetrics != nil {
+		backgroundDeleter.Observer = appMetrics
+		appMetrics.RegisterDeleterQueue(backgroundDeleter)
+		appMetrics.RegisterLinkCounts(repo)
+	}
+
+	// Create the background job scheduler and register the URL deletions, replaying the pending ones,
+	// the flushes of the counted clicks and the webhook deliveries.
+	scheduler := jobs.NewScheduler()
+	if err := backgroundDeleter.Register(ctx, scheduler); err != nil {
+		return nil, err
+	}
+	if err := clickCounter.Register(scheduler); err != nil {
+		return nil, err
+	}
+	if err := webhookDispatcher.Register(ctx, scheduler); err != nil {
+		return nil, err
+	}
+
+	// Create the destination policy engine from the configured allow and deny lists.
+	destinationPolicy, err := policy.NewEngine(
+		cfg.PolicyAllowListPath,
+		cfg.PolicyDenyListPath,
+		cfg.BaseURL,
+		cfg.PolicyReloadInterval.Duration(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the rate limiter for the redirect, shorten, batch, delete and report routes,
+	// and register the removal of its idle buckets.
+	rateLimiter, err := newRateLimiter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := rateLimiter.Register(scheduler, cfg.RateLimitPruneInterval.Duration()); err != nil {
+		return nil, err
+	}
+
+	// Load the cookie signing keys from configuration or the secret file.
+	cookieKeys, err := middlewares.LoadKeyRing(cfg.CookieSigningKeys, cfg.CookieSigningKeysFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure the session lifetime and cookie attributes.
+	sameSite, err := middlewares.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		return nil, err
+	}
+	sessionOptions := middlewares.SessionOptions{
+		TTL:      cfg.SessionTTL.Duration(),
+		Domain:   cfg.CookieDomain,
+		Path:     cfg.CookiePath,
+		SameSite: sameSite,
+		Secure:   cfg.CookieSecure || cfg.EnableHTTPS,
+	}
+
+	// Create the bearer token manager if bearer authentication is enabled.
+	tokens, err := newTokenManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the OpenID Connect provider if single sign-on is enabled.
+	oidcProvider := newOIDCProvider(cfg)
+
+	// Restrict the admin routes to the configured admin users and trusted subnets.
+	adminGuard, err := middlewares.NewAdminGuard(cfg.AdminUserIDs, cfg.AdminTrustedSubnets)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the optional page served for links outside their activation window.
+	inactiveLinkPage, err := readPageFile(cfg.InactiveLinkPagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the optional page served for taken down links.
+	takedownPage, err := readPageFile(cfg.TakedownPagePath)
+	if err != nil {
+		return nil, err
+	}
+	abuseThresholds := moderation.Thresholds{Reports: cfg.AbuseReportThreshold, Takedowns: cfg.AbuseTakedownThreshold}
+
+	// Create the lifecycle supervisor, whose state is reported by the readiness probe.
+	supervisor := lifecycle.NewSupervisor(cfg.ShutdownTimeout.Duration())
 
 	// Create a new HTTP request handler with the repository, background deleter, applictaion logger and configuration.
-	h := handlers.NewHandler(repo, backgroundDeleter, appLogger, cfg.BaseURL)
+	h := handlers.NewHandler(
+		repo, backgroundDeleter, appLogger, cfg.BaseURL,
+		handlers.WithURLValidator(validator.NewValidator(cfg.AllowedURLSchemes)),
+		handlers.WithDestinationPolicy(destinationPolicy),
+		handlers.WithRateLimiter(rateLimiter),
+		handlers.WithCookieKeys(cookieKeys),
+		handlers.WithSessionOptions(sessionOptions),
+		handlers.WithTokenManager(tokens),
+		handlers.WithOIDC(oidcProvider, cfg.OIDCPostLoginRedirect),
+		handlers.WithWorkspaceService(workspaces.NewService(repo, cfg.WorkspaceInvitationTTL.Duration())),
+		handlers.WithAdminGuard(adminGuard),
+		handlers.WithModerationService(moderation.NewService(repo, abuseThresholds)),
+		handlers.WithWebhookService(webhookService),
+		handlers.WithClickCounter(clickCounter),
+		handlers.WithInactiveLinkResponse(inactiveLinkStatus(cfg.InactiveLinkStatus), inactiveLinkPage),
+		handlers.WithTakedownPage(takedownPage),
+		handlers.WithAPIDocs(cfg.APIDocsEnabled),
+		handlers.WithJobScheduler(scheduler),
+		handlers.WithLifecycle(supervisor),
+		handlers.WithMetrics(appMetrics, cfg.MetricsAddress == ""),
+	)
 
-	// Return a new instance of the application with the initialized components.
-	return &App{
+	// Create a new instance of the application with the initialized components.
+	app := &App{
 		Logger:            appLogger,
 		Repository:        repo,
 		Handler:           h,
 		BackgroundDeleter: backgroundDeleter,
+		Jobs:              scheduler,
+		RateLimiter:       rateLimiter,
+		Policy:            destinationPolicy,
+		Server:            &http.Server{Addr: cfg.ServerAddress, Handler: h.Router},
+		Metrics:           appMetrics,
+		Lifecycle:         supervisor,
 		context:           ctx,
-	}, nil
+	}
+	if appMetrics != nil && cfg.MetricsAddress != "" {
+		app.MetricsServer = &http.Server{Addr: cfg.MetricsAddress, Handler: appMetrics.Handler()}
+	}
+
+	// Register the components with the lifecycle supervisor.
+	if err := app.registerComponents(cfg.EnableHTTPS, cfg.ShutdownDelay.Duration()); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Run starts the components of the application and serves HTTP requests until the context is cancelled
+// or a component fails. It then stops the components in reverse order and returns their failures.
+func (a *App) Run(ctx context.Context) error {
+	return a.Lifecycle.Run(ctx)
+}
+
+// registerComponents registers the metrics server, the repository, the rate limit store, the background workers
+// using them and the HTTP server. They stop in reverse order: the server keeps serving for the shutdown delay while
+// the readiness probe fails, then finishes the requests in flight, the queued deletions are drained, the rate limit
+// store and the repository are closed, and the metrics are served until the end.
+func (a *App) registerComponents(enableHTTPS bool, shutdownDelay time.Duration) error {
+	var components []lifecycle.Component
+	if a.MetricsServer != nil {
+		components = append(components, lifecycle.Component{
+			Name:  componentMetrics,
+			Start: func(context.Context) error { return a.startMetricsServer() },
+			Stop:  a.MetricsServer.Shutdown,
+		})
+	}
+	components = append(components, []lifecycle.Component{
+		{
+			Name: componentRepository,
+			Stop: func(context.Context) error { return a.Repository.Close() },
+		},
+		lifecycle.Background(componentPolicy, nil, a.Policy.Run),
+		{
+			Name: componentRateLimit,
+			Stop: func(context.Context) error { return a.RateLimiter.Close() },
+		},
+		lifecycle.Background(componentJobs, []string{componentRepository, componentRateLimit}, a.Jobs.Run),
+		{
+			Name:      componentHTTP,
+			DependsOn: []string{componentRepository, componentPolicy, componentRateLimit, componentJobs},
+			Start:     func(context.Context) error { return a.startServer(enableHTTPS) },
+			Stop: func(ctx context.Context) error {
+				select {
+				case <-time.After(shutdownDelay):
+				case <-ctx.Done():
+				}
+				return a.Server.Shutdown(ctx)
+			},
+			StopTimeout: a.Lifecycle.StopTimeout() + shutdownDelay,
+		},
+	}...)
+	for _, c := range components {
+		if err := a.Lifecycle.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startServer listens on the server address and serves HTTP requests in a separate goroutine.
+// Failures to listen are returned; failures while serving stop the application.
+func (a *App) startServer(enableHTTPS bool) error {
+	addr := a.Server.Addr
+	if addr == "" {
+		addr = ":http"
+		if enableHTTPS {
+			addr = ":https"
+		}
+	}
+	if enableHTTPS {
+		cert, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+		if err != nil {
+			return fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		a.Server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("server listening", slog.String("address", listener.Addr().String()), slog.Bool("https", enableHTTPS))
+
+	go func() {
+		var err error
+		if enableHTTPS {
+			err = a.Server.ServeTLS(listener, "", "")
+		} else {
+			err = a.Server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.Lifecycle.Fail(componentHTTP, err)
+		}
+	}()
+	return nil
+}
+
+// startMetricsServer listens on the metrics address and serves the metrics in a separate goroutine.
+// Failures to listen are returned; failures while serving stop the application.
+func (a *App) startMetricsServer() error {
+	listener, err := net.Listen("tcp", a.MetricsServer.Addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("metrics server listening", slog.String("address", listener.Addr().String()))
+
+	go func() {
+		if err := a.MetricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.Lifecycle.Fail(componentMetrics, err)
+		}
+	}()
+	return nil
+}
+
+// newOIDCProvider creates the OpenID Connect provider, or returns nil if no issuer is configured.
+func newOIDCProvider(cfg config.Config) *oidc.Provider {
+	if cfg.OIDCIssuerURL == "" {
+		return nil
+	}
+	redirectURL := cfg.OIDCRedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimSuffix(cfg.BaseURL, "/") + "/api/auth/oidc/callback"
+	}
+	return oidc.NewProvider(oidc.Config{
+		IssuerURL:    cfg.OIDCIssuerURL,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       cfg.OIDCScopes,
+	}, nil)
+}
+
+// inactiveLinkStatus returns the configured status for inactive links, defaulting to 404 Not Found.
+func inactiveLinkStatus(status int) int {
+	switch status {
+	case http.StatusNotFound, http.StatusForbidden:
+		return status
+	case 0:
+		return http.StatusNotFound
+	default:
+		slog.Warn("unsupported inactive link status, defaulting to 404", slog.Int("status", status))
+		return http.StatusNotFound
+	}
+}
+
+// readPageFile reads an HTML page served for inactive or taken down links if its path is configured.
+func readPageFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+// newRateLimiter creates a rate limiter from the configured per-route-class limits and bucket store.
+func newRateLimiter(ctx context.Context, cfg config.Config) (*middlewares.RateLimiter, error) {
+	configured := map[middlewares.RouteClass]string{
+		middlewares.RouteClassRedirect: cfg.RateLimitRedirect,
+		middlewares.RouteClassShorten:  cfg.RateLimitShorten,
+		middlewares.RouteClassBatch:    cfg.RateLimitBatch,
+		middlewares.RouteClassDelete:   cfg.RateLimitDelete,
+		middlewares.RouteClassReport:   cfg.RateLimitReport,
+	}
+	limits := make(map[middlewares.RouteClass]middlewares.RateLimit, len(configured))
+	for class, value := range configured {
+		limit, err := middlewares.ParseRateLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s rate limit: %w", class, err)
+		}
+		limits[class] = limit
+	}
+
+	var store middlewares.RateLimitStore
+	switch cfg.RateLimitStore {
+	case "", "memory":
+		store = middlewares.NewMemoryRateLimitStore()
+	case "postgres":
+		if cfg.DatabaseDSN == "" {
+			return nil, errors.New("postgres rate limit store requires a database DSN")
+		}
+		pgStore, err := middlewares.NewPostgresRateLimitStore(ctx, cfg.DatabaseDSN)
+		if err != nil {
+			return nil, err
+		}
+		store = pgStore
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: %s", cfg.RateLimitStore)
+	}
+
+	return middlewares.NewRateLimiter(store, limits), nil
+}
+
+// newTokenManager creates the bearer token manager for the configured JWT algorithm.
+// It returns nil if bearer authentication is disabled.
+func newTokenManager(cfg config.Config) (*middlewares.TokenManager, error) {
+	switch cfg.JWTAlgorithm {
+	case "":
+		return nil, nil
+	case middlewares.AlgorithmHS256:
+		if len(cfg.JWTSigningKeys) == 0 && cfg.JWTSigningKeysFile == "" {
+			return nil, errors.New("HS256 bearer tokens require signing keys")
+		}
+		keys, err := middlewares.LoadKeyRing(cfg.JWTSigningKeys, cfg.JWTSigningKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading JWT signing keys: %w", err)
+		}
+		return middlewares.NewHS256TokenManager(keys, cfg.JWTTTL.Duration(), cfg.JWTIssuer), nil
+	case middlewares.AlgorithmRS256:
+		if cfg.JWTPrivateKeyFile == "" || cfg.JWTKeyID == "" {
+			return nil, errors.New("RS256 bearer tokens require a private key file and key ID")
+		}
+		privateKey, err := middlewares.LoadRSAPrivateKey(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		publicKeys := make(map[string]*rsa.PublicKey, len(cfg.JWTPublicKeyFiles))
+		for _, entry := range cfg.JWTPublicKeyFiles {
+			keyID, path, ok := strings.Cut(entry, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid JWT public key entry %q: expected <id>:<path>", entry)
+			}
+			if publicKeys[keyID], err = middlewares.LoadRSAPublicKey(path); err != nil {
+				return nil, err
+			}
+		}
+		return middlewares.NewRS256TokenManager(privateKey, cfg.JWTKeyID, publicKeys, cfg.JWTTTL.Duration(), cfg.JWTIssuer), nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", cfg.JWTAlgorithm)
+	}
 }
@@ -0,0 +1,199 @@
+// Package accounts provides registration and login of user accounts on top of anonymous cookie identities.
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password length limits. bcrypt ignores everything past 72 bytes.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// ErrInvalidCredentials is returned when the login or password does not match a registered account.
+var ErrInvalidCredentials = errors.New("invalid login or password")
+
+// ErrAccountExists is returned when registering an email or username that is already taken.
+var ErrAccountExists = errors.New("account already exists")
+
+// usernamePattern matches valid usernames.
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+// ValidationError describes why registration data was rejected.
+type ValidationError struct {
+	// Field is the name of the rejected field.
+	Field string
+	// Message is a human-readable description of the problem.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+// Service registers and authenticates user accounts.
+type Service struct {
+	// repo stores the accounts and the links merged into them.
+	repo repository.IRepository
+	// cost is the bcrypt cost of new password hashes.
+	cost int
+	// dummyHash is compared against when the login is unknown, so that response times do not reveal registered logins.
+	dummyHash []byte
+	// dummyHashOnce generates dummyHash on first use.
+	dummyHashOnce sync.Once
+}
+
+// NewService creates a new account Service hashing passwords with the given bcrypt cost.
+// A cost of zero selects bcrypt.DefaultCost.
+func NewService(repo repository.IRepository, cost int) *Service {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	return &Service{repo: repo, cost: cost}
+}
+
+// Register creates an account with an email and/or username and a password.
+// If the current user ID is anonymous, the account takes it over, keeping every link it owns.
+func (s *Service) Register(ctx context.Context, currentUserID string, email string, username string, password string) (repository.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	username = strings.TrimSpace(username)
+	if err := validateRegistration(email, username, password); err != nil {
+		return repository.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return repository.User{}, fmt.Errorf("hashing password: %w", err)
+	}
+
+	userID := uuid.NewString()
+	anonymous, err := s.isAnonymous(ctx, currentUserID)
+	if err != nil {
+		return repository.User{}, err
+	}
+	if anonymous {
+		userID = currentUserID
+	}
+
+	user := repository.User{
+		ID:           userID,
+		Email:        email,
+		Username:     username,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := s.repo.AddUser(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrUserDuplicate) {
+			return repository.User{}, ErrAccountExists
+		}
+		return repository.User{}, err
+	}
+	slog.Info("user account registered", slog.String("user", user.ID), slog.Bool("adopted anonymous identity", anonymous))
+	return user, nil
+}
+
+// RegisterExternal records the user ID of an identity authenticated by an external provider, such as
+// OpenID Connect, as an account without a password, so that it is never merged as anonymous.
+// Registering an existing account does nothing.
+func (s *Service) RegisterExternal(ctx context.Context, userID string) error {
+	err := s.repo.AddUser(ctx, repository.User{ID: userID, CreatedAt: time.Now().UTC()})
+	if err != nil && !errors.Is(err, repository.ErrUserDuplicate) {
+		return fmt.Errorf("registering external user: %w", err)
+	}
+	return nil
+}
+
+// Login authenticates an account by email or username and password.
+// If the current user ID is anonymous, the links it owns are merged into the account.
+func (s *Service) Login(ctx context.Context, currentUserID string, login string, password string) (repository.User, error) {
+	login = strings.TrimSpace(login)
+	if strings.Contains(login, "@") {
+		login = strings.ToLower(login)
+	}
+
+	user, err := s.repo.GetUserByLogin(ctx, login)
+	if errors.Is(err, repository.ErrUserNotExist) {
+		// Spend the same time as a real comparison.
+		s.dummyHashOnce.Do(func() {
+			s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), s.cost)
+		})
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return repository.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return repository.User{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return repository.User{}, ErrInvalidCredentials
+	}
+
+	if err := s.MergeAnonymous(ctx, currentUserID, user.ID); err != nil {
+		return repository.User{}, err
+	}
+	return user, nil
+}
+
+// MergeAnonymous transfers the links of the current user ID to the signed-in user ID
+// if the current user ID is anonymous, i.e. does not belong to a registered account.
+func (s *Service) MergeAnonymous(ctx context.Context, currentUserID string, userID string) error {
+	if currentUserID == userID {
+		return nil
+	}
+	anonymous, err := s.isAnonymous(ctx, currentUserID)
+	if err != nil {
+		return err
+	}
+	if !anonymous {
+		return nil
+	}
+	if err := s.repo.MergeUser(ctx, currentUserID, userID); err != nil {
+		return fmt.Errorf("merging anonymous user: %w", err)
+	}
+	slog.Info("anonymous user merged into account", slog.String("anonymous user", currentUserID), slog.String("user", userID))
+	return nil
+}
+
+// isAnonymous reports whether the user ID is set and does not belong to a registered account.
+func (s *Service) isAnonymous(ctx context.Context, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	_, err := s.repo.GetUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotExist) {
+		return true, nil
+	}
+	return false, err
+}
+
+// validateRegistration checks the registration data.
+func validateRegistration(email string, username string, password string) error {
+	if email == "" && username == "" {
+		return &ValidationError{Field: "email", Message: "an email or a username is required"}
+	}
+	if email != "" {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			return &ValidationError{Field: "email", Message: "not a valid email address"}
+		}
+	}
+	if username != "" && !usernamePattern.MatchString(username) {
+		return &ValidationError{Field: "username", Message: "must be 3 to 32 letters, digits, '_', '.' or '-'"}
+	}
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return &ValidationError{Field: "password", Message: fmt.Sprintf("must be %d to %d bytes long", MinPasswordLength, MaxPasswordLength)}
+	}
+	return nil
+}
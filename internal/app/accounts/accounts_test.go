@@ -0,0 +1,127 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestService_Register(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, bcrypt.MinCost)
+
+	testCases := []struct {
+		name          string
+		email         string
+		username      string
+		password      string
+		expectedField string
+	}{
+		{name: "No login", password: "long enough", expectedField: "email"},
+		{name: "Invalid email", email: "not-an-email", password: "long enough", expectedField: "email"},
+		{name: "Email with display name", email: "Alice <alice@example.com>", password: "long enough", expectedField: "email"},
+		{name: "Invalid username", username: "a b", password: "long enough", expectedField: "username"},
+		{name: "Short password", username: "alice", password: "short", expectedField: "password"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.Register(ctx, "anon", tc.email, tc.username, tc.password)
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+			assert.Equal(t, tc.expectedField, validationErr.Field)
+		})
+	}
+
+	t.Run("Anonymous identity is adopted", func(t *testing.T) {
+		user, err := service.Register(ctx, "anon1", " Alice@Example.com ", "alice", "correct horse")
+		require.NoError(t, err)
+		assert.Equal(t, "anon1", user.ID)
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.NotEqual(t, "correct horse", user.PasswordHash)
+	})
+
+	t.Run("Account identity is not adopted", func(t *testing.T) {
+		user, err := service.Register(ctx, "anon1", "", "bob", "correct horse")
+		require.NoError(t, err)
+		assert.NotEqual(t, "anon1", user.ID)
+	})
+
+	t.Run("Duplicate login", func(t *testing.T) {
+		_, err := service.Register(ctx, "anon2", "alice@example.com", "", "correct horse")
+		assert.ErrorIs(t, err, ErrAccountExists)
+		_, err = service.Register(ctx, "anon2", "", "bob", "correct horse")
+		assert.ErrorIs(t, err, ErrAccountExists)
+	})
+}
+
+func TestService_Login(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, bcrypt.MinCost)
+
+	account, err := service.Register(ctx, "", "carol@example.com", "carol", "correct horse")
+	require.NoError(t, err)
+	require.NoError(t, repo.Add(ctx, *repository.NewURL("anon", "https://example.com/anon", "anon-user", false)))
+	require.NoError(t, repo.Add(ctx, *repository.NewURL("other", "https://example.com/other", "other-user", false)))
+
+	_, err = service.Login(ctx, "anon-user", "carol", "wrong password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = service.Login(ctx, "anon-user", "nobody", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	user, err := service.Login(ctx, "anon-user", "CAROL@example.com", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, user.ID)
+
+	urls, err := repo.GetByUser(ctx, account.ID)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "anon", urls[0].Slug)
+	_, err = repo.GetByUser(ctx, "anon-user")
+	assert.ErrorIs(t, err, repository.ErrURLNotExsit)
+
+	// Logging in from another account's identity does not merge that account.
+	other, err := service.Register(ctx, "other-user", "", "dave", "correct horse")
+	require.NoError(t, err)
+	_, err = service.Login(ctx, other.ID, "carol", "correct horse")
+	require.NoError(t, err)
+	urls, err = repo.GetByUser(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+}
+
+func TestService_RegisterExternal(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, bcrypt.MinCost)
+	account, err := service.Register(ctx, "", "erin@example.com", "erin", "correct horse")
+	require.NoError(t, err)
+	require.NoError(t, repo.Add(ctx, *repository.NewURL("sso", "https://example.com/sso", "sso-user", false)))
+
+	require.NoError(t, service.RegisterExternal(ctx, "sso-user"))
+	require.NoError(t, service.RegisterExternal(ctx, "sso-user"), "registering twice does nothing")
+
+	// External identities are accounts, so logging in from one does not take over its links.
+	_, err = service.Login(ctx, "sso-user", "erin", "correct horse")
+	require.NoError(t, err)
+	urls, err := repo.GetByUser(ctx, "sso-user")
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+	_, err = repo.GetByUser(ctx, account.ID)
+	assert.ErrorIs(t, err, repository.ErrURLNotExsit)
+
+	// Nor does registering from one adopt it.
+	user, err := service.Register(ctx, "sso-user", "", "frank", "correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, "sso-user", user.ID)
+
+	// External identities cannot log in with a password.
+	_, err = service.Login(ctx, "", "", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
@@ -25,6 +25,131 @@ type Config struct {
 	LogLevel string `env:"LOG_LEVEL" json:"log_level"`
 	// EnableHTTPS is the HTTPS mode for the application.
 	EnableHTTPS bool `env:"ENABLE_HTTPS" json:"enable_https"`
+	// AllowedURLSchemes is the allowlist of destination URL schemes. Defaults to http and https.
+	AllowedURLSchemes []string `env:"ALLOWED_URL_SCHEMES" json:"allowed_url_schemes"`
+	// PolicyAllowListPath is the optional path to the destination allow list file.
+	PolicyAllowListPath string `env:"POLICY_ALLOW_LIST_PATH" json:"policy_allow_list_path"`
+	// PolicyDenyListPath is the optional path to the destination deny list file.
+	PolicyDenyListPath string `env:"POLICY_DENY_LIST_PATH" json:"policy_deny_list_path"`
+	// PolicyReloadInterval is the interval at which the policy list files are checked for changes.
+	PolicyReloadInterval Duration `env:"POLICY_RELOAD_INTERVAL" json:"policy_reload_interval"`
+	// CookieSigningKeys are the `<id>:<secret>` HMAC keys for authentication cookies, newest first.
+	// The newest key signs new cookies; the others only verify existing ones until removed.
+	CookieSigningKeys []string `env:"COOKIE_SIGNING_KEYS" json:"cookie_signing_keys"`
+	// CookieSigningKeysFile is the optional path to a secret file with one `<id>:<secret>` key per line, newest first.
+	// It takes precedence over CookieSigningKeys.
+	CookieSigningKeysFile string `env:"COOKIE_SIGNING_KEYS_FILE" json:"cookie_signing_keys_file"`
+	// CookieDomain is the optional session cookie domain.
+	CookieDomain string `env:"COOKIE_DOMAIN" json:"cookie_domain"`
+	// CookiePath is the session cookie path. Defaults to "/".
+	CookiePath string `env:"COOKIE_PATH" json:"cookie_path"`
+	// CookieSameSite is the session cookie SameSite mode: "lax" (default), "strict" or "none".
+	CookieSameSite string `env:"COOKIE_SAME_SITE" json:"cookie_same_site"`
+	// CookieSecure restricts the session cookie to HTTPS. Always set when EnableHTTPS is.
+	CookieSecure bool `env:"COOKIE_SECURE" json:"cookie_secure"`
+	// SessionTTL is the session lifetime. Sessions used within the second half of their lifetime are renewed.
+	SessionTTL Duration `env:"SESSION_TTL" json:"session_ttl"`
+	// JWTAlgorithm enables `Authorization: Bearer` authentication with "HS256" or "RS256" tokens. Empty disables it.
+	JWTAlgorithm string `env:"JWT_ALGORITHM" json:"jwt_algorithm"`
+	// JWTSigningKeys are the `<id>:<secret>` HS256 keys, newest first.
+	JWTSigningKeys []string `env:"JWT_SIGNING_KEYS" json:"jwt_signing_keys"`
+	// JWTSigningKeysFile is the optional path to a secret file with one `<id>:<secret>` HS256 key per line, newest first.
+	JWTSigningKeysFile string `env:"JWT_SIGNING_KEYS_FILE" json:"jwt_signing_keys_file"`
+	// JWTPrivateKeyFile is the path to the PEM-encoded RS256 signing key.
+	JWTPrivateKeyFile string `env:"JWT_PRIVATE_KEY_FILE" json:"jwt_private_key_file"`
+	// JWTKeyID is the key ID of the RS256 signing key.
+	JWTKeyID string `env:"JWT_KEY_ID" json:"jwt_key_id"`
+	// JWTPublicKeyFiles are additional `<id>:<path>` PEM-encoded RS256 verification keys.
+	JWTPublicKeyFiles []string `env:"JWT_PUBLIC_KEY_FILES" json:"jwt_public_key_files"`
+	// JWTTTL is the lifetime of issued bearer tokens.
+	JWTTTL Duration `env:"JWT_TTL" json:"jwt_ttl"`
+	// JWTIssuer is the optional `iss` claim of issued tokens, required on accepted tokens.
+	JWTIssuer string `env:"JWT_ISSUER" json:"jwt_issuer"`
+	// OIDCIssuerURL enables OpenID Connect single sign-on with the provider at this issuer URL. Empty disables it.
+	OIDCIssuerURL string `env:"OIDC_ISSUER_URL" json:"oidc_issuer_url"`
+	// OIDCClientID is the client ID registered with the OpenID provider.
+	OIDCClientID string `env:"OIDC_CLIENT_ID" json:"oidc_client_id"`
+	// OIDCClientSecret is the optional client secret registered with the OpenID provider.
+	OIDCClientSecret string `env:"OIDC_CLIENT_SECRET" json:"oidc_client_secret"`
+	// OIDCRedirectURL is the callback URL registered with the OpenID provider. Defaults to `<base url>/api/auth/oidc/callback`.
+	OIDCRedirectURL string `env:"OIDC_REDIRECT_URL" json:"oidc_redirect_url"`
+	// OIDCScopes are additional scopes requested from the OpenID provider.
+	OIDCScopes []string `env:"OIDC_SCOPES" json:"oidc_scopes"`
+	// OIDCPostLoginRedirect is the path users are redirected to after signing in. Defaults to "/".
+	OIDCPostLoginRedirect string `env:"OIDC_POST_LOGIN_REDIRECT" json:"oidc_post_login_redirect"`
+	// WorkspaceInvitationTTL is the lifetime of workspace invitations.
+	WorkspaceInvitationTTL Duration `env:"WORKSPACE_INVITATION_TTL" json:"workspace_invitation_ttl"`
+	// AdminUserIDs are the IDs of users with the admin role, allowed to use the `/api/admin` routes.
+	AdminUserIDs []string `env:"ADMIN_USER_IDS" json:"admin_user_ids"`
+	// AdminTrustedSubnets are the CIDR subnets whose clients are allowed to use the `/api/admin` routes.
+	AdminTrustedSubnets []string `env:"ADMIN_TRUSTED_SUBNETS" json:"admin_trusted_subnets"`
+	// RateLimitRedirect is the per-client rate limit for redirects, e.g. "100/1m". Empty disables it.
+	RateLimitRedirect string `env:"RATE_LIMIT_REDIRECT" json:"rate_limit_redirect"`
+	// RateLimitShorten is the per-client rate limit for single URL shortening, e.g. "20/1m". Empty disables it.
+	RateLimitShorten string `env:"RATE_LIMIT_SHORTEN" json:"rate_limit_shorten"`
+	// RateLimitBatch is the per-client rate limit for batch shortening, e.g. "5/1m". Empty disables it.
+	RateLimitBatch string `env:"RATE_LIMIT_BATCH" json:"rate_limit_batch"`
+	// RateLimitDelete is the per-client rate limit for URL deletion, e.g. "10/1m". Empty disables it.
+	RateLimitDelete string `env:"RATE_LIMIT_DELETE" json:"rate_limit_delete"`
+	// RateLimitReport is the per-client-IP rate limit for abuse reports, "10/1h" by default. Empty disables it.
+	RateLimitReport string `env:"RATE_LIMIT_REPORT" json:"rate_limit_report"`
+	// RateLimitStore is the rate limit bucket store: "memory" (default) or "postgres" to share limits across replicas.
+	RateLimitStore string `env:"RATE_LIMIT_STORE" json:"rate_limit_store"`
+	// RateLimitPruneInterval is the interval the idle rate limit buckets are removed at. Defaults to the longest limit period.
+	RateLimitPruneInterval Duration `env:"RATE_LIMIT_PRUNE_INTERVAL" json:"rate_limit_prune_interval"`
+	// InactiveLinkStatus is the HTTP status (404 or 403) returned for links outside their activation window.
+	InactiveLinkStatus int `env:"INACTIVE_LINK_STATUS" json:"inactive_link_status"`
+	// InactiveLinkPagePath is the optional path to an HTML page served for links outside their activation window.
+	InactiveLinkPagePath string `env:"INACTIVE_LINK_PAGE_PATH" json:"inactive_link_page_path"`
+	// AbuseReportThreshold is the number of abuse reports against the URLs of a user past which the user is flagged. Zero disables it.
+	AbuseReportThreshold int `env:"ABUSE_REPORT_THRESHOLD" json:"abuse_report_threshold"`
+	// AbuseTakedownThreshold is the number of taken down URLs of a user past which the user is flagged. Zero disables it.
+	AbuseTakedownThreshold int `env:"ABUSE_TAKEDOWN_THRESHOLD" json:"abuse_takedown_threshold"`
+	// TakedownPagePath is the optional path to an HTML page served with 451 for taken down links.
+	TakedownPagePath string `env:"TAKEDOWN_PAGE_PATH" json:"takedown_page_path"`
+	// WebhookMaxAttempts is the number of attempts after which a failing webhook delivery is dead-lettered.
+	WebhookMaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" json:"webhook_max_attempts"`
+	// WebhookInitialBackoff is the delay before the first retry of a webhook delivery. It doubles with every further retry.
+	WebhookInitialBackoff Duration `env:"WEBHOOK_INITIAL_BACKOFF" json:"webhook_initial_backoff"`
+	// WebhookMaxBackoff caps the delay between retries of a webhook delivery.
+	WebhookMaxBackoff Duration `env:"WEBHOOK_MAX_BACKOFF" json:"webhook_max_backoff"`
+	// WebhookPollInterval is the interval due webhook deliveries and expired links are checked at.
+	WebhookPollInterval Duration `env:"WEBHOOK_POLL_INTERVAL" json:"webhook_poll_interval"`
+	// WebhookClickFlushInterval is the interval the clicks counted for the click threshold events are stored at.
+	WebhookClickFlushInterval Duration `env:"WEBHOOK_CLICK_FLUSH_INTERVAL" json:"webhook_click_flush_interval"`
+	// WebhookConcurrency is the number of webhooks delivered to at once.
+	WebhookConcurrency int `env:"WEBHOOK_CONCURRENCY" json:"webhook_concurrency"`
+	// WebhookTimeout is the timeout of a single webhook delivery attempt.
+	WebhookTimeout Duration `env:"WEBHOOK_TIMEOUT" json:"webhook_timeout"`
+	// WebhookAllowPrivateNetworks allows webhook endpoints on loopback, private and link-local addresses. For development only.
+	WebhookAllowPrivateNetworks bool `env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" json:"webhook_allow_private_networks"`
+	// DeleteQueueSize is the number of URL delete requests that can wait to be flushed. Deletions beyond it are rejected with 503.
+	DeleteQueueSize int `env:"DELETE_QUEUE_SIZE" json:"delete_queue_size"`
+	// DeleteBatchSize is the number of waiting URL delete requests that triggers a flush before the interval elapses.
+	DeleteBatchSize int `env:"DELETE_BATCH_SIZE" json:"delete_batch_size"`
+	// DeleteFlushInterval is the interval waiting URL delete requests are flushed at.
+	DeleteFlushInterval Duration `env:"DELETE_FLUSH_INTERVAL" json:"delete_flush_interval"`
+	// DeleteMaxRetries is the number of retries of a failed batch of URL deletions before it is given up.
+	DeleteMaxRetries int `env:"DELETE_MAX_RETRIES" json:"delete_max_retries"`
+	// DeleteInitialBackoff is the delay before the first retry of a failed batch of URL deletions. It doubles with every further retry.
+	DeleteInitialBackoff Duration `env:"DELETE_INITIAL_BACKOFF" json:"delete_initial_backoff"`
+	// DeleteMaxBackoff caps the delay between retries of a failed batch of URL deletions.
+	DeleteMaxBackoff Duration `env:"DELETE_MAX_BACKOFF" json:"delete_max_backoff"`
+	// DeletionJobRetention is how long finished URL deletion jobs are kept for their requesters to look up.
+	DeletionJobRetention Duration `env:"DELETION_JOB_RETENTION" json:"deletion_job_retention"`
+	// DeletionJobPruneInterval is the interval finished URL deletion jobs past their retention are removed at.
+	DeletionJobPruneInterval Duration `env:"DELETION_JOB_PRUNE_INTERVAL" json:"deletion_job_prune_interval"`
+	// ShutdownTimeout is the time each component of the application is given to stop on shutdown.
+	ShutdownTimeout Duration `env:"SHUTDOWN_TIMEOUT" json:"shutdown_timeout"`
+	// ShutdownDelay is the time the server keeps serving on shutdown while the readiness probe fails,
+	// so load balancers stop routing requests to it before it stops accepting them.
+	ShutdownDelay Duration `env:"SHUTDOWN_DELAY" json:"shutdown_delay"`
+	// MetricsEnabled enables the Prometheus metrics at /metrics.
+	MetricsEnabled bool `env:"METRICS_ENABLED" json:"metrics_enabled"`
+	// MetricsAddress is the address of a separate listener serving the metrics. Empty serves them on the server address.
+	MetricsAddress string `env:"METRICS_ADDRESS" json:"metrics_address"`
+	// APIDocsEnabled enables the documentation page of the HTTP API at /api/docs.
+	APIDocsEnabled bool `env:"API_DOCS_ENABLED" json:"api_docs_enabled"`
 	// ConfigFilePath is the `config.json` filepath for the application.
 	ConfigFilePath string `env:"CONFIG" envDefault:"./internal/app/config/config.json"`
 }
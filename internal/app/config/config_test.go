@@ -6,6 +6,9 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/caarlos0/env/v11"
 )
 
 func TestConfigFromJSON(t *testing.T) {
@@ -153,3 +156,29 @@ func TestReadConfigFile(t *testing.T) {
 		t.Errorf("readConfigFile returned unexpected configuration.\nExpected: %v\nGot: %v", testConfig, config)
 	}
 }
+
+func TestDurationUnmarshal(t *testing.T) {
+	var cfg struct {
+		Interval Duration `json:"interval"`
+	}
+	if err := json.Unmarshal([]byte(`{"interval": "1m30s"}`), &cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Interval.Duration() != 90*time.Second {
+		t.Errorf("Expected Interval to be '%v', got '%v'", 90*time.Second, cfg.Interval.Duration())
+	}
+
+	if err := json.Unmarshal([]byte(`{"interval": 90}`), &cfg); err == nil {
+		t.Errorf("Expected an error for a numeric duration")
+	}
+
+	os.Setenv("POLICY_RELOAD_INTERVAL", "2s")
+	defer os.Unsetenv("POLICY_RELOAD_INTERVAL")
+	var envCfg Config
+	if err := env.Parse(&envCfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if envCfg.PolicyReloadInterval.Duration() != 2*time.Second {
+		t.Errorf("Expected PolicyReloadInterval to be '%v', got '%v'", 2*time.Second, envCfg.PolicyReloadInterval.Duration())
+	}
+}
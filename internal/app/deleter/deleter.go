@@ -3,83 +3,398 @@ package deleter
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/gennadis/shorturl/internal/app/jobs"
 	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/google/uuid"
 )
 
-// deleteChanBufferSize is the buffer size for the delete channel.
-const deleteChanBufferSize = 100
+// Default deleter settings, used for zero Config fields.
+const (
+	defaultQueueSize      = 1000
+	defaultBatchSize      = 100
+	defaultFlushInterval  = time.Second * 5
+	defaultMaxRetries     = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultJobRetention   = time.Hour * 24 * 7
+	defaultPruneInterval  = time.Hour
+)
+
+// JobName is the name of the scheduler job flushing the queued delete requests.
+const JobName = "url-deletions"
+
+// PruneJobName is the name of the scheduler job removing the finished deletion jobs past their retention.
+const PruneJobName = "deletion-job-prune"
 
-// deleteTickerInterval is the interval for the ticker that triggers deletions.
-const deleteTickerInterval = time.Second * 5
+// ErrQueueFull is returned when the queue has no room for the delete requests. Callers should retry later.
+var ErrQueueFull = errors.New("deletion queue is full")
+
+// ErrTooManyRequests is returned when more delete requests are enqueued at once than the queue can ever hold.
+var ErrTooManyRequests = errors.New("too many delete requests")
+
+// Config configures the queue and the flushes of a BackgroundDeleter. Zero fields take default values.
+type Config struct {
+	// QueueSize is the number of delete requests that can wait to be flushed.
+	QueueSize int
+	// BatchSize is the number of waiting delete requests that triggers a flush before the interval elapses.
+	BatchSize int
+	// FlushInterval is the interval waiting delete requests are flushed at.
+	FlushInterval time.Duration
+	// MaxRetries is the number of retries of a failed batch before its requests are given up.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry of a failed batch. It doubles with every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries of a failed batch.
+	MaxBackoff time.Duration
+	// JobRetention is how long finished deletion jobs are kept for their requesters to look up.
+	JobRetention time.Duration
+	// PruneInterval is the interval finished deletion jobs past their retention are removed at.
+	PruneInterval time.Duration
+}
+
+// withDefaults returns the configuration with default values for its zero fields.
+func (c Config) withDefaults() Config {
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultQueueSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultFlushInterval
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = defaultMaxRetries
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.JobRetention <= 0 {
+		c.JobRetention = defaultJobRetention
+	}
+	if c.PruneInterval <= 0 {
+		c.PruneInterval = defaultPruneInterval
+	}
+	return c
+}
+
+// DeleteNotifier is notified about the deletions applied by the BackgroundDeleter.
+type DeleteNotifier interface {
+	NotifyDeleted(ctx context.Context, delReqs []repository.DeleteRequest) error
+}
+
+// BatchObserver is notified about the batches of delete requests flushed by the BackgroundDeleter.
+type BatchObserver interface {
+	ObserveDeleteBatch(size int)
+}
 
 // BackgroundDeleter handles background deletion tasks.
 type BackgroundDeleter struct {
 	// repo is the repository interface for performing deletions.
 	repo repository.IRepository
-	// DeleteChan is the channel for receiving delete requests.
-	DeleteChan chan repository.DeleteRequest
-	// ErrorChan is the channel for receiving errors during deletion.
-	ErrorChan chan error
+	// cfg configures the queue and the flushes.
+	cfg Config
+	// queue is the channel of delete requests waiting to be flushed.
+	queue chan repository.DeleteRequest
+	// enqueueMu makes enqueueing the requests of a caller all or nothing.
+	enqueueMu sync.Mutex
+	// scheduler runs the flushes once registered.
+	scheduler *jobs.Scheduler
+	// batch is the batch being flushed, held between the runs of the job while it is retried.
+	// Like retries, it is only accessed by the job, whose runs never overlap.
+	batch []repository.DeleteRequest
+	// replay is the pending delete requests left by a previous run, flushed batch by batch before the queue.
+	replay []repository.DeleteRequest
+	// retries is the number of retries of the held batch.
+	retries int
+	// Notifier is the optional notifier of the applied deletions.
+	Notifier DeleteNotifier
+	// Observer is the optional observer of the flushed batches.
+	Observer BatchObserver
 }
 
 // NewBackgroundDeleter creates and returns a new BackgroundDeleter.
-// It initializes the delete and error channels with a buffer size.
-func NewBackgroundDeleter(repo repository.IRepository) *BackgroundDeleter {
-	bd := &BackgroundDeleter{
-		repo:       repo,
-		DeleteChan: make(chan repository.DeleteRequest, deleteChanBufferSize),
-		ErrorChan:  make(chan error, deleteChanBufferSize),
-	}
-	return bd
-}
-
-// Run starts the background deletion process. It listens for delete requests and handles them at regular intervals.
-// It returns a WaitGroup that can be used to wait for the background process to finish.
-func (m *BackgroundDeleter) Run(ctx context.Context) *sync.WaitGroup {
-	ticker := time.NewTicker(deleteTickerInterval)
-	deleteRequests := []repository.DeleteRequest{}
-	wg := &sync.WaitGroup{}
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer ticker.Stop()
-
-		for {
-			select {
-			// Listen for delete requests.
-			case task := <-m.DeleteChan:
-				deleteRequests = append(deleteRequests, task)
-			// Perform deletion at regular intervals.
-			case <-ticker.C:
-				m.handleDeletions(ctx, &deleteRequests)
-			// Handle context cancellation.
-			case <-ctx.Done():
-				m.handleDeletions(context.Background(), &deleteRequests)
-				return
-			// Log and handle errors.
-			case err := <-m.ErrorChan:
-				slog.Error("url deletion requests handling", slog.Any("error", err))
+func NewBackgroundDeleter(repo repository.IRepository, cfg Config) *BackgroundDeleter {
+	cfg = cfg.withDefaults()
+	return &BackgroundDeleter{
+		repo:  repo,
+		cfg:   cfg,
+		queue: make(chan repository.DeleteRequest, cfg.QueueSize),
+	}
+}
+
+// Enqueue queues the delete requests without blocking: either all of them are queued or none.
+// It returns ErrQueueFull when the queue has no room for them.
+func (m *BackgroundDeleter) Enqueue(delReqs []repository.DeleteRequest) error {
+	return m.enqueue(delReqs, nil)
+}
+
+// EnqueueJob stores a deletion job and queues its delete requests as a single step.
+// The job is only stored once the queue has room for all of its requests, so a rejected job is never left
+// pending to be replayed by the next run, and the requests are only queued once the job is stored.
+func (m *BackgroundDeleter) EnqueueJob(ctx context.Context, job repository.DeletionJob, delReqs []repository.DeleteRequest) error {
+	return m.enqueue(delReqs, func() error {
+		if err := m.repo.AddDeletionJob(ctx, job); err != nil {
+			return fmt.Errorf("adding deletion job: %w", err)
+		}
+		return nil
+	})
+}
+
+// enqueue queues the delete requests once the queue has room for all of them and the optional store succeeded.
+func (m *BackgroundDeleter) enqueue(delReqs []repository.DeleteRequest, store func() error) error {
+	if len(delReqs) > cap(m.queue) {
+		return ErrTooManyRequests
+	}
+
+	m.enqueueMu.Lock()
+	defer m.enqueueMu.Unlock()
+
+	// Only the flushes receive concurrently, so the room can only grow until the requests are sent.
+	if cap(m.queue)-len(m.queue) < len(delReqs) {
+		return ErrQueueFull
+	}
+	if store != nil {
+		if err := store(); err != nil {
+			return err
+		}
+	}
+	for _, dr := range delReqs {
+		m.queue <- dr
+	}
+	if m.scheduler != nil && len(m.queue) >= m.cfg.BatchSize {
+		if err := m.scheduler.Trigger(JobName); err != nil {
+			slog.Error("triggering url deletion flush", slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// Queued returns the number of delete requests waiting in the queue.
+func (m *BackgroundDeleter) Queued() int {
+	return len(m.queue)
+}
+
+// QueueSize returns the number of delete requests the queue can hold.
+func (m *BackgroundDeleter) QueueSize() int {
+	return cap(m.queue)
+}
+
+// RetryAfter returns how long callers rejected with ErrQueueFull should wait before retrying.
+func (m *BackgroundDeleter) RetryAfter() time.Duration {
+	return m.cfg.FlushInterval
+}
+
+// Register loads the pending slugs of deletion jobs left by a previous run and registers the flushes
+// of the queued delete requests as a periodic job of the scheduler. The job flushes at regular intervals
+// and early when a batch is full; a failed batch is retried with backoff, and no new requests are taken
+// until it succeeds or is given up, so the queue fills up and callers are pushed back.
+// The pending slugs are replayed first. They survive restarts with the file and PostgreSQL repositories,
+// which store the jobs before the deletions are accepted.
+// The finished deletion jobs are removed by a second periodic job once past their retention.
+func (m *BackgroundDeleter) Register(ctx context.Context, s *jobs.Scheduler) error {
+	pending, err := m.repo.GetPendingDeletions(ctx)
+	if err != nil {
+		slog.Error("getting pending url deletions", slog.Any("error", err))
+	}
+	if len(pending) > 0 {
+		slog.Info("replaying pending url deletions", slog.Int("requests", len(pending)))
+	}
+	m.replay = pending
+
+	err = s.Every(JobName, m.flush, jobs.Options{
+		Interval:       m.cfg.FlushInterval,
+		RunOnStart:     len(pending) > 0,
+		InitialBackoff: m.cfg.InitialBackoff,
+		MaxBackoff:     m.cfg.MaxBackoff,
+		OnShutdown:     m.drain,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.Every(PruneJobName, m.pruneJobs, jobs.Options{Interval: m.cfg.PruneInterval}); err != nil {
+		return err
+	}
+	m.scheduler = s
+	return nil
+}
+
+// pruneJobs removes the finished deletion jobs past their retention.
+func (m *BackgroundDeleter) pruneJobs(ctx context.Context) error {
+	pruned, err := m.repo.PruneDeletionJobs(ctx, time.Now().Add(-m.cfg.JobRetention))
+	if err != nil {
+		return fmt.Errorf("pruning deletion jobs: %w", err)
+	}
+	if pruned > 0 {
+		slog.Debug("deletion jobs pruned", slog.Int("jobs", pruned))
+	}
+	return nil
+}
+
+// flush deletes the held batch, then the replayed and the queued delete requests batch by batch.
+// A failed batch is held for the next run, and given up after the last retry.
+func (m *BackgroundDeleter) flush(ctx context.Context) error {
+	for ctx.Err() == nil {
+		if len(m.batch) == 0 {
+			m.batch = m.takeReplay(m.cfg.BatchSize)
+		}
+		if len(m.batch) == 0 {
+			m.batch = m.take(m.cfg.BatchSize)
+			if len(m.batch) == 0 {
+				return nil
 			}
 		}
-	}()
 
-	return wg
+		err := m.handleDeletions(ctx, m.batch)
+		switch {
+		case err == nil:
+			m.batch, m.retries = nil, 0
+		case m.retries >= m.cfg.MaxRetries:
+			slog.Error("url deletion requests given up", slog.Int("requests", len(m.batch)), slog.Int("retries", m.retries), slog.Any("error", err))
+			m.trackDeletions(ctx, m.batch, nil, repository.DeletionReasonInternal)
+			m.batch, m.retries = nil, 0
+			return err
+		default:
+			m.retries++
+			slog.Warn("url deletion requests handling, retrying", slog.Int("requests", len(m.batch)), slog.Int("retries", m.retries), slog.Any("error", err))
+			return err
+		}
+	}
+	return nil
+}
+
+// takeReplay removes up to n delete requests from the replayed ones.
+func (m *BackgroundDeleter) takeReplay(n int) []repository.DeleteRequest {
+	if len(m.replay) < n {
+		n = len(m.replay)
+	}
+	delReqs := m.replay[:n:n]
+	m.replay = m.replay[n:]
+	return delReqs
 }
 
-// handleDeletions processes the delete requests.
-// It deletes all requests in the deleteRequests slice and handles any errors that occur.
-func (m *BackgroundDeleter) handleDeletions(ctx context.Context, delReqs *[]repository.DeleteRequest) {
-	if len(*delReqs) > 0 {
-		err := m.repo.DeleteMany(ctx, *delReqs)
-		if err != nil {
-			m.ErrorChan <- err
+// take receives up to n delete requests waiting in the queue without blocking.
+func (m *BackgroundDeleter) take(n int) []repository.DeleteRequest {
+	delReqs := []repository.DeleteRequest{}
+	for len(delReqs) < n {
+		select {
+		case dr := <-m.queue:
+			delReqs = append(delReqs, dr)
+		default:
+			return delReqs
 		}
-		slog.Debug("delete requests handled successfully", slog.Any("delete requests", &delReqs))
-		*delReqs = nil
+	}
+	return delReqs
+}
+
+// drain makes a single attempt at the held batch and the replayed and queued delete requests left.
+// If it does not succeed, their deletion jobs stay pending to be replayed by the next run.
+func (m *BackgroundDeleter) drain(ctx context.Context) error {
+	delReqs := append(append(m.batch, m.takeReplay(len(m.replay))...), m.take(len(m.queue))...)
+	m.batch = nil
+	if len(delReqs) == 0 {
+		return nil
+	}
+	if err := m.handleDeletions(ctx, delReqs); err != nil {
+		return fmt.Errorf("url deletion requests handling on shutdown, %d left pending: %w", len(delReqs), err)
+	}
+	return nil
+}
+
+// handleDeletions deletes the requested URLs, then settles their deletion jobs, records and notifies the deletions.
+// It returns the error of the deletion, leaving the requests to be retried.
+func (m *BackgroundDeleter) handleDeletions(ctx context.Context, delReqs []repository.DeleteRequest) error {
+	if m.Observer != nil {
+		m.Observer.ObserveDeleteBatch(len(delReqs))
+	}
+	deleted, err := m.repo.DeleteMany(ctx, delReqs)
+	if err != nil {
+		return err
+	}
+	m.trackDeletions(ctx, delReqs, deleted, repository.DeletionReasonNotFound)
+	m.recordDeletions(ctx, deleted)
+	m.notifyDeletions(ctx, deleted)
+	slog.Debug("delete requests handled successfully", slog.Int("requests", len(delReqs)), slog.Int("deleted", len(deleted)))
+	return nil
+}
+
+// trackDeletions settles the slugs of deletion jobs: deleted requests are completed,
+// the others failed with the reason.
+func (m *BackgroundDeleter) trackDeletions(ctx context.Context, delReqs []repository.DeleteRequest, deleted []repository.DeleteRequest, reason string) {
+	applied := make(map[repository.DeleteRequest]bool, len(deleted))
+	for _, dr := range deleted {
+		applied[dr] = true
+	}
+
+	items := []repository.DeletionJobItem{}
+	now := time.Now().UTC()
+	for _, dr := range delReqs {
+		if dr.JobID == "" {
+			continue
+		}
+		item := repository.DeletionJobItem{JobID: dr.JobID, Slug: dr.Slug, Status: repository.DeletionStatusCompleted, UpdatedAt: now}
+		if !applied[dr] {
+			item.Status = repository.DeletionStatusFailed
+			item.Reason = reason
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return
+	}
+	if err := m.repo.UpdateDeletionJobItems(ctx, items); err != nil {
+		slog.Error("tracking url deletion jobs", slog.Any("error", err))
+	}
+}
+
+// recordDeletions appends the applied deletions to the audit log, one event per originating request.
+func (m *BackgroundDeleter) recordDeletions(ctx context.Context, delReqs []repository.DeleteRequest) {
+	type origin struct {
+		userID, workspaceID, sourceIP, requestID string
+	}
+	events := make(map[origin]*repository.AuditEvent)
+	order := []origin{}
+	now := time.Now().UTC()
+	for _, dr := range delReqs {
+		o := origin{userID: dr.UserID, workspaceID: dr.WorkspaceID, sourceIP: dr.SourceIP, requestID: dr.RequestID}
+		event, ok := events[o]
+		if !ok {
+			event = &repository.AuditEvent{
+				ID:          uuid.NewString(),
+				Time:        now,
+				Actor:       dr.UserID,
+				Action:      repository.AuditActionDelete,
+				WorkspaceID: dr.WorkspaceID,
+				SourceIP:    dr.SourceIP,
+				RequestID:   dr.RequestID,
+			}
+			events[o] = event
+			order = append(order, o)
+		}
+		event.Slugs = append(event.Slugs, dr.Slug)
+	}
+	for _, o := range order {
+		if err := m.repo.AddAuditEvent(ctx, *events[o]); err != nil {
+			slog.Error("recording url deletion", slog.String("request id", o.requestID), slog.Any("error", err))
+		}
+	}
+}
+
+// notifyDeletions passes the applied deletions to the notifier, if any.
+func (m *BackgroundDeleter) notifyDeletions(ctx context.Context, delReqs []repository.DeleteRequest) {
+	if m.Notifier == nil {
+		return
+	}
+	if err := m.Notifier.NotifyDeleted(ctx, delReqs); err != nil {
+		slog.Error("notifying url deletions", slog.Any("error", err))
 	}
 }
@@ -2,15 +2,29 @@ package deleter
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gennadis/shorturl/internal/app/jobs"
 	"github.com/gennadis/shorturl/internal/app/repository"
 )
 
+// runDeleter registers the deleter with a new scheduler and runs it until the context is cancelled.
+func runDeleter(t *testing.T, ctx context.Context, backgroundDeleter *BackgroundDeleter) *sync.WaitGroup {
+	t.Helper()
+	scheduler := jobs.NewScheduler()
+	if err := backgroundDeleter.Register(ctx, scheduler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return scheduler.Run(ctx)
+}
+
 func TestBackgroundDeleter_Run(t *testing.T) {
 	memStorage := repository.NewMemoryRepository()
-	backgroundDeleter := NewBackgroundDeleter(memStorage)
+	backgroundDeleter := NewBackgroundDeleter(memStorage, Config{})
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -19,11 +33,11 @@ func TestBackgroundDeleter_Run(t *testing.T) {
 		UserID: "test",
 	}
 
-	go func() {
-		backgroundDeleter.DeleteChan <- deleteRequest
-	}()
+	if err := backgroundDeleter.Enqueue([]repository.DeleteRequest{deleteRequest}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 
-	wg := backgroundDeleter.Run(ctx)
+	wg := runDeleter(t, ctx, backgroundDeleter)
 	time.Sleep(time.Second / 2)
 	cancel()
 	wg.Wait()
@@ -31,7 +45,7 @@ func TestBackgroundDeleter_Run(t *testing.T) {
 
 func TestBackgroundDeleter_HandleDeletions(t *testing.T) {
 	memStorage := repository.NewMemoryRepository()
-	backgroundDeleter := NewBackgroundDeleter(memStorage)
+	backgroundDeleter := NewBackgroundDeleter(memStorage, Config{})
 	ctx := context.Background()
 
 	deleteRequests := []repository.DeleteRequest{
@@ -41,8 +55,404 @@ func TestBackgroundDeleter_HandleDeletions(t *testing.T) {
 			UserID: "test2"},
 	}
 
-	backgroundDeleter.handleDeletions(ctx, &deleteRequests)
-	if len(deleteRequests) != 0 {
-		t.Errorf("Expected delete requests slice to be empty, got %d", len(deleteRequests))
+	if err := backgroundDeleter.handleDeletions(ctx, deleteRequests); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// recordingNotifier records the deletions it is notified about.
+type recordingNotifier struct {
+	delReqs []repository.DeleteRequest
+}
+
+func (n *recordingNotifier) NotifyDeleted(ctx context.Context, delReqs []repository.DeleteRequest) error {
+	n.delReqs = append(n.delReqs, delReqs...)
+	return nil
+}
+
+func TestBackgroundDeleter_RecordsDeletions(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := NewBackgroundDeleter(memStorage, Config{})
+	notifier := &recordingNotifier{}
+	backgroundDeleter.Notifier = notifier
+	ctx := context.Background()
+
+	for _, u := range []repository.URL{
+		{Slug: "a", OriginalURL: "https://example.com/a", UserID: "user"},
+		{Slug: "b", OriginalURL: "https://example.com/b", UserID: "user"},
+		{Slug: "c", OriginalURL: "https://example.com/c", UserID: "other"},
+	} {
+		if err := memStorage.Add(ctx, u); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Requests matching no URL are neither recorded nor notified.
+	deleteRequests := []repository.DeleteRequest{
+		{Slug: "a", UserID: "user", SourceIP: "192.0.2.1", RequestID: "req-1"},
+		{Slug: "b", UserID: "user", SourceIP: "192.0.2.1", RequestID: "req-1"},
+		{Slug: "c", UserID: "other", RequestID: "req-2"},
+		{Slug: "c", UserID: "user", RequestID: "req-3"},
+		{Slug: "missing", UserID: "user", RequestID: "req-4"},
+	}
+	if err := backgroundDeleter.handleDeletions(ctx, deleteRequests); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(notifier.delReqs) != 3 {
+		t.Errorf("Expected 3 notified deletions, got %+v", notifier.delReqs)
+	}
+
+	events, err := memStorage.GetAuditEvents(ctx, repository.AuditFilter{Action: repository.AuditActionDelete})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events, got %d", len(events))
+	}
+	if events[0].Actor != "user" || events[0].RequestID != "req-1" || events[0].SourceIP != "192.0.2.1" || len(events[0].Slugs) != 2 {
+		t.Errorf("Unexpected audit event: %+v", events[0])
+	}
+	if events[1].Actor != "other" || len(events[1].Slugs) != 1 {
+		t.Errorf("Unexpected audit event: %+v", events[1])
+	}
+}
+
+func TestBackgroundDeleter_TracksDeletionJobs(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := NewBackgroundDeleter(memStorage, Config{})
+	ctx := context.Background()
+
+	if err := memStorage.Add(ctx, repository.URL{Slug: "a", OriginalURL: "https://example.com/a", UserID: "user"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := memStorage.Add(ctx, repository.URL{Slug: "b", OriginalURL: "https://example.com/b", UserID: "other"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	job := repository.DeletionJob{
+		ID:     "job",
+		UserID: "user",
+		Items: []repository.DeletionJobItem{
+			{JobID: "job", Slug: "a", Status: repository.DeletionStatusPending},
+			{JobID: "job", Slug: "b", Status: repository.DeletionStatusPending},
+		},
+	}
+	if err := memStorage.AddDeletionJob(ctx, job); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deleteRequests := []repository.DeleteRequest{
+		{Slug: "a", UserID: "user", JobID: "job"},
+		{Slug: "b", UserID: "user", JobID: "job"},
+	}
+	if err := backgroundDeleter.handleDeletions(ctx, deleteRequests); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := memStorage.GetDeletionJob(ctx, "job")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Status() != repository.DeletionStatusCompleted {
+		t.Errorf("Expected completed job, got %q", got.Status())
+	}
+	if got.Items[0].Status != repository.DeletionStatusCompleted {
+		t.Errorf("Expected owned slug to be completed, got: %+v", got.Items[0])
+	}
+	if got.Items[1].Status != repository.DeletionStatusFailed || got.Items[1].Reason != repository.DeletionReasonNotFound {
+		t.Errorf("Expected slug of another user to fail as not found, got: %+v", got.Items[1])
+	}
+}
+
+// flakyRepository fails the first deletions, then deletes through the wrapped repository.
+type flakyRepository struct {
+	repository.IRepository
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (r *flakyRepository) DeleteMany(ctx context.Context, delReqs []repository.DeleteRequest) ([]repository.DeleteRequest, error) {
+	r.mu.Lock()
+	r.attempts++
+	if r.failures > 0 {
+		r.failures--
+		r.mu.Unlock()
+		return nil, errors.New("repository unavailable")
+	}
+	r.mu.Unlock()
+	return r.IRepository.DeleteMany(ctx, delReqs)
+}
+
+func (r *flakyRepository) deleteAttempts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts
+}
+
+func TestBackgroundDeleter_Enqueue(t *testing.T) {
+	backgroundDeleter := NewBackgroundDeleter(repository.NewMemoryRepository(), Config{QueueSize: 2})
+	delReqs := []repository.DeleteRequest{{Slug: "a", UserID: "user"}, {Slug: "b", UserID: "user"}, {Slug: "c", UserID: "user"}}
+
+	if err := backgroundDeleter.Enqueue(delReqs[:2]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := backgroundDeleter.Enqueue(delReqs[2:]); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	if err := backgroundDeleter.Enqueue(delReqs); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Expected ErrTooManyRequests, got %v", err)
+	}
+	if queued := backgroundDeleter.Queued(); queued != 2 {
+		t.Errorf("Expected 2 queued requests, got %d", queued)
+	}
+}
+
+// failingJobRepository fails to store deletion jobs.
+type failingJobRepository struct {
+	repository.IRepository
+}
+
+func (r failingJobRepository) AddDeletionJob(ctx context.Context, job repository.DeletionJob) error {
+	return errors.New("repository unavailable")
+}
+
+func TestBackgroundDeleter_EnqueueJob(t *testing.T) {
+	ctx := context.Background()
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := NewBackgroundDeleter(memStorage, Config{QueueSize: 1})
+	enqueueJob := func(d *BackgroundDeleter, id string, slug string) error {
+		job := repository.DeletionJob{ID: id, UserID: "user", Items: []repository.DeletionJobItem{{JobID: id, Slug: slug, Status: repository.DeletionStatusPending}}}
+		return d.EnqueueJob(ctx, job, []repository.DeleteRequest{{Slug: slug, UserID: "user", JobID: id}})
+	}
+
+	if err := enqueueJob(backgroundDeleter, "j1", "a"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// A job rejected by the full queue is not stored, so it is not replayed by the next run.
+	if err := enqueueJob(backgroundDeleter, "j2", "b"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	if _, err := memStorage.GetDeletionJob(ctx, "j2"); !errors.Is(err, repository.ErrDeletionJobNotExist) {
+		t.Errorf("Expected rejected job j2 not to be stored, got: %v", err)
+	}
+	if pending, _ := memStorage.GetPendingDeletions(ctx); len(pending) != 1 || pending[0].JobID != "j1" {
+		t.Errorf("Expected only the pending deletion of j1, got: %+v", pending)
+	}
+
+	// A job that cannot be stored is not queued.
+	unstored := NewBackgroundDeleter(failingJobRepository{memStorage}, Config{QueueSize: 1})
+	if err := enqueueJob(unstored, "j3", "c"); err == nil {
+		t.Error("Expected an error storing the job")
+	}
+	if queued := unstored.Queued(); queued != 0 {
+		t.Errorf("Expected no queued requests, got %d", queued)
+	}
+}
+
+func TestBackgroundDeleter_PrunesFinishedJobs(t *testing.T) {
+	ctx := context.Background()
+	memStorage := repository.NewMemoryRepository()
+	old := time.Now().Add(-2 * time.Hour)
+	for _, job := range []repository.DeletionJob{
+		{ID: "old", UserID: "user", CreatedAt: old, Items: []repository.DeletionJobItem{{JobID: "old", Slug: "a", Status: repository.DeletionStatusCompleted, UpdatedAt: old}}},
+		{ID: "recent", UserID: "user", CreatedAt: time.Now(), Items: []repository.DeletionJobItem{{JobID: "recent", Slug: "b", Status: repository.DeletionStatusCompleted, UpdatedAt: time.Now()}}},
+	} {
+		if err := memStorage.AddDeletionJob(ctx, job); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	backgroundDeleter := NewBackgroundDeleter(memStorage, Config{JobRetention: time.Hour})
+	if err := backgroundDeleter.pruneJobs(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := memStorage.GetDeletionJob(ctx, "old"); !errors.Is(err, repository.ErrDeletionJobNotExist) {
+		t.Errorf("Expected the old job to be pruned, got: %v", err)
+	}
+	if _, err := memStorage.GetDeletionJob(ctx, "recent"); err != nil {
+		t.Errorf("Expected the recent job to be kept, got: %v", err)
+	}
+}
+
+func TestBackgroundDeleter_FlushesFullBatches(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, slug := range []string{"a", "b"} {
+		if err := memStorage.Add(ctx, repository.URL{Slug: slug, OriginalURL: "https://example.com/" + slug, UserID: "user"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	backgroundDeleter := NewBackgroundDeleter(memStorage, Config{BatchSize: 2, FlushInterval: time.Hour})
+	wg := runDeleter(t, ctx, backgroundDeleter)
+
+	if err := backgroundDeleter.Enqueue([]repository.DeleteRequest{{Slug: "a", UserID: "user"}, {Slug: "b", UserID: "user"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		u, _ := memStorage.GetBySlug(ctx, "b")
+		if u.IsDeleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the full batch to be flushed before the interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestBackgroundDeleter_RetriesFailedBatches(t *testing.T) {
+	testCases := []struct {
+		name           string
+		failures       int
+		expectedStatus string
+		expectedReason string
+	}{
+		{name: "Retry succeeds", failures: 2, expectedStatus: repository.DeletionStatusCompleted},
+		{name: "Retries exhausted", failures: 10, expectedStatus: repository.DeletionStatusFailed, expectedReason: repository.DeletionReasonInternal},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			memStorage := repository.NewMemoryRepository()
+			repo := &flakyRepository{IRepository: memStorage, failures: tc.failures}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if err := memStorage.Add(ctx, repository.URL{Slug: "a", OriginalURL: "https://example.com/a", UserID: "user"}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			backgroundDeleter := NewBackgroundDeleter(repo, Config{BatchSize: 1, MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+			wg := runDeleter(t, ctx, backgroundDeleter)
+
+			job := repository.DeletionJob{ID: "job", UserID: "user", Items: []repository.DeletionJobItem{{JobID: "job", Slug: "a", Status: repository.DeletionStatusPending}}}
+			if err := memStorage.AddDeletionJob(ctx, job); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := backgroundDeleter.Enqueue([]repository.DeleteRequest{{Slug: "a", UserID: "user", JobID: "job"}}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var got repository.DeletionJob
+			deadline := time.Now().Add(time.Second)
+			for got.Status() != repository.DeletionStatusCompleted || len(got.Items) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("Expected the job to be settled, got: %+v", got)
+				}
+				time.Sleep(5 * time.Millisecond)
+				got, _ = memStorage.GetDeletionJob(ctx, "job")
+			}
+			cancel()
+			wg.Wait()
+
+			if got.Items[0].Status != tc.expectedStatus || got.Items[0].Reason != tc.expectedReason {
+				t.Errorf("Expected %s (%q), got: %+v", tc.expectedStatus, tc.expectedReason, got.Items[0])
+			}
+			if attempts := repo.deleteAttempts(); attempts != min(tc.failures, 3)+1 {
+				t.Errorf("Expected %d deletion attempts, got %d", min(tc.failures, 3)+1, attempts)
+			}
+		})
+	}
+}
+
+func TestBackgroundDeleter_ReplaysPendingDeletions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileStorage, err := repository.NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := fileStorage.Add(ctx, repository.URL{Slug: "a", OriginalURL: "https://example.com/a", UserID: "user"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	job := repository.DeletionJob{ID: "job", UserID: "user", RequestID: "req-1", Items: []repository.DeletionJobItem{{JobID: "job", Slug: "a", Status: repository.DeletionStatusPending}}}
+	if err := fileStorage.AddDeletionJob(ctx, job); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The deletion was accepted but the process stopped before flushing it.
+	restarted, err := repository.NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	wg := runDeleter(t, runCtx, NewBackgroundDeleter(restarted, Config{FlushInterval: time.Hour}))
+	cancel()
+	wg.Wait()
+
+	if u, _ := restarted.GetBySlug(ctx, "a"); !u.IsDeleted {
+		t.Errorf("Expected the pending deletion to be replayed")
+	}
+	if got, _ := restarted.GetDeletionJob(ctx, "job"); got.Status() != repository.DeletionStatusCompleted || got.Items[0].Status != repository.DeletionStatusCompleted {
+		t.Errorf("Expected the replayed deletion to be acknowledged, got: %+v", got)
+	}
+	events, _ := restarted.GetAuditEvents(ctx, repository.AuditFilter{Action: repository.AuditActionDelete})
+	if len(events) != 1 || events[0].RequestID != "req-1" {
+		t.Errorf("Expected the replayed deletion to be audited with its request ID, got: %+v", events)
+	}
+}
+
+func TestBackgroundDeleter_ReplaysPendingDeletionsInBatches(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	repo := &flakyRepository{IRepository: memStorage}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slugs := []string{"a", "b", "c", "d", "e"}
+	job := repository.DeletionJob{ID: "job", UserID: "user"}
+	for _, slug := range slugs {
+		if err := memStorage.Add(ctx, repository.URL{Slug: slug, OriginalURL: "https://example.com/" + slug, UserID: "user"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		job.Items = append(job.Items, repository.DeletionJobItem{JobID: "job", Slug: slug, Status: repository.DeletionStatusPending})
+	}
+	if err := memStorage.AddDeletionJob(ctx, job); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	wg := runDeleter(t, ctx, NewBackgroundDeleter(repo, Config{BatchSize: 2, FlushInterval: time.Hour}))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if got, _ := memStorage.GetDeletionJob(ctx, "job"); got.Status() == repository.DeletionStatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the pending deletions to be replayed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	if attempts := repo.deleteAttempts(); attempts != 3 {
+		t.Errorf("Expected the replay to be flushed in 3 batches, got %d", attempts)
+	}
+}
+
+func TestBackgroundDeleter_LeavesFailedDrainPending(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	repo := &flakyRepository{IRepository: memStorage, failures: 1}
+	ctx := context.Background()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	backgroundDeleter := NewBackgroundDeleter(repo, Config{FlushInterval: time.Hour})
+	wg := runDeleter(t, runCtx, backgroundDeleter)
+
+	job := repository.DeletionJob{ID: "job", UserID: "user", Items: []repository.DeletionJobItem{{JobID: "job", Slug: "a", Status: repository.DeletionStatusPending}}}
+	if err := memStorage.AddDeletionJob(ctx, job); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := backgroundDeleter.Enqueue([]repository.DeleteRequest{{Slug: "a", UserID: "user", JobID: "job"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cancel()
+	wg.Wait()
+
+	if pending, _ := memStorage.GetPendingDeletions(ctx); len(pending) != 1 {
+		t.Errorf("Expected the deletion to stay pending for the next run, got: %+v", pending)
 	}
 }
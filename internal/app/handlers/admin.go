@@ -0,0 +1,431 @@
+// Package handlers provides HTTP request handlers for the operator administration API.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultAdminPageSize is the number of admin search results returned when no limit is requested.
+const defaultAdminPageSize = 100
+
+// maxAdminPageSize is the maximum number of admin search results returned at once.
+const maxAdminPageSize = 1000
+
+// AdminURL represents a URL entry of any user.
+type AdminURL struct {
+	Slug        string `json:"slug"`
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	UserID      string `json:"user_id"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	Deleted     bool   `json:"deleted"`
+	Disabled    bool   `json:"disabled"`
+}
+
+// AdminUpdateURLRequest represents the request payload for disabling or re-enabling a URL.
+type AdminUpdateURLRequest struct {
+	Disabled *bool `json:"disabled"`
+}
+
+// AdminUserResponse represents the URL counts, block status and repeat offender flag of a user.
+type AdminUserResponse struct {
+	UserID   string `json:"user_id"`
+	URLs     int    `json:"urls"`
+	Deleted  int    `json:"deleted"`
+	Disabled int    `json:"disabled"`
+	Blocked  bool   `json:"blocked"`
+	Flagged  bool   `json:"flagged"`
+}
+
+// BlockUserRequest represents the request payload for blocking a user.
+type BlockUserRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// BlockedUserResponse represents a blocked user.
+type BlockedUserResponse struct {
+	UserID    string    `json:"user_id"`
+	Reason    string    `json:"reason,omitempty"`
+	BlockedBy string    `json:"blocked_by"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// AdminJobResponse represents the state of a background job.
+type AdminJobResponse struct {
+	Name                string     `json:"name"`
+	Kind                string     `json:"kind"`
+	Running             int        `json:"running"`
+	Queued              int        `json:"queued"`
+	Runs                int        `json:"runs"`
+	Failures            int        `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastStartedAt       *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt      *time.Time `json:"last_finished_at,omitempty"`
+	LastDurationMS      int64      `json:"last_duration_ms"`
+	LastError           string     `json:"last_error,omitempty"`
+	NextRunAt           *time.Time `json:"next_run_at,omitempty"`
+}
+
+// WithJobScheduler sets the background job scheduler whose jobs are listed to operators.
+func WithJobScheduler(s *jobs.Scheduler) HandlerOption {
+	return func(h *Handler) {
+		h.jobs = s
+	}
+}
+
+// WithAdminGuard enables the `/api/admin` routes for the operators allowed by the guard.
+// Without a guard, the routes reject every request.
+func WithAdminGuard(g *middlewares.AdminGuard) HandlerOption {
+	return func(h *Handler) {
+		h.adminGuard = g
+	}
+}
+
+// Function to parse the slug, target, owner, limit and offset query parameters of an admin search.
+func parseURLFilter(r *http.Request) (repository.URLFilter, error) {
+	query := r.URL.Query()
+	filter := repository.URLFilter{
+		Slug:        query.Get("slug"),
+		OriginalURL: query.Get("target"),
+		UserID:      query.Get("owner"),
+		Limit:       defaultAdminPageSize,
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAdminPageSize {
+			return repository.URLFilter{}, errors.New("invalid limit")
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return repository.URLFilter{}, errors.New("invalid offset")
+		}
+		filter.Offset = offset
+	}
+	return filter, nil
+}
+
+// Method to handle searching the URLs of all users by slug, target or owner.
+func (h *Handler) HandleAdminSearchURLs(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseURLFilter(r)
+	if err != nil {
+		slog.Debug("invalid admin search", slog.Any("error", err))
+		h.respondInvalidQuery(w, r, err)
+		return
+	}
+
+	urls, err := h.repo.SearchURLs(r.Context(), filter)
+	if err != nil {
+		slog.Error("searching urls", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(urls) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]AdminURL, 0, len(urls))
+	for _, u := range urls {
+		resp = append(resp, AdminURL{
+			Slug:        u.Slug,
+			ShortURL:    h.baseURL + "/" + u.Slug,
+			OriginalURL: u.OriginalURL,
+			UserID:      u.UserID,
+			WorkspaceID: u.WorkspaceID,
+			Deleted:     u.IsDeleted,
+			Disabled:    u.IsDisabled,
+		})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle disabling or re-enabling any URL. Disabled URLs respond with 451.
+func (h *Handler) HandleAdminUpdateURL(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := h.getUserIDFromCtx(r)
+
+	defer r.Body.Close()
+	var updateReq AdminUpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+	if updateReq.Disabled == nil {
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, "The disabled flag is missing.", missingField("disabled"))
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	if err := h.repo.SetURLDisabled(r.Context(), slug, *updateReq.Disabled); err != nil {
+		if errors.Is(err, repository.ErrURLNotExsit) {
+			h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "No URL is shortened to "+slug+".")
+			return
+		}
+		slog.Error("updating url", slog.String("slug", slug), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("url moderated", slog.String("admin", adminID), slog.String("slug", slug), slog.Bool("disabled", *updateReq.Disabled))
+	action := repository.AuditActionAdminEnable
+	if *updateReq.Disabled {
+		action = repository.AuditActionAdminDisable
+	}
+	h.recordAudit(r, repository.AuditEvent{Action: action, Slugs: []string{slug}})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle deleting any URL.
+func (h *Handler) HandleAdminDeleteURL(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := h.getUserIDFromCtx(r)
+
+	slug := chi.URLParam(r, "slug")
+	url, err := h.repo.GetBySlug(r.Context(), slug)
+	if err != nil {
+		h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "No URL is shortened to "+slug+".")
+		return
+	}
+
+	delReq := repository.DeleteRequest{Slug: url.Slug, UserID: url.UserID, WorkspaceID: url.WorkspaceID}
+	if _, err := h.repo.DeleteMany(r.Context(), []repository.DeleteRequest{delReq}); err != nil {
+		slog.Error("deleting url", slog.String("slug", slug), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("url deleted by admin", slog.String("admin", adminID), slog.String("slug", slug), slog.String("owner", url.UserID))
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionAdminDelete, Slugs: []string{slug}, WorkspaceID: url.WorkspaceID, TargetUserID: url.UserID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle listing per-user URL counts, optionally narrowed by the URL search parameters.
+func (h *Handler) HandleAdminListUsers(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseURLFilter(r)
+	if err != nil {
+		slog.Debug("invalid admin search", slog.Any("error", err))
+		h.respondInvalidQuery(w, r, err)
+		return
+	}
+
+	counts, err := h.repo.CountURLsByUser(r.Context(), filter)
+	if err != nil {
+		slog.Error("counting urls by user", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(counts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	blockedUsers, err := h.repo.GetBlockedUsers(r.Context())
+	if err != nil {
+		slog.Error("listing blocked users", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	blocked := make(map[string]bool, len(blockedUsers))
+	for _, b := range blockedUsers {
+		blocked[b.UserID] = true
+	}
+	flaggedUsers, err := h.repo.GetFlaggedUsers(r.Context())
+	if err != nil {
+		slog.Error("listing flagged users", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	flagged := make(map[string]bool, len(flaggedUsers))
+	for _, f := range flaggedUsers {
+		flagged[f.UserID] = true
+	}
+
+	resp := make([]AdminUserResponse, 0, len(counts))
+	for _, c := range counts {
+		resp = append(resp, AdminUserResponse{
+			UserID:   c.UserID,
+			URLs:     c.Total,
+			Deleted:  c.Deleted,
+			Disabled: c.Disabled,
+			Blocked:  blocked[c.UserID],
+			Flagged:  flagged[c.UserID],
+		})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle getting the URL counts, block status and repeat offender flag of a user.
+func (h *Handler) HandleAdminGetUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	counts, err := h.repo.CountURLsByUser(r.Context(), repository.URLFilter{UserID: userID})
+	if err != nil {
+		slog.Error("counting user urls", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	blocked, err := h.repo.IsUserBlocked(r.Context(), userID)
+	if err != nil {
+		slog.Error("checking user block", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+
+	flaggedUsers, err := h.repo.GetFlaggedUsers(r.Context())
+	if err != nil {
+		slog.Error("listing flagged users", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+
+	resp := AdminUserResponse{UserID: userID, Blocked: blocked}
+	for _, f := range flaggedUsers {
+		resp.Flagged = resp.Flagged || f.UserID == userID
+	}
+	if len(counts) > 0 {
+		resp.URLs, resp.Deleted, resp.Disabled = counts[0].Total, counts[0].Deleted, counts[0].Disabled
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle listing the blocked users.
+func (h *Handler) HandleAdminListBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	blockedUsers, err := h.repo.GetBlockedUsers(r.Context())
+	if err != nil {
+		slog.Error("listing blocked users", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(blockedUsers) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]BlockedUserResponse, 0, len(blockedUsers))
+	for _, b := range blockedUsers {
+		resp = append(resp, BlockedUserResponse{UserID: b.UserID, Reason: b.Reason, BlockedBy: b.BlockedBy, BlockedAt: b.BlockedAt})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle listing the background jobs and their states.
+func (h *Handler) HandleAdminListJobs(w http.ResponseWriter, r *http.Request) {
+	if h.jobs == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	statuses := h.jobs.Statuses()
+	if len(statuses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// optionalTime omits the times of events that did not happen yet.
+	optionalTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		t = t.UTC()
+		return &t
+	}
+	resp := make([]AdminJobResponse, 0, len(statuses))
+	for _, s := range statuses {
+		resp = append(resp, AdminJobResponse{
+			Name:                s.Name,
+			Kind:                s.Kind,
+			Running:             s.Running,
+			Queued:              s.Queued,
+			Runs:                s.Runs,
+			Failures:            s.Failures,
+			ConsecutiveFailures: s.ConsecutiveFailures,
+			LastStartedAt:       optionalTime(s.LastStartedAt),
+			LastFinishedAt:      optionalTime(s.LastFinishedAt),
+			LastDurationMS:      s.LastDuration.Milliseconds(),
+			LastError:           s.LastError,
+			NextRunAt:           optionalTime(s.NextRunAt),
+		})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle blocking a user. Blocked users cannot create URLs, and their URLs respond with 451.
+func (h *Handler) HandleAdminBlockUser(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := h.getUserIDFromCtx(r)
+
+	defer r.Body.Close()
+	var blockReq BlockUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&blockReq); err != nil && !errors.Is(err, io.EOF) {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	userID := chi.URLParam(r, "userID")
+	blocked := repository.BlockedUser{UserID: userID, Reason: blockReq.Reason, BlockedBy: adminID, BlockedAt: time.Now().UTC()}
+	if err := h.repo.BlockUser(r.Context(), blocked); err != nil {
+		slog.Error("blocking user", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("user blocked", slog.String("admin", adminID), slog.String("user", userID))
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionAdminBlock, TargetUserID: userID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle unblocking a user.
+func (h *Handler) HandleAdminUnblockUser(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := h.getUserIDFromCtx(r)
+
+	userID := chi.URLParam(r, "userID")
+	if err := h.repo.UnblockUser(r.Context(), userID); err != nil {
+		if errors.Is(err, repository.ErrBlockedUserNotExist) {
+			h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "The user is not blocked.")
+			return
+		}
+		slog.Error("unblocking user", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("user unblocked", slog.String("admin", adminID), slog.String("user", userID))
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionAdminUnblock, TargetUserID: userID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to reject URL creation by blocked users with 403. It returns false if the request was rejected.
+func (h *Handler) allowURLCreation(w http.ResponseWriter, r *http.Request, userID string) bool {
+	blocked, err := h.repo.IsUserBlocked(r.Context(), userID)
+	if err != nil {
+		slog.Error("checking user block", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return false
+	}
+	if blocked {
+		slog.Info("url creation by blocked user rejected", slog.String("user", userID))
+		h.respondWithProblem(w, r, http.StatusForbidden, ProblemUserBlocked, "The user is blocked from creating URLs.")
+		return false
+	}
+	return true
+}
+
+// Method to check whether a URL was taken down: disabled by an operator, taken down after abuse reports
+// or owned by a blocked user.
+func (h *Handler) isTakenDown(ctx context.Context, url repository.URL) (bool, error) {
+	if url.IsDisabled {
+		return true, nil
+	}
+	takenDown, err := h.repo.IsURLTakenDown(ctx, url.Slug)
+	if err != nil || takenDown {
+		return takenDown, err
+	}
+	return h.repo.IsUserBlocked(ctx, url.UserID)
+}
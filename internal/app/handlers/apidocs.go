@@ -0,0 +1,120 @@
+// Package handlers provides the self-hosted documentation page of the HTTP API.
+package handlers
+
+// apiDocsPage renders the OpenAPI description served at `/api/openapi.json` without external assets.
+var apiDocsPage = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ShortURL API</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 0 auto; max-width: 960px; padding: 1rem; color: #222; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: .25rem; margin-top: 2rem; }
+details { border: 1px solid #ddd; border-radius: 4px; margin: .5rem 0; }
+summary { cursor: pointer; padding: .5rem; font-family: monospace; }
+details > div { padding: 0 .75rem .75rem; }
+.method { display: inline-block; width: 4.5rem; font-weight: bold; text-transform: uppercase; }
+.get { color: #1a7f37; } .post { color: #0969da; } .put { color: #9a6700; } .patch { color: #8250df; } .delete { color: #cf222e; }
+.summary { font-family: system-ui, sans-serif; color: #555; margin-left: .5rem; }
+table { border-collapse: collapse; width: 100%; margin: .5rem 0; }
+th, td { border: 1px solid #eee; padding: .25rem .5rem; text-align: left; vertical-align: top; }
+pre { background: #f6f8fa; padding: .5rem; overflow-x: auto; margin: .25rem 0; }
+</style>
+</head>
+<body>
+<h1 id="title">ShortURL API</h1>
+<p id="description"></p>
+<p><a href="/api/openapi.json">OpenAPI document</a></p>
+<div id="operations">Loading&hellip;</div>
+<script>
+"use strict";
+let spec;
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  Object.assign(node, attrs || {});
+  for (const child of children) {
+    node.append(child instanceof Node ? child : document.createTextNode(String(child)));
+  }
+  return node;
+}
+
+function resolve(schema) {
+  if (schema && schema.$ref) {
+    return spec.components.schemas[schema.$ref.split("/").pop()];
+  }
+  return schema || {};
+}
+
+function example(schema, depth) {
+  schema = resolve(schema);
+  if (depth > 5) return null;
+  if (schema.type === "object" && schema.properties) {
+    const obj = {};
+    for (const [name, prop] of Object.entries(schema.properties)) obj[name] = example(prop, depth + 1);
+    return obj;
+  }
+  if (schema.type === "object") return {};
+  if (schema.type === "array") return [example(schema.items, depth + 1)];
+  if (schema.format === "date-time") return "2024-01-01T00:00:00Z";
+  return { string: "string", integer: 0, number: 0, boolean: false }[schema.type] ?? null;
+}
+
+function bodies(content) {
+  const nodes = [];
+  for (const [type, media] of Object.entries(content || {})) {
+    const ref = media.schema && (media.schema.$ref || (media.schema.items && media.schema.items.$ref));
+    nodes.push(el("div", {}, el("code", {}, type), ref ? " " + ref.split("/").pop() : ""));
+    if (type.startsWith("application/json") || type.startsWith("application/problem+json") || type.startsWith("application/x-ndjson")) {
+      nodes.push(el("pre", {}, JSON.stringify(example(media.schema, 0), null, 2)));
+    }
+  }
+  return nodes;
+}
+
+function operation(path, method, op) {
+  const details = el("details", {},
+    el("summary", {}, el("span", { className: "method " + method }, method), path, el("span", { className: "summary" }, op.summary)));
+  const body = el("div");
+  if (op.parameters && op.parameters.length) {
+    const rows = op.parameters.map((p) => el("tr", {}, el("td", {}, el("code", {}, p.name)), el("td", {}, p.in), el("td", {}, p.required ? "yes" : "no"), el("td", {}, p.description || "")));
+    body.append(el("h4", {}, "Parameters"), el("table", {}, el("tr", {}, el("th", {}, "Name"), el("th", {}, "In"), el("th", {}, "Required"), el("th", {}, "Description")), ...rows));
+  }
+  if (op.requestBody) {
+    body.append(el("h4", {}, "Request body"), ...bodies(op.requestBody.content));
+  }
+  body.append(el("h4", {}, "Responses"));
+  for (const [status, resp] of Object.entries(op.responses)) {
+    body.append(el("div", {}, el("strong", {}, status), " " + resp.description), ...bodies(resp.content));
+  }
+  if (op.security) {
+    const schemes = op.security.map((s) => Object.keys(s)[0]).filter(Boolean);
+    body.append(el("p", {}, "Authentication: " + schemes.join(", ")));
+  }
+  details.append(body);
+  return details;
+}
+
+fetch("/api/openapi.json").then((resp) => resp.json()).then((doc) => {
+  spec = doc;
+  document.title = doc.info.title;
+  document.getElementById("title").textContent = doc.info.title + " " + doc.info.version;
+  document.getElementById("description").textContent = doc.info.description;
+  const root = document.getElementById("operations");
+  root.textContent = "";
+  for (const tag of doc.tags) {
+    const ops = [];
+    for (const [path, methods] of Object.entries(doc.paths).sort()) {
+      for (const [method, op] of Object.entries(methods)) {
+        if (op.tags.includes(tag.name)) ops.push(operation(path, method, op));
+      }
+    }
+    if (ops.length) root.append(el("h2", {}, tag.name), el("p", {}, tag.description || ""), ...ops);
+  }
+}).catch((err) => {
+  document.getElementById("operations").textContent = "Failed to load the OpenAPI document: " + err;
+});
+</script>
+</body>
+</html>
+`)
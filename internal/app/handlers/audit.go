@@ -0,0 +1,165 @@
+// Package handlers provides HTTP request handlers for recording, querying and exporting the audit log.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/google/uuid"
+)
+
+// NDJSONContentType is the content type for newline-delimited JSON responses.
+const NDJSONContentType = "application/x-ndjson"
+
+// AuditEventResponse represents an entry of the audit log.
+type AuditEventResponse struct {
+	ID           string    `json:"id"`
+	Time         time.Time `json:"time"`
+	Actor        string    `json:"actor"`
+	Action       string    `json:"action"`
+	Slugs        []string  `json:"slugs,omitempty"`
+	WorkspaceID  string    `json:"workspace_id,omitempty"`
+	TargetUserID string    `json:"target_user_id,omitempty"`
+	SourceIP     string    `json:"source_ip,omitempty"`
+	RequestID    string    `json:"request_id,omitempty"`
+}
+
+// Function to convert a recorded audit event into its response representation.
+func newAuditEventResponse(e repository.AuditEvent) AuditEventResponse {
+	return AuditEventResponse{
+		ID:           e.ID,
+		Time:         e.Time,
+		Actor:        e.Actor,
+		Action:       e.Action,
+		Slugs:        e.Slugs,
+		WorkspaceID:  e.WorkspaceID,
+		TargetUserID: e.TargetUserID,
+		SourceIP:     e.SourceIP,
+		RequestID:    e.RequestID,
+	}
+}
+
+// Function to parse the actor, action, slug, since, until, limit and offset query parameters of an audit log query.
+// The since and until times are in RFC 3339 format.
+func parseAuditFilter(r *http.Request, defaultLimit int) (repository.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := repository.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Slug:   query.Get("slug"),
+		Limit:  defaultLimit,
+	}
+	for param, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return repository.AuditFilter{}, errors.New("invalid " + param)
+			}
+			*dst = t
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAdminPageSize {
+			return repository.AuditFilter{}, errors.New("invalid limit")
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return repository.AuditFilter{}, errors.New("invalid offset")
+		}
+		filter.Offset = offset
+	}
+	return filter, nil
+}
+
+// Method to handle querying the audit log.
+func (h *Handler) HandleAdminListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r, defaultAdminPageSize)
+	if err != nil {
+		slog.Debug("invalid audit log query", slog.Any("error", err))
+		h.respondInvalidQuery(w, r, err)
+		return
+	}
+
+	events, err := h.repo.GetAuditEvents(r.Context(), filter)
+	if err != nil {
+		slog.Error("querying audit log", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(events) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]AuditEventResponse, 0, len(events))
+	for _, e := range events {
+		resp = append(resp, newAuditEventResponse(e))
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle exporting the audit log as newline-delimited JSON. Without a limit, all matching events are exported.
+func (h *Handler) HandleAdminExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r, 0)
+	if err != nil {
+		slog.Debug("invalid audit log export", slog.Any("error", err))
+		h.respondInvalidQuery(w, r, err)
+		return
+	}
+
+	events, err := h.repo.GetAuditEvents(r.Context(), filter)
+	if err != nil {
+		slog.Error("exporting audit log", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", NDJSONContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for _, e := range events {
+		if err := encoder.Encode(newAuditEventResponse(e)); err != nil {
+			slog.Error("writing audit log export", slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// Method to append an action performed by the request's user to the audit log.
+// A failure to record the action is logged without failing the request.
+func (h *Handler) recordAudit(r *http.Request, event repository.AuditEvent) {
+	event.ID = uuid.NewString()
+	event.Time = time.Now().UTC()
+	event.Actor, _ = h.getUserIDFromCtx(r)
+	event.SourceIP = middlewares.ClientIP(r)
+	event.RequestID = middlewares.RequestIDFromContext(r.Context())
+	if err := h.repo.AddAuditEvent(r.Context(), event); err != nil {
+		slog.Error("recording audit event", slog.String("action", event.Action), slog.Any("error", err))
+	}
+}
+
+// Function to build the delete requests of the URLs, carrying the request's audit context to the background deleter.
+func newDeleteRequests(r *http.Request, slugs []string, userID string, workspaceID string) []repository.DeleteRequest {
+	delReqs := make([]repository.DeleteRequest, 0, len(slugs))
+	for _, s := range slugs {
+		delReqs = append(delReqs, repository.DeleteRequest{
+			Slug:        s,
+			UserID:      userID,
+			WorkspaceID: workspaceID,
+			SourceIP:    middlewares.ClientIP(r),
+			RequestID:   middlewares.RequestIDFromContext(r.Context()),
+		})
+	}
+	return delReqs
+}
@@ -17,7 +17,7 @@ import (
 
 func ExampleHandler_HandleShortenURL() {
 	repo := repository.NewMemoryRepository()
-	bgDeleter := deleter.NewBackgroundDeleter(repo)
+	bgDeleter := deleter.NewBackgroundDeleter(repo, deleter.Config{})
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	handler := NewHandler(repo, bgDeleter, logger, "http://localhost:8080")
 
@@ -37,7 +37,7 @@ func ExampleHandler_HandleShortenURL() {
 
 func ExampleHandler_HandleExpandURL() {
 	repo := repository.NewMemoryRepository()
-	bgDeleter := deleter.NewBackgroundDeleter(repo)
+	bgDeleter := deleter.NewBackgroundDeleter(repo, deleter.Config{})
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	handler := NewHandler(repo, bgDeleter, logger, "http://localhost:8080")
 
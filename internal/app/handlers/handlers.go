@@ -8,11 +8,24 @@ import (
 	"log/slog"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gennadis/shorturl/internal/app/accounts"
 	"github.com/gennadis/shorturl/internal/app/deleter"
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/gennadis/shorturl/internal/app/lifecycle"
+	"github.com/gennadis/shorturl/internal/app/metrics"
 	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/moderation"
+	"github.com/gennadis/shorturl/internal/app/oidc"
+	"github.com/gennadis/shorturl/internal/app/policy"
 	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/gennadis/shorturl/internal/app/validator"
+	"github.com/gennadis/shorturl/internal/app/webhooks"
+	"github.com/gennadis/shorturl/internal/app/workspaces"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	slogchi "github.com/samber/slog-chi"
 )
 
@@ -23,18 +36,71 @@ const charset = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
 // slugLen represents the length of the generated slug.
 const slugLen = 6
 
+// maxAPIKeyLabelLen is the maximum length of an API key label.
+const maxAPIKeyLabelLen = 255
+
 // JSONContentType is the content type for JSON responses.
 const JSONContentType = "application/json"
 
 // PlainTextContentType is the content type for plain text responses.
 const PlainTextContentType = "text/plain; charset=utf-8"
 
+// HTMLContentType is the content type for HTML responses.
+const HTMLContentType = "text/html; charset=utf-8"
+
 // ErrorMissingUserIDCtx is returned when user ID is missing in the context.
 var ErrorMissingUserIDCtx = errors.New("no userID in context")
 
+// Problem codes of the handlers, in addition to the codes shared with middlewares.
+const (
+	ProblemMissingURL          = "missing_url"
+	ProblemInvalidURL          = "invalid_url"
+	ProblemDestinationDenied   = "destination_denied"
+	ProblemInvalidActivation   = "invalid_activation"
+	ProblemEmptyBatch          = "empty_batch"
+	ProblemUnknownSlug         = "unknown_slug"
+	ProblemGone                = "gone"
+	ProblemInactiveLink        = "inactive_link"
+	ProblemMethodNotAllowed    = "method_not_allowed"
+	ProblemNotFound            = "not_found"
+	ProblemConflict            = "conflict"
+	ProblemInvalidParameter    = "invalid_parameter"
+	ProblemUserBlocked         = "user_blocked"
+	ProblemInvalidAccount      = "invalid_account"
+	ProblemAccountExists       = "account_exists"
+	ProblemInvalidCredentials  = "invalid_credentials"
+	ProblemInvalidAPIKey       = "invalid_api_key"
+	ProblemInvalidWorkspace    = "invalid_workspace"
+	ProblemInvalidReport       = "invalid_report"
+	ProblemInvalidWebhook      = "invalid_webhook"
+	ProblemSignInFailed        = "sign_in_failed"
+	ProblemInvalidSignInState  = "invalid_sign_in_state"
+	ProblemProviderUnavailable = "provider_unavailable"
+	ProblemTooManySlugs        = "too_many_slugs"
+	ProblemDeletionQueueFull   = "deletion_queue_full"
+)
+
+// LinkSchedule represents a recurring weekly schedule a shortened URL resolves within.
+type LinkSchedule struct {
+	Weekdays  []string `json:"weekdays,omitempty"`
+	StartHour int      `json:"start_hour"`
+	EndHour   int      `json:"end_hour"`
+	TimeZone  string   `json:"time_zone,omitempty"`
+}
+
+// LinkActivation represents the optional activation window and schedule of a shortened URL.
+type LinkActivation struct {
+	NotBefore *time.Time    `json:"not_before,omitempty"`
+	NotAfter  *time.Time    `json:"not_after,omitempty"`
+	Schedule  *LinkSchedule `json:"schedule,omitempty"`
+}
+
 // ShortenURLRequest represents the request payload for shortening a URL.
+// A workspace ID creates the URL inside the workspace, which requires the editor role.
 type ShortenURLRequest struct {
 	OriginalURL string `json:"url"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	LinkActivation
 }
 
 // ShortenURLResponse represents the response payload for a shortened URL.
@@ -46,12 +112,30 @@ type ShortenURLResponse struct {
 type BatchShortenURLRequest struct {
 	CorrelationID string `json:"correlation_id"`
 	OriginalURL   string `json:"original_url"`
+	LinkActivation
 }
 
-// BatchShortenURLResponse represents the response payload for batch shortened URLs.
+// Outcomes of an item of a batch shortening request.
+const (
+	BatchItemCreated  = "created"
+	BatchItemExisting = "existing"
+	BatchItemInvalid  = "invalid"
+)
+
+// BatchShortenURLResponse represents the outcome of an item of a batch shortening request.
+// Created and existing items carry the short URL, invalid items the reason they were rejected.
 type BatchShortenURLResponse struct {
-	CorrelationID string `json:"correlation_id"`
-	ShortURL      string `json:"short_url"`
+	CorrelationID string          `json:"correlation_id"`
+	Status        string          `json:"status"`
+	ShortURL      string          `json:"short_url,omitempty"`
+	Error         *BatchItemError `json:"error,omitempty"`
+}
+
+// BatchItemError describes why an item of a batch shortening request was rejected.
+type BatchItemError struct {
+	Code    string `json:"code"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message"`
 }
 
 // UserURL represents a user's URL entry.
@@ -60,6 +144,61 @@ type UserURL struct {
 	OriginalURL string `json:"original_url"`
 }
 
+// TokenResponse represents the response payload for an issued bearer token.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// CreateAPIKeyRequest represents the request payload for creating an API key.
+type CreateAPIKeyRequest struct {
+	Label  string   `json:"label"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// UpdateAPIKeyRequest represents the request payload for relabeling an API key.
+type UpdateAPIKeyRequest struct {
+	Label string `json:"label"`
+}
+
+// APIKeyResponse represents an API key entry. The key itself is never returned after creation.
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Label      string     `json:"label"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyResponse represents the response payload for a created API key, including the key.
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// RegisterRequest represents the request payload for registering an account.
+type RegisterRequest struct {
+	Email    string `json:"email,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password"`
+}
+
+// LoginRequest represents the request payload for logging in with an email or username.
+type LoginRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// AccountResponse represents a registered account.
+type AccountResponse struct {
+	UserID   string `json:"user_id"`
+	Email    string `json:"email,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
 // ServiceStatsResponse represents the response payload for service stats.
 type ServiceStatsResponse struct {
 	URLsCount  int `json:"urls"`
@@ -75,41 +214,260 @@ func generateSlug() string {
 	return string(b)
 }
 
+// Function to convert a stored API key into its response representation.
+func newAPIKeyResponse(key repository.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Label:      key.Label,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
+
+// Function to apply the requested activation window and schedule to a URL.
+func (a LinkActivation) apply(url *repository.URL) error {
+	url.NotBefore = a.NotBefore
+	url.NotAfter = a.NotAfter
+	if a.Schedule != nil {
+		url.Schedule = &repository.Schedule{
+			Weekdays:  a.Schedule.Weekdays,
+			StartHour: a.Schedule.StartHour,
+			EndHour:   a.Schedule.EndHour,
+			TimeZone:  a.Schedule.TimeZone,
+		}
+	}
+	return url.ValidateActivation()
+}
+
 // Handler handles HTTP requests for the short URL service.
 type Handler struct {
-	Router            *chi.Mux
-	repo              repository.IRepository
-	backgroundDeleter *deleter.BackgroundDeleter
-	baseURL           string
+	Router                *chi.Mux
+	repo                  repository.IRepository
+	backgroundDeleter     *deleter.BackgroundDeleter
+	baseURL               string
+	validator             *validator.Validator
+	policy                *policy.Engine
+	rateLimiter           *middlewares.RateLimiter
+	adminGuard            *middlewares.AdminGuard
+	cookieKeys            *middlewares.KeyRing
+	sessionOptions        middlewares.SessionOptions
+	sessions              *middlewares.SessionManager
+	tokens                *middlewares.TokenManager
+	accounts              *accounts.Service
+	workspaces            *workspaces.Service
+	moderation            *moderation.Service
+	webhooks              *webhooks.Service
+	clicks                *webhooks.ClickCounter
+	jobs                  *jobs.Scheduler
+	lifecycle             *lifecycle.Supervisor
+	healthChecks          []HealthCheck
+	metrics               *metrics.Metrics
+	metricsEndpoint       bool
+	oidc                  *oidc.Provider
+	oidcPostLoginRedirect string
+	inactiveLinkStatus    int
+	inactiveLinkPage      []byte
+	takedownPage          []byte
+	apiDocs               bool
+	openAPI               *openAPIDocument
+}
+
+// HandlerOption configures optional Handler behaviour.
+type HandlerOption func(*Handler)
+
+// WithInactiveLinkResponse sets the status code and the optional HTML page
+// returned for URLs requested outside their activation window or schedule.
+func WithInactiveLinkResponse(statusCode int, page []byte) HandlerOption {
+	return func(h *Handler) {
+		h.inactiveLinkStatus = statusCode
+		h.inactiveLinkPage = page
+	}
+}
+
+// WithURLValidator sets the validator used to check and canonicalize destination URLs.
+func WithURLValidator(v *validator.Validator) HandlerOption {
+	return func(h *Handler) {
+		h.validator = v
+	}
+}
+
+// WithDestinationPolicy sets the policy engine destination URLs are checked against
+// when shortening and again when redirecting.
+func WithDestinationPolicy(p *policy.Engine) HandlerOption {
+	return func(h *Handler) {
+		h.policy = p
+	}
+}
+
+// WithRateLimiter sets the rate limiter applied to the redirect, shorten, batch, delete and report routes.
+func WithRateLimiter(rl *middlewares.RateLimiter) HandlerOption {
+	return func(h *Handler) {
+		h.rateLimiter = rl
+	}
+}
+
+// WithCookieKeys sets the key ring used to sign and verify authentication cookies.
+func WithCookieKeys(keys *middlewares.KeyRing) HandlerOption {
+	return func(h *Handler) {
+		h.cookieKeys = keys
+	}
+}
+
+// WithSessionOptions sets the session lifetime and the session cookie attributes.
+func WithSessionOptions(opts middlewares.SessionOptions) HandlerOption {
+	return func(h *Handler) {
+		h.sessionOptions = opts
+	}
+}
+
+// WithTokenManager enables `Authorization: Bearer` authentication with the token manager
+// and the endpoint exchanging a cookie identity for a token.
+func WithTokenManager(tm *middlewares.TokenManager) HandlerOption {
+	return func(h *Handler) {
+		h.tokens = tm
+	}
+}
+
+// WithAccountService sets the service registering and authenticating user accounts.
+func WithAccountService(s *accounts.Service) HandlerOption {
+	return func(h *Handler) {
+		h.accounts = s
+	}
 }
 
 // NewHandler creates a new instance of the Handler.
-func NewHandler(repo repository.IRepository, bgDeleter *deleter.BackgroundDeleter, logger *slog.Logger, baseURL string) *Handler {
+func NewHandler(repo repository.IRepository, bgDeleter *deleter.BackgroundDeleter, logger *slog.Logger, baseURL string, opts ...HandlerOption) *Handler {
 	h := Handler{
-		Router:            chi.NewRouter(),
-		repo:              repo,
-		backgroundDeleter: bgDeleter,
-		baseURL:           baseURL,
+		Router:             chi.NewRouter(),
+		repo:               repo,
+		backgroundDeleter:  bgDeleter,
+		baseURL:            baseURL,
+		validator:          validator.NewValidator(nil),
+		inactiveLinkStatus: http.StatusNotFound,
+	}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	if h.cookieKeys == nil {
+		h.cookieKeys = middlewares.NewEphemeralKeyRing()
+	}
+	h.sessions = middlewares.NewSessionManager(h.cookieKeys, repo, h.sessionOptions)
+	if h.accounts == nil {
+		h.accounts = accounts.NewService(repo, 0)
+	}
+	if h.workspaces == nil {
+		h.workspaces = workspaces.NewService(repo, 0)
+	}
+	if h.moderation == nil {
+		h.moderation = moderation.NewService(repo, moderation.Thresholds{})
+	}
+	if h.webhooks == nil {
+		h.webhooks = webhooks.NewService(repo, baseURL)
+	}
+	if h.clicks == nil {
+		h.clicks = webhooks.NewClickCounter(h.webhooks, 0)
+	}
+	if h.oidcPostLoginRedirect == "" {
+		h.oidcPostLoginRedirect = "/"
 	}
 
-	// Middleware setup.
+	// Middleware setup. The probes and the metrics are served first, bypassing authentication and logging.
+	h.Router.Use(h.probes, h.metrics.Middleware, middlewares.RequestIDMiddleware, slogchi.New(logger))
+	if h.tokens != nil {
+		h.Router.Use(middlewares.BearerAuthMiddleware(h.tokens))
+	}
 	h.Router.Use(
-		slogchi.New(logger),
-		middlewares.CookieAuthMiddleware,
+		middlewares.APIKeyAuthMiddleware(h.repo),
+		middlewares.CookieAuthMiddleware(h.sessions),
 		middlewares.GzipMiddleware,
 	)
 
 	// Routes setup.
-	h.Router.Get("/{slug}", h.HandleExpandURL)
-	h.Router.Get("/api/user/urls", h.HandleGetUserURLs)
+	h.Router.With(h.rateLimiter.Limit(middlewares.RouteClassRedirect)).Get("/{slug}", h.HandleExpandURL)
+	h.Router.With(middlewares.RequireScope(repository.ScopeRead)).Get("/api/user/urls", h.HandleGetUserURLs)
 	h.Router.Get("/api/internal/stats", h.HandleGetServiceStats)
-	h.Router.Get("/ping", h.HandleDatabasePing)
-	h.Router.Post("/", h.HandleShortenURL)
-	h.Router.Post("/api/shorten", h.HandleJSONShortenURL)
-	h.Router.Post("/api/shorten/batch", h.HandleBatchJSONShortenURL)
-	h.Router.Delete("/api/user/urls", h.HandleDeleteUserURLs)
+	h.Router.Get(pingPath, h.HandleDatabasePing)
+	h.Router.Get(healthzPath, h.HandleHealthz)
+	h.Router.Get(readyzPath, h.HandleReadyz)
+	if h.metricsEndpoint {
+		h.Router.Get(metricsPath, h.HandleMetrics)
+	}
+	h.Router.Get("/api/openapi.json", h.HandleOpenAPI)
+	if h.apiDocs {
+		h.Router.Get("/api/docs", h.HandleAPIDocs)
+	}
+	h.Router.With(middlewares.PlainTextErrors, middlewares.RequireScope(repository.ScopeShorten), h.rateLimiter.Limit(middlewares.RouteClassShorten)).Post("/", h.HandleShortenURL)
+	h.Router.With(middlewares.RequireScope(repository.ScopeShorten), h.rateLimiter.Limit(middlewares.RouteClassShorten)).Post("/api/shorten", h.HandleJSONShortenURL)
+	h.Router.With(middlewares.RequireScope(repository.ScopeShorten), h.rateLimiter.Limit(middlewares.RouteClassBatch)).Post("/api/shorten/batch", h.HandleBatchJSONShortenURL)
+	h.Router.With(middlewares.RequireScope(repository.ScopeDelete), h.rateLimiter.Limit(middlewares.RouteClassDelete)).Delete("/api/user/urls", h.HandleDeleteUserURLs)
+	h.Router.With(middlewares.RequireScope(repository.ScopeRead)).Get(deletionJobsPath+"{id}", h.HandleGetDeletionJob)
+	h.Router.With(h.rateLimiter.Limit(middlewares.RouteClassReport)).Post("/api/report/{slug}", h.HandleReportURL)
+	h.Router.With(h.denyAPIKeyAuth).Post("/api/user/register", h.HandleRegister)
+	h.Router.With(h.denyAPIKeyAuth).Post("/api/user/login", h.HandleLogin)
+	h.Router.Post("/api/user/logout", h.HandleLogout)
+	h.Router.Route("/api/user/keys", func(r chi.Router) {
+		r.Use(h.denyAPIKeyAuth)
+		r.Post("/", h.HandleCreateAPIKey)
+		r.Get("/", h.HandleListAPIKeys)
+		r.Patch("/{id}", h.HandleUpdateAPIKey)
+		r.Delete("/{id}", h.HandleRevokeAPIKey)
+	})
+	h.Router.Route("/api/user/webhooks", func(r chi.Router) {
+		r.Use(h.denyAPIKeyAuth)
+		r.Post("/", h.HandleCreateWebhook)
+		r.Get("/", h.HandleListWebhooks)
+		r.Delete("/{id}", h.HandleDeleteWebhook)
+		r.Get("/{id}/deliveries", h.HandleListWebhookDeliveries)
+	})
+	h.Router.Route("/api/workspaces", func(r chi.Router) {
+		r.With(h.denyAPIKeyAuth).Post("/", h.HandleCreateWorkspace)
+		r.With(middlewares.RequireScope(repository.ScopeRead)).Get("/", h.HandleListWorkspaces)
+		r.With(h.denyAPIKeyAuth).Post("/join", h.HandleAcceptWorkspaceInvitation)
+		r.Route("/{workspaceID}", func(r chi.Router) {
+			r.With(middlewares.RequireScope(repository.ScopeRead)).Get("/members", h.HandleListWorkspaceMembers)
+			r.With(h.denyAPIKeyAuth).Patch("/members/{userID}", h.HandleUpdateWorkspaceMember)
+			r.With(h.denyAPIKeyAuth).Delete("/members/{userID}", h.HandleRemoveWorkspaceMember)
+			r.With(h.denyAPIKeyAuth).Post("/invitations", h.HandleCreateWorkspaceInvitation)
+			r.With(h.denyAPIKeyAuth).Get("/invitations", h.HandleListWorkspaceInvitations)
+			r.With(h.denyAPIKeyAuth).Delete("/invitations/{invitationID}", h.HandleRevokeWorkspaceInvitation)
+			r.With(middlewares.RequireScope(repository.ScopeRead)).Get("/urls", h.HandleGetWorkspaceURLs)
+			r.With(middlewares.RequireScope(repository.ScopeShorten), h.rateLimiter.Limit(middlewares.RouteClassShorten)).Patch("/urls/{slug}", h.HandleUpdateWorkspaceURL)
+			r.With(middlewares.RequireScope(repository.ScopeDelete), h.rateLimiter.Limit(middlewares.RouteClassDelete)).Delete("/urls", h.HandleDeleteWorkspaceURLs)
+		})
+	})
+	h.Router.Route("/api/admin", func(r chi.Router) {
+		r.Use(h.denyAPIKeyAuth, h.adminGuard.Require)
+		r.Get("/urls", h.HandleAdminSearchURLs)
+		r.Patch("/urls/{slug}", h.HandleAdminUpdateURL)
+		r.Delete("/urls/{slug}", h.HandleAdminDeleteURL)
+		r.Get("/users", h.HandleAdminListUsers)
+		r.Get("/users/blocked", h.HandleAdminListBlockedUsers)
+		r.Get("/users/flagged", h.HandleAdminListFlaggedUsers)
+		r.Get("/users/{userID}", h.HandleAdminGetUser)
+		r.Put("/users/{userID}/block", h.HandleAdminBlockUser)
+		r.Delete("/users/{userID}/block", h.HandleAdminUnblockUser)
+		r.Get("/reports", h.HandleAdminListReports)
+		r.Post("/reports/{slug}/takedown", h.HandleAdminTakedownURL)
+		r.Post("/reports/{slug}/dismiss", h.HandleAdminDismissReports)
+		r.Get("/audit", h.HandleAdminListAuditEvents)
+		r.Get("/audit/export", h.HandleAdminExportAuditEvents)
+		r.Get("/jobs", h.HandleAdminListJobs)
+	})
+	if h.tokens != nil {
+		h.Router.Post("/api/auth/token", h.HandleIssueToken)
+	}
+	if h.oidc != nil {
+		h.Router.Get("/api/auth/oidc/login", h.HandleOIDCLogin)
+		h.Router.Get("/api/auth/oidc/callback", h.HandleOIDCCallback)
+	}
 	h.Router.MethodNotAllowed(h.HandleMethodNotAllowed)
 
+	// Describe the registered routes.
+	h.openAPI = h.newOpenAPIDocument()
+
 	return &h
 }
 
@@ -117,7 +475,7 @@ func NewHandler(repo repository.IRepository, bgDeleter *deleter.BackgroundDelete
 func (h *Handler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromCtx(r)
 	if errors.Is(err, ErrorMissingUserIDCtx) {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMissingUserID(w, r)
 		return
 	}
 
@@ -125,18 +483,27 @@ func (h *Handler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
 	originalURL, err := io.ReadAll(r.Body)
 	if err != nil {
 		slog.Error("reading request body", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMalformedBody(w, r, err)
 		return
 	}
 
 	if len(originalURL) == 0 {
 		slog.Error("url parameter is missing")
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemMissingURL, "The request body must be the URL to shorten.", missingField("url"))
+		return
+	}
+
+	if !h.allowURLCreation(w, r, userID) {
+		return
+	}
+
+	normalizedURL, ok := h.validateOriginalURL(w, r, string(originalURL), "url")
+	if !ok {
 		return
 	}
 
 	slug := generateSlug()
-	url := repository.NewURL(slug, string(originalURL), userID, false)
+	url := repository.NewURL(slug, normalizedURL, userID, false)
 	slog.Debug(
 		"slug generation",
 		slog.String("original url", url.OriginalURL),
@@ -152,7 +519,7 @@ func (h *Handler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
 					slog.String("orignal url", url.OriginalURL),
 					slog.Any("error", err),
 				)
-				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				h.respondInternalError(w, r)
 				return
 			}
 
@@ -161,10 +528,12 @@ func (h *Handler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
 		}
 
 		slog.Error("saving url", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondInternalError(w, r)
 		return
 	}
 
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionShorten, Slugs: []string{url.Slug}})
+	h.notifyLinksCreated(r.Context(), *url)
 	h.respondWithPlainText(w, h.baseURL+"/"+url.Slug, http.StatusCreated)
 }
 
@@ -172,7 +541,7 @@ func (h *Handler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HandleJSONShortenURL(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromCtx(r)
 	if errors.Is(err, ErrorMissingUserIDCtx) {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMissingUserID(w, r)
 		return
 	}
 
@@ -180,34 +549,56 @@ func (h *Handler) HandleJSONShortenURL(w http.ResponseWriter, r *http.Request) {
 	var shortenReq ShortenURLRequest
 	if err := json.NewDecoder(r.Body).Decode(&shortenReq); err != nil {
 		slog.Error("unmarshalling request data", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMalformedBody(w, r, err)
 		return
 	}
 
 	if len(shortenReq.OriginalURL) == 0 {
 		slog.Error("missing original url parameter", slog.Any("shorten request", shortenReq))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemMissingURL, "The URL to shorten is missing.", missingField("url"))
+		return
+	}
+
+	if !h.allowURLCreation(w, r, userID) {
+		return
+	}
+
+	if shortenReq.WorkspaceID != "" {
+		if _, err := h.workspaces.Authorize(r.Context(), shortenReq.WorkspaceID, userID, repository.RoleEditor); err != nil {
+			h.respondWorkspaceError(w, r, userID, shortenReq.WorkspaceID, err)
+			return
+		}
+	}
+
+	normalizedURL, ok := h.validateOriginalURL(w, r, shortenReq.OriginalURL, "url")
+	if !ok {
 		return
 	}
 
 	slug := generateSlug()
-	url := repository.NewURL(slug, shortenReq.OriginalURL, userID, false)
+	url := repository.NewURL(slug, normalizedURL, userID, false)
+	url.WorkspaceID = shortenReq.WorkspaceID
+	if err := shortenReq.LinkActivation.apply(url); err != nil {
+		slog.Error("invalid link activation", slog.Any("shorten request", shortenReq), slog.Any("error", err))
+		h.respondInvalidActivation(w, r, err)
+		return
+	}
 	slog.Debug(
 		"url shortened successfully",
-		slog.String("original url", shortenReq.OriginalURL),
+		slog.String("original url", url.OriginalURL),
 		slog.String("generated slug", slug),
 	)
 
 	if err := h.repo.Add(r.Context(), *url); err != nil {
 		if errors.Is(err, repository.ErrURLDuplicate) {
-			existingURL, err := h.repo.GetByOriginalURL(r.Context(), string(shortenReq.OriginalURL))
+			existingURL, err := h.repo.GetByOriginalURL(r.Context(), url.OriginalURL)
 			if err != nil {
 				slog.Error(
 					"reading existing slug",
-					slog.String("original url", shortenReq.OriginalURL),
+					slog.String("original url", url.OriginalURL),
 					slog.Any("error", err),
 				)
-				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				h.respondInternalError(w, r)
 				return
 			}
 
@@ -216,10 +607,12 @@ func (h *Handler) HandleJSONShortenURL(w http.ResponseWriter, r *http.Request) {
 		}
 
 		slog.Error("saving url to a storage", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.respondInternalError(w, r)
 		return
 	}
 
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionShorten, Slugs: []string{url.Slug}, WorkspaceID: url.WorkspaceID})
+	h.notifyLinksCreated(r.Context(), *url)
 	h.respondWithJson(w, http.StatusCreated, ShortenURLResponse{Result: h.baseURL + "/" + url.Slug})
 }
 
@@ -227,7 +620,7 @@ func (h *Handler) HandleJSONShortenURL(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HandleExpandURL(w http.ResponseWriter, r *http.Request) {
 	_, err := h.getUserIDFromCtx(r)
 	if errors.Is(err, ErrorMissingUserIDCtx) {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMissingUserID(w, r)
 		return
 	}
 
@@ -237,12 +630,33 @@ func (h *Handler) HandleExpandURL(w http.ResponseWriter, r *http.Request) {
 	url, err := h.repo.GetBySlug(r.Context(), slug)
 	if err != nil {
 		slog.Error("retrieving original URL", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemUnknownSlug, "No URL is shortened to "+slug+".")
 		return
 	}
 	if url.IsDeleted {
 		slog.Debug("requested URL is marked as deleted", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+		h.respondWithProblem(w, r, http.StatusGone, ProblemGone, "The URL is deleted.")
+		return
+	}
+	takenDown, err := h.isTakenDown(r.Context(), url)
+	if err != nil {
+		slog.Error("checking url takedown", slog.String("slug", slug), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if takenDown {
+		slog.Debug("requested URL is taken down", slog.String("slug", slug))
+		h.respondTakenDown(w)
+		return
+	}
+	if err := h.checkDestinationPolicy(url.OriginalURL); err != nil {
+		slog.Info("requested URL denied by policy", slog.String("slug", slug), slog.Any("error", err))
+		h.respondWithProblem(w, r, http.StatusForbidden, ProblemDestinationDenied, "The destination of the URL is denied by policy.")
+		return
+	}
+	if !url.IsActiveAt(time.Now()) {
+		slog.Debug("requested URL is outside its activation window", slog.String("slug", slug))
+		h.respondInactiveLink(w, r)
 		return
 	}
 	slog.Debug(
@@ -251,20 +665,21 @@ func (h *Handler) HandleExpandURL(w http.ResponseWriter, r *http.Request) {
 		slog.String("original URL", url.OriginalURL),
 	)
 
+	h.countClick(url)
 	w.Header().Set("Location", url.OriginalURL)
 	w.WriteHeader(http.StatusTemporaryRedirect)
 }
 
 // Method to handle method not allowed.
 func (h *Handler) HandleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	h.respondWithProblem(w, r, http.StatusBadRequest, ProblemMethodNotAllowed, "The method "+r.Method+" is not allowed on "+r.URL.Path+".")
 }
 
 // Method to handle getting user's URLs.
 func (h *Handler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromCtx(r)
 	if errors.Is(err, ErrorMissingUserIDCtx) {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMissingUserID(w, r)
 		return
 	}
 	slog.Debug("urls for user requested", slog.String("user", userID))
@@ -288,7 +703,7 @@ func (h *Handler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HandleGetServiceStats(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromCtx(r)
 	if errors.Is(err, ErrorMissingUserIDCtx) {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMissingUserID(w, r)
 		return
 	}
 	slog.Debug("service stats requested", slog.String("user", userID))
@@ -303,20 +718,13 @@ func (h *Handler) HandleGetServiceStats(w http.ResponseWriter, r *http.Request)
 	h.respondWithJson(w, http.StatusOK, resp)
 }
 
-// Method to handle database ping.
-func (h *Handler) HandleDatabasePing(w http.ResponseWriter, r *http.Request) {
-	if err := h.repo.Ping(r.Context()); err != nil {
-		slog.Error("storage ping", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-	}
-	w.WriteHeader(http.StatusOK)
-}
-
 // Method to handle batch shortening URL requests with JSON payload.
+// Valid items are stored even when others are rejected: the response lists the outcome of every item
+// in request order, with 201 when all items were created and 207 otherwise.
 func (h *Handler) HandleBatchJSONShortenURL(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromCtx(r)
 	if errors.Is(err, ErrorMissingUserIDCtx) {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMissingUserID(w, r)
 		return
 	}
 
@@ -324,52 +732,105 @@ func (h *Handler) HandleBatchJSONShortenURL(w http.ResponseWriter, r *http.Reque
 	var batchShortenReq []BatchShortenURLRequest
 	if err := json.NewDecoder(r.Body).Decode(&batchShortenReq); err != nil {
 		slog.Error("unmarshaling request data", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMalformedBody(w, r, err)
 		return
 	}
 
 	if len(batchShortenReq) == 0 {
 		slog.Debug("empty batch request slice", slog.String("user", userID))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemEmptyBatch, "The batch contains no URLs.")
 		return
 	}
 
-	var batchShortenResp []BatchShortenURLResponse
+	if !h.allowURLCreation(w, r, userID) {
+		return
+	}
+
+	batchShortenResp := make([]BatchShortenURLResponse, len(batchShortenReq))
 	var batchURLs []repository.URL
-	for _, u := range batchShortenReq {
+	for i, u := range batchShortenReq {
+		batchShortenResp[i] = BatchShortenURLResponse{CorrelationID: u.CorrelationID}
 		if u.OriginalURL == "" {
-			slog.Debug("url parameter is missing", slog.String("user", userID))
-			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			slog.Debug("url parameter is missing", slog.String("user", userID), slog.String("correlation id", u.CorrelationID))
+			batchShortenResp[i].reject(ProblemMissingURL, "", "The URL to shorten is missing.")
+			continue
+		}
+
+		normalizedURL, problem, err := h.checkOriginalURL(u.OriginalURL, "original_url")
+		if err != nil {
+			h.respondInternalError(w, r)
 			return
 		}
+		if problem != nil {
+			batchShortenResp[i].reject(problem.Code, problem.Errors[0].Code, problem.Errors[0].Message)
+			continue
+		}
 
 		slug := generateSlug()
-		url := h.baseURL + "/" + slug
 		slog.Debug(
 			"slug generation",
-			slog.String("original url", u.OriginalURL),
+			slog.String("original url", normalizedURL),
 			slog.String("slug", slug),
 		)
-		URL := repository.NewURL(slug, u.OriginalURL, userID, false)
+		URL := repository.NewURL(slug, normalizedURL, userID, false)
+		if err := u.LinkActivation.apply(URL); err != nil {
+			slog.Debug("invalid link activation", slog.String("user", userID), slog.Any("error", err))
+			batchShortenResp[i].reject(ProblemInvalidActivation, "", "The activation window or schedule is invalid: "+err.Error()+".")
+			continue
+		}
 		batchURLs = append(batchURLs, *URL)
-		batchShortenResp = append(batchShortenResp, BatchShortenURLResponse{CorrelationID: u.CorrelationID, ShortURL: url})
+		batchShortenResp[i].Status = BatchItemCreated
+		batchShortenResp[i].ShortURL = h.baseURL + "/" + slug
 	}
 
-	err = h.repo.AddMany(r.Context(), batchURLs)
+	existing, err := h.repo.AddManyKeepExisting(r.Context(), batchURLs)
 	if err != nil {
 		slog.Error("urls batch creation", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.respondInternalError(w, r)
 		return
 	}
 
-	h.respondWithJson(w, http.StatusCreated, batchShortenResp)
+	// Items whose URL was already stored, by another user or earlier in the batch, point to the stored slug.
+	created := make([]repository.URL, 0, len(batchURLs))
+	status := http.StatusCreated
+	for i, j := 0, 0; i < len(batchShortenResp); i++ {
+		if batchShortenResp[i].Status != BatchItemCreated {
+			status = http.StatusMultiStatus
+			continue
+		}
+		url := batchURLs[j]
+		j++
+		if existingURL, ok := existing[url.OriginalURL]; ok && existingURL.Slug != url.Slug {
+			batchShortenResp[i].Status = BatchItemExisting
+			batchShortenResp[i].ShortURL = h.baseURL + "/" + existingURL.Slug
+			status = http.StatusMultiStatus
+			continue
+		}
+		created = append(created, url)
+	}
+
+	if len(created) > 0 {
+		slugs := make([]string, 0, len(created))
+		for _, u := range created {
+			slugs = append(slugs, u.Slug)
+		}
+		h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionBatchShorten, Slugs: slugs})
+		h.notifyLinksCreated(r.Context(), created...)
+	}
+	h.respondWithJson(w, status, batchShortenResp)
+}
+
+// Method to mark a batch item as rejected with a problem code, an optional field-level reason and a message.
+func (resp *BatchShortenURLResponse) reject(code string, reason string, message string) {
+	resp.Status = BatchItemInvalid
+	resp.Error = &BatchItemError{Code: code, Reason: reason, Message: message}
 }
 
 // Method to handle deleting user's URLs.
 func (h *Handler) HandleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromCtx(r)
 	if errors.Is(err, ErrorMissingUserIDCtx) {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMissingUserID(w, r)
 		return
 	}
 
@@ -377,7 +838,7 @@ func (h *Handler) HandleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(&slugs); err != nil {
 		slog.Error("unmarshalling request data", slog.Any("error", err))
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		h.respondMalformedBody(w, r, err)
 		return
 	}
 	slog.Debug(
@@ -386,15 +847,7 @@ func (h *Handler) HandleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 		slog.Any("slugs", slugs),
 	)
 
-	if len(slugs) == 0 {
-		w.WriteHeader(http.StatusAccepted)
-		return
-	}
-
-	for _, s := range slugs {
-		h.backgroundDeleter.DeleteChan <- repository.DeleteRequest{Slug: s, UserID: userID}
-	}
-	w.WriteHeader(http.StatusAccepted)
+	h.queueDeletionJob(w, r, slugs, userID, "")
 	slog.Debug(
 		"urls deletion request accepted",
 		slog.String("user", userID),
@@ -402,6 +855,262 @@ func (h *Handler) HandleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
+// Method to handle exchanging the current cookie identity for a bearer token.
+func (h *Handler) HandleIssueToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	if method := middlewares.AuthMethodFromContext(r.Context()); method != middlewares.AuthMethodCookie {
+		slog.Info("token exchange requires a cookie identity", slog.String("user", userID), slog.String("auth method", method))
+		h.respondWithProblem(w, r, http.StatusForbidden, middlewares.ProblemForbidden, "Bearer tokens are only issued for cookie sessions.")
+		return
+	}
+
+	token, expiresAt, err := h.tokens.Issue(userID)
+	if err != nil {
+		slog.Error("issuing bearer token", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Debug("bearer token issued", slog.String("user", userID), slog.Time("expires at", expiresAt))
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondWithJson(w, http.StatusOK, TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// Method to handle registering an account. An anonymous current identity becomes the account.
+func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var registerReq RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&registerReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	user, err := h.accounts.Register(r.Context(), userID, registerReq.Email, registerReq.Username, registerReq.Password)
+	if err != nil {
+		var validationErr *accounts.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			slog.Debug("registration rejected", slog.String("field", validationErr.Field))
+			h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidAccount, "The account details are invalid.", middlewares.FieldError{
+				Field:   validationErr.Field,
+				Code:    "invalid",
+				Message: validationErr.Message,
+			})
+		case errors.Is(err, accounts.ErrAccountExists):
+			slog.Debug("registration conflict", slog.String("user", userID))
+			h.respondWithProblem(w, r, http.StatusConflict, ProblemAccountExists, "The email or username is already registered.")
+		default:
+			slog.Error("registering account", slog.String("user", userID), slog.Any("error", err))
+			h.respondInternalError(w, r)
+		}
+		return
+	}
+
+	if err := h.startSession(w, r, user.ID); err != nil {
+		slog.Error("starting session", slog.String("user", user.ID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	h.respondWithJson(w, http.StatusCreated, AccountResponse{UserID: user.ID, Email: user.Email, Username: user.Username})
+}
+
+// Method to handle logging in to an account. Links of an anonymous current identity are merged into the account.
+func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var loginReq LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	user, err := h.accounts.Login(r.Context(), userID, loginReq.Login, loginReq.Password)
+	if err != nil {
+		if errors.Is(err, accounts.ErrInvalidCredentials) {
+			slog.Info("login rejected", slog.String("user", userID))
+			h.respondWithProblem(w, r, http.StatusUnauthorized, ProblemInvalidCredentials, "The login or password is incorrect.")
+			return
+		}
+		slog.Error("logging in", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+
+	if err := h.startSession(w, r, user.ID); err != nil {
+		slog.Error("starting session", slog.String("user", user.ID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	h.respondWithJson(w, http.StatusOK, AccountResponse{UserID: user.ID, Email: user.Email, Username: user.Username})
+}
+
+// Method to handle logging out: the current session is revoked and its cookie cleared.
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	session, ok := middlewares.SessionFromContext(r.Context())
+	if !ok {
+		h.sessions.Clear(w)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.sessions.Revoke(r.Context(), session); err != nil {
+		slog.Error("revoking session", slog.String("user", session.UserID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	h.sessions.Clear(w)
+	slog.Debug("session revoked", slog.String("user", session.UserID), slog.String("session", session.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle creating an API key for the current user.
+func (h *Handler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var createReq CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+	if len(createReq.Label) > maxAPIKeyLabelLen {
+		slog.Debug("API key label too long", slog.String("user", userID))
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidAPIKey, "The API key label is too long.", middlewares.FieldError{Field: "label", Code: "too_long", Message: "The label is longer than " + strconv.Itoa(maxAPIKeyLabelLen) + " characters."})
+		return
+	}
+	for _, scope := range createReq.Scopes {
+		if !repository.IsValidScope(scope) {
+			slog.Debug("unknown API key scope", slog.String("user", userID), slog.String("scope", scope))
+			h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidAPIKey, "The API key scope "+scope+" is unknown.", middlewares.FieldError{Field: "scopes", Code: "unknown_scope", Message: "Unknown scope " + scope + "."})
+			return
+		}
+	}
+
+	rawKey, prefix, err := middlewares.GenerateAPIKey()
+	if err != nil {
+		slog.Error("generating API key", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	key := repository.APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Label:     createReq.Label,
+		Prefix:    prefix,
+		Hash:      repository.HashAPIKey(rawKey),
+		Scopes:    createReq.Scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.repo.AddAPIKey(r.Context(), key); err != nil {
+		slog.Error("saving API key", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Debug("API key created", slog.String("user", userID), slog.String("key", key.ID))
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondWithJson(w, http.StatusCreated, CreateAPIKeyResponse{APIKeyResponse: newAPIKeyResponse(key), Key: rawKey})
+}
+
+// Method to handle listing the current user's API keys.
+func (h *Handler) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	keys, err := h.repo.GetAPIKeysByUser(r.Context(), userID)
+	if err != nil {
+		slog.Error("listing API keys", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		resp = append(resp, newAPIKeyResponse(k))
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle relabeling one of the current user's API keys.
+func (h *Handler) HandleUpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var updateReq UpdateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+	if len(updateReq.Label) > maxAPIKeyLabelLen {
+		slog.Debug("API key label too long", slog.String("user", userID))
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidAPIKey, "The API key label is too long.", middlewares.FieldError{Field: "label", Code: "too_long", Message: "The label is longer than " + strconv.Itoa(maxAPIKeyLabelLen) + " characters."})
+		return
+	}
+
+	keyID := chi.URLParam(r, "id")
+	if err := h.repo.UpdateAPIKeyLabel(r.Context(), userID, keyID, updateReq.Label); err != nil {
+		h.respondAPIKeyError(w, r, userID, keyID, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle revoking one of the current user's API keys.
+func (h *Handler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	keyID := chi.URLParam(r, "id")
+	if err := h.repo.RevokeAPIKey(r.Context(), userID, keyID, time.Now().UTC()); err != nil {
+		h.respondAPIKeyError(w, r, userID, keyID, err)
+		return
+	}
+	slog.Debug("API key revoked", slog.String("user", userID), slog.String("key", keyID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Method to extract user ID from request context.
 func (h *Handler) getUserIDFromCtx(r *http.Request) (string, error) {
 	userID, ok := r.Context().Value(middlewares.UserIDContextKey).(string)
@@ -412,6 +1121,139 @@ func (h *Handler) getUserIDFromCtx(r *http.Request) (string, error) {
 	return userID, nil
 }
 
+// Method to validate and canonicalize a destination URL and check it against the destination policy.
+// It responds with a problem naming the field with a 400 or 403 and returns false if the URL is rejected.
+func (h *Handler) validateOriginalURL(w http.ResponseWriter, r *http.Request, rawURL string, field string) (string, bool) {
+	normalizedURL, problem, err := h.checkOriginalURL(rawURL, field)
+	if err != nil {
+		h.respondInternalError(w, r)
+		return "", false
+	}
+	if problem != nil {
+		middlewares.WriteProblem(w, r, problem)
+		return "", false
+	}
+	return normalizedURL, true
+}
+
+// Method to validate and canonicalize a destination URL and check it against the destination policy.
+// A rejected URL is described by a problem naming the field; unexpected failures are returned as errors.
+func (h *Handler) checkOriginalURL(rawURL string, field string) (string, *middlewares.Problem, error) {
+	normalizedURL, err := h.validator.Normalize(rawURL)
+	if err != nil {
+		var validationErr *validator.ValidationError
+		if !errors.As(err, &validationErr) {
+			slog.Error("validating original url", slog.Any("error", err))
+			return "", nil, err
+		}
+		slog.Debug("original url rejected", slog.String("reason", validationErr.Reason))
+		return "", middlewares.NewProblem(http.StatusBadRequest, ProblemInvalidURL, "The URL to shorten is invalid.", middlewares.FieldError{
+			Field:   field,
+			Code:    validationErr.Reason,
+			Message: validationErr.Message,
+		}), nil
+	}
+
+	if err := h.checkDestinationPolicy(normalizedURL); err != nil {
+		var violation *policy.Violation
+		if !errors.As(err, &violation) {
+			slog.Error("checking destination policy", slog.Any("error", err))
+			return "", nil, err
+		}
+		slog.Info(
+			"original url denied by policy",
+			slog.String("original url", normalizedURL),
+			slog.String("reason", violation.Reason),
+			slog.String("rule", violation.Rule),
+		)
+		return "", middlewares.NewProblem(http.StatusForbidden, ProblemDestinationDenied, "The destination of the URL is denied by policy.", middlewares.FieldError{
+			Field:   field,
+			Code:    violation.Reason,
+			Message: violation.Error(),
+		}), nil
+	}
+
+	return normalizedURL, nil, nil
+}
+
+// Method to switch the client to a new session for the user, revoking the session of the request, if any.
+// Starting a fresh session on sign-in prevents session fixation.
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, userID string) error {
+	if session, ok := middlewares.SessionFromContext(r.Context()); ok {
+		if err := h.sessions.Revoke(r.Context(), session); err != nil {
+			return err
+		}
+	}
+	h.sessions.Start(w, userID)
+	return nil
+}
+
+// Method to reject requests authenticated with an API key, so that keys cannot manage keys or accounts.
+func (h *Handler) denyAPIKeyAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if middlewares.AuthMethodFromContext(r.Context()) == middlewares.AuthMethodAPIKey {
+			slog.Info("endpoint requires a cookie or bearer identity")
+			h.respondWithProblem(w, r, http.StatusForbidden, middlewares.ProblemForbidden, "The endpoint does not accept API keys.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Method to respond to a failed API key update or revocation.
+func (h *Handler) respondAPIKeyError(w http.ResponseWriter, r *http.Request, userID string, keyID string, err error) {
+	if errors.Is(err, repository.ErrAPIKeyNotExist) {
+		slog.Debug("API key not found", slog.String("user", userID), slog.String("key", keyID))
+		h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "The API key does not exist.")
+		return
+	}
+	slog.Error("updating API key", slog.String("user", userID), slog.String("key", keyID), slog.Any("error", err))
+	h.respondInternalError(w, r)
+}
+
+// Method to check a destination URL against the destination policy, if one is configured.
+func (h *Handler) checkDestinationPolicy(destination string) error {
+	if h.policy == nil {
+		return nil
+	}
+	return h.policy.Check(destination)
+}
+
+// Method to respond with a problem details error.
+func (h *Handler) respondWithProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string, fieldErrors ...middlewares.FieldError) {
+	middlewares.WriteProblem(w, r, middlewares.NewProblem(status, code, detail, fieldErrors...))
+}
+
+// Method to respond to a request without a user ID in the context.
+func (h *Handler) respondMissingUserID(w http.ResponseWriter, r *http.Request) {
+	h.respondWithProblem(w, r, http.StatusBadRequest, middlewares.ProblemMissingUserID, "The request carries no user identity.")
+}
+
+// Method to respond to a request body that cannot be decoded.
+func (h *Handler) respondMalformedBody(w http.ResponseWriter, r *http.Request, err error) {
+	h.respondWithProblem(w, r, http.StatusBadRequest, middlewares.ProblemMalformedBody, "The request body is malformed: "+err.Error()+".")
+}
+
+// Method to respond to invalid query parameters.
+func (h *Handler) respondInvalidQuery(w http.ResponseWriter, r *http.Request, err error) {
+	h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, "The query parameters are invalid: "+err.Error()+".")
+}
+
+// Method to respond to an invalid activation window or schedule of a URL.
+func (h *Handler) respondInvalidActivation(w http.ResponseWriter, r *http.Request, err error) {
+	h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidActivation, "The activation window or schedule is invalid: "+err.Error()+".")
+}
+
+// Method to respond to an unexpected failure. The details are logged, not returned.
+func (h *Handler) respondInternalError(w http.ResponseWriter, r *http.Request) {
+	h.respondWithProblem(w, r, http.StatusInternalServerError, middlewares.ProblemInternal, "The request could not be processed. Report the request ID if the problem persists.")
+}
+
+// Function to describe a missing required field.
+func missingField(field string) middlewares.FieldError {
+	return middlewares.FieldError{Field: field, Code: "missing", Message: "The field is required."}
+}
+
 // Method to respond with a plain text.
 func (h *Handler) respondWithPlainText(w http.ResponseWriter, response string, statusCode int) {
 	w.Header().Set("Content-Type", PlainTextContentType)
@@ -421,6 +1263,19 @@ func (h *Handler) respondWithPlainText(w http.ResponseWriter, response string, s
 	}
 }
 
+// Method to respond to a request for a URL outside its activation window.
+func (h *Handler) respondInactiveLink(w http.ResponseWriter, r *http.Request) {
+	if len(h.inactiveLinkPage) == 0 {
+		h.respondWithProblem(w, r, h.inactiveLinkStatus, ProblemInactiveLink, "The URL is outside its activation window.")
+		return
+	}
+	w.Header().Set("Content-Type", HTMLContentType)
+	w.WriteHeader(h.inactiveLinkStatus)
+	if _, err := w.Write(h.inactiveLinkPage); err != nil {
+		slog.Error("writing inactive link page", slog.Any("error", err))
+	}
+}
+
 // Method to respond with JSON.
 func (h *Handler) respondWithJson(w http.ResponseWriter, statusCode int, data interface{}) {
 	respJSON, err := json.Marshal(data)
@@ -4,17 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/gennadis/shorturl/internal/app/accounts"
 	"github.com/gennadis/shorturl/internal/app/deleter"
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/gennadis/shorturl/internal/app/lifecycle"
+	"github.com/gennadis/shorturl/internal/app/metrics"
 	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/moderation"
+	"github.com/gennadis/shorturl/internal/app/oidc"
+	"github.com/gennadis/shorturl/internal/app/oidc/oidctest"
+	"github.com/gennadis/shorturl/internal/app/policy"
 	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/gennadis/shorturl/internal/app/validator"
+	"github.com/gennadis/shorturl/internal/app/webhooks"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -53,14 +69,14 @@ func TestHandleShortenURL(t *testing.T) {
 			name:                "MissingURLParameter",
 			requestBody:         "",
 			expectedStatus:      http.StatusBadRequest,
-			expectedBody:        http.StatusText(http.StatusBadRequest),
+			expectedBody:        "The request body must be the URL to shorten.",
 			expectedContentType: PlainTextContentType,
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			memStorage := repository.NewMemoryRepository()
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -70,7 +86,7 @@ func TestHandleShortenURL(t *testing.T) {
 
 			recorder := httptest.NewRecorder()
 			ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
-			handler.HandleShortenURL(recorder, req.WithContext(ctx))
+			handler.Router.ServeHTTP(recorder, req.WithContext(ctx))
 
 			assert.Equal(t, tc.expectedStatus, recorder.Code)
 			assert.Contains(t, recorder.Body.String(), tc.expectedBody)
@@ -84,6 +100,30 @@ func TestHandleShortenURL(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("MissingURLParameterAcceptingJSON", func(t *testing.T) {
+		memStorage := repository.NewMemoryRepository()
+		backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+		handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("Accept", middlewares.ProblemContentType)
+		req.Header.Set(middlewares.RequestIDHeader, "test-request")
+		recorder := httptest.NewRecorder()
+		handler.Router.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, middlewares.ProblemContentType, recorder.Header().Get("Content-Type"))
+		var response middlewares.Problem
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		assert.Equal(t, ProblemMissingURL, response.Code)
+		assert.Equal(t, "test-request", response.RequestID)
+		assert.Equal(t, "/", response.Instance)
+		if assert.Len(t, response.Errors, 1) {
+			assert.Equal(t, "url", response.Errors[0].Field)
+		}
+	})
 }
 
 func TestHandleJSONShortenURL(t *testing.T) {
@@ -104,35 +144,35 @@ func TestHandleJSONShortenURL(t *testing.T) {
 			name:                "EmptyBodyRequest",
 			requestBody:         `{}`,
 			expectedStatus:      http.StatusBadRequest,
-			expectedBody:        http.StatusText(http.StatusBadRequest),
-			expectedContentType: PlainTextContentType,
+			expectedBody:        `"code":"` + ProblemMissingURL + `"`,
+			expectedContentType: middlewares.ProblemContentType,
 		},
 		{
 			name:                "UnmarshalRequestBodyError",
 			requestBody:         "{invalid_json}",
 			expectedStatus:      http.StatusBadRequest,
-			expectedBody:        http.StatusText(http.StatusBadRequest),
-			expectedContentType: PlainTextContentType,
+			expectedBody:        `"code":"` + middlewares.ProblemMalformedBody + `"`,
+			expectedContentType: middlewares.ProblemContentType,
 		},
 		{
 			name:                "MissingURLParameter",
 			requestBody:         `{"test": "test"}`,
 			expectedStatus:      http.StatusBadRequest,
-			expectedBody:        http.StatusText(http.StatusBadRequest),
-			expectedContentType: PlainTextContentType,
+			expectedBody:        `"code":"` + ProblemMissingURL + `"`,
+			expectedContentType: middlewares.ProblemContentType,
 		},
 		{
 			name:                "EmptyBodyRequest",
 			requestBody:         "",
 			expectedStatus:      http.StatusBadRequest,
-			expectedBody:        http.StatusText(http.StatusBadRequest),
-			expectedContentType: PlainTextContentType,
+			expectedBody:        `"code":"` + middlewares.ProblemMalformedBody + `"`,
+			expectedContentType: middlewares.ProblemContentType,
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			memStorage := repository.NewMemoryRepository()
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -185,7 +225,7 @@ func TestHandleExpandURL(t *testing.T) {
 			if err := memStorage.Add(ctx, *url); err != nil {
 				t.Fatalf("memstore write error")
 			}
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -230,7 +270,7 @@ func TestDefaultHandler(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			memStorage := repository.NewMemoryRepository()
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -242,8 +282,10 @@ func TestDefaultHandler(t *testing.T) {
 			handler.HandleMethodNotAllowed(recorder, req.WithContext(ctx))
 
 			assert.Equal(t, tc.expectedStatus, recorder.Code)
-			assert.Equal(t, http.StatusText(http.StatusBadRequest), strings.TrimSpace(recorder.Body.String()))
-			assert.Equal(t, PlainTextContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, middlewares.ProblemContentType, recorder.Header().Get("Content-Type"))
+			var response middlewares.Problem
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, ProblemMethodNotAllowed, response.Code)
 		})
 	}
 }
@@ -278,7 +320,7 @@ func TestHandleGetUserURLs(t *testing.T) {
 					t.Fatalf("memstore write error")
 				}
 			}
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -330,7 +372,7 @@ func TestHandleGetServiceStats(t *testing.T) {
 					t.Fatalf("memstore write error")
 				}
 			}
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -362,7 +404,7 @@ func TestHandleDatabasePing(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			backgroundDeleter := deleter.NewBackgroundDeleter(tc.storage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(tc.storage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(tc.storage, backgroundDeleter, logger, baseURL)
 
@@ -378,6 +420,128 @@ func TestHandleDatabasePing(t *testing.T) {
 	}
 }
 
+func TestHealthProbes(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{QueueSize: 1})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	supervisor := lifecycle.NewSupervisor(time.Second)
+	var cacheErr error
+	cacheCheck := HealthCheck{Name: "cache", Check: func(context.Context) (string, error) { return "", cacheErr }}
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithLifecycle(supervisor), WithHealthChecks(cacheCheck))
+
+	probe := func(target string) (*httptest.ResponseRecorder, HealthResponse) {
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		var resp HealthResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec, resp
+	}
+	checkStatuses := func(resp HealthResponse) map[string]string {
+		statuses := map[string]string{}
+		for _, check := range resp.Checks {
+			statuses[check.Name] = check.Status
+		}
+		return statuses
+	}
+
+	// The process is alive, and probes do not start sessions.
+	rec, resp := probe("/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, HealthStatusPass, resp.Status)
+	assert.Empty(t, rec.Result().Cookies())
+	pingRec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(pingRec, httptest.NewRequest("GET", "/ping", nil))
+	assert.Equal(t, http.StatusOK, pingRec.Code)
+	assert.Empty(t, pingRec.Result().Cookies())
+
+	// The application is unready until every component started.
+	rec, resp = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, map[string]string{"lifecycle": HealthStatusFail, "storage": HealthStatusPass, "deleter": HealthStatusPass, "cache": HealthStatusPass}, checkStatuses(resp))
+	assert.Empty(t, rec.Result().Cookies())
+
+	// It goes unready again on shutdown, before the components are stopped.
+	var stoppingCode int
+	assert.NoError(t, supervisor.Register(lifecycle.Component{Name: "http", Stop: func(context.Context) error {
+		stoppingRec, _ := probe("/readyz")
+		stoppingCode = stoppingRec.Code
+		return nil
+	}}))
+	ready := make(chan struct{})
+	supervisor.OnStateChange(func(state string) {
+		if state == lifecycle.StateReady {
+			close(ready)
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- supervisor.Run(ctx) }()
+	<-ready
+
+	rec, resp = probe("/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, HealthStatusPass, resp.Status)
+	if assert.Len(t, resp.Checks, 4) {
+		assert.Equal(t, "0 of 1 delete requests queued", resp.Checks[2].Detail)
+	}
+
+	// A full deletion queue or a failing check makes the application unready.
+	assert.NoError(t, backgroundDeleter.Enqueue([]repository.DeleteRequest{{Slug: "a", UserID: userID}}))
+	cacheErr = errors.New("cache unavailable")
+	rec, resp = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, map[string]string{"lifecycle": HealthStatusPass, "storage": HealthStatusPass, "deleter": HealthStatusFail, "cache": HealthStatusFail}, checkStatuses(resp))
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, http.StatusServiceUnavailable, stoppingCode)
+}
+
+func TestMetrics(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	appMetrics := metrics.New()
+	repo := repository.NewInstrumentedRepository(memStorage, appMetrics.ObserveRepositoryCall)
+	assert.NoError(t, repo.Add(context.Background(), *repository.NewURL("testSlug", "https://example.com", userID, false)))
+	backgroundDeleter := deleter.NewBackgroundDeleter(repo, deleter.Config{QueueSize: 10})
+	appMetrics.RegisterDeleterQueue(backgroundDeleter)
+	appMetrics.RegisterLinkCounts(repo)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	// Without a separate listener the metrics are served at /metrics.
+	handler := NewHandler(repo, backgroundDeleter, logger, baseURL, WithMetrics(appMetrics, true))
+	for _, target := range []string{"/testSlug", "/missing"} {
+		handler.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	rec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Empty(t, rec.Result().Cookies())
+
+	body := rec.Body.String()
+	for _, expected := range []string{
+		`shorturl_http_requests_total{method="GET",route="/{slug}",status="307"} 1`,
+		`shorturl_http_requests_total{method="GET",route="/{slug}",status="400"} 1`,
+		`shorturl_http_request_duration_seconds_count{method="GET",route="/{slug}",status="307"} 1`,
+		`shorturl_repository_call_duration_seconds_count{method="GetBySlug"} 2`,
+		`shorturl_deleter_queue_length 0`,
+		`shorturl_deleter_queue_capacity 10`,
+		`shorturl_links 1`,
+		`shorturl_users 1`,
+		`go_goroutines`,
+	} {
+		assert.Contains(t, body, expected)
+	}
+	assert.NotContains(t, body, "shorturl_repository_errors_total", "Missing links are not storage failures")
+	assert.NotContains(t, body, `route="/metrics"`, "Scrapes are not counted")
+
+	// With a separate listener the metrics are only recorded.
+	handler = NewHandler(repo, backgroundDeleter, logger, baseURL, WithMetrics(appMetrics, false))
+	rec = httptest.NewRecorder()
+	handler.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.NotEqual(t, http.StatusOK, rec.Code)
+}
+
 func TestHandleBatchJSONShortenURL(t *testing.T) {
 	testCases := []struct {
 		name                string
@@ -395,19 +559,19 @@ func TestHandleBatchJSONShortenURL(t *testing.T) {
 			name:                "EmptyRequestBody",
 			requestBody:         `[]`,
 			expectedStatus:      http.StatusBadRequest,
-			expectedContentType: PlainTextContentType,
+			expectedContentType: middlewares.ProblemContentType,
 		},
 		{
 			name:                "InvalidRequestBody",
-			requestBody:         `[{"correlation_id": "1"}]`,
+			requestBody:         `{"correlation_id": "1"}`,
 			expectedStatus:      http.StatusBadRequest,
-			expectedContentType: PlainTextContentType,
+			expectedContentType: middlewares.ProblemContentType,
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			memStorage := repository.NewMemoryRepository()
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -427,7 +591,7 @@ func TestHandleBatchJSONShortenURL(t *testing.T) {
 
 func TestHandleShortenURL_URLAlreadyExists(t *testing.T) {
 	memStorage := repository.NewMemoryRepository()
-	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -455,7 +619,7 @@ func TestHandleShortenURL_URLAlreadyExists(t *testing.T) {
 
 func TestHandleJSONShortenURL_URLAlreadyExists(t *testing.T) {
 	memStorage := repository.NewMemoryRepository()
-	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -515,7 +679,7 @@ func TestHandleDeleteUserURLs(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			memStorage := repository.NewMemoryRepository()
-			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage)
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
 
@@ -539,3 +703,1246 @@ func TestHandleDeleteUserURLs(t *testing.T) {
 		})
 	}
 }
+
+func TestDeletionJobs(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	if err := memStorage.Add(context.Background(), *repository.NewURL("otherSlug", "https://example.com/other", "otherUser", false)); err != nil {
+		t.Fatalf("memstore write error")
+	}
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+	send := func(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	getJob := func(location string, cookie *http.Cookie) DeletionJobResponse {
+		rec := send("GET", location, "", cookie)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var job DeletionJobResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+		return job
+	}
+
+	shortenRec := send("POST", "/api/shorten", `{"url": "https://example.com/mine"}`, nil)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	cookie := shortenRec.Result().Cookies()[0]
+	var shortened ShortenURLResponse
+	assert.NoError(t, json.Unmarshal(shortenRec.Body.Bytes(), &shortened))
+	slug := strings.TrimPrefix(shortened.Result, baseURL+"/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := jobs.NewScheduler()
+	assert.NoError(t, backgroundDeleter.Register(ctx, scheduler))
+	wg := scheduler.Run(ctx)
+
+	// Repeated slugs are deleted once; slugs of other users are tracked too.
+	deleteRec := send("DELETE", "/api/user/urls", `["`+slug+`", "otherSlug", "`+slug+`"]`, cookie)
+	assert.Equal(t, http.StatusAccepted, deleteRec.Code)
+	location := deleteRec.Header().Get("Location")
+	var queued DeletionJobResponse
+	assert.NoError(t, json.Unmarshal(deleteRec.Body.Bytes(), &queued))
+	assert.Equal(t, "/api/user/jobs/"+queued.ID, location)
+	assert.Equal(t, repository.DeletionStatusPending, queued.Status)
+	assert.Len(t, queued.Items, 2)
+
+	// Jobs are only visible to the user who requested them.
+	assert.Equal(t, http.StatusNotFound, send("GET", location, "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/user/jobs/missing", "", cookie).Code)
+
+	// The queued deletions are flushed on shutdown.
+	cancel()
+	wg.Wait()
+	assert.Equal(t, 0, backgroundDeleter.Queued())
+
+	job := getJob(location, cookie)
+	assert.Equal(t, repository.DeletionStatusCompleted, job.Status)
+	if assert.Len(t, job.Items, 2) {
+		assert.Equal(t, DeletionJobItemResponse{Slug: slug, Status: repository.DeletionStatusCompleted, UpdatedAt: job.Items[0].UpdatedAt}, job.Items[0])
+		assert.Equal(t, DeletionJobItemResponse{Slug: "otherSlug", Status: repository.DeletionStatusFailed, Reason: repository.DeletionReasonNotFound, UpdatedAt: job.Items[1].UpdatedAt}, job.Items[1])
+	}
+
+	// An empty request completes immediately.
+	emptyRec := send("DELETE", "/api/user/urls", `[]`, cookie)
+	assert.Equal(t, http.StatusAccepted, emptyRec.Code)
+	assert.Equal(t, repository.DeletionStatusCompleted, getJob(emptyRec.Header().Get("Location"), cookie).Status)
+}
+
+func TestDeletionJobs_Backpressure(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{QueueSize: 1, FlushInterval: 2 * time.Second})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+	testCases := []struct {
+		name               string
+		body               string
+		expectedStatus     int
+		expectedCode       string
+		expectedRetryAfter string
+	}{
+		{name: "Queued", body: `["a"]`, expectedStatus: http.StatusAccepted},
+		{name: "Queue full", body: `["b"]`, expectedStatus: http.StatusServiceUnavailable, expectedCode: ProblemDeletionQueueFull, expectedRetryAfter: "2"},
+		{name: "More slugs than the queue holds", body: `["c", "d"]`, expectedStatus: http.StatusBadRequest, expectedCode: ProblemTooManySlugs},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", "/api/user/urls", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			handler.Router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedRetryAfter, rec.Header().Get("Retry-After"))
+			if tc.expectedCode != "" {
+				var problem middlewares.Problem
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+				assert.Equal(t, tc.expectedCode, problem.Code)
+			}
+		})
+	}
+	assert.Equal(t, 1, backgroundDeleter.Queued())
+}
+
+func TestHandleBatchJSONShortenURL_PerItem(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	if err := memStorage.Add(context.Background(), *repository.NewURL("existingSlug", "https://example.com/existing", "otherUser", false)); err != nil {
+		t.Fatalf("memstore write error")
+	}
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+	send := func(body string) ([]BatchShortenURLResponse, int) {
+		req := httptest.NewRequest("POST", "/api/shorten/batch", strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
+		handler.HandleBatchJSONShortenURL(recorder, req.WithContext(ctx))
+		var resp []BatchShortenURLResponse
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		return resp, recorder.Code
+	}
+
+	resp, status := send(`[
+		{"correlation_id": "new", "original_url": "https://example.com/new"},
+		{"correlation_id": "existing", "original_url": "https://example.com/existing"},
+		{"correlation_id": "empty", "original_url": ""},
+		{"correlation_id": "garbage", "original_url": "not a url"},
+		{"correlation_id": "repeated", "original_url": "https://example.com/new"}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	if !assert.Len(t, resp, 5) {
+		return
+	}
+	assert.Equal(t, "new", resp[0].CorrelationID)
+	assert.Equal(t, BatchItemCreated, resp[0].Status)
+	assert.True(t, strings.HasPrefix(resp[0].ShortURL, baseURL+"/"))
+	assert.Equal(t, BatchItemExisting, resp[1].Status)
+	assert.Equal(t, baseURL+"/existingSlug", resp[1].ShortURL)
+	assert.Equal(t, BatchItemInvalid, resp[2].Status)
+	assert.Equal(t, ProblemMissingURL, resp[2].Error.Code)
+	assert.Empty(t, resp[2].ShortURL)
+	assert.Equal(t, BatchItemInvalid, resp[3].Status)
+	assert.Equal(t, ProblemInvalidURL, resp[3].Error.Code)
+	assert.Equal(t, validator.ReasonInvalidCharacter, resp[3].Error.Reason)
+	assert.Equal(t, BatchItemExisting, resp[4].Status)
+	assert.Equal(t, resp[0].ShortURL, resp[4].ShortURL)
+
+	// The valid item was committed despite the rejected ones.
+	stored, err := memStorage.GetBySlug(context.Background(), strings.TrimPrefix(resp[0].ShortURL, baseURL+"/"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/new", stored.OriginalURL)
+
+	resp, status = send(`[{"correlation_id": "1", "original_url": "https://example.com/one"}, {"correlation_id": "2", "original_url": "https://example.com/two"}]`)
+	assert.Equal(t, http.StatusCreated, status)
+	for _, item := range resp {
+		assert.Equal(t, BatchItemCreated, item.Status)
+		assert.Nil(t, item.Error)
+	}
+}
+
+func TestHandleExpandURL_InactiveLink(t *testing.T) {
+	ctx := context.Background()
+	future := time.Now().Add(time.Hour)
+	testCases := []struct {
+		name                string
+		opts                []HandlerOption
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "DefaultResponse",
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: middlewares.ProblemContentType,
+			expectedBody:        `"code":"` + ProblemInactiveLink + `"`,
+		},
+		{
+			name:                "ForbiddenResponse",
+			opts:                []HandlerOption{WithInactiveLinkResponse(http.StatusForbidden, nil)},
+			expectedStatus:      http.StatusForbidden,
+			expectedContentType: middlewares.ProblemContentType,
+			expectedBody:        `"code":"` + ProblemInactiveLink + `"`,
+		},
+		{
+			name:                "CustomPage",
+			opts:                []HandlerOption{WithInactiveLinkResponse(http.StatusForbidden, []byte("<h1>Not yet available</h1>"))},
+			expectedStatus:      http.StatusForbidden,
+			expectedContentType: HTMLContentType,
+			expectedBody:        "<h1>Not yet available</h1>",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			memStorage := repository.NewMemoryRepository()
+			url := repository.NewURL("testSlug", "https://example.com", userID, false)
+			url.NotBefore = &future
+			if err := memStorage.Add(ctx, *url); err != nil {
+				t.Fatalf("memstore write error")
+			}
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, tc.opts...)
+
+			req, err := http.NewRequest("GET", "/testSlug", nil)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
+			handler.HandleExpandURL(recorder, req.WithContext(ctx))
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			assert.Equal(t, tc.expectedContentType, recorder.Header().Get("Content-Type"))
+			assert.Contains(t, recorder.Body.String(), tc.expectedBody)
+			assert.Empty(t, recorder.Header().Get("Location"))
+		})
+	}
+}
+
+func TestHandleJSONShortenURL_Activation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		requestBody    string
+		expectedStatus int
+	}{
+		{
+			name:           "ValidWindowAndSchedule",
+			requestBody:    `{"url": "https://example.com", "not_before": "2030-01-01T00:00:00Z", "schedule": {"weekdays": ["mon"], "start_hour": 9, "end_hour": 17, "time_zone": "Europe/Berlin"}}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "InvertedWindow",
+			requestBody:    `{"url": "https://example.com", "not_before": "2030-01-01T00:00:00Z", "not_after": "2029-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "InvalidTimeZone",
+			requestBody:    `{"url": "https://example.com", "schedule": {"time_zone": "Nowhere/City"}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			memStorage := repository.NewMemoryRepository()
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+			req, err := http.NewRequest("POST", "/api/shorten", bytes.NewBufferString(tc.requestBody))
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
+			handler.HandleJSONShortenURL(recorder, req.WithContext(ctx))
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+			if tc.expectedStatus == http.StatusCreated {
+				stored, err := memStorage.GetByOriginalURL(context.Background(), "https://example.com")
+				assert.NoError(t, err)
+				assert.NotNil(t, stored.NotBefore)
+				assert.NotNil(t, stored.Schedule)
+			}
+		})
+	}
+}
+
+func TestHandleShortenURL_Validation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		handle         func(h *Handler) http.HandlerFunc
+		requestBody    string
+		expectedReason string
+	}{
+		{
+			name:           "PlainTextJavascriptURL",
+			handle:         func(h *Handler) http.HandlerFunc { return h.HandleShortenURL },
+			requestBody:    "javascript:alert(1)",
+			expectedReason: validator.ReasonSchemeNotAllowed,
+		},
+		{
+			name:           "PlainTextRelativePath",
+			handle:         func(h *Handler) http.HandlerFunc { return h.HandleShortenURL },
+			requestBody:    "/relative/path",
+			expectedReason: validator.ReasonNotAbsolute,
+		},
+		{
+			name:           "JSONWhitespaceURL",
+			handle:         func(h *Handler) http.HandlerFunc { return h.HandleJSONShortenURL },
+			requestBody:    `{"url": "   "}`,
+			expectedReason: validator.ReasonEmpty,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			memStorage := repository.NewMemoryRepository()
+			backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+			req, err := http.NewRequest("POST", "/", bytes.NewBufferString(tc.requestBody))
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
+			tc.handle(handler)(recorder, req.WithContext(ctx))
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Equal(t, middlewares.ProblemContentType, recorder.Header().Get("Content-Type"))
+
+			var response middlewares.Problem
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, ProblemInvalidURL, response.Code)
+			assert.Equal(t, http.StatusBadRequest, response.Status)
+			if assert.Len(t, response.Errors, 1) {
+				assert.Equal(t, tc.expectedReason, response.Errors[0].Code)
+				assert.NotEmpty(t, response.Errors[0].Message)
+			}
+
+			urlsCount, _, err := memStorage.GetServiceStats(context.Background())
+			assert.NoError(t, err)
+			assert.Zero(t, urlsCount)
+		})
+	}
+}
+
+func TestHandleShortenURL_CanonicalDuplicate(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+	url := repository.NewURL("existingSlug", "http://example.com", userID, false)
+	if err := memStorage.Add(context.Background(), *url); err != nil {
+		t.Fatalf("memstore write error")
+	}
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(" http://Example.com:80/ "))
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
+	handler.HandleShortenURL(recorder, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, baseURL+"/existingSlug", recorder.Body.String())
+}
+
+func TestDestinationPolicy(t *testing.T) {
+	denyPath := filepath.Join(t.TempDir(), "deny.txt")
+	if err := os.WriteFile(denyPath, []byte("*.phish.example\n"), 0644); err != nil {
+		t.Fatalf("writing deny list: %v", err)
+	}
+	engine, err := policy.NewEngine("", denyPath, baseURL, time.Minute)
+	assert.NoError(t, err)
+
+	memStorage := repository.NewMemoryRepository()
+	stored := repository.NewURL("storedSlug", "https://login.phish.example", userID, false)
+	if err := memStorage.Add(context.Background(), *stored); err != nil {
+		t.Fatalf("memstore write error")
+	}
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithDestinationPolicy(engine))
+
+	testCases := []struct {
+		name           string
+		requestBody    string
+		expectedReason string
+	}{
+		{
+			name:           "DeniedDomain",
+			requestBody:    `{"url": "https://bank.phish.example/login"}`,
+			expectedReason: policy.ReasonDenied,
+		},
+		{
+			name:           "RedirectLoop",
+			requestBody:    `{"url": "` + baseURL + `/abc123"}`,
+			expectedReason: policy.ReasonSelfReference,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/api/shorten", bytes.NewBufferString(tc.requestBody))
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
+			handler.HandleJSONShortenURL(recorder, req.WithContext(ctx))
+
+			assert.Equal(t, http.StatusForbidden, recorder.Code)
+			var response middlewares.Problem
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, ProblemDestinationDenied, response.Code)
+			if assert.Len(t, response.Errors, 1) {
+				assert.Equal(t, tc.expectedReason, response.Errors[0].Code)
+			}
+		})
+	}
+
+	t.Run("StoredURLRechecked", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/storedSlug", nil)
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		ctx := context.WithValue(req.Context(), middlewares.UserIDContextKey, userID)
+		handler.HandleExpandURL(recorder, req.WithContext(ctx))
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Location"))
+	})
+}
+
+func TestHandleIssueToken(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	tokenKeys, err := middlewares.LoadKeyRing([]string{"t1:0123456789abcdef"}, "")
+	assert.NoError(t, err)
+	tokens := middlewares.NewHS256TokenManager(tokenKeys, time.Hour, "")
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithTokenManager(tokens))
+
+	// Shorten a URL with a cookie identity.
+	shortenReq := httptest.NewRequest("POST", "/api/shorten", strings.NewReader(`{"url": "https://example.com"}`))
+	shortenRec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(shortenRec, shortenReq)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	cookies := shortenRec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+
+	// Exchange the cookie identity for a bearer token.
+	tokenReq := httptest.NewRequest("POST", "/api/auth/token", nil)
+	tokenReq.AddCookie(cookies[0])
+	tokenRec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(tokenRec, tokenReq)
+	assert.Equal(t, http.StatusOK, tokenRec.Code)
+
+	var tokenResp TokenResponse
+	assert.NoError(t, json.Unmarshal(tokenRec.Body.Bytes(), &tokenResp))
+	assert.Equal(t, "Bearer", tokenResp.TokenType)
+	assert.InDelta(t, time.Hour.Seconds(), tokenResp.ExpiresIn, 2)
+
+	// The bearer token carries the same identity.
+	urlsReq := httptest.NewRequest("GET", "/api/user/urls", nil)
+	urlsReq.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+	urlsRec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(urlsRec, urlsReq)
+	assert.Equal(t, http.StatusOK, urlsRec.Code)
+	assert.Contains(t, urlsRec.Body.String(), "https://example.com")
+	assert.Empty(t, urlsRec.Result().Cookies())
+
+	// A bearer token cannot be exchanged for another token.
+	refreshReq := httptest.NewRequest("POST", "/api/auth/token", nil)
+	refreshReq.Header.Set("Authorization", "Bearer "+tokenResp.AccessToken)
+	refreshRec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(refreshRec, refreshReq)
+	assert.Equal(t, http.StatusForbidden, refreshRec.Code)
+}
+
+func TestAPIKeys(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+
+	send := func(method, target, body string, setAuth func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if setAuth != nil {
+			setAuth(req)
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Establish a cookie identity.
+	listRec := send("GET", "/api/user/keys", "", nil)
+	assert.Equal(t, http.StatusNoContent, listRec.Code)
+	cookie := listRec.Result().Cookies()[0]
+	withCookie := func(r *http.Request) { r.AddCookie(cookie) }
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/user/keys", `{"label": "ci", "scopes": ["admin"]}`, withCookie).Code)
+
+	createRec := send("POST", "/api/user/keys", `{"label": "ci", "scopes": ["shorten"]}`, withCookie)
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	var created CreateAPIKeyResponse
+	assert.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Key)
+	assert.Equal(t, "ci", created.Label)
+	assert.Equal(t, []string{repository.ScopeShorten}, created.Scopes)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+	withKey := func(r *http.Request) { r.Header.Set(middlewares.APIKeyHeader, created.Key) }
+
+	// The key acts as the cookie user within its scopes.
+	shortenRec := send("POST", "/api/shorten", `{"url": "https://example.com/api-key"}`, withKey)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	assert.Empty(t, shortenRec.Result().Cookies())
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/user/urls", "", withKey).Code)
+	assert.Equal(t, http.StatusForbidden, send("POST", "/api/user/keys", `{"label": "escalate"}`, withKey).Code)
+
+	urlsRec := send("GET", "/api/user/urls", "", withCookie)
+	assert.Equal(t, http.StatusOK, urlsRec.Code)
+	assert.Contains(t, urlsRec.Body.String(), "https://example.com/api-key")
+
+	// Labels can be changed by the owner only.
+	assert.Equal(t, http.StatusNotFound, send("PATCH", "/api/user/keys/"+created.ID, `{"label": "mine"}`, nil).Code)
+	assert.Equal(t, http.StatusNoContent, send("PATCH", "/api/user/keys/"+created.ID, `{"label": "deploy"}`, withCookie).Code)
+
+	listRec = send("GET", "/api/user/keys", "", withCookie)
+	assert.Equal(t, http.StatusOK, listRec.Code)
+	assert.NotContains(t, listRec.Body.String(), created.Key)
+	var keys []APIKeyResponse
+	assert.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &keys))
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "deploy", keys[0].Label)
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.Nil(t, keys[0].RevokedAt)
+
+	// Revoked keys are rejected.
+	assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/user/keys/"+created.ID, "", withCookie).Code)
+	assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/shorten", `{"url": "https://example.com/revoked"}`, withKey).Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/user/keys/unknown", "", withCookie).Code)
+}
+
+func TestAccounts(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithAccountService(accounts.NewService(memStorage, bcrypt.MinCost)))
+
+	send := func(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	lastCookie := func(rec *httptest.ResponseRecorder) *http.Cookie {
+		cookies := rec.Result().Cookies()
+		return cookies[len(cookies)-1]
+	}
+
+	// An anonymous user shortens a URL and registers, keeping the link.
+	shortenRec := send("POST", "/api/shorten", `{"url": "https://example.com/first-browser"}`, nil)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	firstBrowser := lastCookie(shortenRec)
+
+	invalidRec := send("POST", "/api/user/register", `{"username": "alice", "password": "short"}`, firstBrowser)
+	assert.Equal(t, http.StatusBadRequest, invalidRec.Code)
+	assert.Contains(t, invalidRec.Body.String(), `"field":"password"`)
+
+	registerRec := send("POST", "/api/user/register", `{"email": "alice@example.com", "username": "alice", "password": "correct horse"}`, firstBrowser)
+	assert.Equal(t, http.StatusCreated, registerRec.Code)
+	var account AccountResponse
+	assert.NoError(t, json.Unmarshal(registerRec.Body.Bytes(), &account))
+	assert.Equal(t, "alice", account.Username)
+	assert.Equal(t, http.StatusConflict, send("POST", "/api/user/register", `{"username": "alice", "password": "correct horse"}`, nil).Code)
+
+	// Another browser shortens a URL anonymously, then logs in and sees both links.
+	shortenRec = send("POST", "/api/shorten", `{"url": "https://example.com/second-browser"}`, nil)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	secondBrowser := lastCookie(shortenRec)
+
+	assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/user/login", `{"login": "alice", "password": "wrong password"}`, secondBrowser).Code)
+	loginRec := send("POST", "/api/user/login", `{"login": "alice@example.com", "password": "correct horse"}`, secondBrowser)
+	assert.Equal(t, http.StatusOK, loginRec.Code)
+
+	urlsRec := send("GET", "/api/user/urls", "", lastCookie(loginRec))
+	assert.Equal(t, http.StatusOK, urlsRec.Code)
+	assert.Contains(t, urlsRec.Body.String(), "https://example.com/first-browser")
+	assert.Contains(t, urlsRec.Body.String(), "https://example.com/second-browser")
+
+	// Logging in replaces the anonymous session, and logging out revokes the account session.
+	assert.Equal(t, http.StatusNoContent, send("GET", "/api/user/urls", "", secondBrowser).Code)
+	signedIn := lastCookie(loginRec)
+	logoutRec := send("POST", "/api/user/logout", "", signedIn)
+	assert.Equal(t, http.StatusNoContent, logoutRec.Code)
+	assert.Equal(t, -1, lastCookie(logoutRec).MaxAge)
+	assert.Equal(t, http.StatusNoContent, send("GET", "/api/user/urls", "", signedIn).Code)
+
+	t.Run("Blocked anonymous user", func(t *testing.T) {
+		ctx := context.Background()
+		shortenRec := send("POST", "/api/shorten", `{"url": "https://example.com/blocked-browser"}`, nil)
+		assert.Equal(t, http.StatusCreated, shortenRec.Code)
+		var shortened ShortenURLResponse
+		assert.NoError(t, json.Unmarshal(shortenRec.Body.Bytes(), &shortened))
+		slug := strings.TrimPrefix(shortened.Result, baseURL+"/")
+		stored, err := memStorage.GetBySlug(ctx, slug)
+		assert.NoError(t, err)
+		assert.NoError(t, memStorage.BlockUser(ctx, repository.BlockedUser{UserID: stored.UserID, Reason: "spam", BlockedBy: "admin", BlockedAt: time.Now()}))
+		assert.Equal(t, http.StatusUnavailableForLegalReasons, send("GET", "/"+slug, "", nil).Code)
+
+		// Logging in does not lift the block from the links.
+		loginRec := send("POST", "/api/user/login", `{"login": "alice", "password": "correct horse"}`, lastCookie(shortenRec))
+		assert.Equal(t, http.StatusOK, loginRec.Code)
+		assert.Equal(t, http.StatusUnavailableForLegalReasons, send("GET", "/"+slug, "", nil).Code)
+		blocked, err := memStorage.IsUserBlocked(ctx, account.UserID)
+		assert.NoError(t, err)
+		assert.True(t, blocked)
+	})
+}
+
+func TestOIDC(t *testing.T) {
+	idp := oidctest.NewProvider("shorturl")
+	defer idp.Close()
+
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	provider := oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.Issuer(),
+		ClientID:    "shorturl",
+		RedirectURL: baseURL + "/api/auth/oidc/callback",
+	}, nil)
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithOIDC(provider, "/welcome"))
+	noRedirectClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	send := func(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	cookieNamed := func(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+		var found *http.Cookie
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == name {
+				found = c
+			}
+		}
+		return found
+	}
+	signIn := func(anonymous *http.Cookie) (callbackURL string, state *http.Cookie) {
+		loginRec := send("/api/auth/oidc/login", anonymous)
+		assert.Equal(t, http.StatusFound, loginRec.Code)
+		state = cookieNamed(loginRec, "oidcState")
+		assert.NotNil(t, state)
+
+		resp, err := noRedirectClient.Get(loginRec.Header().Get("Location"))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+		return resp.Header.Get("Location"), state
+	}
+
+	// An anonymous user shortens a URL, then signs in with the identity provider.
+	shortenReq := httptest.NewRequest("POST", "/api/shorten", strings.NewReader(`{"url": "https://example.com/before-sso"}`))
+	shortenRec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(shortenRec, shortenReq)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	anonymous := cookieNamed(shortenRec, "authCookie")
+
+	callbackURL, state := signIn(anonymous)
+	assert.True(t, strings.HasPrefix(callbackURL, baseURL+"/api/auth/oidc/callback?"))
+
+	t.Run("State mismatch", func(t *testing.T) {
+		_, otherState := signIn(anonymous)
+		assert.Equal(t, http.StatusBadRequest, send(callbackURL, anonymous, otherState).Code)
+	})
+
+	t.Run("State cookie is not an authentication cookie", func(t *testing.T) {
+		_, otherState := signIn(anonymous)
+		replayed := &http.Cookie{Name: "authCookie", Value: otherState.Value}
+		req := httptest.NewRequest("POST", "/api/shorten", strings.NewReader(`{"url": "https://example.com/replayed"}`))
+		req.AddCookie(replayed)
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		// Each replay is a new anonymous user, not a user named after the state.
+		assert.Equal(t, http.StatusNoContent, send("/api/user/urls", replayed).Code)
+	})
+
+	callbackRec := send(callbackURL, anonymous, state)
+	assert.Equal(t, http.StatusFound, callbackRec.Code)
+	assert.Equal(t, "/welcome", callbackRec.Header().Get("Location"))
+	signedIn := cookieNamed(callbackRec, "authCookie")
+	assert.NotNil(t, signedIn)
+
+	urlsRec := send("/api/user/urls", signedIn)
+	assert.Equal(t, http.StatusOK, urlsRec.Code)
+	assert.Contains(t, urlsRec.Body.String(), "https://example.com/before-sso")
+
+	// The subject maps to the same user ID on every sign-in.
+	callbackURL, state = signIn(signedIn)
+	again := send(callbackURL, signedIn, state)
+	assert.Equal(t, http.StatusFound, again.Code)
+	againRec := send("/api/user/urls", cookieNamed(again, "authCookie"))
+	assert.Equal(t, http.StatusOK, againRec.Code)
+	assert.Contains(t, againRec.Body.String(), "https://example.com/before-sso")
+
+	t.Run("Replayed callback", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, send(callbackURL, signedIn, state).Code)
+	})
+
+	t.Run("Signed-in identity is not merged as anonymous", func(t *testing.T) {
+		registerReq := httptest.NewRequest("POST", "/api/user/register", strings.NewReader(`{"username": "mallory", "password": "correct horse"}`))
+		current := cookieNamed(again, "authCookie")
+		registerReq.AddCookie(current)
+		registerRec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(registerRec, registerReq)
+		assert.Equal(t, http.StatusCreated, registerRec.Code)
+
+		account := cookieNamed(registerRec, "authCookie")
+		assert.Equal(t, http.StatusNoContent, send("/api/user/urls", account).Code)
+		callbackURL, state := signIn(account)
+		signedInAgain := send(callbackURL, account, state)
+		assert.Contains(t, send("/api/user/urls", cookieNamed(signedInAgain, "authCookie")).Body.String(), "https://example.com/before-sso")
+	})
+
+	t.Run("Blocked anonymous user", func(t *testing.T) {
+		ctx := context.Background()
+		shortenReq := httptest.NewRequest("POST", "/api/shorten", strings.NewReader(`{"url": "https://example.com/blocked-before-sso"}`))
+		shortenRec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(shortenRec, shortenReq)
+		assert.Equal(t, http.StatusCreated, shortenRec.Code)
+		var shortened ShortenURLResponse
+		assert.NoError(t, json.Unmarshal(shortenRec.Body.Bytes(), &shortened))
+		slug := strings.TrimPrefix(shortened.Result, baseURL+"/")
+		stored, err := memStorage.GetBySlug(ctx, slug)
+		assert.NoError(t, err)
+		assert.NoError(t, memStorage.BlockUser(ctx, repository.BlockedUser{UserID: stored.UserID, Reason: "spam", BlockedBy: "admin", BlockedAt: time.Now()}))
+
+		// Signing in does not lift the block from the links.
+		blockedAnonymous := cookieNamed(shortenRec, "authCookie")
+		callbackURL, state := signIn(blockedAnonymous)
+		assert.Equal(t, http.StatusFound, send(callbackURL, blockedAnonymous, state).Code)
+		assert.Equal(t, http.StatusUnavailableForLegalReasons, send("/"+slug).Code)
+		merged, err := memStorage.GetBySlug(ctx, slug)
+		assert.NoError(t, err)
+		assert.NotEqual(t, stored.UserID, merged.UserID)
+		blocked, err := memStorage.IsUserBlocked(ctx, merged.UserID)
+		assert.NoError(t, err)
+		assert.True(t, blocked)
+	})
+}
+
+func TestWorkspaces(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	engine, err := policy.NewEngine("", "", baseURL, time.Minute)
+	assert.NoError(t, err)
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithDestinationPolicy(engine))
+
+	send := func(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The owner creates a workspace and a link inside it.
+	createRec := send("POST", "/api/workspaces", `{"name": "Marketing"}`, nil)
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	owner := createRec.Result().Cookies()[0]
+	var workspace WorkspaceResponse
+	assert.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &workspace))
+	assert.Equal(t, repository.RoleOwner, workspace.Role)
+	workspaceURL := "/api/workspaces/" + workspace.ID
+
+	shortenRec := send("POST", "/api/shorten", `{"url": "https://example.com/campaign", "workspace_id": "`+workspace.ID+`"}`, owner)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	var shortenResp ShortenURLResponse
+	assert.NoError(t, json.Unmarshal(shortenRec.Body.Bytes(), &shortenResp))
+	slug := strings.TrimPrefix(shortenResp.Result, baseURL+"/")
+	assert.Equal(t, http.StatusNoContent, send("GET", "/api/user/urls", "", owner).Code, "Workspace links are not personal links")
+
+	// A stranger cannot see or use the workspace.
+	strangerRec := send("GET", "/api/workspaces", "", nil)
+	assert.Equal(t, http.StatusNoContent, strangerRec.Code)
+	stranger := strangerRec.Result().Cookies()[0]
+	assert.Equal(t, http.StatusNotFound, send("GET", workspaceURL+"/urls", "", stranger).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/shorten", `{"url": "https://example.com/x", "workspace_id": "`+workspace.ID+`"}`, stranger).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", workspaceURL+"/invitations", `{"role": "viewer"}`, stranger).Code)
+
+	// The owner invites the stranger as a viewer.
+	assert.Equal(t, http.StatusBadRequest, send("POST", workspaceURL+"/invitations", `{"role": "admin"}`, owner).Code)
+	inviteRec := send("POST", workspaceURL+"/invitations", `{"role": "viewer"}`, owner)
+	assert.Equal(t, http.StatusCreated, inviteRec.Code)
+	var invitation CreateWorkspaceInvitationResponse
+	assert.NoError(t, json.Unmarshal(inviteRec.Body.Bytes(), &invitation))
+	assert.NotEmpty(t, invitation.Token)
+
+	// A missing token is rejected as a missing field, not a malformed body.
+	missingTokenRec := send("POST", "/api/workspaces/join", `{"token": ""}`, stranger)
+	assert.Equal(t, http.StatusBadRequest, missingTokenRec.Code)
+	var missingToken middlewares.Problem
+	assert.NoError(t, json.Unmarshal(missingTokenRec.Body.Bytes(), &missingToken))
+	assert.Equal(t, ProblemInvalidWorkspace, missingToken.Code)
+	assert.Equal(t, []middlewares.FieldError{missingField("token")}, missingToken.Errors)
+
+	joinRec := send("POST", "/api/workspaces/join", `{"token": "`+invitation.Token+`"}`, stranger)
+	assert.Equal(t, http.StatusOK, joinRec.Code)
+	var member WorkspaceMemberResponse
+	assert.NoError(t, json.Unmarshal(joinRec.Body.Bytes(), &member))
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/workspaces/join", `{"token": "`+invitation.Token+`"}`, stranger).Code)
+
+	listRec := send("GET", workspaceURL+"/urls", "", stranger)
+	assert.Equal(t, http.StatusOK, listRec.Code)
+	assert.Contains(t, listRec.Body.String(), "https://example.com/campaign")
+	assert.Equal(t, http.StatusForbidden, send("PATCH", workspaceURL+"/urls/"+slug, `{"url": "https://example.com/edited"}`, stranger).Code)
+	assert.Equal(t, http.StatusForbidden, send("DELETE", workspaceURL+"/urls", `["`+slug+`"]`, stranger).Code)
+
+	// Promoted to editor, the member can edit and delete workspace links.
+	assert.Equal(t, http.StatusNoContent, send("PATCH", workspaceURL+"/members/"+member.UserID, `{"role": "editor"}`, owner).Code)
+	editRec := send("PATCH", workspaceURL+"/urls/"+slug, `{"url": "https://example.com/edited"}`, stranger)
+	assert.Equal(t, http.StatusOK, editRec.Code)
+	assert.Contains(t, editRec.Body.String(), "https://example.com/edited")
+	assert.Equal(t, http.StatusNotFound, send("PATCH", workspaceURL+"/urls/unknown", `{"url": "https://example.com/other"}`, stranger).Code)
+	missingURLRec := send("PATCH", workspaceURL+"/urls/"+slug, `{"url": ""}`, stranger)
+	assert.Equal(t, http.StatusBadRequest, missingURLRec.Code)
+	var missingURL middlewares.Problem
+	assert.NoError(t, json.Unmarshal(missingURLRec.Body.Bytes(), &missingURL))
+	assert.Equal(t, ProblemMissingURL, missingURL.Code)
+	assert.Equal(t, []middlewares.FieldError{missingField("url")}, missingURL.Errors)
+
+	// Edits are held to the destination policy, and blocked editors cannot redirect links.
+	deniedRec := send("PATCH", workspaceURL+"/urls/"+slug, `{"url": "`+baseURL+`/loop"}`, stranger)
+	assert.Equal(t, http.StatusForbidden, deniedRec.Code)
+	assert.Contains(t, deniedRec.Body.String(), ProblemDestinationDenied)
+	assert.NoError(t, memStorage.BlockUser(context.Background(), repository.BlockedUser{UserID: member.UserID, BlockedBy: "admin", BlockedAt: time.Now()}))
+	blockedRec := send("PATCH", workspaceURL+"/urls/"+slug, `{"url": "https://example.com/spam"}`, stranger)
+	assert.Equal(t, http.StatusForbidden, blockedRec.Code)
+	assert.Contains(t, blockedRec.Body.String(), ProblemUserBlocked)
+	editedURL, err := memStorage.GetBySlug(context.Background(), slug)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/edited", editedURL.OriginalURL)
+	assert.NoError(t, memStorage.UnblockUser(context.Background(), member.UserID))
+	assert.Equal(t, http.StatusAccepted, send("DELETE", workspaceURL+"/urls", `["`+slug+`"]`, stranger).Code)
+
+	// The last owner cannot leave, other members can.
+	membersRec := send("GET", workspaceURL+"/members", "", owner)
+	assert.Equal(t, http.StatusOK, membersRec.Code)
+	var members []WorkspaceMemberResponse
+	assert.NoError(t, json.Unmarshal(membersRec.Body.Bytes(), &members))
+	assert.Len(t, members, 2)
+	for _, m := range members {
+		if m.Role == repository.RoleOwner {
+			assert.Equal(t, http.StatusConflict, send("DELETE", workspaceURL+"/members/"+m.UserID, "", owner).Code)
+		}
+	}
+	assert.Equal(t, http.StatusNoContent, send("DELETE", workspaceURL+"/members/"+member.UserID, "", stranger).Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", workspaceURL+"/urls", "", stranger).Code)
+}
+
+func TestAdmin(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	guard, err := middlewares.NewAdminGuard(nil, []string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithAdminGuard(guard))
+
+	send := func(method, target, body string, cookie *http.Cookie, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	const operator = "10.1.2.3:4567"
+
+	// A user creates two links.
+	createRec := send("POST", "/api/shorten", `{"url": "https://spam.example.com/1"}`, nil, "")
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	spammer := createRec.Result().Cookies()[0]
+	assert.Equal(t, http.StatusCreated, send("POST", "/api/shorten", `{"url": "https://spam.example.com/2"}`, spammer, "").Code)
+
+	// Only operators reach the admin API.
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/admin/urls", "", spammer, "").Code)
+
+	searchRec := send("GET", "/api/admin/urls?target=SPAM.example.com/1", "", nil, operator)
+	assert.Equal(t, http.StatusOK, searchRec.Code)
+	var found []AdminURL
+	assert.NoError(t, json.Unmarshal(searchRec.Body.Bytes(), &found))
+	assert.Len(t, found, 1)
+	spammerID := found[0].UserID
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/api/admin/urls?limit=0", "", nil, operator).Code)
+	assert.Equal(t, http.StatusNoContent, send("GET", "/api/admin/urls?owner=nobody", "", nil, operator).Code)
+
+	// A disabled link is unavailable for legal reasons until re-enabled.
+	slugURL := "/api/admin/urls/" + found[0].Slug
+	assert.Equal(t, http.StatusNoContent, send("PATCH", slugURL, `{"disabled": true}`, nil, operator).Code)
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, send("GET", "/"+found[0].Slug, "", nil, "").Code)
+	assert.Equal(t, http.StatusNoContent, send("PATCH", slugURL, `{"disabled": false}`, nil, operator).Code)
+	assert.Equal(t, http.StatusTemporaryRedirect, send("GET", "/"+found[0].Slug, "", nil, "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("PATCH", slugURL, `{}`, nil, operator).Code)
+	assert.Equal(t, http.StatusNotFound, send("PATCH", "/api/admin/urls/unknown", `{"disabled": true}`, nil, operator).Code)
+
+	// A deleted link is gone.
+	assert.Equal(t, http.StatusNoContent, send("DELETE", slugURL, "", nil, operator).Code)
+	assert.Equal(t, http.StatusGone, send("GET", "/"+found[0].Slug, "", nil, "").Code)
+
+	// A blocked user cannot create links, and their links are unavailable.
+	assert.Equal(t, http.StatusNoContent, send("PUT", "/api/admin/users/"+spammerID+"/block", `{"reason": "spam"}`, nil, operator).Code)
+	assert.Equal(t, http.StatusForbidden, send("POST", "/", "https://spam.example.com/3", spammer, "").Code)
+	assert.Equal(t, http.StatusForbidden, send("POST", "/api/shorten/batch", `[{"correlation_id": "1", "original_url": "https://spam.example.com/4"}]`, spammer, "").Code)
+	otherRec := send("GET", "/api/admin/urls?owner="+spammerID+"&offset=1", "", nil, operator)
+	assert.Equal(t, http.StatusOK, otherRec.Code)
+	assert.NoError(t, json.Unmarshal(otherRec.Body.Bytes(), &found))
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, send("GET", "/"+found[0].Slug, "", nil, "").Code)
+
+	userRec := send("GET", "/api/admin/users/"+spammerID, "", nil, operator)
+	assert.Equal(t, http.StatusOK, userRec.Code)
+	var user AdminUserResponse
+	assert.NoError(t, json.Unmarshal(userRec.Body.Bytes(), &user))
+	assert.Equal(t, AdminUserResponse{UserID: spammerID, URLs: 2, Deleted: 1, Blocked: true}, user)
+	usersRec := send("GET", "/api/admin/users", "", nil, operator)
+	assert.Equal(t, http.StatusOK, usersRec.Code)
+	assert.Contains(t, usersRec.Body.String(), spammerID)
+	blockedRec := send("GET", "/api/admin/users/blocked", "", nil, operator)
+	assert.Equal(t, http.StatusOK, blockedRec.Code)
+	assert.Contains(t, blockedRec.Body.String(), `"reason":"spam"`)
+
+	// Unblocking restores the links.
+	assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/admin/users/"+spammerID+"/block", "", nil, operator).Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/admin/users/"+spammerID+"/block", "", nil, operator).Code)
+	assert.Equal(t, http.StatusTemporaryRedirect, send("GET", "/"+found[0].Slug, "", nil, "").Code)
+}
+
+func TestAdminJobs(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	guard, err := middlewares.NewAdminGuard(nil, []string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	scheduler := jobs.NewScheduler()
+	assert.NoError(t, backgroundDeleter.Register(context.Background(), scheduler))
+
+	send := func(handler *Handler, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/admin/jobs", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	const operator = "10.1.2.3:4567"
+
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithAdminGuard(guard), WithJobScheduler(scheduler))
+	assert.Equal(t, http.StatusForbidden, send(handler, "192.0.2.1:1234").Code)
+
+	rec := send(handler, operator)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var listed []AdminJobResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	if assert.Len(t, listed, 2) {
+		assert.Equal(t, AdminJobResponse{Name: deleter.JobName, Kind: jobs.KindPeriodic}, listed[0])
+		assert.Equal(t, AdminJobResponse{Name: deleter.PruneJobName, Kind: jobs.KindPeriodic}, listed[1])
+	}
+
+	// Without a scheduler there are no jobs to list.
+	withoutJobs := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithAdminGuard(guard))
+	assert.Equal(t, http.StatusNoContent, send(withoutJobs, operator).Code)
+}
+
+func TestAbuseReports(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	guard, err := middlewares.NewAdminGuard(nil, []string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	handler := NewHandler(
+		memStorage, backgroundDeleter, logger, baseURL,
+		WithAdminGuard(guard),
+		WithModerationService(moderation.NewService(memStorage, moderation.Thresholds{Takedowns: 1})),
+	)
+
+	send := func(method, target, body string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	const operator = "10.1.2.3:4567"
+
+	createRec := send("POST", "/api/shorten", `{"url": "https://phishing.example.com"}`, "")
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	var created ShortenURLResponse
+	assert.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &created))
+	slug := strings.TrimPrefix(created.Result, baseURL+"/")
+
+	// Anyone can report a link. Anonymous reporters are told apart by their address,
+	// as every request without a cookie is issued a new user ID.
+	testCases := []struct {
+		name           string
+		slug           string
+		body           string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{name: "Valid report", slug: slug, body: `{"reason": "phishing", "details": "fake bank login"}`, expectedStatus: http.StatusAccepted},
+		{name: "Repeated report with a new cookie", slug: slug, body: `{"reason": "spam"}`, expectedStatus: http.StatusAccepted},
+		{name: "Report from another address", slug: slug, body: `{"reason": "malware"}`, remoteAddr: "198.51.100.7:1234", expectedStatus: http.StatusAccepted},
+		{name: "Unknown reason", slug: slug, body: `{"reason": "boring"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid payload", slug: slug, body: `reason=spam`, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown link", slug: "unknown", body: `{"reason": "spam"}`, expectedStatus: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStatus, send("POST", "/api/report/"+tc.slug, tc.body, tc.remoteAddr).Code)
+		})
+	}
+
+	// Reports wait in the moderation queue until reviewed.
+	queueRec := send("GET", "/api/admin/reports", "", operator)
+	assert.Equal(t, http.StatusOK, queueRec.Code)
+	var queue []AbuseReportResponse
+	assert.NoError(t, json.Unmarshal(queueRec.Body.Bytes(), &queue))
+	if assert.Len(t, queue, 2) {
+		assert.Equal(t, "phishing", queue[0].Reason)
+		assert.Equal(t, "ip:198.51.100.7", queue[1].ReporterID)
+	}
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/admin/reports", "", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/api/admin/reports?status=closed", "", operator).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/admin/reports/unknown/dismiss", "", operator).Code)
+
+	// A takedown serves the explanation page with 451 and flags the owner past the threshold.
+	takedownRec := send("POST", "/api/admin/reports/"+slug+"/takedown", "", operator)
+	assert.Equal(t, http.StatusOK, takedownRec.Code)
+	assert.JSONEq(t, `{"slug": "`+slug+`", "status": "taken_down", "resolved": 2}`, takedownRec.Body.String())
+	expandRec := send("GET", "/"+slug, "", "")
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, expandRec.Code)
+	assert.Equal(t, HTMLContentType, expandRec.Header().Get("Content-Type"))
+	assert.Contains(t, expandRec.Body.String(), "taken down")
+
+	// Re-enabling the URL does not lift the takedown.
+	assert.Equal(t, http.StatusNoContent, send("PATCH", "/api/admin/urls/"+slug, `{"disabled": false}`, operator).Code)
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, send("GET", "/"+slug, "", "").Code)
+	assert.Equal(t, http.StatusNoContent, send("GET", "/api/admin/reports", "", operator).Code)
+	assert.Contains(t, send("GET", "/api/admin/reports?status=taken_down", "", operator).Body.String(), `"reviewed_by"`)
+
+	flaggedRec := send("GET", "/api/admin/users/flagged", "", operator)
+	assert.Equal(t, http.StatusOK, flaggedRec.Code)
+	var flagged []FlaggedUserResponse
+	assert.NoError(t, json.Unmarshal(flaggedRec.Body.Bytes(), &flagged))
+	assert.Len(t, flagged, 1)
+	assert.Equal(t, queue[0].OwnerID, flagged[0].UserID)
+}
+
+func TestAuditLog(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	guard, err := middlewares.NewAdminGuard(nil, []string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithAdminGuard(guard))
+
+	send := func(method, target, body string, cookie *http.Cookie, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(middlewares.RequestIDHeader, "req-"+method)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	const operator = "10.1.2.3:4567"
+
+	createRec := send("POST", "/api/shorten", `{"url": "https://example.com/audited"}`, nil, "192.0.2.1:1234")
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	assert.Equal(t, "req-POST", createRec.Header().Get(middlewares.RequestIDHeader))
+	cookie := createRec.Result().Cookies()[0]
+	var created ShortenURLResponse
+	assert.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &created))
+	slug := strings.TrimPrefix(created.Result, baseURL+"/")
+	assert.Equal(t, http.StatusAccepted, send("DELETE", "/api/user/urls", `["`+slug+`"]`, cookie, "192.0.2.1:1234").Code)
+
+	// Only operators query the audit log.
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/admin/audit", "", cookie, "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/api/admin/audit?since=yesterday", "", nil, operator).Code)
+
+	auditRec := send("GET", "/api/admin/audit?slug="+slug, "", nil, operator)
+	assert.Equal(t, http.StatusOK, auditRec.Code)
+	var events []AuditEventResponse
+	assert.NoError(t, json.Unmarshal(auditRec.Body.Bytes(), &events))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, repository.AuditActionShorten, events[0].Action)
+		assert.Equal(t, repository.AuditActionDeleteRequest, events[1].Action)
+		assert.Equal(t, events[0].Actor, events[1].Actor)
+		assert.Equal(t, "192.0.2.1", events[1].SourceIP)
+		assert.Equal(t, "req-DELETE", events[1].RequestID)
+	}
+
+	// The export is newline-delimited JSON of all matching events.
+	exportRec := send("GET", "/api/admin/audit/export", "", nil, operator)
+	assert.Equal(t, http.StatusOK, exportRec.Code)
+	assert.Equal(t, NDJSONContentType, exportRec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(exportRec.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	for _, line := range lines {
+		var e AuditEventResponse
+		assert.NoError(t, json.Unmarshal([]byte(line), &e))
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	webhookService := webhooks.NewService(memStorage, baseURL)
+	clicks := webhooks.NewClickCounter(webhookService, 0)
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithWebhookService(webhookService), WithClickCounter(clicks))
+
+	send := func(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Invalid payload", body: `url=https://example.com`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid endpoint", body: `{"url": "example.com/hook"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown event", body: `{"url": "https://example.com/hook", "events": ["link.updated"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "Missing click threshold", body: `{"url": "https://example.com/hook", "events": ["link.click_threshold"]}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStatus, send("POST", "/api/user/webhooks", tc.body, nil).Code)
+		})
+	}
+
+	// The secret is only returned on registration.
+	createRec := send("POST", "/api/user/webhooks", `{"url": "https://example.com/hook", "click_threshold": 1}`, nil)
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	cookie := createRec.Result().Cookies()[0]
+	var created CreateWebhookResponse
+	assert.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Secret)
+	listRec := send("GET", "/api/user/webhooks", "", cookie)
+	assert.Equal(t, http.StatusOK, listRec.Code)
+	assert.NotContains(t, listRec.Body.String(), created.Secret)
+
+	// Creating a link and flushing its clicks queues deliveries in the log.
+	shortenRec := send("POST", "/api/shorten", `{"url": "https://example.com/hooked"}`, cookie)
+	assert.Equal(t, http.StatusCreated, shortenRec.Code)
+	var shortenResp ShortenURLResponse
+	assert.NoError(t, json.Unmarshal(shortenRec.Body.Bytes(), &shortenResp))
+	assert.Equal(t, http.StatusTemporaryRedirect, send("GET", "/"+strings.TrimPrefix(shortenResp.Result, baseURL+"/"), "", nil).Code)
+	assert.NoError(t, clicks.Flush(context.Background()))
+
+	deliveriesURL := "/api/user/webhooks/" + created.ID + "/deliveries"
+	deliveriesRec := send("GET", deliveriesURL, "", cookie)
+	assert.Equal(t, http.StatusOK, deliveriesRec.Code)
+	var deliveries []WebhookDeliveryResponse
+	assert.NoError(t, json.Unmarshal(deliveriesRec.Body.Bytes(), &deliveries))
+	if assert.Len(t, deliveries, 2) {
+		assert.Equal(t, webhooks.EventLinkClickThreshold, deliveries[0].Event)
+		assert.Equal(t, webhooks.EventLinkCreated, deliveries[1].Event)
+		assert.Equal(t, repository.DeliveryStatusPending, deliveries[1].Status)
+		assert.NotNil(t, deliveries[1].NextAttemptAt)
+	}
+	assert.Equal(t, http.StatusBadRequest, send("GET", deliveriesURL+"?limit=0", "", cookie).Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", deliveriesURL, "", nil).Code)
+
+	// Only the owner removes the webhook.
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/user/webhooks/"+created.ID, "", nil).Code)
+	assert.Equal(t, http.StatusNoContent, send("DELETE", "/api/user/webhooks/"+created.ID, "", cookie).Code)
+	assert.Equal(t, http.StatusNoContent, send("GET", "/api/user/webhooks", "", cookie).Code)
+}
+
+func TestOpenAPI(t *testing.T) {
+	memStorage := repository.NewMemoryRepository()
+	backgroundDeleter := deleter.NewBackgroundDeleter(memStorage, deleter.Config{})
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandler(memStorage, backgroundDeleter, logger, baseURL, WithAPIDocs(true))
+
+	rec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var doc openAPIDocument
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, OpenAPIVersion, doc.OpenAPI)
+
+	// Every registered route is documented.
+	err := chi.Walk(handler.Router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		pattern := normalizeRoutePattern(route)
+		if assert.Contains(t, doc.Paths, pattern, "undocumented route %s %s", method, pattern) {
+			assert.Contains(t, doc.Paths[pattern], strings.ToLower(method), "undocumented route %s %s", method, pattern)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Operation IDs are unique and every referenced schema is a component.
+	ids := map[string]bool{}
+	for _, op := range apiOperations {
+		assert.False(t, ids[op.id], "duplicate operation id %s", op.id)
+		ids[op.id] = true
+	}
+	for _, ref := range regexp.MustCompile(`#/components/schemas/(\w+)`).FindAllStringSubmatch(rec.Body.String(), -1) {
+		assert.Contains(t, doc.Components.Schemas, ref[1])
+	}
+	shorten := doc.Components.Schemas["ShortenURLRequest"]
+	if assert.NotNil(t, shorten) {
+		assert.Contains(t, shorten.Properties, "url")
+		assert.Contains(t, shorten.Required, "url")
+	}
+
+	// The documentation page is only served when enabled.
+	docsRec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(docsRec, httptest.NewRequest("GET", "/api/docs", nil))
+	assert.Equal(t, http.StatusOK, docsRec.Code)
+	assert.Equal(t, HTMLContentType, docsRec.Header().Get("Content-Type"))
+
+	disabled := NewHandler(memStorage, backgroundDeleter, logger, baseURL)
+	disabledRec := httptest.NewRecorder()
+	disabled.Router.ServeHTTP(disabledRec, httptest.NewRequest("GET", "/api/docs", nil))
+	assert.Equal(t, http.StatusNotFound, disabledRec.Code)
+}
@@ -0,0 +1,197 @@
+// Package handlers provides HTTP request handlers for the liveness and readiness probes and the metrics.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/lifecycle"
+	"github.com/gennadis/shorturl/internal/app/metrics"
+)
+
+// Paths of the probes, served before the authentication and logging middleware.
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+	pingPath    = "/ping"
+	metricsPath = "/metrics"
+)
+
+// healthCheckTimeout bounds each readiness check.
+const healthCheckTimeout = time.Second * 2
+
+// Statuses of the probes and their checks.
+const (
+	HealthStatusPass = "pass"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheck is a named readiness check of a component. Check returns an optional detail about
+// the observed state, and an error if the component cannot serve requests.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) (detail string, err error)
+}
+
+// HealthResponse represents the outcome of a probe and its checks.
+type HealthResponse struct {
+	Status string                `json:"status"`
+	Checks []HealthCheckResponse `json:"checks,omitempty"`
+}
+
+// HealthCheckResponse represents the outcome of a single readiness check.
+type HealthCheckResponse struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// WithLifecycle sets the lifecycle supervisor whose state is reported by the readiness probe,
+// so the application is unready while starting and during shutdown.
+func WithLifecycle(s *lifecycle.Supervisor) HandlerOption {
+	return func(h *Handler) {
+		h.lifecycle = s
+	}
+}
+
+// WithHealthChecks adds readiness checks to the built-in checks of the storage and the background deleter.
+func WithHealthChecks(checks ...HealthCheck) HandlerOption {
+	return func(h *Handler) {
+		h.healthChecks = append(h.healthChecks, checks...)
+	}
+}
+
+// WithMetrics sets the metrics recording the HTTP requests. If serveEndpoint is set, the metrics are
+// also served at /metrics; otherwise they are expected to be served on a separate listener.
+func WithMetrics(m *metrics.Metrics, serveEndpoint bool) HandlerOption {
+	return func(h *Handler) {
+		h.metrics = m
+		h.metricsEndpoint = m != nil && serveEndpoint
+	}
+}
+
+// Method to serve the probes and the metrics before the authentication and logging middleware,
+// so probes and scrapes neither start sessions nor fill the request log.
+func (h *Handler) probes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			switch r.URL.Path {
+			case healthzPath:
+				h.HandleHealthz(w, r)
+				return
+			case readyzPath:
+				h.HandleReadyz(w, r)
+				return
+			case pingPath:
+				h.HandleDatabasePing(w, r)
+				return
+			case metricsPath:
+				if h.metricsEndpoint {
+					h.HandleMetrics(w, r)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Method to handle the liveness probe. It passes as long as the process serves requests.
+func (h *Handler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondWithJson(w, http.StatusOK, HealthResponse{Status: HealthStatusPass})
+}
+
+// Method to handle the readiness probe. It runs the readiness checks concurrently
+// and responds with 503 if any of them fails.
+func (h *Handler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := h.readinessChecks()
+	resp := HealthResponse{Status: HealthStatusPass, Checks: make([]HealthCheckResponse, len(checks))}
+
+	wg := sync.WaitGroup{}
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			resp.Checks[i] = runHealthCheck(r.Context(), check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	for _, check := range resp.Checks {
+		if check.Status == HealthStatusFail {
+			resp.Status = HealthStatusFail
+			status = http.StatusServiceUnavailable
+			slog.Warn("readiness check failed", slog.String("check", check.Name), slog.String("error", check.Error))
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondWithJson(w, status, resp)
+}
+
+// Function to run a readiness check within the check timeout.
+func runHealthCheck(ctx context.Context, check HealthCheck) HealthCheckResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check.Check(ctx)
+	resp := HealthCheckResponse{Name: check.Name, Status: HealthStatusPass, Detail: detail, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		resp.Status = HealthStatusFail
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// Method to list the readiness checks: the application lifecycle, the storage, the background deleter
+// and the checks added with WithHealthChecks.
+func (h *Handler) readinessChecks() []HealthCheck {
+	checks := []HealthCheck{}
+	if h.lifecycle != nil {
+		checks = append(checks, HealthCheck{Name: "lifecycle", Check: func(context.Context) (string, error) {
+			state := h.lifecycle.State()
+			if state != lifecycle.StateReady {
+				return state, fmt.Errorf("application is %s", state)
+			}
+			return state, nil
+		}})
+	}
+	checks = append(checks, HealthCheck{Name: "storage", Check: func(ctx context.Context) (string, error) {
+		return "", h.repo.Ping(ctx)
+	}})
+	if h.backgroundDeleter != nil {
+		checks = append(checks, HealthCheck{Name: "deleter", Check: func(context.Context) (string, error) {
+			queued, size := h.backgroundDeleter.Queued(), h.backgroundDeleter.QueueSize()
+			detail := fmt.Sprintf("%d of %d delete requests queued", queued, size)
+			if queued >= size {
+				return detail, errors.New("deletion queue is full")
+			}
+			return detail, nil
+		}})
+	}
+	return append(checks, h.healthChecks...)
+}
+
+// Method to handle the metrics in the Prometheus text exposition format.
+func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	h.metrics.Handler().ServeHTTP(w, r)
+}
+
+// Method to handle the storage ping, kept for existing probes. Prefer the readiness probe.
+func (h *Handler) HandleDatabasePing(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.Ping(r.Context()); err != nil {
+		slog.Error("storage ping", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,140 @@
+// Package handlers provides HTTP request handlers for deletion jobs tracking background URL deletions.
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/deleter"
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// deletionJobsPath is the path deletion jobs are served under.
+const deletionJobsPath = "/api/user/jobs/"
+
+// DeletionJobResponse represents a deletion job and the outcome of each requested slug.
+type DeletionJobResponse struct {
+	ID          string                    `json:"id"`
+	Status      string                    `json:"status"`
+	WorkspaceID string                    `json:"workspace_id,omitempty"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	Items       []DeletionJobItemResponse `json:"items"`
+}
+
+// DeletionJobItemResponse represents the outcome of deleting a single slug.
+type DeletionJobItemResponse struct {
+	Slug      string    `json:"slug"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Function to convert a deletion job into its response representation.
+func newDeletionJobResponse(job repository.DeletionJob) DeletionJobResponse {
+	resp := DeletionJobResponse{
+		ID:          job.ID,
+		Status:      job.Status(),
+		WorkspaceID: job.WorkspaceID,
+		CreatedAt:   job.CreatedAt,
+		Items:       make([]DeletionJobItemResponse, 0, len(job.Items)),
+	}
+	for _, item := range job.Items {
+		resp.Items = append(resp.Items, DeletionJobItemResponse{
+			Slug:      item.Slug,
+			Status:    item.Status,
+			Reason:    item.Reason,
+			UpdatedAt: item.UpdatedAt,
+		})
+	}
+	return resp
+}
+
+// Method to queue the deletion of the slugs as a deletion job and respond with the job and its location.
+// The job is stored as its deletions are queued, so its pending slugs are replayed after a restart,
+// and a job rejected by the saturated background deleter is never stored.
+// Repeated slugs are deleted once.
+func (h *Handler) queueDeletionJob(w http.ResponseWriter, r *http.Request, slugs []string, userID string, workspaceID string) {
+	now := time.Now().UTC()
+	job := repository.DeletionJob{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		SourceIP:    middlewares.ClientIP(r),
+		RequestID:   middlewares.RequestIDFromContext(r.Context()),
+		CreatedAt:   now,
+		Items:       []repository.DeletionJobItem{},
+	}
+	unique := make([]string, 0, len(slugs))
+	seen := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		unique = append(unique, slug)
+		job.Items = append(job.Items, repository.DeletionJobItem{JobID: job.ID, Slug: slug, Status: repository.DeletionStatusPending, UpdatedAt: now})
+	}
+
+	delReqs := newDeleteRequests(r, unique, userID, workspaceID)
+	for i := range delReqs {
+		delReqs[i].JobID = job.ID
+	}
+	if err := h.backgroundDeleter.EnqueueJob(r.Context(), job, delReqs); err != nil {
+		h.rejectDeletionJob(w, r, job, err)
+		return
+	}
+	if len(unique) > 0 {
+		h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionDeleteRequest, Slugs: unique, WorkspaceID: workspaceID})
+	}
+
+	w.Header().Set("Location", deletionJobsPath+job.ID)
+	h.respondWithJson(w, http.StatusAccepted, newDeletionJobResponse(job))
+}
+
+// Method to respond to a deletion job the background deleter did not take with the matching problem:
+// 400 if it has more slugs than a request may delete, 503 with Retry-After while the deleter is saturated,
+// 500 if the job could not be stored.
+func (h *Handler) rejectDeletionJob(w http.ResponseWriter, r *http.Request, job repository.DeletionJob, err error) {
+	switch {
+	case errors.Is(err, deleter.ErrTooManyRequests):
+		slog.Debug("too many slugs to delete", slog.String("user", job.UserID), slog.Int("slugs", len(job.Items)))
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemTooManySlugs, "Too many slugs are requested to be deleted at once.")
+	case errors.Is(err, deleter.ErrQueueFull):
+		slog.Warn("deletion queue is full", slog.String("user", job.UserID), slog.Int("slugs", len(job.Items)))
+		retryAfter := strconv.Itoa(int(math.Ceil(h.backgroundDeleter.RetryAfter().Seconds())))
+		w.Header().Set("Retry-After", retryAfter)
+		h.respondWithProblem(w, r, http.StatusServiceUnavailable, ProblemDeletionQueueFull, "Too many deletions are queued, retry after "+retryAfter+" seconds.")
+	default:
+		slog.Error("queueing deletion job", slog.String("user", job.UserID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+	}
+}
+
+// Method to handle getting a deletion job of the user.
+func (h *Handler) HandleGetDeletionJob(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	jobID := chi.URLParam(r, "id")
+	job, err := h.repo.GetDeletionJob(r.Context(), jobID)
+	if errors.Is(err, repository.ErrDeletionJobNotExist) || (err == nil && job.UserID != userID) {
+		h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "The deletion job does not exist.")
+		return
+	}
+	if err != nil {
+		slog.Error("getting deletion job", slog.String("user", userID), slog.String("job", jobID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	h.respondWithJson(w, http.StatusOK, newDeletionJobResponse(job))
+}
@@ -0,0 +1,151 @@
+// Package handlers provides the OpenID Connect single sign-on handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/oidc"
+)
+
+// oidcStateCookieName is the name of the cookie carrying the pending sign-in state.
+const oidcStateCookieName = "oidcState"
+
+// oidcStateCookiePath restricts the sign-in state cookie to the sign-in routes.
+const oidcStateCookiePath = "/api/auth/oidc"
+
+// oidcStateTTL is the time a user has to complete the sign-in at the provider.
+const oidcStateTTL = 10 * time.Minute
+
+// oidcState is the pending sign-in state, kept in a signed cookie between the login and callback requests.
+type oidcState struct {
+	State     string `json:"state"`
+	Nonce     string `json:"nonce"`
+	Verifier  string `json:"verifier"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// WithOIDC enables OpenID Connect single sign-on with the provider.
+// Users are redirected to postLoginRedirect after signing in.
+func WithOIDC(provider *oidc.Provider, postLoginRedirect string) HandlerOption {
+	return func(h *Handler) {
+		h.oidc = provider
+		h.oidcPostLoginRedirect = postLoginRedirect
+	}
+}
+
+// Method to handle starting an OpenID Connect sign-in by redirecting to the provider.
+func (h *Handler) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := oidc.NewState()
+	if err != nil {
+		slog.Error("generating OIDC state", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		slog.Error("generating OIDC nonce", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	verifier, err := oidc.NewPKCEVerifier()
+	if err != nil {
+		slog.Error("generating PKCE verifier", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+
+	authURL, err := h.oidc.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		slog.Error("building OIDC authorization URL", slog.Any("error", err))
+		h.respondWithProblem(w, r, http.StatusBadGateway, ProblemProviderUnavailable, "The identity provider is unavailable.")
+		return
+	}
+
+	pending, err := json.Marshal(oidcState{
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(oidcStateTTL).Unix(),
+	})
+	if err != nil {
+		slog.Error("marshalling OIDC state", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    h.cookieKeys.SignFor(middlewares.PurposeOIDCState, string(pending)),
+		Path:     oidcStateCookiePath,
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Method to handle the OpenID Connect callback: the authorization code is redeemed, the ID token verified,
+// and the provider subject mapped to a user ID that is set in the authentication cookie.
+func (h *Handler) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	// The state is single use.
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Path: oidcStateCookiePath, MaxAge: -1})
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		slog.Info("OIDC sign-in failed at provider", slog.String("error", providerErr), slog.String("description", query.Get("error_description")))
+		h.respondWithProblem(w, r, http.StatusUnauthorized, ProblemSignInFailed, "The identity provider rejected the sign-in.")
+		return
+	}
+
+	var pending oidcState
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil {
+		slog.Info("OIDC callback without sign-in state")
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidSignInState, "The sign-in state is missing, restart the sign-in.")
+		return
+	}
+	value, ok := h.cookieKeys.VerifyFor(middlewares.PurposeOIDCState, cookie.Value)
+	if !ok || json.Unmarshal([]byte(value), &pending) != nil || time.Now().Unix() >= pending.ExpiresAt {
+		slog.Info("OIDC callback with invalid or expired sign-in state")
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidSignInState, "The sign-in state is invalid or expired, restart the sign-in.")
+		return
+	}
+	if query.Get("state") != pending.State || query.Get("code") == "" {
+		slog.Info("OIDC callback state mismatch")
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidSignInState, "The sign-in state does not match the callback.")
+		return
+	}
+
+	claims, err := h.oidc.Exchange(r.Context(), query.Get("code"), pending.Verifier, pending.Nonce)
+	if err != nil {
+		slog.Error("redeeming OIDC authorization code", slog.Any("error", err))
+		h.respondWithProblem(w, r, http.StatusUnauthorized, ProblemSignInFailed, "The authorization code could not be redeemed.")
+		return
+	}
+
+	userID := oidc.UserID(claims.Issuer, claims.Subject)
+	if err := h.accounts.RegisterExternal(r.Context(), userID); err != nil {
+		slog.Error("registering OIDC user", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if currentUserID, err := h.getUserIDFromCtx(r); err == nil {
+		if err := h.accounts.MergeAnonymous(r.Context(), currentUserID, userID); err != nil {
+			slog.Error("merging anonymous user", slog.String("user", userID), slog.Any("error", err))
+			h.respondInternalError(w, r)
+			return
+		}
+	}
+	slog.Info("OIDC sign-in successful", slog.String("user", userID), slog.String("subject", claims.Subject))
+
+	if err := h.startSession(w, r, userID); err != nil {
+		slog.Error("starting session", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	http.Redirect(w, r, h.oidcPostLoginRedirect, http.StatusFound)
+}
@@ -0,0 +1,977 @@
+// Package handlers provides the OpenAPI description of the HTTP API and the documentation page rendering it.
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/go-chi/chi/v5"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification the API description follows.
+const OpenAPIVersion = "3.0.3"
+
+// apiVersion is the version of the HTTP API.
+const apiVersion = "1.0.0"
+
+// Security schemes of the API description.
+const (
+	securityCookie = "cookieAuth"
+	securityBearer = "bearerAuth"
+	securityAPIKey = "apiKeyAuth"
+)
+
+// Tags grouping the operations of the API description.
+const (
+	tagLinks      = "links"
+	tagAccount    = "account"
+	tagKeys       = "api keys"
+	tagWebhooks   = "webhooks"
+	tagWorkspaces = "workspaces"
+	tagReports    = "abuse reports"
+	tagAdmin      = "admin"
+	tagService    = "service"
+)
+
+// pathParamPattern matches the path parameters of a route pattern.
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPIDocument is an OpenAPI 3 document describing the HTTP API.
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Servers    []openAPIServer                        `json:"servers,omitempty"`
+	Security   []map[string][]string                  `json:"security"`
+	Tags       []openAPITag                           `json:"tags"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+// openAPIInfo describes the API.
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// openAPIServer is a server the API is reachable at.
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+// openAPITag describes a group of operations.
+type openAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// openAPIOperation describes an operation on a path.
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+// openAPIParameter describes a path or query parameter.
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+// openAPIRequestBody describes the body of a request.
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+// openAPIResponse describes a response.
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIHeader describes a response header.
+type openAPIHeader struct {
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+// openAPIMediaType describes the schema of a body in a content type.
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+// openAPIComponents holds the schemas and security schemes referenced by the operations.
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+// openAPISecurityScheme describes a way of authenticating requests.
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// openAPISchema is a JSON schema of a body, parameter or header.
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// apiOperation documents a route registered in NewHandler.
+type apiOperation struct {
+	method    string
+	pattern   string
+	id        string
+	tag       string
+	summary   string
+	query     []apiQueryParam
+	request   *apiBody
+	responses []apiResponse
+	// noAPIKey marks routes rejecting API key authentication.
+	noAPIKey bool
+}
+
+// apiQueryParam documents a query parameter.
+type apiQueryParam struct {
+	name        string
+	description string
+	schema      *openAPISchema
+}
+
+// apiBody documents a request or response body: a Go value of the body type in a content type.
+type apiBody struct {
+	contentType string
+	value       any
+}
+
+// apiResponse documents a response of an operation.
+type apiResponse struct {
+	status      int
+	description string
+	body        *apiBody
+	// location describes the Location header of the response, if any.
+	location string
+}
+
+// Function to document a JSON body of the type of v.
+func jsonBody(v any) *apiBody {
+	return &apiBody{contentType: JSONContentType, value: v}
+}
+
+// Bodies that are not JSON. Failures without a documented body carry problemBody.
+var (
+	problemBody = &apiBody{contentType: middlewares.ProblemContentType, value: middlewares.Problem{}}
+	textBody    = &apiBody{contentType: PlainTextContentType, value: ""}
+	htmlBody    = &apiBody{contentType: HTMLContentType, value: ""}
+	ndjsonBody  = &apiBody{contentType: NDJSONContentType, value: AuditEventResponse{}}
+)
+
+// Schemas of query parameters.
+var (
+	stringSchema   = &openAPISchema{Type: "string"}
+	integerSchema  = &openAPISchema{Type: "integer"}
+	dateTimeSchema = &openAPISchema{Type: "string", Format: "date-time"}
+)
+
+// redirectLocation describes the Location header of redirects.
+const redirectLocation = "The redirect target."
+
+// Responses shared by operations.
+var (
+	respBadRequest        = apiResponse{status: http.StatusBadRequest, description: "The request is malformed."}
+	respForbidden         = apiResponse{status: http.StatusForbidden, description: "The caller is not allowed to perform the operation."}
+	respNotFound          = apiResponse{status: http.StatusNotFound, description: "The resource does not exist or is not visible to the caller."}
+	respNoContent         = apiResponse{status: http.StatusNoContent, description: "The operation succeeded without a response body."}
+	respEmpty             = apiResponse{status: http.StatusNoContent, description: "The list is empty."}
+	respTooManyRequests   = apiResponse{status: http.StatusTooManyRequests, description: "The rate limit of the route class was exceeded."}
+	respInvalidURL        = apiResponse{status: http.StatusBadRequest, description: "The request is malformed or the destination URL is invalid.", body: problemBody}
+	respDeniedByPolicy    = apiResponse{status: http.StatusForbidden, description: "The destination is denied by policy or the user is blocked.", body: problemBody}
+	respAdminForbidden    = apiResponse{status: http.StatusForbidden, description: "The caller is not an operator."}
+	respWorkspaceConflict = apiResponse{status: http.StatusConflict, description: "The operation would leave the workspace without an owner or duplicate a member."}
+	respDeletionQueued    = apiResponse{status: http.StatusAccepted, description: "The deletions were queued as a deletion job.", body: jsonBody(DeletionJobResponse{}), location: "The deletion job to poll."}
+	respDeletionQueueFull = apiResponse{status: http.StatusServiceUnavailable, description: "The deletion queue is full. Retry after the delay in the Retry-After header."}
+)
+
+// Query parameters shared by operations.
+var (
+	queryLimit     = apiQueryParam{name: "limit", description: "Maximum number of items to return.", schema: integerSchema}
+	queryOffset    = apiQueryParam{name: "offset", description: "Number of items to skip.", schema: integerSchema}
+	queryURLFilter = []apiQueryParam{
+		{name: "slug", description: "Substring of the slug.", schema: stringSchema},
+		{name: "target", description: "Substring of the destination URL.", schema: stringSchema},
+		{name: "owner", description: "ID of the owning user.", schema: stringSchema},
+		queryLimit,
+		queryOffset,
+	}
+	queryAuditFilter = []apiQueryParam{
+		{name: "actor", description: "ID of the acting user.", schema: stringSchema},
+		{name: "action", description: "Audited action, e.g. `shorten` or `admin_block`.", schema: stringSchema},
+		{name: "slug", description: "Slug the event concerns.", schema: stringSchema},
+		{name: "since", description: "Earliest event time, RFC 3339.", schema: dateTimeSchema},
+		{name: "until", description: "Latest event time, RFC 3339.", schema: dateTimeSchema},
+		queryLimit,
+		queryOffset,
+	}
+)
+
+// apiTags describes the tags of the API description.
+var apiTags = []openAPITag{
+	{Name: tagLinks, Description: "Shortening, expanding and deleting links."},
+	{Name: tagAccount, Description: "Accounts, sessions, bearer tokens and single sign-on."},
+	{Name: tagKeys, Description: "API keys for programmatic access."},
+	{Name: tagWebhooks, Description: "Webhooks notified about events on the user's links."},
+	{Name: tagWorkspaces, Description: "Workspaces sharing links between members."},
+	{Name: tagReports, Description: "Reporting links leading to abusive content."},
+	{Name: tagAdmin, Description: "Operator moderation, user management, the audit log and the background jobs."},
+	{Name: tagService, Description: "Service status, statistics and this description."},
+}
+
+// apiOperations documents every route registered in NewHandler.
+var apiOperations = []apiOperation{
+	{
+		method: http.MethodGet, pattern: "/{slug}", id: "expandURL", tag: tagLinks,
+		summary: "Redirect to the destination of a short link.",
+		responses: []apiResponse{
+			{status: http.StatusTemporaryRedirect, description: "Redirect to the destination URL.", location: redirectLocation},
+			{status: http.StatusBadRequest, description: "The link does not exist."},
+			{status: http.StatusForbidden, description: "The destination is denied by policy, or the link is inactive and inactive links respond with 403."},
+			{status: http.StatusNotFound, description: "The link is outside its activation window or schedule.", body: htmlBody},
+			{status: http.StatusGone, description: "The link was deleted."},
+			{status: http.StatusUnavailableForLegalReasons, description: "The link was disabled or taken down.", body: htmlBody},
+			respTooManyRequests,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/", id: "shortenURL", tag: tagLinks,
+		summary: "Shorten the URL in the plain text body.",
+		request: textBody,
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "The short URL.", body: textBody},
+			{status: http.StatusConflict, description: "The URL was shortened before; the existing short URL.", body: textBody},
+			respInvalidURL,
+			respDeniedByPolicy,
+			respTooManyRequests,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/shorten", id: "shortenURLJSON", tag: tagLinks,
+		summary: "Shorten a URL, optionally inside a workspace and with an activation window or schedule.",
+		request: jsonBody(ShortenURLRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "The short URL.", body: jsonBody(ShortenURLResponse{})},
+			{status: http.StatusConflict, description: "The URL was shortened before; the existing short URL.", body: jsonBody(ShortenURLResponse{})},
+			respInvalidURL,
+			respDeniedByPolicy,
+			{status: http.StatusNotFound, description: "The workspace does not exist or the user is not a member."},
+			respTooManyRequests,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/shorten/batch", id: "shortenURLBatch", tag: tagLinks,
+		summary: "Shorten a batch of URLs.",
+		request: jsonBody([]BatchShortenURLRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "Every item was created; the short URLs by correlation ID.", body: jsonBody([]BatchShortenURLResponse{})},
+			{status: http.StatusMultiStatus, description: "Some items already existed or were invalid; the outcome of every item by correlation ID.", body: jsonBody([]BatchShortenURLResponse{})},
+			respBadRequest,
+			{status: http.StatusForbidden, description: "The user is blocked."},
+			respTooManyRequests,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/user/urls", id: "listUserURLs", tag: tagLinks,
+		summary: "List the links of the current user.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The links of the user.", body: jsonBody([]UserURL{})},
+			respEmpty,
+			respBadRequest,
+		},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/user/urls", id: "deleteUserURLs", tag: tagLinks,
+		summary: "Delete links of the current user by slug in the background.",
+		request: jsonBody([]string{}),
+		responses: []apiResponse{
+			respDeletionQueued,
+			respBadRequest,
+			respTooManyRequests,
+			respDeletionQueueFull,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/user/jobs/{id}", id: "getDeletionJob", tag: tagLinks,
+		summary: "Get the status of a deletion job of the current user, slug by slug.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The deletion job.", body: jsonBody(DeletionJobResponse{})},
+			respBadRequest,
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/report/{slug}", id: "reportURL", tag: tagReports,
+		summary: "Report a link leading to abusive content.",
+		request: jsonBody(AbuseReportRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusAccepted, description: "The report was filed for review."},
+			respBadRequest,
+			respNotFound,
+			respTooManyRequests,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/user/register", id: "register", tag: tagAccount, noAPIKey: true,
+		summary: "Register an account. An anonymous current identity becomes the account.",
+		request: jsonBody(RegisterRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "The registered account.", body: jsonBody(AccountResponse{})},
+			{status: http.StatusBadRequest, description: "The request is malformed or the credentials are invalid.", body: problemBody},
+			{status: http.StatusConflict, description: "The email or username is taken."},
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/user/login", id: "login", tag: tagAccount, noAPIKey: true,
+		summary: "Log in to an account. Links of an anonymous current identity are merged into the account.",
+		request: jsonBody(LoginRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The account logged in to.", body: jsonBody(AccountResponse{})},
+			respBadRequest,
+			{status: http.StatusUnauthorized, description: "The credentials are wrong."},
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/user/logout", id: "logout", tag: tagAccount,
+		summary:   "Log out, revoking the current session.",
+		responses: []apiResponse{respNoContent},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/auth/token", id: "issueToken", tag: tagAccount,
+		summary: "Exchange the cookie identity for a bearer token.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The bearer token.", body: jsonBody(TokenResponse{})},
+			{status: http.StatusForbidden, description: "The request is not authenticated with a cookie."},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/auth/oidc/login", id: "oidcLogin", tag: tagAccount,
+		summary: "Start single sign-on with the identity provider.",
+		responses: []apiResponse{
+			{status: http.StatusFound, description: "Redirect to the identity provider.", location: redirectLocation},
+			{status: http.StatusBadGateway, description: "The identity provider is unavailable."},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/auth/oidc/callback", id: "oidcCallback", tag: tagAccount,
+		summary: "Complete single sign-on and start a session.",
+		query: []apiQueryParam{
+			{name: "code", description: "Authorization code issued by the identity provider.", schema: stringSchema},
+			{name: "state", description: "State of the login attempt.", schema: stringSchema},
+		},
+		responses: []apiResponse{
+			{status: http.StatusFound, description: "Redirect to the post-login page.", location: redirectLocation},
+			respBadRequest,
+			{status: http.StatusUnauthorized, description: "The identity provider rejected the login."},
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/user/keys", id: "createAPIKey", tag: tagKeys, noAPIKey: true,
+		summary: "Create an API key. The key is only returned once.",
+		request: jsonBody(CreateAPIKeyRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "The created API key.", body: jsonBody(CreateAPIKeyResponse{})},
+			respBadRequest,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/user/keys", id: "listAPIKeys", tag: tagKeys, noAPIKey: true,
+		summary: "List the API keys of the current user.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The API keys.", body: jsonBody([]APIKeyResponse{})},
+			respEmpty,
+		},
+	},
+	{
+		method: http.MethodPatch, pattern: "/api/user/keys/{id}", id: "updateAPIKey", tag: tagKeys, noAPIKey: true,
+		summary:   "Relabel an API key.",
+		request:   jsonBody(UpdateAPIKeyRequest{}),
+		responses: []apiResponse{respNoContent, respBadRequest, respNotFound},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/user/keys/{id}", id: "revokeAPIKey", tag: tagKeys, noAPIKey: true,
+		summary:   "Revoke an API key.",
+		responses: []apiResponse{respNoContent, respNotFound},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/user/webhooks", id: "createWebhook", tag: tagWebhooks, noAPIKey: true,
+		summary: "Register a webhook. The signing secret is only returned once.",
+		request: jsonBody(CreateWebhookRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "The registered webhook.", body: jsonBody(CreateWebhookResponse{})},
+			respBadRequest,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/user/webhooks", id: "listWebhooks", tag: tagWebhooks, noAPIKey: true,
+		summary: "List the webhooks of the current user.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The webhooks.", body: jsonBody([]WebhookResponse{})},
+			respEmpty,
+		},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/user/webhooks/{id}", id: "deleteWebhook", tag: tagWebhooks, noAPIKey: true,
+		summary:   "Remove a webhook. Its pending deliveries are dead-lettered.",
+		responses: []apiResponse{respNoContent, respNotFound},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/user/webhooks/{id}/deliveries", id: "listWebhookDeliveries", tag: tagWebhooks, noAPIKey: true,
+		summary: "List the delivery log of a webhook, newest first.",
+		query:   []apiQueryParam{queryLimit},
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The deliveries.", body: jsonBody([]WebhookDeliveryResponse{})},
+			respEmpty,
+			respBadRequest,
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/workspaces", id: "createWorkspace", tag: tagWorkspaces, noAPIKey: true,
+		summary: "Create a workspace owned by the current user.",
+		request: jsonBody(CreateWorkspaceRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "The created workspace.", body: jsonBody(WorkspaceResponse{})},
+			respBadRequest,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/workspaces", id: "listWorkspaces", tag: tagWorkspaces,
+		summary: "List the workspaces of the current user.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The workspaces with the role of the user.", body: jsonBody([]WorkspaceResponse{})},
+			respEmpty,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/workspaces/join", id: "acceptWorkspaceInvitation", tag: tagWorkspaces, noAPIKey: true,
+		summary: "Join a workspace with an invitation token.",
+		request: jsonBody(AcceptWorkspaceInvitationRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The membership.", body: jsonBody(WorkspaceMemberResponse{})},
+			respBadRequest,
+			respNotFound,
+			respWorkspaceConflict,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/workspaces/{workspaceID}/members", id: "listWorkspaceMembers", tag: tagWorkspaces,
+		summary: "List the members of a workspace.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The members.", body: jsonBody([]WorkspaceMemberResponse{})},
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodPatch, pattern: "/api/workspaces/{workspaceID}/members/{userID}", id: "updateWorkspaceMember", tag: tagWorkspaces, noAPIKey: true,
+		summary:   "Change the role of a member. Requires the owner role.",
+		request:   jsonBody(UpdateWorkspaceMemberRequest{}),
+		responses: []apiResponse{respNoContent, respBadRequest, respForbidden, respNotFound, respWorkspaceConflict},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/workspaces/{workspaceID}/members/{userID}", id: "removeWorkspaceMember", tag: tagWorkspaces, noAPIKey: true,
+		summary:   "Remove a member. Owners remove anyone; members remove themselves.",
+		responses: []apiResponse{respNoContent, respForbidden, respNotFound, respWorkspaceConflict},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/workspaces/{workspaceID}/invitations", id: "createWorkspaceInvitation", tag: tagWorkspaces, noAPIKey: true,
+		summary: "Invite a member with a role. The token is only returned once.",
+		request: jsonBody(CreateWorkspaceInvitationRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusCreated, description: "The invitation.", body: jsonBody(CreateWorkspaceInvitationResponse{})},
+			respBadRequest,
+			respForbidden,
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/workspaces/{workspaceID}/invitations", id: "listWorkspaceInvitations", tag: tagWorkspaces, noAPIKey: true,
+		summary: "List the invitations of a workspace.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The invitations.", body: jsonBody([]WorkspaceInvitationResponse{})},
+			respEmpty,
+			respForbidden,
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/workspaces/{workspaceID}/invitations/{invitationID}", id: "revokeWorkspaceInvitation", tag: tagWorkspaces, noAPIKey: true,
+		summary:   "Revoke an invitation.",
+		responses: []apiResponse{respNoContent, respForbidden, respNotFound},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/workspaces/{workspaceID}/urls", id: "listWorkspaceURLs", tag: tagWorkspaces,
+		summary: "List the links of a workspace.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The links.", body: jsonBody([]WorkspaceURL{})},
+			respEmpty,
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodPatch, pattern: "/api/workspaces/{workspaceID}/urls/{slug}", id: "updateWorkspaceURL", tag: tagWorkspaces,
+		summary: "Change the destination of a workspace link. Requires the editor role.",
+		request: jsonBody(UpdateURLRequest{}),
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The updated link.", body: jsonBody(WorkspaceURL{})},
+			respInvalidURL,
+			respDeniedByPolicy,
+			respNotFound,
+			{status: http.StatusConflict, description: "Another link already leads to the destination."},
+			respTooManyRequests,
+		},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/workspaces/{workspaceID}/urls", id: "deleteWorkspaceURLs", tag: tagWorkspaces,
+		summary: "Delete workspace links by slug in the background. Requires the editor role.",
+		request: jsonBody([]string{}),
+		responses: []apiResponse{
+			respDeletionQueued,
+			respBadRequest,
+			respForbidden,
+			respNotFound,
+			respTooManyRequests,
+			respDeletionQueueFull,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/urls", id: "adminSearchURLs", tag: tagAdmin, noAPIKey: true,
+		summary: "Search the links of all users.",
+		query:   queryURLFilter,
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The matching links.", body: jsonBody([]AdminURL{})},
+			respEmpty,
+			respBadRequest,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodPatch, pattern: "/api/admin/urls/{slug}", id: "adminUpdateURL", tag: tagAdmin, noAPIKey: true,
+		summary:   "Disable or re-enable a link. Disabled links respond with 451.",
+		request:   jsonBody(AdminUpdateURLRequest{}),
+		responses: []apiResponse{respNoContent, respBadRequest, respAdminForbidden, respNotFound},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/admin/urls/{slug}", id: "adminDeleteURL", tag: tagAdmin, noAPIKey: true,
+		summary:   "Delete a link.",
+		responses: []apiResponse{respNoContent, respAdminForbidden, respNotFound},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/users", id: "adminListUsers", tag: tagAdmin, noAPIKey: true,
+		summary: "List the link counts of users, narrowed by the link search parameters.",
+		query:   queryURLFilter,
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The users.", body: jsonBody([]AdminUserResponse{})},
+			respEmpty,
+			respBadRequest,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/users/blocked", id: "adminListBlockedUsers", tag: tagAdmin, noAPIKey: true,
+		summary: "List the blocked users.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The blocked users.", body: jsonBody([]BlockedUserResponse{})},
+			respEmpty,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/users/flagged", id: "adminListFlaggedUsers", tag: tagAdmin, noAPIKey: true,
+		summary: "List the users flagged as repeat offenders.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The flagged users.", body: jsonBody([]FlaggedUserResponse{})},
+			respEmpty,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/users/{userID}", id: "adminGetUser", tag: tagAdmin, noAPIKey: true,
+		summary: "Get the link counts, block status and repeat offender flag of a user.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The user.", body: jsonBody(AdminUserResponse{})},
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodPut, pattern: "/api/admin/users/{userID}/block", id: "adminBlockUser", tag: tagAdmin, noAPIKey: true,
+		summary:   "Block a user. Blocked users cannot create links, and their links respond with 451.",
+		request:   jsonBody(BlockUserRequest{}),
+		responses: []apiResponse{respNoContent, respBadRequest, respAdminForbidden},
+	},
+	{
+		method: http.MethodDelete, pattern: "/api/admin/users/{userID}/block", id: "adminUnblockUser", tag: tagAdmin, noAPIKey: true,
+		summary:   "Unblock a user.",
+		responses: []apiResponse{respNoContent, respAdminForbidden, respNotFound},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/reports", id: "adminListReports", tag: tagAdmin, noAPIKey: true,
+		summary: "List the moderation queue.",
+		query: []apiQueryParam{
+			{name: "status", description: "Report status: `open` (default), `dismissed`, `taken_down` or `all`.", schema: stringSchema},
+		},
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The reports.", body: jsonBody([]AbuseReportResponse{})},
+			respEmpty,
+			respBadRequest,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/admin/reports/{slug}/takedown", id: "adminTakedownURL", tag: tagAdmin, noAPIKey: true,
+		summary: "Take a reported link down, resolving its open reports.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The outcome of the review.", body: jsonBody(ModerationResultResponse{})},
+			respAdminForbidden,
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodPost, pattern: "/api/admin/reports/{slug}/dismiss", id: "adminDismissReports", tag: tagAdmin, noAPIKey: true,
+		summary: "Dismiss the open reports on a link.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The outcome of the review.", body: jsonBody(ModerationResultResponse{})},
+			respAdminForbidden,
+			respNotFound,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/audit", id: "adminListAuditEvents", tag: tagAdmin, noAPIKey: true,
+		summary: "Query the audit log.",
+		query:   queryAuditFilter,
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The audit events, oldest first.", body: jsonBody([]AuditEventResponse{})},
+			respEmpty,
+			respBadRequest,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/audit/export", id: "adminExportAuditEvents", tag: tagAdmin, noAPIKey: true,
+		summary: "Export the audit log as newline-delimited JSON.",
+		query:   queryAuditFilter,
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "One audit event per line.", body: ndjsonBody},
+			respBadRequest,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/admin/jobs", id: "adminListJobs", tag: tagAdmin, noAPIKey: true,
+		summary: "List the background jobs and their states.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The background jobs.", body: jsonBody([]AdminJobResponse{})},
+			respEmpty,
+			respAdminForbidden,
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/internal/stats", id: "getServiceStats", tag: tagService,
+		summary: "Get the number of links and users.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The service stats.", body: jsonBody(ServiceStatsResponse{})},
+			{status: http.StatusNoContent, description: "The stats are unavailable."},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/ping", id: "ping", tag: tagService,
+		summary: "Check the storage connection. Prefer the readiness probe.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The storage is reachable."},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/metrics", id: "getMetrics", tag: tagService,
+		summary: "Get the metrics in the Prometheus text exposition format. Served when metrics are enabled without a separate listener.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The metrics.", body: textBody},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/healthz", id: "getLiveness", tag: tagService,
+		summary: "Check that the process is alive.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The process is alive.", body: jsonBody(HealthResponse{})},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/readyz", id: "getReadiness", tag: tagService,
+		summary: "Check that the application is ready to serve requests: started, not shutting down, and its storage and background deleter available.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The application is ready.", body: jsonBody(HealthResponse{})},
+			{status: http.StatusServiceUnavailable, description: "A readiness check failed.", body: jsonBody(HealthResponse{})},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/openapi.json", id: "getOpenAPIDocument", tag: tagService,
+		summary: "Get this OpenAPI description.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The OpenAPI 3 document.", body: jsonBody(map[string]any{})},
+		},
+	},
+	{
+		method: http.MethodGet, pattern: "/api/docs", id: "getAPIDocs", tag: tagService,
+		summary: "Browse this OpenAPI description. Available if enabled in the configuration.",
+		responses: []apiResponse{
+			{status: http.StatusOK, description: "The documentation page.", body: htmlBody},
+		},
+	},
+}
+
+// WithAPIDocs enables the documentation page rendering the OpenAPI description at `/api/docs`.
+func WithAPIDocs(enabled bool) HandlerOption {
+	return func(h *Handler) {
+		h.apiDocs = enabled
+	}
+}
+
+// Method to handle getting the OpenAPI description of the registered routes.
+func (h *Handler) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	h.respondWithJson(w, http.StatusOK, h.openAPI)
+}
+
+// Method to handle getting the documentation page rendering the OpenAPI description.
+func (h *Handler) HandleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", HTMLContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(apiDocsPage); err != nil {
+		slog.Error("writing api docs page", slog.Any("error", err))
+	}
+}
+
+// Method to build the OpenAPI description of the routes registered in the router.
+// Routes without an operation in apiOperations are left out.
+func (h *Handler) newOpenAPIDocument() *openAPIDocument {
+	operations := make(map[string]apiOperation, len(apiOperations))
+	for _, op := range apiOperations {
+		operations[op.method+" "+op.pattern] = op
+	}
+
+	doc := &openAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: openAPIInfo{
+			Title:       "ShortURL Service",
+			Description: "Shortens long URLs into manageable links. Requests without credentials are given an anonymous identity in the `" + middlewares.CookieName + "` cookie.",
+			Version:     apiVersion,
+		},
+		Tags:  apiTags,
+		Paths: map[string]map[string]openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*openAPISchema{},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				securityCookie: {Type: "apiKey", In: "cookie", Name: middlewares.CookieName, Description: "Signed session cookie, issued on the first request."},
+				securityAPIKey: {Type: "apiKey", In: "header", Name: middlewares.APIKeyHeader, Description: "API key created at `/api/user/keys`."},
+			},
+		},
+	}
+	if h.baseURL != "" {
+		doc.Servers = []openAPIServer{{URL: h.baseURL}}
+	}
+	if h.tokens != nil {
+		doc.Components.SecuritySchemes[securityBearer] = openAPISecurityScheme{Type: "http", Scheme: "bearer", Description: "Bearer token issued at `/api/auth/token`."}
+	}
+	doc.Security = h.openAPISecurity(false)
+
+	schemas := schemaBuilder{schemas: doc.Components.Schemas}
+	err := chi.Walk(h.Router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		pattern := normalizeRoutePattern(route)
+		op, ok := operations[method+" "+pattern]
+		if !ok {
+			slog.Warn("route missing from the openapi description", slog.String("method", method), slog.String("route", pattern))
+			return nil
+		}
+		if doc.Paths[pattern] == nil {
+			doc.Paths[pattern] = map[string]openAPIOperation{}
+		}
+		doc.Paths[pattern][strings.ToLower(method)] = h.newOpenAPIOperation(op, &schemas)
+		return nil
+	})
+	if err != nil {
+		slog.Error("walking routes", slog.Any("error", err))
+	}
+	return doc
+}
+
+// Method to convert a documented route into an OpenAPI operation.
+func (h *Handler) newOpenAPIOperation(op apiOperation, schemas *schemaBuilder) openAPIOperation {
+	operation := openAPIOperation{
+		OperationID: op.id,
+		Summary:     op.summary,
+		Tags:        []string{op.tag},
+		Responses:   map[string]openAPIResponse{},
+	}
+	if op.noAPIKey {
+		operation.Security = h.openAPISecurity(true)
+	}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(op.pattern, -1) {
+		operation.Parameters = append(operation.Parameters, openAPIParameter{Name: match[1], In: "path", Required: true, Schema: stringSchema})
+	}
+	for _, q := range op.query {
+		operation.Parameters = append(operation.Parameters, openAPIParameter{Name: q.name, In: "query", Description: q.description, Schema: q.schema})
+	}
+	if op.request != nil {
+		operation.RequestBody = &openAPIRequestBody{Required: true, Content: schemas.content(op.request)}
+	}
+	for _, resp := range op.responses {
+		response := openAPIResponse{Description: resp.description}
+		if response.Description == "" {
+			response.Description = http.StatusText(resp.status)
+		}
+		if resp.body == nil && resp.status >= http.StatusBadRequest {
+			resp.body = problemBody
+		}
+		if resp.body != nil {
+			response.Content = schemas.content(resp.body)
+		}
+		if resp.location != "" {
+			response.Headers = map[string]openAPIHeader{"Location": {Description: resp.location, Schema: stringSchema}}
+		}
+		operation.Responses[strconv.Itoa(resp.status)] = response
+	}
+	operation.Responses[strconv.Itoa(http.StatusInternalServerError)] = openAPIResponse{
+		Description: http.StatusText(http.StatusInternalServerError),
+		Content:     schemas.content(problemBody),
+	}
+	return operation
+}
+
+// Method to list the security requirements of the API: any enabled scheme or none, as anonymous identities are issued.
+func (h *Handler) openAPISecurity(noAPIKey bool) []map[string][]string {
+	security := []map[string][]string{{securityCookie: {}}}
+	if h.tokens != nil {
+		security = append(security, map[string][]string{securityBearer: {}})
+	}
+	if !noAPIKey {
+		security = append(security, map[string][]string{securityAPIKey: {}})
+	}
+	return append(security, map[string][]string{})
+}
+
+// Function to convert a chi route pattern into an OpenAPI path: subrouter roots lose their trailing slash.
+func normalizeRoutePattern(route string) string {
+	if route != "/" {
+		route = strings.TrimSuffix(route, "/")
+	}
+	return route
+}
+
+// schemaBuilder generates the schemas of Go types, registering named structs as components.
+type schemaBuilder struct {
+	schemas map[string]*openAPISchema
+}
+
+// content returns the media type map of a body.
+func (b *schemaBuilder) content(body *apiBody) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{body.contentType: {Schema: b.schema(reflect.TypeOf(body.value))}}
+}
+
+// schema returns the schema of a Go type as encoded by encoding/json.
+func (b *schemaBuilder) schema(t reflect.Type) *openAPISchema {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.schemas[t.Name()]; !ok {
+			// Reserve the name first so that recursive types terminate.
+			b.schemas[t.Name()] = &openAPISchema{}
+			*b.schemas[t.Name()] = *b.structSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + t.Name()}
+	default:
+		return &openAPISchema{}
+	}
+}
+
+// structSchema returns the object schema of a struct. Fields of embedded structs are promoted
+// and fields without omitempty are required, as with encoding/json.
+func (b *schemaBuilder) structSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				promoted := b.structSchema(embedded)
+				for k, v := range promoted.Properties {
+					s.Properties[k] = v
+				}
+				s.Required = append(s.Required, promoted.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = b.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
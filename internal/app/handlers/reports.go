@@ -0,0 +1,228 @@
+// Package handlers provides HTTP request handlers for abuse reports and the moderation queue.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/moderation"
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultTakedownPage is the explanation page served for taken down URLs when none is configured.
+var defaultTakedownPage = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Link unavailable</title></head>
+<body>
+<h1>This link has been taken down</h1>
+<p>The link was disabled by the operators of this service following a review for abuse, such as spam, phishing or malware.</p>
+<p>The record of the link is kept, but it no longer redirects to its destination.</p>
+</body>
+</html>
+`)
+
+// AbuseReportRequest represents the request payload for reporting a URL.
+type AbuseReportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+}
+
+// AbuseReportResponse represents an abuse report in the moderation queue.
+type AbuseReportResponse struct {
+	ID         string     `json:"id"`
+	Slug       string     `json:"slug"`
+	ShortURL   string     `json:"short_url"`
+	OwnerID    string     `json:"owner_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details,omitempty"`
+	ReporterID string     `json:"reporter_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Status     string     `json:"status"`
+	ReviewedBy string     `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// ModerationResultResponse represents the outcome of reviewing the reports on a URL.
+type ModerationResultResponse struct {
+	Slug     string `json:"slug"`
+	Status   string `json:"status"`
+	Resolved int    `json:"resolved"`
+}
+
+// FlaggedUserResponse represents a user flagged as a repeat offender.
+type FlaggedUserResponse struct {
+	UserID    string    `json:"user_id"`
+	Reason    string    `json:"reason"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// WithModerationService sets the service filing abuse reports and taking reported URLs down.
+func WithModerationService(s *moderation.Service) HandlerOption {
+	return func(h *Handler) {
+		h.moderation = s
+	}
+}
+
+// WithTakedownPage sets the HTML page served with 451 for taken down URLs.
+// Without a page, a built-in explanation page is served.
+func WithTakedownPage(page []byte) HandlerOption {
+	return func(h *Handler) {
+		h.takedownPage = page
+	}
+}
+
+// Method to handle reporting a URL leading to abusive content.
+func (h *Handler) HandleReportURL(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var reportReq AbuseReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&reportReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	if err := h.moderation.Report(r.Context(), slug, userID, middlewares.ClientIP(r), reportReq.Reason, reportReq.Details); err != nil {
+		switch {
+		case errors.Is(err, moderation.ErrInvalidReport):
+			h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidReport, "The report reason is unknown or the details are too long.")
+		case errors.Is(err, moderation.ErrNotFound):
+			h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "No URL is shortened to "+slug+".")
+		default:
+			slog.Error("reporting url", slog.String("slug", slug), slog.Any("error", err))
+			h.respondInternalError(w, r)
+		}
+		return
+	}
+	slog.Info("url reported", slog.String("slug", slug), slog.String("reporter", userID), slog.String("reason", reportReq.Reason))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Method to handle listing the moderation queue. The `status` query parameter defaults to open reports;
+// `all` lists reports of every status.
+func (h *Handler) HandleAdminListReports(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = repository.ReportStatusOpen
+	case "all":
+		status = ""
+	case repository.ReportStatusOpen, repository.ReportStatusDismissed, repository.ReportStatusTakenDown:
+	default:
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, "The status must be all, open, dismissed or taken_down.", middlewares.FieldError{Field: "status", Code: "invalid", Message: "Unknown status " + status + "."})
+		return
+	}
+
+	reports, err := h.moderation.Queue(r.Context(), status)
+	if err != nil {
+		slog.Error("listing abuse reports", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(reports) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]AbuseReportResponse, 0, len(reports))
+	for _, rep := range reports {
+		resp = append(resp, AbuseReportResponse{
+			ID:         rep.ID,
+			Slug:       rep.Slug,
+			ShortURL:   h.baseURL + "/" + rep.Slug,
+			OwnerID:    rep.OwnerID,
+			Reason:     rep.Reason,
+			Details:    rep.Details,
+			ReporterID: rep.ReporterID,
+			CreatedAt:  rep.CreatedAt,
+			Status:     rep.Status,
+			ReviewedBy: rep.ReviewedBy,
+			ReviewedAt: rep.ReviewedAt,
+		})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle taking a reported URL down. The URL responds with 451 afterwards.
+func (h *Handler) HandleAdminTakedownURL(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := h.getUserIDFromCtx(r)
+
+	slug := chi.URLParam(r, "slug")
+	resolved, err := h.moderation.Takedown(r.Context(), slug, adminID)
+	if err != nil {
+		if errors.Is(err, moderation.ErrNotFound) {
+			h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "No URL is shortened to "+slug+".")
+			return
+		}
+		slog.Error("taking url down", slog.String("slug", slug), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("url taken down", slog.String("admin", adminID), slog.String("slug", slug), slog.Int("reports", resolved))
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionAdminTakedown, Slugs: []string{slug}})
+	h.respondWithJson(w, http.StatusOK, ModerationResultResponse{Slug: slug, Status: repository.ReportStatusTakenDown, Resolved: resolved})
+}
+
+// Method to handle dismissing the open reports on a URL.
+func (h *Handler) HandleAdminDismissReports(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := h.getUserIDFromCtx(r)
+
+	slug := chi.URLParam(r, "slug")
+	resolved, err := h.moderation.Dismiss(r.Context(), slug, adminID)
+	if err != nil {
+		if errors.Is(err, moderation.ErrNotFound) {
+			h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "No URL is shortened to "+slug+".")
+			return
+		}
+		slog.Error("dismissing abuse reports", slog.String("slug", slug), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("abuse reports dismissed", slog.String("admin", adminID), slog.String("slug", slug), slog.Int("reports", resolved))
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionAdminDismiss, Slugs: []string{slug}})
+	h.respondWithJson(w, http.StatusOK, ModerationResultResponse{Slug: slug, Status: repository.ReportStatusDismissed, Resolved: resolved})
+}
+
+// Method to handle listing the users flagged as repeat offenders.
+func (h *Handler) HandleAdminListFlaggedUsers(w http.ResponseWriter, r *http.Request) {
+	flaggedUsers, err := h.repo.GetFlaggedUsers(r.Context())
+	if err != nil {
+		slog.Error("listing flagged users", slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(flaggedUsers) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]FlaggedUserResponse, 0, len(flaggedUsers))
+	for _, f := range flaggedUsers {
+		resp = append(resp, FlaggedUserResponse{UserID: f.UserID, Reason: f.Reason, FlaggedAt: f.FlaggedAt})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to respond to a taken down URL with 451 and the explanation page.
+func (h *Handler) respondTakenDown(w http.ResponseWriter) {
+	page := h.takedownPage
+	if len(page) == 0 {
+		page = defaultTakedownPage
+	}
+	w.Header().Set("Content-Type", HTMLContentType)
+	w.WriteHeader(http.StatusUnavailableForLegalReasons)
+	if _, err := w.Write(page); err != nil {
+		slog.Error("writing takedown page", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,224 @@
+// Package handlers provides HTTP request handlers for user webhooks and their delivery log.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/gennadis/shorturl/internal/app/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultDeliveryLogSize is the number of deliveries returned when no limit is requested.
+const defaultDeliveryLogSize = 50
+
+// CreateWebhookRequest represents the request payload for registering a webhook.
+type CreateWebhookRequest struct {
+	URL            string   `json:"url"`
+	Secret         string   `json:"secret,omitempty"`
+	Events         []string `json:"events,omitempty"`
+	ClickThreshold int      `json:"click_threshold,omitempty"`
+}
+
+// WebhookResponse represents a webhook. The secret is never returned after registration.
+type WebhookResponse struct {
+	ID             string    `json:"id"`
+	URL            string    `json:"url"`
+	Events         []string  `json:"events"`
+	ClickThreshold int       `json:"click_threshold,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CreateWebhookResponse represents the response payload for a registered webhook, including its secret.
+type CreateWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+// WebhookDeliveryResponse represents an entry of the delivery log of a webhook.
+type WebhookDeliveryResponse struct {
+	ID             string          `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// WithWebhookService sets the service registering webhooks and queueing their deliveries.
+func WithWebhookService(s *webhooks.Service) HandlerOption {
+	return func(h *Handler) {
+		h.webhooks = s
+	}
+}
+
+// WithClickCounter sets the counter buffering the clicks on URLs, which must be registered with the job scheduler
+// for the clicks to be flushed.
+func WithClickCounter(c *webhooks.ClickCounter) HandlerOption {
+	return func(h *Handler) {
+		h.clicks = c
+	}
+}
+
+// Function to convert a stored webhook into its response representation.
+func newWebhookResponse(w repository.Webhook) WebhookResponse {
+	return WebhookResponse{ID: w.ID, URL: w.URL, Events: w.Events, ClickThreshold: w.ClickThreshold, CreatedAt: w.CreatedAt}
+}
+
+// Method to handle registering a webhook.
+func (h *Handler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var createReq CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	webhook, err := h.webhooks.Register(r.Context(), userID, createReq.URL, createReq.Secret, createReq.Events, createReq.ClickThreshold)
+	if err != nil {
+		if errors.Is(err, webhooks.ErrInvalidWebhook) {
+			slog.Debug("invalid webhook", slog.String("user", userID), slog.Any("error", err))
+			h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidWebhook, err.Error()+".")
+			return
+		}
+		slog.Error("registering webhook", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("webhook registered", slog.String("user", userID), slog.String("webhook", webhook.ID))
+	h.respondWithJson(w, http.StatusCreated, CreateWebhookResponse{WebhookResponse: newWebhookResponse(webhook), Secret: webhook.Secret})
+}
+
+// Method to handle listing the user's webhooks.
+func (h *Handler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	list, err := h.webhooks.List(r.Context(), userID)
+	if err != nil {
+		slog.Error("listing webhooks", slog.String("user", userID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]WebhookResponse, 0, len(list))
+	for _, webhook := range list {
+		resp = append(resp, newWebhookResponse(webhook))
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle removing a webhook. Its pending deliveries are dead-lettered.
+func (h *Handler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	webhookID := chi.URLParam(r, "id")
+	if err := h.webhooks.Delete(r.Context(), userID, webhookID); err != nil {
+		if errors.Is(err, webhooks.ErrNotFound) {
+			h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "The webhook does not exist.")
+			return
+		}
+		slog.Error("deleting webhook", slog.String("user", userID), slog.String("webhook", webhookID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Info("webhook deleted", slog.String("user", userID), slog.String("webhook", webhookID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle listing the delivery log of a webhook, newest first.
+func (h *Handler) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	limit := defaultDeliveryLogSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAdminPageSize {
+			h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, "The limit must be between 1 and "+strconv.Itoa(maxAdminPageSize)+".", middlewares.FieldError{Field: "limit", Code: "out_of_range", Message: "The limit is out of range."})
+			return
+		}
+	}
+
+	webhookID := chi.URLParam(r, "id")
+	deliveries, err := h.webhooks.Deliveries(r.Context(), userID, webhookID, limit)
+	if err != nil {
+		if errors.Is(err, webhooks.ErrNotFound) {
+			h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "The webhook does not exist.")
+			return
+		}
+		slog.Error("listing webhook deliveries", slog.String("webhook", webhookID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]WebhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		entry := WebhookDeliveryResponse{
+			ID:             d.ID,
+			Event:          d.Event,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastStatusCode: d.LastStatusCode,
+			LastError:      d.LastError,
+			Payload:        d.Payload,
+			CreatedAt:      d.CreatedAt,
+			UpdatedAt:      d.UpdatedAt,
+		}
+		if d.Status == repository.DeliveryStatusPending {
+			entry.NextAttemptAt = &d.NextAttemptAt
+		}
+		resp = append(resp, entry)
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to queue the created events of URLs. A failure is logged without failing the request.
+func (h *Handler) notifyLinksCreated(ctx context.Context, urls ...repository.URL) {
+	for _, u := range urls {
+		if err := h.webhooks.LinkCreated(ctx, u); err != nil {
+			slog.Error("queueing link created event", slog.String("slug", u.Slug), slog.Any("error", err))
+		}
+	}
+}
+
+// Method to count a click on a URL. The click threshold events are queued when the counted clicks are flushed.
+func (h *Handler) countClick(url repository.URL) {
+	h.clicks.Add(url)
+}
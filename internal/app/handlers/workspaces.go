@@ -0,0 +1,465 @@
+// Package handlers provides HTTP request handlers for workspaces, their members, invitations and links.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/middlewares"
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/gennadis/shorturl/internal/app/workspaces"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateWorkspaceRequest represents the request payload for creating a workspace.
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// WorkspaceResponse represents a workspace together with the role of the current user in it.
+type WorkspaceResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceMemberResponse represents a workspace member.
+type WorkspaceMemberResponse struct {
+	UserID   string    `json:"user_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// UpdateWorkspaceMemberRequest represents the request payload for changing the role of a workspace member.
+type UpdateWorkspaceMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// CreateWorkspaceInvitationRequest represents the request payload for inviting users to a workspace.
+type CreateWorkspaceInvitationRequest struct {
+	Role string `json:"role"`
+}
+
+// WorkspaceInvitationResponse represents a workspace invitation. The token is never returned after creation.
+type WorkspaceInvitationResponse struct {
+	ID         string     `json:"id"`
+	Role       string     `json:"role"`
+	InvitedBy  string     `json:"invited_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedBy string     `json:"accepted_by,omitempty"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+// CreateWorkspaceInvitationResponse represents the response payload for a created invitation, including its token.
+type CreateWorkspaceInvitationResponse struct {
+	WorkspaceInvitationResponse
+	Token string `json:"token"`
+}
+
+// AcceptWorkspaceInvitationRequest represents the request payload for joining a workspace.
+type AcceptWorkspaceInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+// WorkspaceURL represents a workspace URL entry.
+type WorkspaceURL struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	CreatedBy   string `json:"created_by"`
+}
+
+// UpdateURLRequest represents the request payload for changing the destination of a shortened URL.
+type UpdateURLRequest struct {
+	OriginalURL string `json:"url"`
+}
+
+// WithWorkspaceService sets the service managing workspaces and authorizing their members.
+func WithWorkspaceService(s *workspaces.Service) HandlerOption {
+	return func(h *Handler) {
+		h.workspaces = s
+	}
+}
+
+// Function to convert a stored invitation into its response representation.
+func newWorkspaceInvitationResponse(inv repository.WorkspaceInvitation) WorkspaceInvitationResponse {
+	return WorkspaceInvitationResponse{
+		ID:         inv.ID,
+		Role:       inv.Role,
+		InvitedBy:  inv.InvitedBy,
+		CreatedAt:  inv.CreatedAt,
+		ExpiresAt:  inv.ExpiresAt,
+		AcceptedBy: inv.AcceptedBy,
+		AcceptedAt: inv.AcceptedAt,
+	}
+}
+
+// Method to handle creating a workspace owned by the current user.
+func (h *Handler) HandleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var createReq CreateWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	workspace, err := h.workspaces.Create(r.Context(), userID, createReq.Name)
+	if err != nil {
+		h.respondWorkspaceError(w, r, userID, "", err)
+		return
+	}
+	slog.Debug("workspace created", slog.String("user", userID), slog.String("workspace", workspace.ID))
+	h.respondWithJson(w, http.StatusCreated, WorkspaceResponse{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Role:      workspace.Role,
+		CreatedAt: workspace.CreatedAt,
+	})
+}
+
+// Method to handle listing the workspaces the current user is a member of.
+func (h *Handler) HandleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	memberships, err := h.workspaces.List(r.Context(), userID)
+	if err != nil {
+		h.respondWorkspaceError(w, r, userID, "", err)
+		return
+	}
+	if len(memberships) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]WorkspaceResponse, 0, len(memberships))
+	for _, m := range memberships {
+		resp = append(resp, WorkspaceResponse{ID: m.ID, Name: m.Name, Role: m.Role, CreatedAt: m.CreatedAt})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle listing the members of a workspace.
+func (h *Handler) HandleListWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	members, err := h.workspaces.Members(r.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+
+	resp := make([]WorkspaceMemberResponse, 0, len(members))
+	for _, m := range members {
+		resp = append(resp, WorkspaceMemberResponse{UserID: m.UserID, Role: m.Role, JoinedAt: m.JoinedAt})
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle changing the role of a workspace member.
+func (h *Handler) HandleUpdateWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var updateReq UpdateWorkspaceMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	memberID := chi.URLParam(r, "userID")
+	if err := h.workspaces.ChangeRole(r.Context(), workspaceID, userID, memberID, updateReq.Role); err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+	slog.Debug("workspace member role changed", slog.String("workspace", workspaceID), slog.String("member", memberID), slog.String("role", updateReq.Role))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle removing a member from a workspace, or leaving it.
+func (h *Handler) HandleRemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	memberID := chi.URLParam(r, "userID")
+	if err := h.workspaces.RemoveMember(r.Context(), workspaceID, userID, memberID); err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+	slog.Debug("workspace member removed", slog.String("workspace", workspaceID), slog.String("member", memberID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle inviting users to a workspace.
+func (h *Handler) HandleCreateWorkspaceInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var createReq CreateWorkspaceInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	invitation, token, err := h.workspaces.Invite(r.Context(), workspaceID, userID, createReq.Role)
+	if err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+	slog.Debug("workspace invitation created", slog.String("workspace", workspaceID), slog.String("invitation", invitation.ID))
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondWithJson(w, http.StatusCreated, CreateWorkspaceInvitationResponse{
+		WorkspaceInvitationResponse: newWorkspaceInvitationResponse(invitation),
+		Token:                       token,
+	})
+}
+
+// Method to handle listing the invitations of a workspace.
+func (h *Handler) HandleListWorkspaceInvitations(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	invitations, err := h.workspaces.Invitations(r.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+	if len(invitations) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]WorkspaceInvitationResponse, 0, len(invitations))
+	for _, inv := range invitations {
+		resp = append(resp, newWorkspaceInvitationResponse(inv))
+	}
+	h.respondWithJson(w, http.StatusOK, resp)
+}
+
+// Method to handle revoking an invitation of a workspace.
+func (h *Handler) HandleRevokeWorkspaceInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	invitationID := chi.URLParam(r, "invitationID")
+	if err := h.workspaces.RevokeInvitation(r.Context(), workspaceID, userID, invitationID); err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+	slog.Debug("workspace invitation revoked", slog.String("workspace", workspaceID), slog.String("invitation", invitationID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Method to handle joining a workspace with an invitation token.
+func (h *Handler) HandleAcceptWorkspaceInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	var acceptReq AcceptWorkspaceInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&acceptReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+	if acceptReq.Token == "" {
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidWorkspace, "The invitation token is missing.", missingField("token"))
+		return
+	}
+
+	member, err := h.workspaces.Accept(r.Context(), userID, acceptReq.Token)
+	if err != nil {
+		h.respondWorkspaceError(w, r, userID, "", err)
+		return
+	}
+	slog.Debug("workspace invitation accepted", slog.String("user", userID), slog.String("workspace", member.WorkspaceID))
+	h.respondWithJson(w, http.StatusOK, WorkspaceMemberResponse{UserID: member.UserID, Role: member.Role, JoinedAt: member.JoinedAt})
+}
+
+// Method to handle listing the URLs of a workspace.
+func (h *Handler) HandleGetWorkspaceURLs(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	if _, err := h.workspaces.Authorize(r.Context(), workspaceID, userID, repository.RoleViewer); err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+
+	urls, err := h.repo.GetByWorkspace(r.Context(), workspaceID)
+	if errors.Is(err, repository.ErrURLNotExsit) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		slog.Error("listing workspace urls", slog.String("workspace", workspaceID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+
+	workspaceURLs := make([]WorkspaceURL, 0, len(urls))
+	for _, u := range urls {
+		workspaceURLs = append(workspaceURLs, WorkspaceURL{ShortURL: h.baseURL + "/" + u.Slug, OriginalURL: u.OriginalURL, CreatedBy: u.UserID})
+	}
+	h.respondWithJson(w, http.StatusOK, workspaceURLs)
+}
+
+// Method to handle changing the destination of a workspace URL.
+func (h *Handler) HandleUpdateWorkspaceURL(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	if _, err := h.workspaces.Authorize(r.Context(), workspaceID, userID, repository.RoleEditor); err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+
+	defer r.Body.Close()
+	var updateReq UpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+	if updateReq.OriginalURL == "" {
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemMissingURL, "The URL to shorten is missing.", missingField("url"))
+		return
+	}
+	if !h.allowURLCreation(w, r, userID) {
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	url, err := h.repo.GetBySlug(r.Context(), slug)
+	if err != nil || url.WorkspaceID != workspaceID || url.IsDeleted {
+		slog.Debug("workspace url not found", slog.String("workspace", workspaceID), slog.String("slug", slug))
+		h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "The workspace has no URL shortened to "+slug+".")
+		return
+	}
+
+	normalizedURL, ok := h.validateOriginalURL(w, r, updateReq.OriginalURL, "url")
+	if !ok {
+		return
+	}
+	if err := h.repo.UpdateOriginalURL(r.Context(), slug, normalizedURL); err != nil {
+		if errors.Is(err, repository.ErrURLDuplicate) {
+			h.respondWithProblem(w, r, http.StatusConflict, ProblemConflict, "The URL is already shortened.")
+			return
+		}
+		slog.Error("updating workspace url", slog.String("slug", slug), slog.Any("error", err))
+		h.respondInternalError(w, r)
+		return
+	}
+	slog.Debug("workspace url updated", slog.String("workspace", workspaceID), slog.String("slug", slug), slog.String("user", userID))
+	h.recordAudit(r, repository.AuditEvent{Action: repository.AuditActionEdit, Slugs: []string{slug}, WorkspaceID: workspaceID})
+	h.respondWithJson(w, http.StatusOK, WorkspaceURL{ShortURL: h.baseURL + "/" + slug, OriginalURL: normalizedURL, CreatedBy: url.UserID})
+}
+
+// Method to handle deleting workspace URLs.
+func (h *Handler) HandleDeleteWorkspaceURLs(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromCtx(r)
+	if errors.Is(err, ErrorMissingUserIDCtx) {
+		h.respondMissingUserID(w, r)
+		return
+	}
+
+	workspaceID := chi.URLParam(r, "workspaceID")
+	if _, err := h.workspaces.Authorize(r.Context(), workspaceID, userID, repository.RoleEditor); err != nil {
+		h.respondWorkspaceError(w, r, userID, workspaceID, err)
+		return
+	}
+
+	var slugs []string
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&slugs); err != nil {
+		slog.Error("unmarshalling request data", slog.Any("error", err))
+		h.respondMalformedBody(w, r, err)
+		return
+	}
+
+	h.queueDeletionJob(w, r, slugs, userID, workspaceID)
+	slog.Debug(
+		"workspace urls deletion request accepted",
+		slog.String("workspace", workspaceID),
+		slog.String("user", userID),
+		slog.Any("slugs", slugs),
+	)
+}
+
+// Method to respond with the status matching a workspace error.
+func (h *Handler) respondWorkspaceError(w http.ResponseWriter, r *http.Request, userID string, workspaceID string, err error) {
+	switch {
+	case errors.Is(err, workspaces.ErrNotFound),
+		errors.Is(err, repository.ErrWorkspaceMemberNotExist),
+		errors.Is(err, repository.ErrWorkspaceInvitationNotExist):
+		slog.Debug("workspace resource not found", slog.String("user", userID), slog.String("workspace", workspaceID), slog.Any("error", err))
+		h.respondWithProblem(w, r, http.StatusNotFound, ProblemNotFound, "The workspace, member or invitation does not exist.")
+	case errors.Is(err, workspaces.ErrForbidden):
+		slog.Info("workspace operation forbidden", slog.String("user", userID), slog.String("workspace", workspaceID))
+		h.respondWithProblem(w, r, http.StatusForbidden, middlewares.ProblemForbidden, "The workspace role does not allow the operation.")
+	case errors.Is(err, workspaces.ErrLastOwner), errors.Is(err, repository.ErrWorkspaceMemberDuplicate):
+		slog.Debug("workspace operation conflict", slog.String("user", userID), slog.String("workspace", workspaceID), slog.Any("error", err))
+		h.respondWithProblem(w, r, http.StatusConflict, ProblemConflict, err.Error()+".")
+	case errors.Is(err, workspaces.ErrInvalidName), errors.Is(err, workspaces.ErrInvalidRole):
+		slog.Debug("invalid workspace request", slog.String("user", userID), slog.Any("error", err))
+		h.respondWithProblem(w, r, http.StatusBadRequest, ProblemInvalidWorkspace, err.Error()+".")
+	default:
+		slog.Error("workspace operation", slog.String("user", userID), slog.String("workspace", workspaceID), slog.Any("error", err))
+		h.respondInternalError(w, r)
+	}
+}
@@ -0,0 +1,455 @@
+// Package jobs provides the scheduler running the named background jobs of the application.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Job kinds.
+const (
+	// KindPeriodic marks a job run at regular intervals.
+	KindPeriodic = "periodic"
+	// KindQueued marks a job running the tasks submitted to its queue.
+	KindQueued = "queued"
+)
+
+// Default job settings, used for zero Options fields.
+const (
+	defaultConcurrency = 1
+	defaultQueueSize   = 100
+)
+
+var (
+	// ErrDuplicateJob is returned when a job is registered under a name already in use.
+	ErrDuplicateJob = errors.New("job is already registered")
+	// ErrUnknownJob is returned when no job is registered under the name.
+	ErrUnknownJob = errors.New("job is not registered")
+	// ErrWrongKind is returned when a job is triggered or submitted to against its kind.
+	ErrWrongKind = errors.New("operation is not supported by the job kind")
+	// ErrInvalidInterval is returned when a periodic job is registered without an interval.
+	ErrInvalidInterval = errors.New("periodic job requires a positive interval")
+	// ErrQueueFull is returned when the queue of a job has no room for the task. Callers should retry later.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrStarted is returned when a job is registered after the scheduler started.
+	ErrStarted = errors.New("scheduler is already started")
+	// ErrStopped is returned when a task is submitted after the scheduler stopped.
+	ErrStopped = errors.New("scheduler is stopped")
+	// ErrPanic wraps the value a job panicked with.
+	ErrPanic = errors.New("job panicked")
+)
+
+// Func is the work of a job. Periodic jobs stop being scheduled once the context is cancelled.
+type Func func(ctx context.Context) error
+
+// Options configures a job. Zero fields take default values.
+type Options struct {
+	// Interval is the delay between the runs of a periodic job.
+	Interval time.Duration
+	// Jitter is the upper bound of a random delay added to every wait of a periodic job,
+	// spreading the runs of jobs scheduled together.
+	Jitter time.Duration
+	// RunOnStart runs a periodic job as soon as the scheduler starts instead of after the first interval.
+	RunOnStart bool
+	// InitialBackoff is the delay before the run following a failed run of a periodic job.
+	// It doubles with every further consecutive failure. Zero keeps the interval.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay after failed runs. Zero caps it at the interval.
+	MaxBackoff time.Duration
+	// Concurrency is the number of tasks of a queued job run at once. Defaults to 1.
+	Concurrency int
+	// QueueSize is the number of tasks that can wait in the queue of a queued job. Defaults to 100.
+	QueueSize int
+	// OnShutdown is called once the job stopped, with a context that is not cancelled,
+	// to flush or persist the work left.
+	OnShutdown Func
+}
+
+// withDefaults returns the options with default values for their zero fields.
+func (o Options) withDefaults() Options {
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultConcurrency
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultQueueSize
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = o.Interval
+	}
+	if o.Jitter < 0 {
+		o.Jitter = 0
+	}
+	return o
+}
+
+// Status describes the state of a job.
+type Status struct {
+	// Name is the name the job is registered under.
+	Name string
+	// Kind is the job kind: periodic or queued.
+	Kind string
+	// Running is the number of runs in progress.
+	Running int
+	// Queued is the number of tasks waiting in the queue of a queued job.
+	Queued int
+	// Runs is the number of finished runs.
+	Runs int
+	// Failures is the number of failed runs.
+	Failures int
+	// ConsecutiveFailures is the number of failed runs since the last successful one.
+	ConsecutiveFailures int
+	// LastStartedAt is the time the last run started.
+	LastStartedAt time.Time
+	// LastFinishedAt is the time the last run finished.
+	LastFinishedAt time.Time
+	// LastDuration is the duration of the last finished run.
+	LastDuration time.Duration
+	// LastError describes the failure of the last run. Empty if it succeeded.
+	LastError string
+	// NextRunAt is the time the next run of a periodic job is scheduled at.
+	NextRunAt time.Time
+}
+
+// job is a registered job and its state.
+type job struct {
+	// name is the name the job is registered under.
+	name string
+	// kind is the job kind.
+	kind string
+	// fn is the work of a periodic job.
+	fn Func
+	// opts configures the job.
+	opts Options
+	// trigger requests an early run of a periodic job. Requests made while a run is pending coalesce.
+	trigger chan struct{}
+	// queue holds the tasks of a queued job.
+	queue chan Func
+	// mu guards the status.
+	mu sync.Mutex
+	// status is the state of the job.
+	status Status
+}
+
+// Scheduler runs named periodic and queued jobs in the background.
+// Runs of a periodic job never overlap; the tasks of a queued job are run by a fixed number of workers.
+// Every run recovers from panics. On shutdown the scheduler waits for the runs in progress,
+// runs the tasks left in the queues and calls the shutdown hooks of the jobs.
+type Scheduler struct {
+	// mu guards the registered jobs and the scheduler state.
+	mu sync.RWMutex
+	// jobs are the registered jobs, in registration order.
+	jobs []*job
+	// byName indexes the registered jobs by their names.
+	byName map[string]*job
+	// started is set once the scheduler runs.
+	started bool
+	// stopped is set once the queues are closed.
+	stopped bool
+	// random draws the jitter of the periodic jobs.
+	random *rand.Rand
+	// randomMu guards random.
+	randomMu sync.Mutex
+}
+
+// NewScheduler creates a new Scheduler with no jobs.
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		byName: make(map[string]*job),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Every registers a periodic job running fn at the interval of the options.
+// Jobs must be registered before the scheduler runs.
+func (s *Scheduler) Every(name string, fn Func, opts Options) error {
+	if opts.Interval <= 0 {
+		return ErrInvalidInterval
+	}
+	return s.register(&job{name: name, kind: KindPeriodic, fn: fn, opts: opts.withDefaults(), trigger: make(chan struct{}, 1)})
+}
+
+// Queue registers a queued job running the tasks submitted to it.
+// Tasks can be submitted as soon as the job is registered; they run once the scheduler runs.
+func (s *Scheduler) Queue(name string, opts Options) error {
+	opts = opts.withDefaults()
+	return s.register(&job{name: name, kind: KindQueued, opts: opts, queue: make(chan Func, opts.QueueSize)})
+}
+
+// register adds the job to the scheduler.
+func (s *Scheduler) register(j *job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return ErrStarted
+	}
+	if _, ok := s.byName[j.name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, j.name)
+	}
+	j.status = Status{Name: j.name, Kind: j.kind}
+	s.jobs = append(s.jobs, j)
+	s.byName[j.name] = j
+	return nil
+}
+
+// Trigger requests an early run of a periodic job without blocking. A job backing off after a failure
+// runs once the backoff elapses, and requests made before the run starts coalesce into one run.
+func (s *Scheduler) Trigger(name string) error {
+	j, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	if j.kind != KindPeriodic {
+		return fmt.Errorf("%w: %s is %s", ErrWrongKind, name, j.kind)
+	}
+	select {
+	case j.trigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Submit queues a task of a queued job without blocking.
+// It returns ErrQueueFull when the queue has no room for it and ErrStopped after shutdown.
+func (s *Scheduler) Submit(name string, task Func) error {
+	j, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	if j.kind != KindQueued {
+		return fmt.Errorf("%w: %s is %s", ErrWrongKind, name, j.kind)
+	}
+
+	// The read lock keeps the queue open until the task is sent.
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.stopped {
+		return ErrStopped
+	}
+	select {
+	case j.queue <- task:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrQueueFull, name)
+	}
+}
+
+// Statuses returns the states of the registered jobs, in registration order.
+func (s *Scheduler) Statuses() []Status {
+	s.mu.RLock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(jobs))
+	for _, j := range jobs {
+		j.mu.Lock()
+		status := j.status
+		j.mu.Unlock()
+		if j.queue != nil {
+			status.Queued = len(j.queue)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// job returns the job registered under the name.
+func (s *Scheduler) job(name string) (*job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	j, ok := s.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return j, nil
+}
+
+// Run starts the registered jobs and stops them once the context is cancelled.
+// It returns a WaitGroup that can be used to wait for the jobs and their shutdown hooks to finish.
+func (s *Scheduler) Run(ctx context.Context) *sync.WaitGroup {
+	s.mu.Lock()
+	s.started = true
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	wg := &sync.WaitGroup{}
+	for _, j := range jobs {
+		wg.Add(1)
+		switch j.kind {
+		case KindPeriodic:
+			go func(j *job) {
+				defer wg.Done()
+				s.runPeriodic(ctx, j)
+				s.shutdown(ctx, j)
+			}(j)
+		case KindQueued:
+			go func(j *job) {
+				defer wg.Done()
+				s.runQueued(ctx, j)
+				s.shutdown(ctx, j)
+			}(j)
+		}
+	}
+
+	// Close the queues on shutdown, so their workers finish once the tasks left are run.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		s.mu.Lock()
+		s.stopped = true
+		for _, j := range jobs {
+			if j.queue != nil {
+				close(j.queue)
+			}
+		}
+		s.mu.Unlock()
+		slog.Debug("job scheduler stopped", slog.Int("jobs", len(jobs)))
+	}()
+
+	return wg
+}
+
+// runPeriodic runs a periodic job at its interval until the context is cancelled.
+// Failed runs delay the next one with exponential backoff.
+func (s *Scheduler) runPeriodic(ctx context.Context, j *job) {
+	delay := j.opts.Interval
+	if j.opts.RunOnStart {
+		delay = 0
+	}
+	timer := time.NewTimer(s.withJitter(j, delay))
+	defer timer.Stop()
+
+	failures := 0
+	for {
+		j.mu.Lock()
+		j.status.NextRunAt = time.Now().Add(delay)
+		j.mu.Unlock()
+
+		// Triggers do not cut the backoff after a failure short.
+		trigger := j.trigger
+		if failures > 0 {
+			trigger = nil
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-trigger:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := s.execute(ctx, j, j.fn); err != nil {
+			failures++
+			delay = j.backoff(failures)
+		} else {
+			failures = 0
+			delay = j.opts.Interval
+		}
+		timer.Reset(s.withJitter(j, delay))
+	}
+}
+
+// runQueued runs the tasks of a queued job with its workers until the queue is closed and empty.
+// Tasks left in the queue on shutdown run with a context that is not cancelled.
+func (s *Scheduler) runQueued(ctx context.Context, j *job) {
+	workers := &sync.WaitGroup{}
+	for i := 0; i < j.opts.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for task := range j.queue {
+				taskCtx := ctx
+				if ctx.Err() != nil {
+					taskCtx = context.WithoutCancel(ctx)
+				}
+				_ = s.execute(taskCtx, j, task)
+			}
+		}()
+	}
+	workers.Wait()
+}
+
+// shutdown calls the shutdown hook of the job, if any.
+func (s *Scheduler) shutdown(ctx context.Context, j *job) {
+	if j.opts.OnShutdown == nil {
+		return
+	}
+	if err := s.execute(context.WithoutCancel(ctx), j, j.opts.OnShutdown); err != nil {
+		slog.Error("job shutdown", slog.String("job", j.name), slog.Any("error", err))
+	}
+}
+
+// execute runs the work of the job, recovering from panics and recording the outcome in the job status.
+func (s *Scheduler) execute(ctx context.Context, j *job, fn Func) (err error) {
+	start := time.Now()
+	j.mu.Lock()
+	j.status.Running++
+	j.status.LastStartedAt = start
+	j.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrPanic, r)
+			slog.Error("job panicked", slog.String("job", j.name), slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
+		}
+
+		finished := time.Now()
+		j.mu.Lock()
+		j.status.Running--
+		j.status.Runs++
+		j.status.LastFinishedAt = finished
+		j.status.LastDuration = finished.Sub(start)
+		j.status.LastError = ""
+		if err != nil {
+			j.status.Failures++
+			j.status.ConsecutiveFailures++
+			j.status.LastError = err.Error()
+		} else {
+			j.status.ConsecutiveFailures = 0
+		}
+		j.mu.Unlock()
+
+		if err != nil {
+			slog.Warn("job failed", slog.String("job", j.name), slog.Any("error", err))
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// backoff returns the delay before the run following the given number of consecutive failures.
+func (j *job) backoff(failures int) time.Duration {
+	delay := j.opts.InitialBackoff
+	if delay <= 0 {
+		return j.opts.Interval
+	}
+	for i := 1; i < failures && delay < j.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, j.opts.MaxBackoff)
+}
+
+// withJitter adds the random jitter of the job to the delay.
+func (s *Scheduler) withJitter(j *job, delay time.Duration) time.Duration {
+	if j.opts.Jitter <= 0 {
+		return delay
+	}
+	s.randomMu.Lock()
+	defer s.randomMu.Unlock()
+	return delay + time.Duration(s.random.Int63n(int64(j.opts.Jitter)))
+}
@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls the condition until it holds or the deadline passes.
+func waitFor(t *testing.T, condition func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// statusOf returns the status of the named job.
+func statusOf(t *testing.T, s *Scheduler, name string) Status {
+	t.Helper()
+	for _, status := range s.Statuses() {
+		if status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("Expected job %q to be registered", name)
+	return Status{}
+}
+
+func TestScheduler_Register(t *testing.T) {
+	s := NewScheduler()
+	noop := func(context.Context) error { return nil }
+
+	if err := s.Every("periodic", noop, Options{Interval: time.Hour}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Queue("queued", Options{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Every("periodic", noop, Options{Interval: time.Hour}); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob, got %v", err)
+	}
+	if err := s.Every("no interval", noop, Options{}); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("Expected ErrInvalidInterval, got %v", err)
+	}
+	if err := s.Trigger("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+	if err := s.Trigger("queued"); !errors.Is(err, ErrWrongKind) {
+		t.Errorf("Expected ErrWrongKind, got %v", err)
+	}
+	if err := s.Submit("periodic", noop); !errors.Is(err, ErrWrongKind) {
+		t.Errorf("Expected ErrWrongKind, got %v", err)
+	}
+
+	statuses := s.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "periodic" || statuses[0].Kind != KindPeriodic || statuses[1].Kind != KindQueued {
+		t.Errorf("Unexpected statuses: %+v", statuses)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := s.Run(ctx)
+	if err := s.Queue("late", Options{}); !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected ErrStarted, got %v", err)
+	}
+	cancel()
+	wg.Wait()
+	if err := s.Submit("queued", noop); !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected ErrStopped, got %v", err)
+	}
+}
+
+func TestScheduler_Periodic(t *testing.T) {
+	s := NewScheduler()
+	var runs, shutdowns atomic.Int32
+	err := s.Every("tick", func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}, Options{
+		Interval:   10 * time.Millisecond,
+		Jitter:     5 * time.Millisecond,
+		RunOnStart: true,
+		OnShutdown: func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				t.Errorf("Expected the shutdown hook context not to be cancelled")
+			}
+			shutdowns.Add(1)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := s.Run(ctx)
+	waitFor(t, func() bool { return runs.Load() >= 3 }, "Expected the job to run at its interval")
+	cancel()
+	wg.Wait()
+
+	status := statusOf(t, s, "tick")
+	if status.Runs < 3 || status.Running != 0 || status.Failures != 0 || status.LastFinishedAt.IsZero() || status.NextRunAt.IsZero() {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if shutdowns.Load() != 1 {
+		t.Errorf("Expected the shutdown hook to be called once, got %d", shutdowns.Load())
+	}
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	s := NewScheduler()
+	runs := make(chan struct{}, 10)
+	err := s.Every("triggered", func(context.Context) error {
+		runs <- struct{}{}
+		return nil
+	}, Options{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := s.Run(ctx)
+	if err := s.Trigger("triggered"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the trigger to run the job before its interval")
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestScheduler_BacksOffAndRecoversPanics(t *testing.T) {
+	s := NewScheduler()
+	var runs atomic.Int32
+	err := s.Every("flaky", func(context.Context) error {
+		switch runs.Add(1) {
+		case 1:
+			panic("boom")
+		case 2:
+			return errors.New("unavailable")
+		default:
+			return nil
+		}
+	}, Options{Interval: time.Hour, RunOnStart: true, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := s.Run(ctx)
+	waitFor(t, func() bool { return runs.Load() >= 3 }, "Expected failed runs to be retried with backoff")
+	cancel()
+	wg.Wait()
+
+	status := statusOf(t, s, "flaky")
+	if status.Runs != 3 || status.Failures != 2 || status.ConsecutiveFailures != 0 || status.LastError != "" {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestScheduler_Queued(t *testing.T) {
+	s := NewScheduler()
+	if err := s.Queue("work", Options{Concurrency: 2, QueueSize: 3}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+		done    atomic.Int32
+	)
+	release := make(chan struct{})
+	task := func(context.Context) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+		done.Add(1)
+		return nil
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Submit("work", task); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := s.Submit("work", task); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := s.Run(ctx)
+	waitFor(t, func() bool { return statusOf(t, s, "work").Running == 2 }, "Expected two tasks to run at once")
+	if queued := statusOf(t, s, "work").Queued; queued != 1 {
+		t.Errorf("Expected 1 queued task, got %d", queued)
+	}
+
+	// The task left in the queue runs on shutdown.
+	cancel()
+	close(release)
+	wg.Wait()
+
+	if done.Load() != 3 || peak != 2 {
+		t.Errorf("Expected 3 tasks run 2 at once, got %d run %d at once", done.Load(), peak)
+	}
+	if status := statusOf(t, s, "work"); status.Runs != 3 || status.Queued != 0 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
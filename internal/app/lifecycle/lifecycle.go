@@ -0,0 +1,310 @@
+// Package lifecycle provides the supervisor starting and stopping the components of the application.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// defaultStopTimeout is the time a component is given to stop when no timeout is configured.
+const defaultStopTimeout = time.Second * 5
+
+// Supervisor states. The supervisor is ready once every component started, until shutdown begins.
+const (
+	StateStarting = "starting"
+	StateReady    = "ready"
+	StateStopping = "stopping"
+	StateStopped  = "stopped"
+)
+
+var (
+	// ErrDuplicateComponent is returned when a component is registered under a name already in use.
+	ErrDuplicateComponent = errors.New("component is already registered")
+	// ErrUnknownDependency is returned when a component depends on a component that is not registered.
+	ErrUnknownDependency = errors.New("component depends on an unknown component")
+	// ErrDependencyCycle is returned when the dependencies of the components form a cycle.
+	ErrDependencyCycle = errors.New("component dependencies form a cycle")
+	// ErrStarted is returned when a component is registered after the supervisor started.
+	ErrStarted = errors.New("supervisor is already started")
+)
+
+// Component is a part of the application with a lifecycle.
+type Component struct {
+	// Name identifies the component in the logs and errors.
+	Name string
+	// DependsOn names the components started before this one and stopped after it.
+	DependsOn []string
+	// Start starts the component. It must not block; background work runs until Stop is called.
+	Start func(ctx context.Context) error
+	// Stop stops the component, finishing its work in flight before the context is done.
+	Stop func(ctx context.Context) error
+	// StopTimeout is the time the component is given to stop. Zero takes the supervisor's timeout.
+	StopTimeout time.Duration
+}
+
+// ComponentError reports the failure of a component to start, run or stop.
+type ComponentError struct {
+	// Component is the name of the failed component.
+	Component string
+	// Op is the failed operation: start, run or stop.
+	Op string
+	// Err is the failure.
+	Err error
+}
+
+// Error returns the description of the failure.
+func (e *ComponentError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Component, e.Op, e.Err)
+}
+
+// Unwrap returns the failure.
+func (e *ComponentError) Unwrap() error {
+	return e.Err
+}
+
+// Supervisor starts the registered components in dependency order, waits for the context to be cancelled
+// or a component to fail, then stops the started components in reverse order.
+type Supervisor struct {
+	// mu guards the components, the state and the listeners.
+	mu sync.Mutex
+	// components are the registered components, in registration order.
+	components []Component
+	// stopTimeout is the time a component is given to stop by default.
+	stopTimeout time.Duration
+	// state is the current state.
+	state string
+	// listeners are notified about the state transitions.
+	listeners []func(state string)
+	// failed receives the first failure reported by a running component.
+	failed chan error
+}
+
+// NewSupervisor creates a new Supervisor giving every component the stop timeout, unless it sets its own.
+func NewSupervisor(stopTimeout time.Duration) *Supervisor {
+	if stopTimeout <= 0 {
+		stopTimeout = defaultStopTimeout
+	}
+	return &Supervisor{
+		stopTimeout: stopTimeout,
+		state:       StateStarting,
+		failed:      make(chan error, 1),
+	}
+}
+
+// Register adds a component. Components must be registered before the supervisor runs.
+func (s *Supervisor) Register(c Component) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != StateStarting {
+		return ErrStarted
+	}
+	for _, registered := range s.components {
+		if registered.Name == c.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateComponent, c.Name)
+		}
+	}
+	s.components = append(s.components, c)
+	return nil
+}
+
+// StopTimeout returns the time a component is given to stop unless it sets its own.
+func (s *Supervisor) StopTimeout() time.Duration {
+	return s.stopTimeout
+}
+
+// OnStateChange registers a listener notified about every state transition.
+func (s *Supervisor) OnStateChange(listener func(state string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// State returns the current state.
+func (s *Supervisor) State() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Ready reports whether every component started and shutdown has not begun.
+func (s *Supervisor) Ready() bool {
+	return s.State() == StateReady
+}
+
+// Fail reports the failure of a running component, stopping the application.
+// Only the first failure is reported.
+func (s *Supervisor) Fail(component string, err error) {
+	select {
+	case s.failed <- &ComponentError{Component: component, Op: "run", Err: err}:
+	default:
+	}
+}
+
+// Run starts the components in dependency order and blocks until the context is cancelled or a component fails.
+// It then stops the started components in reverse order, each within its stop timeout.
+// It returns the start, run and stop failures joined, or nil after a clean shutdown.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mu.Lock()
+	components := append([]Component(nil), s.components...)
+	s.mu.Unlock()
+
+	ordered, err := order(components)
+	if err != nil {
+		s.transition(StateStopped)
+		return err
+	}
+
+	var errs []error
+	started := make([]Component, 0, len(ordered))
+	for _, c := range ordered {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				errs = append(errs, &ComponentError{Component: c.Name, Op: "start", Err: err})
+				break
+			}
+		}
+		slog.Debug("component started", slog.String("component", c.Name))
+		started = append(started, c)
+	}
+
+	if len(errs) == 0 {
+		s.transition(StateReady)
+		select {
+		case <-ctx.Done():
+			slog.Info("application received shutdown signal")
+		case err := <-s.failed:
+			slog.Error("component failed, shutting down", slog.Any("error", err))
+			errs = append(errs, err)
+		}
+	}
+
+	s.transition(StateStopping)
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := s.stop(ctx, started[i]); err != nil {
+			slog.Error("component shutdown", slog.String("component", started[i].Name), slog.Any("error", err))
+			errs = append(errs, err)
+		}
+	}
+	s.transition(StateStopped)
+	return errors.Join(errs...)
+}
+
+// stop stops the component within its stop timeout.
+func (s *Supervisor) stop(ctx context.Context, c Component) error {
+	if c.Stop == nil {
+		return nil
+	}
+	timeout := c.StopTimeout
+	if timeout <= 0 {
+		timeout = s.stopTimeout
+	}
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := c.Stop(stopCtx); err != nil {
+		return &ComponentError{Component: c.Name, Op: "stop", Err: err}
+	}
+	slog.Debug("component stopped", slog.String("component", c.Name), slog.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// transition moves the supervisor to the state and notifies the listeners.
+func (s *Supervisor) transition(state string) {
+	s.mu.Lock()
+	from := s.state
+	s.state = state
+	listeners := append([]func(string){}, s.listeners...)
+	s.mu.Unlock()
+
+	if from == state {
+		return
+	}
+	slog.Info("application state changed", slog.String("from", from), slog.String("to", state))
+	for _, listener := range listeners {
+		listener(state)
+	}
+}
+
+// order sorts the components so that every component follows its dependencies.
+// Components without dependencies between them keep their registration order.
+func order(components []Component) ([]Component, error) {
+	index := make(map[string]int, len(components))
+	for i, c := range components {
+		index[c.Name] = i
+	}
+	for _, c := range components {
+		for _, dep := range c.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, c.Name, dep)
+			}
+		}
+	}
+
+	ordered := make([]Component, 0, len(components))
+	placed := make(map[string]bool, len(components))
+	for len(ordered) < len(components) {
+		progressed := false
+		for _, c := range components {
+			if placed[c.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range c.DependsOn {
+				if !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, c)
+				placed[c.Name] = true
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			return nil, ErrDependencyCycle
+		}
+	}
+	return ordered, nil
+}
+
+// Background adapts a component running in the background until its context is cancelled,
+// returning a WaitGroup to wait for it, like the background workers of the application.
+// Stop cancels the context and waits for the work to finish.
+func Background(name string, dependsOn []string, run func(ctx context.Context) *sync.WaitGroup) Component {
+	var (
+		cancel context.CancelFunc
+		wg     *sync.WaitGroup
+	)
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+			wg = run(runCtx)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
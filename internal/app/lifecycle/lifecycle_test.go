@@ -0,0 +1,233 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the starts and stops of components.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// component returns a component recording its starts and stops, failing them with the errors.
+func (r *recorder) component(name string, dependsOn []string, startErr, stopErr error) Component {
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(context.Context) error {
+			r.record("start " + name)
+			return startErr
+		},
+		Stop: func(context.Context) error {
+			r.record("stop " + name)
+			return stopErr
+		},
+	}
+}
+
+func TestSupervisor_Run(t *testing.T) {
+	rec := &recorder{}
+	s := NewSupervisor(time.Second)
+	for _, c := range []Component{
+		rec.component("http", []string{"jobs", "repository"}, nil, nil),
+		rec.component("jobs", []string{"repository"}, nil, nil),
+		rec.component("repository", nil, nil, nil),
+	} {
+		if err := s.Register(c); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := s.Register(Component{Name: "jobs"}); !errors.Is(err, ErrDuplicateComponent) {
+		t.Errorf("Expected ErrDuplicateComponent, got %v", err)
+	}
+
+	states := make(chan string, 4)
+	s.OnStateChange(func(state string) { states <- state })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	if state := <-states; state != StateReady || !s.Ready() {
+		t.Fatalf("Expected the supervisor to be ready, got %q", state)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := []string{<-states, <-states}; !reflect.DeepEqual(got, []string{StateStopping, StateStopped}) {
+		t.Errorf("Unexpected state transitions: %v", got)
+	}
+
+	expected := []string{"start repository", "start jobs", "start http", "stop http", "stop jobs", "stop repository"}
+	if got := rec.recorded(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if err := s.Register(Component{Name: "late"}); !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected ErrStarted, got %v", err)
+	}
+}
+
+func TestSupervisor_InvalidDependencies(t *testing.T) {
+	testCases := []struct {
+		name       string
+		components []Component
+		expected   error
+	}{
+		{
+			name:       "Unknown dependency",
+			components: []Component{{Name: "a", DependsOn: []string{"missing"}}},
+			expected:   ErrUnknownDependency,
+		},
+		{
+			name:       "Cycle",
+			components: []Component{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}},
+			expected:   ErrDependencyCycle,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewSupervisor(0)
+			for _, c := range tc.components {
+				if err := s.Register(c); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if err := s.Run(context.Background()); !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestSupervisor_StartFailure(t *testing.T) {
+	rec := &recorder{}
+	errUnavailable := errors.New("unavailable")
+	s := NewSupervisor(time.Second)
+	for _, c := range []Component{
+		rec.component("repository", nil, nil, nil),
+		rec.component("jobs", []string{"repository"}, errUnavailable, nil),
+		rec.component("http", []string{"jobs"}, nil, nil),
+	} {
+		if err := s.Register(c); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	err := s.Run(context.Background())
+	var componentErr *ComponentError
+	if !errors.As(err, &componentErr) || componentErr.Component != "jobs" || componentErr.Op != "start" || !errors.Is(err, errUnavailable) {
+		t.Fatalf("Expected the start failure of jobs, got %v", err)
+	}
+	expected := []string{"start repository", "start jobs", "stop repository"}
+	if got := rec.recorded(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if s.State() != StateStopped {
+		t.Errorf("Expected the supervisor to be stopped, got %q", s.State())
+	}
+}
+
+func TestSupervisor_FailureAndStopErrors(t *testing.T) {
+	rec := &recorder{}
+	errServe := errors.New("serve failed")
+	errClose := errors.New("close failed")
+	s := NewSupervisor(time.Second)
+	for _, c := range []Component{
+		rec.component("repository", nil, nil, errClose),
+		rec.component("http", []string{"repository"}, nil, nil),
+	} {
+		if err := s.Register(c); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	s.OnStateChange(func(state string) {
+		if state == StateReady {
+			s.Fail("http", errServe)
+		}
+	})
+
+	err := s.Run(context.Background())
+	if !errors.Is(err, errServe) || !errors.Is(err, errClose) {
+		t.Fatalf("Expected the run and stop failures, got %v", err)
+	}
+	expected := []string{"start repository", "start http", "stop http", "stop repository"}
+	if got := rec.recorded(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestBackground(t *testing.T) {
+	stopped := make(chan struct{})
+	c := Background("worker", nil, func(ctx context.Context) *sync.WaitGroup {
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			close(stopped)
+		}()
+		return wg
+	})
+
+	// The worker runs until it is stopped, not until the start context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("Expected the worker to keep running until stopped")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("Expected the worker to be stopped")
+	}
+}
+
+func TestBackground_StopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := Background("stuck", nil, func(ctx context.Context) *sync.WaitGroup {
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-release
+		}()
+		return wg
+	})
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the stop to time out, got %v", err)
+	}
+}
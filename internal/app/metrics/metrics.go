@@ -0,0 +1,200 @@
+// Package metrics provides the Prometheus metrics of the application.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of the application metrics.
+const namespace = "shorturl"
+
+// unmatchedRoute labels the requests that matched no route, keeping the route label bounded.
+const unmatchedRoute = "unmatched"
+
+// statsTimeout bounds the query of the link counts on every scrape.
+const statsTimeout = time.Second * 2
+
+// QueueReporter reports the depth of a queue, like the background deleter does.
+type QueueReporter interface {
+	// Queued returns the number of entries waiting in the queue.
+	Queued() int
+	// QueueSize returns the number of entries the queue can hold.
+	QueueSize() int
+}
+
+// StatsSource reports the number of links and users, like the repository does.
+type StatsSource interface {
+	GetServiceStats(ctx context.Context) (urlsCount int, usersCount int, err error)
+}
+
+// Metrics collects the metrics of the application in its own registry.
+type Metrics struct {
+	// registry holds the collectors exposed by the handler.
+	registry *prometheus.Registry
+	// httpRequests counts the HTTP requests by method, route pattern and status.
+	httpRequests *prometheus.CounterVec
+	// httpDuration observes the latency of the HTTP requests by method, route pattern and status.
+	httpDuration *prometheus.HistogramVec
+	// repositoryDuration observes the latency of the repository calls by method.
+	repositoryDuration *prometheus.HistogramVec
+	// repositoryErrors counts the failed repository calls by method.
+	repositoryErrors *prometheus.CounterVec
+	// deleteBatchSize observes the sizes of the batches of delete requests flushed.
+	deleteBatchSize prometheus.Histogram
+}
+
+// New creates a new Metrics registering the HTTP, repository and deleter metrics,
+// and the Go runtime and process metrics.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method, route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		repositoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_call_duration_seconds",
+			Help:      "Latency of repository calls by method.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"method"}),
+		repositoryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_errors_total",
+			Help:      "Number of repository calls failed by the storage by method. Missing and duplicate entries are not counted.",
+		}, []string{"method"}),
+		deleteBatchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "deleter_batch_size",
+			Help:      "Number of delete requests in the batches flushed by the background deleter.",
+			Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.repositoryDuration,
+		m.repositoryErrors,
+		m.deleteBatchSize,
+	)
+	return m
+}
+
+// Handler returns the handler serving the metrics in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts the HTTP requests and observes their latency by method, route pattern and status.
+// It does nothing for nil Metrics.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveRepositoryCall observes the latency of a repository call and counts it if the storage failed.
+// It is the repository.CallObserver of an instrumented repository.
+func (m *Metrics) ObserveRepositoryCall(method string, duration time.Duration, err error) {
+	m.repositoryDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if err != nil && !repository.IsDomainError(err) {
+		m.repositoryErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ObserveDeleteBatch observes the size of a batch of delete requests flushed by the background deleter.
+func (m *Metrics) ObserveDeleteBatch(size int) {
+	m.deleteBatchSize.Observe(float64(size))
+}
+
+// RegisterDeleterQueue exposes the length and the capacity of the queue of the background deleter.
+func (m *Metrics) RegisterDeleterQueue(queue QueueReporter) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "deleter_queue_length",
+			Help:      "Number of delete requests waiting to be flushed by the background deleter.",
+		}, func() float64 { return float64(queue.Queued()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "deleter_queue_capacity",
+			Help:      "Number of delete requests the queue of the background deleter can hold.",
+		}, func() float64 { return float64(queue.QueueSize()) }),
+	)
+}
+
+// RegisterLinkCounts exposes the number of links and users, queried from the source on every scrape.
+func (m *Metrics) RegisterLinkCounts(source StatsSource) {
+	m.registry.MustRegister(&statsCollector{
+		source: source,
+		links:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "links"), "Number of stored links.", nil, nil),
+		users:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "users"), "Number of users with links.", nil, nil),
+	})
+}
+
+// statsCollector collects the number of links and users on every scrape.
+type statsCollector struct {
+	// source reports the counts.
+	source StatsSource
+	// links describes the number of links.
+	links *prometheus.Desc
+	// users describes the number of users.
+	users *prometheus.Desc
+}
+
+// Describe sends the descriptions of the counts.
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.links
+	ch <- c.users
+}
+
+// Collect queries the counts and sends them. The counts are left out of the scrape if the query fails.
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	links, users, err := c.source.GetServiceStats(ctx)
+	if err != nil {
+		slog.Error("collecting link counts", slog.Any("error", err))
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.links, prometheus.GaugeValue, float64(links))
+	ch <- prometheus.MustNewConstMetric(c.users, prometheus.GaugeValue, float64(users))
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeQueue reports a fixed queue depth.
+type fakeQueue struct{ queued, size int }
+
+func (q fakeQueue) Queued() int    { return q.queued }
+func (q fakeQueue) QueueSize() int { return q.size }
+
+// fakeStats reports fixed counts, or fails with err.
+type fakeStats struct {
+	links, users int
+	err          error
+}
+
+func (s fakeStats) GetServiceStats(context.Context) (int, int, error) {
+	return s.links, s.users, s.err
+}
+
+// scrape returns the metrics served by the handler.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := New()
+	router := chi.NewRouter()
+	router.Use(m.Middleware)
+	router.Get("/api/user/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	router.Get("/implicit", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, target := range []string{"/api/user/1", "/api/user/2", "/implicit", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	body := scrape(t, m)
+	for _, expected := range []string{
+		`shorturl_http_requests_total{method="GET",route="/api/user/{id}",status="202"} 2`,
+		`shorturl_http_requests_total{method="GET",route="/implicit",status="200"} 1`,
+		`shorturl_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`shorturl_http_request_duration_seconds_count{method="GET",route="/api/user/{id}",status="202"} 2`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the metrics to contain %s", expected)
+		}
+	}
+
+	// Nil metrics serve the requests without recording them.
+	var disabled *Metrics
+	rec := httptest.NewRecorder()
+	disabled.Middleware(router).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/1", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", rec.Code)
+	}
+}
+
+func TestMetrics_RepositoryAndDeleter(t *testing.T) {
+	m := New()
+	m.ObserveRepositoryCall("GetBySlug", time.Millisecond, nil)
+	m.ObserveRepositoryCall("GetBySlug", time.Millisecond, repository.ErrURLNotExsit)
+	m.ObserveRepositoryCall("DeleteMany", time.Millisecond, errors.New("connection refused"))
+	m.ObserveDeleteBatch(3)
+	m.RegisterDeleterQueue(fakeQueue{queued: 4, size: 100})
+	m.RegisterLinkCounts(fakeStats{links: 7, users: 2})
+
+	body := scrape(t, m)
+	for _, expected := range []string{
+		`shorturl_repository_call_duration_seconds_count{method="GetBySlug"} 2`,
+		`shorturl_repository_errors_total{method="DeleteMany"} 1`,
+		`shorturl_deleter_batch_size_sum 3`,
+		`shorturl_deleter_queue_length 4`,
+		`shorturl_deleter_queue_capacity 100`,
+		`shorturl_links 7`,
+		`shorturl_users 2`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the metrics to contain %s", expected)
+		}
+	}
+	if strings.Contains(body, `shorturl_repository_errors_total{method="GetBySlug"}`) {
+		t.Error("Expected missing entries not to be counted as repository errors")
+	}
+}
+
+func TestMetrics_LinkCountsFailure(t *testing.T) {
+	m := New()
+	m.RegisterLinkCounts(fakeStats{err: errors.New("unavailable")})
+
+	if body := scrape(t, m); strings.Contains(body, "shorturl_links") {
+		t.Error("Expected the link counts to be left out when the query fails")
+	}
+}
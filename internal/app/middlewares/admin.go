@@ -0,0 +1,81 @@
+// Package middlewares provides access control for the operator administration routes.
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AdminGuard restricts access to operators: users with the admin role and clients in trusted subnets.
+type AdminGuard struct {
+	// admins is the set of user IDs with the admin role.
+	admins map[string]bool
+	// subnets are the networks clients are trusted from.
+	subnets []*net.IPNet
+}
+
+// NewAdminGuard creates an AdminGuard granting access to the admin user IDs and to clients in the trusted subnets.
+// Subnets are given in CIDR notation; a bare IP address trusts that address only.
+// The client address is the remote address of the connection, so a proxy in front of the service must be trusted as a whole.
+func NewAdminGuard(adminUserIDs []string, trustedSubnets []string) (*AdminGuard, error) {
+	g := &AdminGuard{admins: make(map[string]bool, len(adminUserIDs))}
+	for _, id := range adminUserIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			g.admins[id] = true
+		}
+	}
+	for _, subnet := range trustedSubnets {
+		subnet = strings.TrimSpace(subnet)
+		if !strings.Contains(subnet, "/") {
+			ip := net.ParseIP(subnet)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted subnet %q", subnet)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			subnet = fmt.Sprintf("%s/%d", subnet, bits)
+		}
+		_, network, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted subnet %q: %w", subnet, err)
+		}
+		g.subnets = append(g.subnets, network)
+	}
+	return g, nil
+}
+
+// Allows reports whether the request comes from an admin user or a trusted subnet.
+// A nil AdminGuard allows no one.
+func (g *AdminGuard) Allows(r *http.Request) bool {
+	if g == nil {
+		return false
+	}
+	if userID, ok := r.Context().Value(UserIDContextKey).(string); ok && g.admins[userID] {
+		return true
+	}
+	ip := net.ParseIP(ClientIP(r))
+	for _, subnet := range g.subnets {
+		if ip != nil && subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Require is a middleware that rejects requests not allowed by the guard with 403.
+func (g *AdminGuard) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.Allows(r) {
+			userID, _ := r.Context().Value(UserIDContextKey).(string)
+			slog.Info("admin access denied", slog.String("user", userID), slog.String("ip", ClientIP(r)))
+			respondWithProblem(w, r, http.StatusForbidden, ProblemForbidden, "The endpoint is restricted to operators.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAdminGuard(t *testing.T) {
+	_, err := NewAdminGuard(nil, []string{"10.0.0.0/8", "192.168.1.10", "::1"})
+	assert.NoError(t, err)
+
+	_, err = NewAdminGuard(nil, []string{"not-a-subnet"})
+	assert.Error(t, err)
+
+	_, err = NewAdminGuard(nil, []string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestAdminGuard_Require(t *testing.T) {
+	guard, err := NewAdminGuard([]string{"admin"}, []string{"10.0.0.0/8", "192.168.1.10"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		guard          *AdminGuard
+		userID         string
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{name: "Admin user", guard: guard, userID: "admin", remoteAddr: "203.0.113.1:1234", expectedStatus: http.StatusOK},
+		{name: "Trusted subnet", guard: guard, userID: "user", remoteAddr: "10.1.2.3:1234", expectedStatus: http.StatusOK},
+		{name: "Trusted address", guard: guard, userID: "user", remoteAddr: "192.168.1.10:1234", expectedStatus: http.StatusOK},
+		{name: "Untrusted user and address", guard: guard, userID: "user", remoteAddr: "192.168.1.11:1234", expectedStatus: http.StatusForbidden},
+		{name: "Nil guard", guard: nil, userID: "admin", remoteAddr: "10.1.2.3:1234", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := tc.guard.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/api/admin/urls", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req = req.WithContext(context.WithValue(req.Context(), UserIDContextKey, tc.userID))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
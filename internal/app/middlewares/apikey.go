@@ -0,0 +1,109 @@
+// Package middlewares provides API key authentication and scope enforcement.
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+)
+
+// APIKeyHeader is the request header API keys are sent in.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix is prepended to generated API keys so they are easy to recognize, e.g. by secret scanners.
+const apiKeyPrefix = "shk_"
+
+// apiKeyDisplayPrefixLen is the number of leading key characters stored in clear to identify a key.
+const apiKeyDisplayPrefixLen = len(apiKeyPrefix) + 8
+
+// apiKeyTouchInterval is the minimum interval between two last-used timestamp updates of a key.
+const apiKeyTouchInterval = time.Minute
+
+// apiKeyContextKey is the context key for the API key a request was authenticated with.
+const apiKeyContextKey contextKey = "apiKey"
+
+// APIKeyStore looks up API keys and records their use.
+type APIKeyStore interface {
+	// GetAPIKeyByHash retrieves an API key by the hash of the key.
+	GetAPIKeyByHash(ctx context.Context, hash string) (repository.APIKey, error)
+	// TouchAPIKey records the time an API key was last used.
+	TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error
+}
+
+// GenerateAPIKey returns a new random API key and the prefix displayed to identify it.
+func GenerateAPIKey() (key string, prefix string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:apiKeyDisplayPrefixLen], nil
+}
+
+// APIKeyFromContext returns the API key the request was authenticated with, if any.
+func APIKeyFromContext(ctx context.Context) (repository.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(repository.APIKey)
+	return key, ok
+}
+
+// APIKeyAuthMiddleware returns a middleware that authenticates requests carrying an `X-API-Key` header.
+// Requests with an unknown or revoked key are rejected with 401.
+// Requests without the header are passed through unchanged to the next authentication middleware.
+func APIKeyAuthMiddleware(store APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rawKey := r.Header.Get(APIKeyHeader)
+			if rawKey == "" || hasUserID(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := store.GetAPIKeyByHash(r.Context(), repository.HashAPIKey(rawKey))
+			if err != nil {
+				if !errors.Is(err, repository.ErrAPIKeyNotExist) {
+					slog.Error("looking up API key", slog.Any("error", err))
+				}
+				respondWithProblem(w, r, http.StatusUnauthorized, ProblemUnauthorized, "The API key is invalid.")
+				return
+			}
+			if key.IsRevoked() {
+				slog.Info("revoked API key rejected", slog.String("key", key.ID), slog.String("user", key.UserID))
+				respondWithProblem(w, r, http.StatusUnauthorized, ProblemUnauthorized, "The API key is revoked.")
+				return
+			}
+			slog.Debug("API key validation successful", slog.String("key", key.ID), slog.String("user", key.UserID))
+
+			now := time.Now()
+			if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+				if err := store.TouchAPIKey(r.Context(), key.ID, now); err != nil {
+					slog.Error("recording API key use", slog.String("key", key.ID), slog.Any("error", err))
+				}
+			}
+
+			ctx := withUserID(r.Context(), key.UserID, AuthMethodAPIKey)
+			ctx = context.WithValue(ctx, apiKeyContextKey, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope returns a middleware rejecting requests authenticated with an API key
+// that is not allowed the scope. Requests authenticated otherwise are not restricted.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key, ok := APIKeyFromContext(r.Context()); ok && !key.HasScope(scope) {
+				slog.Info("API key scope denied", slog.String("key", key.ID), slog.String("scope", scope))
+				respondWithProblem(w, r, http.StatusForbidden, ProblemInsufficientScope, "The API key is not allowed the "+scope+" scope.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.True(t, strings.HasPrefix(prefix, apiKeyPrefix))
+	assert.Len(t, prefix, apiKeyDisplayPrefixLen)
+
+	other, _, err := GenerateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryRepository()
+	revokedAt := time.Now()
+	require.NoError(t, store.AddAPIKey(ctx, repository.APIKey{ID: "read", UserID: "user1", Hash: repository.HashAPIKey("read-key"), Scopes: []string{repository.ScopeRead}}))
+	require.NoError(t, store.AddAPIKey(ctx, repository.APIKey{ID: "revoked", UserID: "user1", Hash: repository.HashAPIKey("revoked-key"), RevokedAt: &revokedAt}))
+
+	testCases := []struct {
+		name           string
+		apiKey         string
+		scope          string
+		expectedStatus int
+		expectedMethod string
+		expectCookie   bool
+	}{
+		{name: "Valid key", apiKey: "read-key", scope: repository.ScopeRead, expectedStatus: http.StatusOK, expectedMethod: AuthMethodAPIKey},
+		{name: "Missing scope", apiKey: "read-key", scope: repository.ScopeDelete, expectedStatus: http.StatusForbidden},
+		{name: "Unknown key", apiKey: "unknown-key", scope: repository.ScopeRead, expectedStatus: http.StatusUnauthorized},
+		{name: "Revoked key", apiKey: "revoked-key", scope: repository.ScopeRead, expectedStatus: http.StatusUnauthorized},
+		{name: "No key falls back to cookie", scope: repository.ScopeDelete, expectedStatus: http.StatusOK, expectedMethod: AuthMethodCookie, expectCookie: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var method, userID string
+			handler := APIKeyAuthMiddleware(store)(CookieAuthMiddleware(NewSessionManager(newTestKeyRing(t), nil, SessionOptions{}))(RequireScope(tc.scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = AuthMethodFromContext(r.Context())
+				userID, _ = r.Context().Value(UserIDContextKey).(string)
+				w.WriteHeader(http.StatusOK)
+			}))))
+
+			req := httptest.NewRequest("GET", "/api/user/urls", nil)
+			if tc.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tc.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedMethod, method)
+			if tc.expectedMethod == AuthMethodAPIKey {
+				assert.Equal(t, "user1", userID)
+			}
+			assert.Equal(t, tc.expectCookie, len(rec.Result().Cookies()) > 0)
+		})
+	}
+
+	keys, err := store.GetAPIKeysByUser(ctx, "user1")
+	require.NoError(t, err)
+	for _, k := range keys {
+		if k.ID == "read" {
+			assert.NotNil(t, k.LastUsedAt, "using a key should record its last use")
+		} else {
+			assert.Nil(t, k.LastUsedAt)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package middlewares provides JWT bearer token issuing and verification.
+package middlewares
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Supported JWT signing algorithms.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+)
+
+// defaultTokenTTL is the bearer token lifetime used when none is configured.
+const defaultTokenTTL = time.Hour
+
+// ErrInvalidToken is returned when a bearer token is malformed, badly signed or expired.
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// jwtHeader is the JOSE header of a token.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// TokenClaims are the claims carried by a bearer token.
+type TokenClaims struct {
+	// Subject is the user ID.
+	Subject string `json:"sub"`
+	// Issuer is the optional token issuer.
+	Issuer string `json:"iss,omitempty"`
+	// IssuedAt is the issue time as a Unix timestamp.
+	IssuedAt int64 `json:"iat"`
+	// NotBefore is the optional time before which the token is not valid, as a Unix timestamp.
+	NotBefore int64 `json:"nbf,omitempty"`
+	// ExpiresAt is the expiry time as a Unix timestamp.
+	ExpiresAt int64 `json:"exp"`
+}
+
+// TokenManager issues and verifies signed JWT bearer tokens carrying a user ID.
+type TokenManager struct {
+	// algorithm is the only accepted signing algorithm.
+	algorithm string
+	// hmacKeys are the HS256 keys, newest first.
+	hmacKeys *KeyRing
+	// rsaPrivateKey is the RS256 signing key.
+	rsaPrivateKey *rsa.PrivateKey
+	// rsaKeyID is the key ID of rsaPrivateKey.
+	rsaKeyID string
+	// rsaPublicKeys are the RS256 verification keys by key ID.
+	rsaPublicKeys map[string]*rsa.PublicKey
+	// ttl is the lifetime of issued tokens.
+	ttl time.Duration
+	// issuer is the optional `iss` claim of issued tokens, also required on verified tokens.
+	issuer string
+	// now returns the current time.
+	now func() time.Time
+}
+
+// NewHS256TokenManager creates a TokenManager signing with the newest key of the ring using HS256.
+func NewHS256TokenManager(keys *KeyRing, ttl time.Duration, issuer string) *TokenManager {
+	return &TokenManager{
+		algorithm: AlgorithmHS256,
+		hmacKeys:  keys,
+		ttl:       tokenTTL(ttl),
+		issuer:    issuer,
+		now:       time.Now,
+	}
+}
+
+// NewRS256TokenManager creates a TokenManager signing with the private key using RS256.
+// Tokens are verified with the private key's public half and any additional public keys, by key ID.
+func NewRS256TokenManager(privateKey *rsa.PrivateKey, keyID string, publicKeys map[string]*rsa.PublicKey, ttl time.Duration, issuer string) *TokenManager {
+	verificationKeys := map[string]*rsa.PublicKey{keyID: &privateKey.PublicKey}
+	for id, key := range publicKeys {
+		if id != keyID {
+			verificationKeys[id] = key
+		}
+	}
+	return &TokenManager{
+		algorithm:     AlgorithmRS256,
+		rsaPrivateKey: privateKey,
+		rsaKeyID:      keyID,
+		rsaPublicKeys: verificationKeys,
+		ttl:           tokenTTL(ttl),
+		issuer:        issuer,
+		now:           time.Now,
+	}
+}
+
+// tokenTTL returns the configured token lifetime or the default one.
+func tokenTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return defaultTokenTTL
+	}
+	return ttl
+}
+
+// TTL returns the lifetime of issued tokens.
+func (tm *TokenManager) TTL() time.Duration {
+	return tm.ttl
+}
+
+// Issue creates a signed token for the user ID and returns it with its expiry time.
+func (tm *TokenManager) Issue(userID string) (string, time.Time, error) {
+	now := tm.now()
+	expiresAt := now.Add(tm.ttl)
+	claims := TokenClaims{
+		Subject:   userID,
+		Issuer:    tm.issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+
+	header := jwtHeader{Algorithm: tm.algorithm, Type: "JWT"}
+	switch tm.algorithm {
+	case AlgorithmHS256:
+		header.KeyID = tm.hmacKeys.keys[0].ID
+	case AlgorithmRS256:
+		header.KeyID = tm.rsaKeyID
+	}
+
+	encodedHeader, err := encodeSegment(header)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encodedClaims, err := encodeSegment(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signingInput := encodedHeader + "." + encodedClaims
+
+	signature, err := tm.sign(header.KeyID, signingInput)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), expiresAt, nil
+}
+
+// Verify checks the token signature, algorithm, issuer and validity period and returns its claims.
+func (tm *TokenManager) Verify(token string) (TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return TokenClaims{}, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return TokenClaims{}, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	if header.Algorithm != tm.algorithm {
+		return TokenClaims{}, fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, header.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return TokenClaims{}, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if err := tm.verifySignature(header.KeyID, parts[0]+"."+parts[1], signature); err != nil {
+		return TokenClaims{}, err
+	}
+
+	var claims TokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return TokenClaims{}, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	now := tm.now().Unix()
+	switch {
+	case claims.Subject == "":
+		return TokenClaims{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	case claims.ExpiresAt == 0 || now >= claims.ExpiresAt:
+		return TokenClaims{}, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	case claims.NotBefore != 0 && now < claims.NotBefore:
+		return TokenClaims{}, fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	case tm.issuer != "" && claims.Issuer != tm.issuer:
+		return TokenClaims{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// sign signs the input with the key identified by keyID.
+func (tm *TokenManager) sign(keyID string, signingInput string) ([]byte, error) {
+	switch tm.algorithm {
+	case AlgorithmHS256:
+		key, _ := tm.hmacKeys.key(keyID)
+		return mac(key.Secret, signingInput), nil
+	case AlgorithmRS256:
+		digest := sha256.Sum256([]byte(signingInput))
+		return rsa.SignPKCS1v15(rand.Reader, tm.rsaPrivateKey, crypto.SHA256, digest[:])
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", tm.algorithm)
+	}
+}
+
+// verifySignature checks the signature of the input against the key identified by keyID.
+func (tm *TokenManager) verifySignature(keyID string, signingInput string, signature []byte) error {
+	switch tm.algorithm {
+	case AlgorithmHS256:
+		key, ok := tm.hmacKeys.key(keyID)
+		if !ok {
+			return fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, keyID)
+		}
+		if !hmac.Equal(signature, mac(key.Secret, signingInput)) {
+			return fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+		}
+		return nil
+	case AlgorithmRS256:
+		key, ok := tm.rsaPublicKeys[keyID]
+		if !ok {
+			return fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, keyID)
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported algorithm", ErrInvalidToken)
+	}
+}
+
+// encodeSegment JSON-encodes a value as a base64url token segment.
+func encodeSegment(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeSegment decodes a base64url token segment into a value.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// LoadRSAPrivateKey reads a PEM-encoded PKCS#1 or PKCS#8 RSA private key from a file.
+func LoadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+// LoadRSAPublicKey reads a PEM-encoded PKIX or PKCS#1 RSA public key from a file.
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+// readPEM reads the first PEM block of a file.
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
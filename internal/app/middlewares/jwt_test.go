@@ -0,0 +1,175 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenManager_HS256(t *testing.T) {
+	keys := newTestKeyRing(t, "k2:new-secret-0123456789", "k1:old-secret-0123456789")
+	tm := NewHS256TokenManager(keys, time.Minute, "shorturl")
+
+	token, expiresAt, err := tm.Issue("user1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, time.Second)
+
+	claims, err := tm.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", claims.Subject)
+	assert.Equal(t, "shorturl", claims.Issuer)
+
+	oldTM := NewHS256TokenManager(newTestKeyRing(t, "k1:old-secret-0123456789"), time.Minute, "shorturl")
+	oldToken, _, err := oldTM.Issue("user2")
+	require.NoError(t, err)
+	claims, err = tm.Verify(oldToken)
+	require.NoError(t, err, "tokens signed with an older key should keep verifying")
+	assert.Equal(t, "user2", claims.Subject)
+
+	parts := strings.Split(token, ".")
+	testCases := []struct {
+		name  string
+		token string
+		tm    *TokenManager
+	}{
+		{name: "Malformed", token: "not-a-token", tm: tm},
+		{name: "Tampered claims", token: parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2], tm: tm},
+		{name: "None algorithm", token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", tm: tm},
+		{name: "Unknown key", token: token, tm: NewHS256TokenManager(newTestKeyRing(t, "k9:other-secret-0123456789"), time.Minute, "shorturl")},
+		{name: "Wrong issuer", token: token, tm: NewHS256TokenManager(keys, time.Minute, "someone-else")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.tm.Verify(tc.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+
+	t.Run("Expired", func(t *testing.T) {
+		expiring := NewHS256TokenManager(keys, time.Minute, "")
+		expiring.now = func() time.Time { return time.Now().Add(-time.Hour) }
+		expiredToken, _, err := expiring.Issue("user1")
+		require.NoError(t, err)
+
+		_, err = NewHS256TokenManager(keys, time.Minute, "").Verify(expiredToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestTokenManager_RS256(t *testing.T) {
+	dir := t.TempDir()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	privatePath := filepath.Join(dir, "private.pem")
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600))
+
+	publicPath := filepath.Join(dir, "rotated.pem")
+	publicDER, err := x509.MarshalPKIXPublicKey(&rotatedKey.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644))
+
+	loadedPrivate, err := LoadRSAPrivateKey(privatePath)
+	require.NoError(t, err)
+	loadedPublic, err := LoadRSAPublicKey(publicPath)
+	require.NoError(t, err)
+
+	tm := NewRS256TokenManager(loadedPrivate, "rsa-2", map[string]*rsa.PublicKey{"rsa-1": loadedPublic}, time.Minute, "")
+	token, _, err := tm.Issue("user1")
+	require.NoError(t, err)
+	claims, err := tm.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", claims.Subject)
+
+	rotatedTM := NewRS256TokenManager(rotatedKey, "rsa-1", nil, time.Minute, "")
+	rotatedToken, _, err := rotatedTM.Issue("user2")
+	require.NoError(t, err)
+	claims, err = tm.Verify(rotatedToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user2", claims.Subject)
+
+	// An HS256 token must not be accepted by an RS256 manager.
+	hsToken, _, err := NewHS256TokenManager(newTestKeyRing(t, "rsa-2:0123456789abcdef"), time.Minute, "").Issue("admin")
+	require.NoError(t, err)
+	_, err = tm.Verify(hsToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestBearerAuthMiddleware(t *testing.T) {
+	tm := NewHS256TokenManager(newTestKeyRing(t, "k1:0123456789abcdef"), time.Minute, "")
+	sessions := NewSessionManager(newTestKeyRing(t, "c1:0123456789abcdef"), nil, SessionOptions{})
+	token, _, err := tm.Issue("token-user")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+		expectedUserID string
+		expectedMethod string
+		expectCookie   bool
+	}{
+		{
+			name:           "Valid token",
+			authorization:  "Bearer " + token,
+			expectedStatus: http.StatusOK,
+			expectedUserID: "token-user",
+			expectedMethod: AuthMethodBearer,
+		},
+		{
+			name:           "Invalid token",
+			authorization:  "Bearer invalid",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "No token falls back to cookie",
+			expectedStatus: http.StatusOK,
+			expectedMethod: AuthMethodCookie,
+			expectCookie:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var userID, method string
+			handler := BearerAuthMiddleware(tm)(CookieAuthMiddleware(sessions)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = r.Context().Value(UserIDContextKey).(string)
+				method = AuthMethodFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest("GET", "/api/user/urls", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedMethod, method)
+			if tc.expectedUserID != "" {
+				assert.Equal(t, tc.expectedUserID, userID)
+			}
+			assert.Equal(t, tc.expectCookie, len(rec.Result().Cookies()) > 0)
+			if tc.expectedStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
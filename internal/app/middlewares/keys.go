@@ -0,0 +1,211 @@
+// Package middlewares provides the signing key ring used for authentication cookies.
+package middlewares
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// minSigningKeyLength is the minimum length of a signing key secret in bytes.
+const minSigningKeyLength = 16
+
+// Purposes of signed values. The purpose is signed with the value, so a value signed for one purpose
+// is never accepted for another.
+const (
+	// PurposeAuth signs the authentication cookies.
+	PurposeAuth = "auth"
+	// PurposeOIDCState signs the pending OpenID Connect sign-in state.
+	PurposeOIDCState = "oidc-state"
+)
+
+// ErrNoSigningKeys is returned when a key ring is created without any keys.
+var ErrNoSigningKeys = errors.New("no signing keys")
+
+// SigningKey is an HMAC key identified by a key ID.
+type SigningKey struct {
+	// ID is the key identifier embedded in signed values.
+	ID string
+	// Secret is the HMAC secret.
+	Secret []byte
+}
+
+// KeyRing holds the active signing keys. The first key signs new values,
+// and every key in the ring is accepted when verifying, so keys can be rotated
+// by prepending a new key and retired by removing old ones.
+type KeyRing struct {
+	// keys are the active keys, newest first.
+	keys []SigningKey
+}
+
+// NewKeyRing creates a key ring from the given keys, newest first.
+func NewKeyRing(keys ...SigningKey) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKeys
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.ID == "" || strings.ContainsAny(k.ID, ".:") {
+			return nil, fmt.Errorf("invalid signing key id %q", k.ID)
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate signing key id %q", k.ID)
+		}
+		if len(k.Secret) < minSigningKeyLength {
+			return nil, fmt.Errorf("signing key %q is shorter than %d bytes", k.ID, minSigningKeyLength)
+		}
+		seen[k.ID] = true
+	}
+	return &KeyRing{keys: keys}, nil
+}
+
+// NewEphemeralKeyRing creates a key ring with a single random key.
+// Values signed with it do not survive a restart.
+func NewEphemeralKeyRing() *KeyRing {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("generating signing key: %v", err))
+	}
+	return &KeyRing{keys: []SigningKey{{ID: "ephemeral", Secret: secret}}}
+}
+
+// ParseSigningKey parses a key in the `<id>:<secret>` form.
+func ParseSigningKey(s string) (SigningKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || id == "" || secret == "" {
+		return SigningKey{}, fmt.Errorf("invalid signing key: expected <id>:<secret>")
+	}
+	return SigningKey{ID: id, Secret: []byte(secret)}, nil
+}
+
+// LoadKeyRing creates a key ring from `<id>:<secret>` entries, newest first.
+// If filePath is set, keys are read from that file instead, one per line,
+// ignoring empty lines and lines starting with `#`.
+// If no keys are configured, an ephemeral random key is used.
+func LoadKeyRing(entries []string, filePath string) (*KeyRing, error) {
+	if filePath != "" {
+		fileEntries, err := readKeyFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading signing keys file: %w", err)
+		}
+		entries = fileEntries
+	}
+
+	if len(entries) == 0 {
+		slog.Warn("no cookie signing keys configured, using an ephemeral key: sessions will not survive a restart")
+		return NewEphemeralKeyRing(), nil
+	}
+
+	keys := make([]SigningKey, 0, len(entries))
+	for _, e := range entries {
+		key, err := ParseSigningKey(e)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyRing(keys...)
+}
+
+// readKeyFile reads key entries from a file, one per line.
+func readKeyFile(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
+
+// Sign signs the value with the newest key and returns `<key id>.<value>.<signature>`,
+// with the value and signature base64url-encoded.
+func (kr *KeyRing) Sign(value string) string {
+	key := kr.keys[0]
+	payload := key.ID + "." + base64.RawURLEncoding.EncodeToString([]byte(value))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac(key.Secret, payload))
+}
+
+// Verify checks a signed value against the key named by its key ID and returns the original value.
+// It returns false if the key is unknown or retired, or the signature does not match.
+func (kr *KeyRing) Verify(signed string) (string, bool) {
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	keyID, encodedValue, encodedSignature := parts[0], parts[1], parts[2]
+
+	key, ok := kr.key(keyID)
+	if !ok {
+		return "", false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", false
+	}
+	if !hmac.Equal(signature, mac(key.Secret, keyID+"."+encodedValue)) {
+		return "", false
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encodedValue)
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+// SignFor signs the value for the purpose, which Verify returns as the `<purpose>|` prefix of the value.
+func (kr *KeyRing) SignFor(purpose string, value string) string {
+	return kr.Sign(purpose + "|" + value)
+}
+
+// VerifyFor checks a value signed by SignFor for the purpose and returns the original value.
+// It returns false for values signed for another purpose or without one.
+func (kr *KeyRing) VerifyFor(purpose string, signed string) (string, bool) {
+	value, ok := kr.Verify(signed)
+	if !ok {
+		return "", false
+	}
+	return strings.CutPrefix(value, purpose+"|")
+}
+
+// KeyIDs returns the IDs of the active keys, newest first.
+func (kr *KeyRing) KeyIDs() []string {
+	ids := make([]string, len(kr.keys))
+	for i, k := range kr.keys {
+		ids[i] = k.ID
+	}
+	return ids
+}
+
+// key looks up an active key by ID.
+func (kr *KeyRing) key(id string) (SigningKey, bool) {
+	for _, k := range kr.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// mac computes the HMAC-SHA256 of the payload.
+func mac(secret []byte, payload string) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
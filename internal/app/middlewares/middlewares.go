@@ -4,9 +4,7 @@ package middlewares
 import (
 	"compress/gzip"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
@@ -20,11 +18,39 @@ type contextKey string
 // UserIDContextKey is the context key for the user ID.
 const UserIDContextKey contextKey = "userID"
 
-// cookieName is the name of the authentication cookie.
-const cookieName = "authCookie"
+// issuedUserIDContextKey is the context key marking a user ID that was issued by the current request.
+const issuedUserIDContextKey contextKey = "issuedUserID"
 
-// secretKey is the key used to sign the authentication cookie.
-const secretKey = "secretKeyHere"
+// authMethodContextKey is the context key for the method the user ID was authenticated with.
+const authMethodContextKey contextKey = "authMethod"
+
+// Authentication methods.
+const (
+	AuthMethodCookie = "cookie"
+	AuthMethodBearer = "bearer"
+	AuthMethodAPIKey = "api_key"
+)
+
+// AuthMethodFromContext returns the method the request's user ID was authenticated with.
+func AuthMethodFromContext(ctx context.Context) string {
+	method, _ := ctx.Value(authMethodContextKey).(string)
+	return method
+}
+
+// withUserID returns a context carrying the authenticated user ID and authentication method.
+func withUserID(ctx context.Context, userID string, method string) context.Context {
+	ctx = context.WithValue(ctx, UserIDContextKey, userID)
+	return context.WithValue(ctx, authMethodContextKey, method)
+}
+
+// hasUserID reports whether an earlier middleware already authenticated the request.
+func hasUserID(r *http.Request) bool {
+	userID, ok := r.Context().Value(UserIDContextKey).(string)
+	return ok && userID != ""
+}
+
+// CookieName is the name of the authentication cookie.
+const CookieName = "authCookie"
 
 // gzipWriter is a custom http.ResponseWriter that supports gzip compression.
 type gzipWriter struct {
@@ -44,7 +70,7 @@ func GzipMiddleware(next http.Handler) http.Handler {
 		if strings.Contains(strings.ToLower(r.Header.Get("Content-Encoding")), "gzip") {
 			uncompressed, err := gzip.NewReader(r.Body)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respondWithProblem(w, r, http.StatusBadRequest, ProblemMalformedBody, "The request body is not valid gzip: "+err.Error())
 				return
 			}
 			defer uncompressed.Close()
@@ -63,79 +89,74 @@ func GzipMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// CookieAuthMiddleware is a middleware that handles authentication using cookies.
-func CookieAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie(cookieName)
-
-		if err == http.ErrNoCookie || !isValidCookie(cookie) {
-			userID := uuid.NewString()
-			cookieValue := signCookie(userID)
-			newCookie := http.Cookie{
-				Name:  cookieName,
-				Value: cookieValue,
+// CookieAuthMiddleware returns a middleware that authenticates requests using session cookies.
+// Requests without a valid session are assigned a new user ID and a new session.
+func CookieAuthMiddleware(sessions *SessionManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hasUserID(r) {
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			http.SetCookie(w, &newCookie)
-			slog.Debug("new cookie set successfully", slog.String("user", userID))
-
-			ctx := context.WithValue(r.Context(), UserIDContextKey, userID)
-			next.ServeHTTP(w, r.WithContext(ctx))
-			return
-		}
+			session, err := sessions.Authenticate(w, r)
+			switch {
+			case err == nil:
+				slog.Debug("session validation successful", slog.String("user", session.UserID))
+				ctx := withUserID(r.Context(), session.UserID, AuthMethodCookie)
+				next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, sessionContextKey, session)))
+				return
+			case errors.Is(err, ErrInvalidSession):
+				slog.Debug("session rejected", slog.Any("error", err))
+			case !errors.Is(err, http.ErrNoCookie):
+				slog.Error("session validation", slog.Any("error", err))
+				respondWithProblem(w, r, http.StatusServiceUnavailable, ProblemSessionUnavailable, "The session could not be verified.")
+				return
+			}
 
-		cookieValue, _, err := decodeCookieValue(cookie)
-		if err != nil {
-			slog.Error("cookie decoding", slog.Any("error", err))
-			next.ServeHTTP(w, r)
-			return
-		}
-		slog.Debug("cookie validation successful", slog.String("user", string(cookieValue)))
+			session = sessions.Start(w, uuid.NewString())
+			slog.Debug("new session started", slog.String("user", session.UserID))
 
-		ctx := context.WithValue(r.Context(), UserIDContextKey, string(cookieValue))
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			ctx := withUserID(r.Context(), session.UserID, AuthMethodCookie)
+			ctx = context.WithValue(ctx, sessionContextKey, session)
+			ctx = context.WithValue(ctx, issuedUserIDContextKey, true)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
-// signCookie signs the cookie value with an HMAC using the secret key.
-func signCookie(value string) string {
-	mac := hmac.New(sha256.New, []byte(secretKey))
-	mac.Write([]byte(value))
-
-	signature := mac.Sum(nil)
-	signedValue := append([]byte(value), signature...)
-	encodedValue := base64.StdEncoding.EncodeToString(signedValue)
-
-	return encodedValue
-}
+// BearerAuthMiddleware returns a middleware that authenticates requests carrying an
+// `Authorization: Bearer <token>` header. Requests with an invalid token are rejected with 401.
+// Requests without the header are passed through unchanged to the next authentication middleware.
+func BearerAuthMiddleware(tokens *TokenManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-// isValidCookie checks if the cookie is valid by verifying its HMAC signature.
-func isValidCookie(cookie *http.Cookie) bool {
-	if cookie == nil || cookie.Value == "" {
-		return false
-	}
+			claims, err := tokens.Verify(token)
+			if err != nil {
+				slog.Info("bearer token rejected", slog.Any("error", err))
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				respondWithProblem(w, r, http.StatusUnauthorized, ProblemUnauthorized, "The bearer token is invalid or expired.")
+				return
+			}
+			slog.Debug("bearer token validation successful", slog.String("user", claims.Subject))
 
-	cookieValue, hmacSignature, err := decodeCookieValue(cookie)
-	if err != nil {
-		return false
+			next.ServeHTTP(w, r.WithContext(withUserID(r.Context(), claims.Subject, AuthMethodBearer)))
+		})
 	}
-
-	mac := hmac.New(sha256.New, []byte(secretKey))
-	mac.Write(cookieValue)
-	expectedHMAC := mac.Sum(nil)
-
-	return hmac.Equal(hmacSignature, expectedHMAC)
 }
 
-// decodeCookieValue decodes the cookie value and returns the value and the HMAC signature.
-func decodeCookieValue(cookie *http.Cookie) ([]byte, []byte, error) {
-	decodedCookie, err := base64.StdEncoding.DecodeString(cookie.Value)
-	if err != nil || len(decodedCookie) < sha256.Size {
-		return nil, nil, err
+// bearerToken extracts the token from an `Authorization: Bearer <token>` header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
 	}
-
-	cookieValue := decodedCookie[:len(decodedCookie)-sha256.Size]
-	hmacSignature := decodedCookie[len(decodedCookie)-sha256.Size:]
-
-	return cookieValue, hmacSignature, nil
+	token = strings.TrimSpace(token)
+	return token, token != ""
 }
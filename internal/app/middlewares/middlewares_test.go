@@ -3,10 +3,11 @@ package middlewares
 import (
 	"bytes"
 	"compress/gzip"
-	"crypto/hmac"
-	"crypto/sha256"
+	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -86,7 +87,17 @@ func compressString(s string) string {
 	return b.String()
 }
 
-func TestSignCookie(t *testing.T) {
+func newTestKeyRing(t testing.TB, keys ...string) *KeyRing {
+	t.Helper()
+	keyRing, err := LoadKeyRing(keys, "")
+	if err != nil {
+		t.Fatalf("creating key ring: %v", err)
+	}
+	return keyRing
+}
+
+func TestKeyRing_SignVerify(t *testing.T) {
+	keyRing := newTestKeyRing(t, "k1:0123456789abcdef")
 	testCases := []struct {
 		name  string
 		value string
@@ -103,96 +114,130 @@ func TestSignCookie(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			cookieValue := signCookie(tc.value)
-			newCookie := http.Cookie{
-				Name:  cookieName,
-				Value: cookieValue,
-			}
+			signed := keyRing.Sign(tc.value)
+			assert.NotEmpty(t, signed, "Expected non-empty signed value")
+			assert.True(t, strings.HasPrefix(signed, "k1."), "Expected signed value to carry the key ID")
 
-			assert.NotEmpty(t, cookieValue, "Expected non-empty encoded value")
-			assert.True(t, isValidCookie(&newCookie), "Cookie signature verification failed")
+			value, ok := keyRing.Verify(signed)
+			assert.True(t, ok, "Cookie signature verification failed")
+			assert.Equal(t, tc.value, value)
 		})
 	}
 }
 
-func TestIsValidCookie(t *testing.T) {
-	cookieName := "authCookie"
+func TestKeyRing_Verify(t *testing.T) {
+	keyRing := newTestKeyRing(t, "k1:0123456789abcdef")
+	otherKeyRing := newTestKeyRing(t, "k1:fedcba9876543210")
+	valid := keyRing.Sign("test")
+	parts := strings.Split(valid, ".")
+
 	testCases := []struct {
 		name           string
-		cookie         *http.Cookie
+		value          string
 		expectedResult bool
 	}{
-		{
-			name: "Valid cookie",
-			cookie: &http.Cookie{
-				Name:  cookieName,
-				Value: signCookie("test"),
-			},
-			expectedResult: true,
-		},
-		{
-			name:           "Nil cookie",
-			cookie:         nil,
-			expectedResult: false,
-		},
-		{
-			name: "Empty cookie",
-			cookie: &http.Cookie{
-				Name:  cookieName,
-				Value: "",
-			},
-			expectedResult: false,
-		},
-		{
-			name: "Invalid cookie value",
-			cookie: &http.Cookie{
-				Name:  cookieName,
-				Value: "invalidbase64value",
-			},
-			expectedResult: false,
-		},
-		{
-			name: "Invalid cookie value length",
-			cookie: &http.Cookie{
-				Name:  cookieName,
-				Value: "dGVzdJ7TkpqlBo08C7tx5l7TAatECCRx/xqO2BX/",
-			},
-			expectedResult: false,
-		},
-		{
-			name: "Invalid HMAC",
-			cookie: &http.Cookie{
-				Name:  "session",
-				Value: "dGVzdJ7TkpqlBo08C7tx5l7TAatECCRx/xqO2BX/a7cfoS/1=",
-			},
-			expectedResult: false,
-		},
+		{name: "Valid value", value: valid, expectedResult: true},
+		{name: "Empty value", value: "", expectedResult: false},
+		{name: "Legacy unsigned format", value: "dGVzdJ7TkpqlBo08C7tx5l7TAatECCRx/xqO2BX/a7cfoS/1=", expectedResult: false},
+		{name: "Unknown key ID", value: "k2." + parts[1] + "." + parts[2], expectedResult: false},
+		{name: "Tampered value", value: parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte("admin")) + "." + parts[2], expectedResult: false},
+		{name: "Invalid signature encoding", value: parts[0] + "." + parts[1] + ".!!!", expectedResult: false},
+		{name: "Signed with a different secret", value: otherKeyRing.Sign("test"), expectedResult: false},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := isValidCookie(tc.cookie)
+			_, result := keyRing.Verify(tc.value)
 			if result != tc.expectedResult {
-				t.Errorf("Expected cookie validation result: %v, got: %v", tc.expectedResult, result)
+				t.Errorf("Expected verification result: %v, got: %v", tc.expectedResult, result)
 			}
 		})
 	}
 }
 
+func TestKeyRing_Purpose(t *testing.T) {
+	keyRing := newTestKeyRing(t, "k1:0123456789abcdef")
+
+	value, ok := keyRing.VerifyFor(PurposeAuth, keyRing.SignFor(PurposeAuth, "user1"))
+	assert.True(t, ok)
+	assert.Equal(t, "user1", value)
+
+	_, ok = keyRing.VerifyFor(PurposeAuth, keyRing.SignFor(PurposeOIDCState, "user1"))
+	assert.False(t, ok, "Values signed for another purpose should not verify")
+	_, ok = keyRing.VerifyFor(PurposeAuth, keyRing.Sign("user1"))
+	assert.False(t, ok, "Values signed without a purpose should not verify")
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	oldRing := newTestKeyRing(t, "2024:old-secret-0123456789")
+	rotatedRing := newTestKeyRing(t, "2025:new-secret-0123456789", "2024:old-secret-0123456789")
+	retiredRing := newTestKeyRing(t, "2025:new-secret-0123456789")
+
+	oldCookie := oldRing.Sign("user1")
+	value, ok := rotatedRing.Verify(oldCookie)
+	assert.True(t, ok, "Old key should keep verifying after rotation")
+	assert.Equal(t, "user1", value)
+	assert.True(t, strings.HasPrefix(rotatedRing.Sign("user2"), "2025."), "New cookies should be signed with the newest key")
+
+	_, ok = retiredRing.Verify(oldCookie)
+	assert.False(t, ok, "Retired key should no longer verify")
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	err := os.WriteFile(keysFile, []byte("# newest first\nfile2:file-secret-0123456789\n\nfile1:file-secret-9876543210\n"), 0600)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		entries     []string
+		filePath    string
+		expectedIDs []string
+		expectError bool
+	}{
+		{name: "Config keys", entries: []string{"a:0123456789abcdef", "b:0123456789abcdef"}, expectedIDs: []string{"a", "b"}},
+		{name: "File takes precedence", entries: []string{"a:0123456789abcdef"}, filePath: keysFile, expectedIDs: []string{"file2", "file1"}},
+		{name: "Ephemeral fallback", expectedIDs: []string{"ephemeral"}},
+		{name: "Missing file", filePath: filepath.Join(t.TempDir(), "missing"), expectError: true},
+		{name: "Malformed entry", entries: []string{"no-separator"}, expectError: true},
+		{name: "Short secret", entries: []string{"a:short"}, expectError: true},
+		{name: "Duplicate key ID", entries: []string{"a:0123456789abcdef", "a:fedcba9876543210"}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyRing, err := LoadKeyRing(tc.entries, tc.filePath)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedIDs, keyRing.KeyIDs())
+		})
+	}
+}
+
 func TestCookieAuthMiddleware(t *testing.T) {
 	cookieName := "authCookie"
+	keyRing := newTestKeyRing(t, "k1:0123456789abcdef")
+	sessions := NewSessionManager(keyRing, nil, SessionOptions{})
+	existingUserID := uuid.NewString()
+	started := httptest.NewRecorder()
+	sessions.Start(started, existingUserID)
 	testCases := []struct {
 		name               string
 		cookie             *http.Cookie
 		expectedStatusCode int
+		expectNewCookie    bool
 	}{
 		{
 			name: "Valid cookie",
 			cookie: &http.Cookie{
 				Name:  "authCookie",
-				Value: signCookie(uuid.NewString()),
+				Value: started.Result().Cookies()[0].Value,
 			},
 			expectedStatusCode: http.StatusOK,
+			expectNewCookie:    false,
 		},
 		{
 			name: "Invalid cookie",
@@ -201,11 +246,13 @@ func TestCookieAuthMiddleware(t *testing.T) {
 				Value: "invalidCookie",
 			},
 			expectedStatusCode: http.StatusOK,
+			expectNewCookie:    true,
 		},
 		{
 			name:               "Missing cookie",
 			cookie:             nil,
 			expectedStatusCode: http.StatusOK,
+			expectNewCookie:    true,
 		},
 	}
 
@@ -216,75 +263,35 @@ func TestCookieAuthMiddleware(t *testing.T) {
 				req.AddCookie(tc.cookie)
 			}
 
+			var ctxUserID string
 			rec := httptest.NewRecorder()
-			handler := CookieAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var sessionOK bool
+			handler := CookieAuthMiddleware(sessions)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxUserID, _ = r.Context().Value(UserIDContextKey).(string)
+				_, sessionOK = SessionFromContext(r.Context())
 				w.WriteHeader(http.StatusOK)
 			}))
 			handler.ServeHTTP(rec, req)
 
 			assert.Equal(t, tc.expectedStatusCode, rec.Code)
-			if tc.cookie == nil || tc.expectedStatusCode != http.StatusOK {
-				cookie := rec.Result().Cookies()[0]
-				assert.Equal(t, cookieName, cookie.Name)
-				assert.NotEmpty(t, cookie.Value)
-				assert.True(t, isValidCookie(cookie), "Cookie signature verification failed")
-			}
-		})
-	}
-}
-
-func TestDecodeCookieValue(t *testing.T) {
-	userID := "userID"
-	testCases := []struct {
-		name              string
-		cookieValue       string
-		expectedCookie    []byte
-		expectedSignature []byte
-		expectError       bool
-	}{
-		{
-			name:              "ValidCookie",
-			cookieValue:       signCookie(userID),
-			expectedCookie:    []byte(userID),
-			expectedSignature: []byte(secretKey),
-			expectError:       false,
-		},
-		{
-			name:              "InvalidBase64",
-			cookieValue:       "v",
-			expectedCookie:    nil,
-			expectedSignature: nil,
-			expectError:       true,
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			cookie := &http.Cookie{
-				Value: tc.cookieValue,
-			}
-
-			cookieValue, hmacSignature, err := decodeCookieValue(cookie)
-			mac := hmac.New(sha256.New, []byte(secretKey))
-			mac.Write(cookieValue)
-			expectedHMAC := mac.Sum(nil)
-
-			if tc.expectError {
-				if err == nil {
-					t.Errorf("Expected an error, but got nil")
-				}
+			assert.NotEmpty(t, ctxUserID)
+			assert.True(t, sessionOK, "Session should be stored in the request context")
+			if !tc.expectNewCookie {
+				assert.Empty(t, rec.Result().Cookies())
+				assert.Equal(t, existingUserID, ctxUserID)
 				return
 			}
-
-			if err != nil {
-				t.Errorf("Unexpected error: %v", err)
-				return
-			}
-
-			if !hmac.Equal(hmacSignature, expectedHMAC) {
-				t.Errorf("Cookie value mismatch. Expected: %v, Got: %v", tc.expectedCookie, cookieValue)
-			}
-
+			cookie := rec.Result().Cookies()[0]
+			assert.Equal(t, cookieName, cookie.Name)
+			assert.NotEmpty(t, cookie.Value)
+			assert.True(t, cookie.HttpOnly)
+			assert.Equal(t, "/", cookie.Path)
+			assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+			value, ok := keyRing.VerifyFor(PurposeAuth, cookie.Value)
+			assert.True(t, ok, "Cookie signature verification failed")
+			session, err := decodeSession(value)
+			assert.NoError(t, err)
+			assert.Equal(t, ctxUserID, session.UserID)
 		})
 	}
 }
@@ -305,8 +312,9 @@ func BenchmarkCookieAuthMiddleware(b *testing.B) {
 	req.AddCookie(cookie)
 
 	rr := httptest.NewRecorder()
+	middleware := CookieAuthMiddleware(NewSessionManager(newTestKeyRing(b, "k1:0123456789abcdef"), nil, SessionOptions{}))
 
 	for i := 0; i < b.N; i++ {
-		CookieAuthMiddleware(handler).ServeHTTP(rr, req)
+		middleware(handler).ServeHTTP(rr, req)
 	}
 }
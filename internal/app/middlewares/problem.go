@@ -0,0 +1,105 @@
+// Package middlewares provides RFC 7807 problem details responses shared by middlewares and handlers.
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType is the content type of problem details responses.
+const ProblemContentType = "application/problem+json"
+
+// problemType is the problem type URI: the code and title identify the problem.
+const problemType = "about:blank"
+
+// plainTextErrorsContextKey is the context key marking routes answering plain-text clients.
+const plainTextErrorsContextKey contextKey = "plainTextErrors"
+
+// Problem codes. Codes are stable and safe for clients to branch on.
+const (
+	ProblemInternal           = "internal_error"
+	ProblemMalformedBody      = "malformed_body"
+	ProblemMissingUserID      = "missing_user_id"
+	ProblemUnauthorized       = "unauthorized"
+	ProblemForbidden          = "forbidden"
+	ProblemInsufficientScope  = "insufficient_scope"
+	ProblemRateLimited        = "rate_limited"
+	ProblemSessionUnavailable = "session_unavailable"
+)
+
+// FieldError describes why a single field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem represents an RFC 7807 problem details response.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem creates a Problem with a machine-readable code and a human-readable detail.
+func NewProblem(status int, code string, detail string, fieldErrors ...FieldError) *Problem {
+	return &Problem{
+		Type:   problemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+		Errors: fieldErrors,
+	}
+}
+
+// PlainTextErrors is a middleware marking routes whose clients expect plain text.
+// Problems on such routes are written as text unless the client accepts JSON.
+func PlainTextErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), plainTextErrorsContextKey, true)))
+	})
+}
+
+// WriteProblem writes the problem for the request, filling in the request path and ID.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = RequestIDFromContext(r.Context())
+
+	if plainText, _ := r.Context().Value(plainTextErrorsContextKey).(bool); plainText && !acceptsJSON(r) {
+		http.Error(w, p.Detail, p.Status)
+		return
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		slog.Error("marshalling problem", slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if _, err := w.Write(body); err != nil {
+		slog.Error("writing problem response", slog.Any("error", err))
+	}
+}
+
+// respondWithProblem writes a problem without field errors.
+func respondWithProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	WriteProblem(w, r, NewProblem(status, code, detail))
+}
+
+// acceptsJSON reports whether the Accept header of the request names a JSON media type.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") || strings.Contains(accept, ProblemContentType)
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteProblem(t *testing.T) {
+	problem := func(w http.ResponseWriter, r *http.Request) {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, ProblemMalformedBody, "The body is malformed.",
+			FieldError{Field: "url", Code: "missing", Message: "The field is required."}))
+	}
+
+	testCases := []struct {
+		name                string
+		plainText           bool
+		accept              string
+		expectedContentType string
+	}{
+		{name: "Problem details", expectedContentType: ProblemContentType},
+		{name: "Plain text route", plainText: true, expectedContentType: "text/plain; charset=utf-8"},
+		{name: "Plain text route accepting JSON", plainText: true, accept: "application/json", expectedContentType: ProblemContentType},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var handler http.Handler = http.HandlerFunc(problem)
+			if tc.plainText {
+				handler = PlainTextErrors(handler)
+			}
+			handler = RequestIDMiddleware(handler)
+
+			req := httptest.NewRequest("POST", "/api/shorten", nil)
+			req.Header.Set(RequestIDHeader, "req-123")
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, tc.expectedContentType, rec.Header().Get("Content-Type"))
+			if tc.expectedContentType != ProblemContentType {
+				assert.Equal(t, "The body is malformed.", strings.TrimSpace(rec.Body.String()))
+				return
+			}
+
+			var got Problem
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, Problem{
+				Type:      "about:blank",
+				Title:     "Bad Request",
+				Status:    http.StatusBadRequest,
+				Code:      ProblemMalformedBody,
+				Detail:    "The body is malformed.",
+				Instance:  "/api/shorten",
+				RequestID: "req-123",
+				Errors:    []FieldError{{Field: "url", Code: "missing", Message: "The field is required."}},
+			}, got)
+		})
+	}
+}
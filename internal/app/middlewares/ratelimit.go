@@ -0,0 +1,295 @@
+// Package middlewares provides token-bucket rate limiting middleware.
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/jobs"
+)
+
+// RateLimitPruneJobName is the name of the scheduler job removing the idle token buckets.
+const RateLimitPruneJobName = "rate-limit-prune"
+
+// RouteClass identifies a group of routes sharing a rate limit.
+type RouteClass string
+
+// Route classes with separately configurable rate limits.
+const (
+	RouteClassRedirect RouteClass = "redirect"
+	RouteClassShorten  RouteClass = "shorten"
+	RouteClassBatch    RouteClass = "batch"
+	RouteClassDelete   RouteClass = "delete"
+	RouteClassReport   RouteClass = "report"
+)
+
+// RateLimit is a token-bucket limit: a bucket of Requests tokens refilled evenly over Period.
+type RateLimit struct {
+	// Requests is the bucket capacity and the number of tokens refilled per Period.
+	Requests int
+	// Period is the time it takes to refill an empty bucket.
+	Period time.Duration
+}
+
+// ParseRateLimit parses a rate limit in the `<requests>/<period>` form, e.g. "100/1m".
+// An empty string returns a zero RateLimit, which disables limiting.
+func ParseRateLimit(s string) (RateLimit, error) {
+	if s == "" {
+		return RateLimit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: expected <requests>/<period>", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+	return RateLimit{Requests: n, Period: d}, nil
+}
+
+// Enabled reports whether the limit is configured.
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// rate returns the number of tokens refilled per second.
+func (l RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	// Allowed reports whether a token was available.
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available, if the request was not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps token buckets. Implementations may be local to the process or shared across replicas.
+type RateLimitStore interface {
+	// Take refills the bucket identified by key according to limit and tries to take one token from it.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+	// Prune removes the buckets not used for longer than idle and returns the number removed.
+	Prune(ctx context.Context, idle time.Duration) (int, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// TokenBucket is the persisted state of a token bucket.
+type TokenBucket struct {
+	// Tokens is the number of tokens in the bucket at UpdatedAt.
+	Tokens float64
+	// UpdatedAt is the time the bucket was last refilled.
+	UpdatedAt time.Time
+}
+
+// Take refills the bucket up to now and tries to take one token from it.
+// A zero bucket is treated as full.
+func (b *TokenBucket) Take(now time.Time, limit RateLimit) RateLimitResult {
+	capacity := float64(limit.Requests)
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*limit.rate())
+	}
+	b.UpdatedAt = now
+
+	result := RateLimitResult{}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.Tokens) / limit.rate())
+	}
+	result.Remaining = int(math.Floor(b.Tokens))
+	result.Reset = secondsToDuration((capacity - b.Tokens) / limit.rate())
+	return result
+}
+
+// secondsToDuration converts fractional seconds to a time.Duration.
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore.
+type MemoryRateLimitStore struct {
+	// buckets maps bucket keys to their state.
+	buckets map[string]*TokenBucket
+	// now returns the current time.
+	now func() time.Time
+	// mu is a mutex to synchronize access to the buckets.
+	mu sync.Mutex
+}
+
+// NewMemoryRateLimitStore creates a new MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*TokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Take refills the bucket identified by key and tries to take one token from it.
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &TokenBucket{}
+		s.buckets[key] = bucket
+	}
+	return bucket.Take(s.now(), limit), nil
+}
+
+// Prune removes the buckets not used for longer than idle and returns the number removed.
+func (s *MemoryRateLimitStore) Prune(ctx context.Context, idle time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	pruned := 0
+	for key, b := range s.buckets {
+		if now.Sub(b.UpdatedAt) > idle {
+			delete(s.buckets, key)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// Close releases the resources of the store. It does nothing for MemoryRateLimitStore.
+func (s *MemoryRateLimitStore) Close() error {
+	return nil
+}
+
+// RateLimiter limits requests per route class, keyed by the authenticated user ID or the client IP.
+type RateLimiter struct {
+	// store keeps the token buckets.
+	store RateLimitStore
+	// limits are the configured limits per route class.
+	limits map[RouteClass]RateLimit
+}
+
+// NewRateLimiter creates a new RateLimiter backed by the given store.
+func NewRateLimiter(store RateLimitStore, limits map[RouteClass]RateLimit) *RateLimiter {
+	return &RateLimiter{store: store, limits: limits}
+}
+
+// Register registers the removal of the idle token buckets as a periodic job of the scheduler, bounding the size
+// of the store. A bucket idle for longer than the longest period is full again, the same as a missing one.
+// The job runs at the interval, or at the longest period if the interval is zero.
+func (rl *RateLimiter) Register(s *jobs.Scheduler, interval time.Duration) error {
+	idle := rl.longestPeriod()
+	if idle == 0 {
+		return nil
+	}
+	if interval <= 0 {
+		interval = idle
+	}
+	return s.Every(RateLimitPruneJobName, func(ctx context.Context) error {
+		pruned, err := rl.store.Prune(ctx, idle)
+		if err != nil {
+			return fmt.Errorf("pruning rate limit buckets: %w", err)
+		}
+		slog.Debug("rate limit buckets pruned", slog.Int("buckets", pruned))
+		return nil
+	}, jobs.Options{Interval: interval})
+}
+
+// Close releases the resources of the store.
+func (rl *RateLimiter) Close() error {
+	return rl.store.Close()
+}
+
+// longestPeriod returns the longest period of the enabled limits, or zero if none is enabled.
+func (rl *RateLimiter) longestPeriod() time.Duration {
+	var longest time.Duration
+	for _, limit := range rl.limits {
+		if limit.Enabled() && limit.Period > longest {
+			longest = limit.Period
+		}
+	}
+	return longest
+}
+
+// Limit returns a middleware applying the rate limit of the given route class.
+// Requests over the limit are rejected with 429 Too Many Requests.
+// All limited responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+func (rl *RateLimiter) Limit(class RouteClass) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if rl == nil {
+			return next
+		}
+		limit, ok := rl.limits[class]
+		if !ok || !limit.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := string(class) + ":" + rateLimitKey(r, class)
+			result, err := rl.store.Take(r.Context(), key, limit)
+			if err != nil {
+				// Fail open: an unavailable store must not take the service down.
+				slog.Error("rate limit store", slog.String("key", key), slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				slog.Info("rate limit exceeded", slog.String("key", key))
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				respondWithProblem(w, r, http.StatusTooManyRequests, ProblemRateLimited, "The rate limit is exceeded, retry after "+w.Header().Get("Retry-After")+" seconds.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey returns the user ID for requests with a previously issued auth cookie,
+// and the client IP otherwise, so that clients dropping cookies cannot evade the limit.
+// Reports are always limited by the client IP: a cookie is free to mint and a report costs nothing to file.
+func rateLimitKey(r *http.Request, class RouteClass) string {
+	userID, ok := r.Context().Value(UserIDContextKey).(string)
+	issued, _ := r.Context().Value(issuedUserIDContextKey).(bool)
+	if ok && userID != "" && !issued && class != RouteClassReport {
+		return "user:" + userID
+	}
+	return "ip:" + ClientIP(r)
+}
+
+// ClientIP returns the IP address of the remote end of the request.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ceilSeconds rounds a duration up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,117 @@
+// Package middlewares provides a PostgreSQL-backed rate limit store shared across replicas.
+package middlewares
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Ensure PostgresRateLimitStore implements the RateLimitStore interface.
+var _ RateLimitStore = (*PostgresRateLimitStore)(nil)
+
+// PostgresRateLimitStore is a RateLimitStore keeping token buckets in PostgreSQL,
+// so that all replicas of the service share the same limits.
+type PostgresRateLimitStore struct {
+	// db is the database connection.
+	db *sql.DB
+}
+
+// NewPostgresRateLimitStore creates a new PostgresRateLimitStore and sets up its table if it doesn't exist.
+func NewPostgresRateLimitStore(ctx context.Context, pgDSN string) (*PostgresRateLimitStore, error) {
+	createTableQuery := `
+	CREATE TABLE IF NOT EXISTS rate_limit_bucket (
+		bucket_key VARCHAR(255) PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_rate_limit_bucket_updated_at ON rate_limit_bucket (updated_at);
+	`
+
+	db, err := sql.Open("pgx", pgDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createTableQuery); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create rate limit table: %w", err)
+	}
+	return &PostgresRateLimitStore{db: db}, nil
+}
+
+// Take refills the bucket identified by key and tries to take one token from it.
+// The bucket row is locked for the duration of the update so concurrent replicas see consistent state.
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (result RateLimitResult, err error) {
+	insertBucketQuery := `
+	INSERT INTO rate_limit_bucket (bucket_key, tokens, updated_at)
+	VALUES ($1, $2, NOW())
+	ON CONFLICT (bucket_key) DO NOTHING;
+	`
+	selectBucketQuery := `
+	SELECT tokens, updated_at, NOW()
+	FROM rate_limit_bucket
+	WHERE bucket_key = $1
+	FOR UPDATE;
+	`
+	updateBucketQuery := `
+	UPDATE rate_limit_bucket
+	SET tokens = $2, updated_at = $3
+	WHERE bucket_key = $1;
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("rate limit bucket rollback", slog.Any("error", rbErr))
+			}
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, insertBucketQuery, key, float64(limit.Requests)); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var bucket TokenBucket
+	var now time.Time
+	if err = tx.QueryRowContext(ctx, selectBucketQuery, key).Scan(&bucket.Tokens, &bucket.UpdatedAt, &now); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	result = bucket.Take(now, limit)
+	if _, err = tx.ExecContext(ctx, updateBucketQuery, key, bucket.Tokens, bucket.UpdatedAt); err != nil {
+		return RateLimitResult{}, err
+	}
+	return result, tx.Commit()
+}
+
+// Prune removes the buckets not used for longer than idle and returns the number removed.
+func (s *PostgresRateLimitStore) Prune(ctx context.Context, idle time.Duration) (int, error) {
+	pruneBucketsQuery := `
+	DELETE FROM rate_limit_bucket
+	WHERE updated_at < NOW() - make_interval(secs => $1);
+	`
+
+	result, err := s.db.ExecContext(ctx, pruneBucketsQuery, idle.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune rate limit buckets: %w", err)
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(pruned), nil
+}
+
+// Close closes the database connection.
+func (s *PostgresRateLimitStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,229 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	testCases := []struct {
+		name          string
+		value         string
+		expectedLimit RateLimit
+		expectError   bool
+	}{
+		{name: "Empty disables limit", value: "", expectedLimit: RateLimit{}},
+		{name: "Per minute", value: "100/1m", expectedLimit: RateLimit{Requests: 100, Period: time.Minute}},
+		{name: "With spaces", value: " 5 / 10s ", expectedLimit: RateLimit{Requests: 5, Period: 10 * time.Second}},
+		{name: "Missing period", value: "100", expectError: true},
+		{name: "Zero requests", value: "0/1m", expectError: true},
+		{name: "Invalid period", value: "10/soon", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			limit, err := ParseRateLimit(tc.value)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedLimit, limit)
+		})
+	}
+}
+
+func TestTokenBucket_Take(t *testing.T) {
+	limit := RateLimit{Requests: 2, Period: 2 * time.Second}
+	now := time.Now()
+	bucket := TokenBucket{}
+
+	first := bucket.Take(now, limit)
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+
+	second := bucket.Take(now, limit)
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 0, second.Remaining)
+	assert.Equal(t, 2*time.Second, second.Reset)
+
+	third := bucket.Take(now, limit)
+	assert.False(t, third.Allowed)
+	assert.Equal(t, time.Second, third.RetryAfter)
+
+	refilled := bucket.Take(now.Add(time.Second), limit)
+	assert.True(t, refilled.Allowed)
+}
+
+func TestRateLimiter_Limit(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limiter := NewRateLimiter(store, map[RouteClass]RateLimit{
+		RouteClassShorten: {Requests: 2, Period: time.Minute},
+	})
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	limited := limiter.Limit(RouteClassShorten)(okHandler)
+
+	send := func(remoteAddr string, userID string, issued bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/shorten", nil)
+		req.RemoteAddr = remoteAddr
+		ctx := req.Context()
+		if userID != "" {
+			ctx = context.WithValue(ctx, UserIDContextKey, userID)
+			ctx = context.WithValue(ctx, issuedUserIDContextKey, issued)
+		}
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req.WithContext(ctx))
+		return rec
+	}
+
+	t.Run("Exceeding limit by user", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("10.0.0.1:1000", "user1", false).Code)
+		rec := send("10.0.0.2:1000", "user1", false)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "60", rec.Header().Get("RateLimit-Reset"))
+
+		rec = send("10.0.0.3:1000", "user1", false)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusOK, send("10.0.0.1:1000", "user2", false).Code)
+	})
+
+	t.Run("Freshly issued users are keyed by IP", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("192.0.2.1:1000", "new1", true).Code)
+		assert.Equal(t, http.StatusOK, send("192.0.2.1:1001", "new2", true).Code)
+		assert.Equal(t, http.StatusTooManyRequests, send("192.0.2.1:1002", "new3", true).Code)
+		assert.Equal(t, http.StatusTooManyRequests, send("192.0.2.1:1003", "", false).Code)
+	})
+
+	t.Run("Reports are keyed by IP", func(t *testing.T) {
+		reportLimiter := NewRateLimiter(NewMemoryRateLimitStore(), map[RouteClass]RateLimit{
+			RouteClassReport: {Requests: 1, Period: time.Minute},
+		})
+		handler := reportLimiter.Limit(RouteClassReport)(okHandler)
+		report := func(userID string) int {
+			req := httptest.NewRequest("POST", "/api/report/abc", nil)
+			req.RemoteAddr = "192.0.2.9:1000"
+			ctx := context.WithValue(req.Context(), UserIDContextKey, userID)
+			ctx = context.WithValue(ctx, issuedUserIDContextKey, false)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(ctx))
+			return rec.Code
+		}
+		assert.Equal(t, http.StatusOK, report("minted1"))
+		assert.Equal(t, http.StatusTooManyRequests, report("minted2"))
+	})
+
+	t.Run("Unconfigured class is not limited", func(t *testing.T) {
+		handler := limiter.Limit(RouteClassRedirect)(okHandler)
+		for i := 0; i < 5; i++ {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/abc", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+		}
+	})
+
+	t.Run("Nil limiter is not limited", func(t *testing.T) {
+		var nilLimiter *RateLimiter
+		rec := httptest.NewRecorder()
+		nilLimiter.Limit(RouteClassShorten)(okHandler).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestPostgresRateLimitStore_Take(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := PostgresRateLimitStore{db: db}
+	limit := RateLimit{Requests: 10, Period: time.Minute}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO rate_limit_bucket")).
+		WithArgs("shorten:ip:10.0.0.1", float64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tokens, updated_at, NOW()")).
+		WithArgs("shorten:ip:10.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at", "now"}).AddRow(0.5, now, now))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE rate_limit_bucket")).
+		WithArgs("shorten:ip:10.0.0.1", 0.5, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := store.Take(context.Background(), "shorten:ip:10.0.0.1", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 3*time.Second, result.RetryAfter)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMemoryRateLimitStore_Prune(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Requests: 1, Period: time.Minute}
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	_, err := store.Take(context.Background(), "idle", limit)
+	require.NoError(t, err)
+	store.now = func() time.Time { return now.Add(2 * time.Minute) }
+	_, err = store.Take(context.Background(), "active", limit)
+	require.NoError(t, err)
+
+	pruned, err := store.Prune(context.Background(), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.NotContains(t, store.buckets, "idle")
+	assert.Contains(t, store.buckets, "active")
+}
+
+func TestRateLimiter_Register(t *testing.T) {
+	scheduler := jobs.NewScheduler()
+	limiter := NewRateLimiter(NewMemoryRateLimitStore(), map[RouteClass]RateLimit{
+		RouteClassShorten: {Requests: 2, Period: time.Minute},
+		RouteClassReport:  {Requests: 5, Period: time.Hour},
+	})
+	require.NoError(t, limiter.Register(scheduler, 0))
+	statuses := scheduler.Statuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, RateLimitPruneJobName, statuses[0].Name)
+	assert.Equal(t, time.Hour, limiter.longestPeriod())
+
+	// Without enabled limits, no buckets are kept and nothing is pruned.
+	unlimited := jobs.NewScheduler()
+	require.NoError(t, NewRateLimiter(NewMemoryRateLimitStore(), nil).Register(unlimited, time.Minute))
+	assert.Empty(t, unlimited.Statuses())
+}
+
+func TestPostgresRateLimitStore_Prune(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	store := PostgresRateLimitStore{db: db}
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM rate_limit_bucket")).
+		WithArgs(float64(3600)).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectClose()
+
+	pruned, err := store.Prune(context.Background(), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 4, pruned)
+	assert.NoError(t, store.Close())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
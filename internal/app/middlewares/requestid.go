@@ -0,0 +1,50 @@
+// Package middlewares provides the middleware assigning every request an ID.
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header a request ID is read from and echoed in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen is the maximum length of a request ID accepted from a client.
+const maxRequestIDLen = 64
+
+// requestIDContextKey is the context key for the request ID.
+const requestIDContextKey contextKey = "requestID"
+
+// RequestIDFromContext returns the ID of the request, or an empty string outside of RequestIDMiddleware.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// RequestIDMiddleware assigns every request an ID, keeping a valid ID sent by the client in the `X-Request-ID` header.
+// The ID is stored in the request context and returned in the response header.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, requestID)))
+	})
+}
+
+// isValidRequestID reports whether a client-sent request ID is short and made of printable ASCII characters.
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range requestID {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
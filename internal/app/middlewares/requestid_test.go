@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testCases := []struct {
+		name       string
+		requestID  string
+		expectKept bool
+	}{
+		{name: "Client request ID", requestID: "req-123", expectKept: true},
+		{name: "Missing request ID", requestID: "", expectKept: false},
+		{name: "Too long request ID", requestID: strings.Repeat("x", maxRequestIDLen+1), expectKept: false},
+		{name: "Request ID with control characters", requestID: "req\n123", expectKept: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ctxRequestID string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxRequestID = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.requestID != "" {
+				req.Header.Set(RequestIDHeader, tc.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, ctxRequestID, rec.Header().Get(RequestIDHeader))
+			if tc.expectKept {
+				assert.Equal(t, tc.requestID, ctxRequestID)
+			} else {
+				assert.NoError(t, uuid.Validate(ctxRequestID))
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Package middlewares provides the session cookie lifecycle: issuing, sliding renewal and revocation.
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/google/uuid"
+)
+
+// DefaultSessionTTL is the session lifetime used when none is configured.
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+// sessionContextKey is the context key for the session of the request.
+const sessionContextKey contextKey = "session"
+
+// sessionValueVersion prefixes signed session values.
+const sessionValueVersion = "s1"
+
+// ErrInvalidSession is returned when a session cookie is malformed, expired or revoked.
+var ErrInvalidSession = errors.New("invalid session")
+
+// Session is an authenticated browser session.
+type Session struct {
+	// ID is the session identifier used for revocation.
+	ID string
+	// UserID is the ID of the user the session belongs to.
+	UserID string
+	// IssuedAt is the time the session was started.
+	IssuedAt time.Time
+	// ExpiresAt is the time the session expires unless renewed.
+	ExpiresAt time.Time
+}
+
+// SessionStore keeps track of revoked sessions.
+type SessionStore interface {
+	// RevokeSession records a session as revoked until its expiry.
+	RevokeSession(ctx context.Context, session repository.RevokedSession) error
+	// IsSessionRevoked reports whether a session was revoked.
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// SessionOptions configures the session lifetime and the session cookie attributes.
+// The cookie is always HttpOnly.
+type SessionOptions struct {
+	// TTL is the session lifetime. Sessions used within the second half of their lifetime are renewed.
+	TTL time.Duration
+	// Domain is the optional cookie domain.
+	Domain string
+	// Path is the cookie path. Defaults to "/".
+	Path string
+	// SameSite is the cookie SameSite mode. Defaults to Lax.
+	SameSite http.SameSite
+	// Secure restricts the cookie to HTTPS.
+	Secure bool
+}
+
+// ParseSameSite parses a SameSite mode: "lax", "strict", "none" or empty for the default Lax mode.
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("invalid SameSite mode %q: expected lax, strict or none", s)
+	}
+}
+
+// SessionManager issues, verifies, renews and revokes session cookies signed with a key ring.
+type SessionManager struct {
+	// keys sign and verify the session cookies.
+	keys *KeyRing
+	// store keeps track of revoked sessions. Revocation is disabled if nil.
+	store SessionStore
+	// opts are the session lifetime and cookie attributes.
+	opts SessionOptions
+	// now returns the current time.
+	now func() time.Time
+}
+
+// NewSessionManager creates a new SessionManager.
+func NewSessionManager(keys *KeyRing, store SessionStore, opts SessionOptions) *SessionManager {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultSessionTTL
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	return &SessionManager{keys: keys, store: store, opts: opts, now: time.Now}
+}
+
+// SessionFromContext returns the session the request was authenticated with, if any.
+func SessionFromContext(ctx context.Context) (Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(Session)
+	return session, ok
+}
+
+// Start starts a new session for the user and sets its cookie.
+func (sm *SessionManager) Start(w http.ResponseWriter, userID string) Session {
+	now := sm.now()
+	session := Session{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(sm.opts.TTL),
+	}
+	sm.setCookie(w, session)
+	return session
+}
+
+// Authenticate reads and verifies the session cookie of the request.
+func (sm *SessionManager) Authenticate(w http.ResponseWriter, r *http.Request) (Session, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return Session{}, err
+	}
+	value, ok := sm.keys.VerifyFor(PurposeAuth, cookie.Value)
+	if !ok {
+		return Session{}, fmt.Errorf("%w: bad signature", ErrInvalidSession)
+	}
+	session, err := decodeSession(value)
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := sm.now()
+	if !now.Before(session.ExpiresAt) {
+		return Session{}, fmt.Errorf("%w: expired", ErrInvalidSession)
+	}
+	if sm.store != nil {
+		revoked, err := sm.store.IsSessionRevoked(r.Context(), session.ID)
+		if err != nil {
+			return Session{}, fmt.Errorf("checking session revocation: %w", err)
+		}
+		if revoked {
+			return Session{}, fmt.Errorf("%w: revoked", ErrInvalidSession)
+		}
+	}
+
+	// Sliding renewal: extend sessions used in the second half of their lifetime.
+	if session.ExpiresAt.Sub(now) < sm.opts.TTL/2 {
+		session.ExpiresAt = now.Add(sm.opts.TTL)
+		sm.setCookie(w, session)
+		slog.Debug("session renewed", slog.String("session", session.ID), slog.Time("expires at", session.ExpiresAt))
+	}
+	return session, nil
+}
+
+// Revoke revokes the session server-side, so that its cookie is no longer accepted.
+func (sm *SessionManager) Revoke(ctx context.Context, session Session) error {
+	if sm.store == nil {
+		return nil
+	}
+	return sm.store.RevokeSession(ctx, repository.RevokedSession{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		ExpiresAt: session.ExpiresAt,
+		RevokedAt: sm.now(),
+	})
+}
+
+// Clear removes the session cookie from the client.
+func (sm *SessionManager) Clear(w http.ResponseWriter) {
+	cookie := sm.cookie("")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// setCookie sets the signed session cookie.
+func (sm *SessionManager) setCookie(w http.ResponseWriter, session Session) {
+	cookie := sm.cookie(sm.keys.SignFor(PurposeAuth, encodeSession(session)))
+	cookie.Expires = session.ExpiresAt
+	cookie.MaxAge = int(session.ExpiresAt.Sub(sm.now()).Round(time.Second).Seconds())
+	http.SetCookie(w, cookie)
+}
+
+// cookie returns a session cookie with the configured attributes.
+func (sm *SessionManager) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Domain:   sm.opts.Domain,
+		Path:     sm.opts.Path,
+		Secure:   sm.opts.Secure,
+		HttpOnly: true,
+		SameSite: sm.opts.SameSite,
+	}
+}
+
+// encodeSession encodes a session as `s1|<session id>|<user id>|<issued at>|<expires at>` with Unix timestamps.
+func encodeSession(s Session) string {
+	return strings.Join([]string{
+		sessionValueVersion,
+		s.ID,
+		s.UserID,
+		strconv.FormatInt(s.IssuedAt.Unix(), 10),
+		strconv.FormatInt(s.ExpiresAt.Unix(), 10),
+	}, "|")
+}
+
+// decodeSession decodes a session encoded by encodeSession.
+func decodeSession(value string) (Session, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 5 || parts[1] == "" || parts[2] == "" {
+		return Session{}, fmt.Errorf("%w: malformed value", ErrInvalidSession)
+	}
+	issuedAt, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return Session{}, fmt.Errorf("%w: malformed issue time", ErrInvalidSession)
+	}
+	expiresAt, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return Session{}, fmt.Errorf("%w: malformed expiry time", ErrInvalidSession)
+	}
+	return Session{
+		ID:        parts[1],
+		UserID:    parts[2],
+		IssuedAt:  time.Unix(issuedAt, 0),
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}, nil
+}
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionRequest returns a request carrying the session cookie set on the recorder.
+func sessionRequest(t *testing.T, rec *httptest.ResponseRecorder) *http.Request {
+	cookies := rec.Result().Cookies()
+	require.NotEmpty(t, cookies, "Expected a session cookie")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[len(cookies)-1])
+	return req
+}
+
+func TestSessionManager(t *testing.T) {
+	keys := newTestKeyRing(t, "k1:0123456789abcdef")
+	store := repository.NewMemoryRepository()
+	sm := NewSessionManager(keys, store, SessionOptions{TTL: time.Hour, Domain: "example.com", Path: "/api", SameSite: http.SameSiteStrictMode, Secure: true})
+
+	started := httptest.NewRecorder()
+	session := sm.Start(started, "user1")
+	cookie := started.Result().Cookies()[0]
+	assert.Equal(t, CookieName, cookie.Name)
+	assert.Equal(t, "example.com", cookie.Domain)
+	assert.Equal(t, "/api", cookie.Path)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.True(t, cookie.Secure)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, 3600, cookie.MaxAge)
+
+	t.Run("Valid session is not renewed early", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		got, err := sm.Authenticate(rec, sessionRequest(t, started))
+		require.NoError(t, err)
+		assert.Equal(t, session.ID, got.ID)
+		assert.Equal(t, "user1", got.UserID)
+		assert.Empty(t, rec.Result().Cookies())
+	})
+
+	t.Run("Sliding renewal", func(t *testing.T) {
+		later := NewSessionManager(keys, store, SessionOptions{TTL: time.Hour})
+		later.now = func() time.Time { return time.Now().Add(40 * time.Minute) }
+		rec := httptest.NewRecorder()
+		got, err := later.Authenticate(rec, sessionRequest(t, started))
+		require.NoError(t, err)
+		assert.Equal(t, session.ID, got.ID)
+		assert.True(t, got.ExpiresAt.After(session.ExpiresAt))
+		assert.NotEmpty(t, rec.Result().Cookies(), "Renewed session should set a new cookie")
+	})
+
+	t.Run("Expired session", func(t *testing.T) {
+		expired := NewSessionManager(keys, store, SessionOptions{TTL: time.Hour})
+		expired.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		_, err := expired.Authenticate(httptest.NewRecorder(), sessionRequest(t, started))
+		assert.ErrorIs(t, err, ErrInvalidSession)
+	})
+
+	t.Run("Revoked session", func(t *testing.T) {
+		require.NoError(t, sm.Revoke(context.Background(), session))
+		_, err := sm.Authenticate(httptest.NewRecorder(), sessionRequest(t, started))
+		assert.ErrorIs(t, err, ErrInvalidSession)
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		sm.Clear(rec)
+		cleared := rec.Result().Cookies()[0]
+		assert.Equal(t, CookieName, cleared.Name)
+		assert.Empty(t, cleared.Value)
+		assert.Equal(t, -1, cleared.MaxAge)
+	})
+}
+
+func TestParseSameSite(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected http.SameSite
+		wantErr  bool
+	}{
+		{value: "", expected: http.SameSiteLaxMode},
+		{value: "Lax", expected: http.SameSiteLaxMode},
+		{value: "strict", expected: http.SameSiteStrictMode},
+		{value: "none", expected: http.SameSiteNoneMode},
+		{value: "sometimes", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			mode, err := ParseSameSite(tc.value)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, mode)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package moderation provides abuse reporting, takedowns and repeat offender detection.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/google/uuid"
+)
+
+// MaxDetailsLength is the maximum length of the free-text details of a report.
+const MaxDetailsLength = 1000
+
+// Report reasons.
+const (
+	ReasonSpam     = "spam"
+	ReasonPhishing = "phishing"
+	ReasonMalware  = "malware"
+	ReasonIllegal  = "illegal"
+	ReasonOther    = "other"
+)
+
+// validReasons is the set of accepted report reasons.
+var validReasons = map[string]bool{
+	ReasonSpam:     true,
+	ReasonPhishing: true,
+	ReasonMalware:  true,
+	ReasonIllegal:  true,
+	ReasonOther:    true,
+}
+
+// ErrNotFound is returned when the reported URL does not exist, or a URL has no open reports to dismiss.
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidReport is returned for an unknown reason or overly long details.
+var ErrInvalidReport = errors.New("invalid abuse report")
+
+// Thresholds are the limits past which the owner of reported URLs is flagged as a repeat offender.
+// A zero threshold is disabled.
+type Thresholds struct {
+	// Reports is the number of open or upheld reports against the URLs of a user.
+	Reports int
+	// Takedowns is the number of URLs of a user taken down after a report.
+	Takedowns int
+}
+
+// Service files abuse reports, takes reported URLs down and flags repeat offenders.
+type Service struct {
+	// repo stores the URLs, reports and flagged users.
+	repo repository.IRepository
+	// thresholds are the repeat offender thresholds.
+	thresholds Thresholds
+	// now returns the current time.
+	now func() time.Time
+}
+
+// NewService creates a new moderation Service.
+func NewService(repo repository.IRepository, thresholds Thresholds) *Service {
+	return &Service{repo: repo, thresholds: thresholds, now: time.Now}
+}
+
+// IsValidReason reports whether the reason is an accepted report reason.
+func IsValidReason(reason string) bool {
+	return validReasons[reason]
+}
+
+// Report files a report on a URL into the moderation queue.
+// Registered accounts report under their user ID, and anonymous users under their client IP,
+// since their user ID is freshly minted with every new cookie.
+// A repeated report by the same reporter while the first one is open is ignored.
+func (s *Service) Report(ctx context.Context, slug string, userID string, clientIP string, reason string, details string) error {
+	if !IsValidReason(reason) || len(details) > MaxDetailsLength {
+		return ErrInvalidReport
+	}
+	url, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil || url.IsDeleted {
+		return ErrNotFound
+	}
+	reporterID, err := s.reporterID(ctx, userID, clientIP)
+	if err != nil {
+		return err
+	}
+
+	report := repository.AbuseReport{
+		ID:         uuid.NewString(),
+		Slug:       slug,
+		OwnerID:    url.UserID,
+		Reason:     reason,
+		Details:    details,
+		ReporterID: reporterID,
+		CreatedAt:  s.now().UTC(),
+		Status:     repository.ReportStatusOpen,
+	}
+	if err := s.repo.AddAbuseReport(ctx, report); err != nil {
+		if errors.Is(err, repository.ErrAbuseReportDuplicate) {
+			return nil
+		}
+		return fmt.Errorf("saving abuse report: %w", err)
+	}
+	return s.checkOffender(ctx, url.UserID)
+}
+
+// Queue returns the reports with the status, or all reports for an empty status, oldest first.
+func (s *Service) Queue(ctx context.Context, status string) ([]repository.AbuseReport, error) {
+	return s.repo.GetAbuseReports(ctx, status)
+}
+
+// Takedown takes a URL down and closes its open reports as upheld. It returns the number of closed reports.
+// The takedown is recorded apart from the disabled flag of the URL, so re-enabling the URL does not lift it.
+// The URL and the reports are kept as a record of the decision.
+func (s *Service) Takedown(ctx context.Context, slug string, operatorID string) (int, error) {
+	url, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return 0, ErrNotFound
+	}
+	now := s.now().UTC()
+	if err := s.repo.TakeDownURL(ctx, repository.URLTakedown{Slug: slug, TakenDownBy: operatorID, TakenDownAt: now}); err != nil {
+		return 0, fmt.Errorf("taking down url: %w", err)
+	}
+	resolved, err := s.repo.ResolveAbuseReports(ctx, slug, repository.ReportStatusTakenDown, operatorID, now)
+	if err != nil {
+		return 0, fmt.Errorf("resolving abuse reports: %w", err)
+	}
+	return resolved, s.checkOffender(ctx, url.UserID)
+}
+
+// Dismiss closes the open reports on a URL without action. It returns the number of closed reports.
+func (s *Service) Dismiss(ctx context.Context, slug string, operatorID string) (int, error) {
+	resolved, err := s.repo.ResolveAbuseReports(ctx, slug, repository.ReportStatusDismissed, operatorID, s.now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("resolving abuse reports: %w", err)
+	}
+	if resolved == 0 {
+		return 0, ErrNotFound
+	}
+	return resolved, nil
+}
+
+// reporterID returns the ID reports are filed under: the user ID of a registered account,
+// or "ip:" followed by the client IP address for anonymous users.
+func (s *Service) reporterID(ctx context.Context, userID string, clientIP string) (string, error) {
+	if userID != "" {
+		_, err := s.repo.GetUserByID(ctx, userID)
+		if err == nil {
+			return userID, nil
+		}
+		if !errors.Is(err, repository.ErrUserNotExist) {
+			return "", fmt.Errorf("getting user: %w", err)
+		}
+	}
+	return "ip:" + clientIP, nil
+}
+
+// checkOffender flags the owner of reported URLs once they cross a repeat offender threshold.
+func (s *Service) checkOffender(ctx context.Context, ownerID string) error {
+	if s.thresholds.Reports <= 0 && s.thresholds.Takedowns <= 0 {
+		return nil
+	}
+	stats, err := s.repo.GetAbuseStats(ctx, ownerID)
+	if err != nil {
+		return fmt.Errorf("getting abuse stats: %w", err)
+	}
+
+	var reason string
+	switch {
+	case s.thresholds.Takedowns > 0 && stats.TakenDown >= s.thresholds.Takedowns:
+		reason = fmt.Sprintf("%d links taken down", stats.TakenDown)
+	case s.thresholds.Reports > 0 && stats.Reports >= s.thresholds.Reports:
+		reason = fmt.Sprintf("%d abuse reports", stats.Reports)
+	default:
+		return nil
+	}
+	if err := s.repo.FlagUser(ctx, repository.FlaggedUser{UserID: ownerID, Reason: reason, FlaggedAt: s.now().UTC()}); err != nil {
+		return fmt.Errorf("flagging user: %w", err)
+	}
+	slog.Warn("repeat offender flagged", slog.String("user", ownerID), slog.String("reason", reason))
+	return nil
+}
@@ -0,0 +1,68 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, Thresholds{Reports: 3, Takedowns: 2})
+
+	for _, slug := range []string{"bad1", "bad2", "fine"} {
+		require.NoError(t, repo.Add(ctx, repository.URL{Slug: slug, OriginalURL: "https://example.com/" + slug, UserID: "owner"}))
+	}
+
+	require.NoError(t, repo.AddUser(ctx, repository.User{ID: "account", Username: "account"}))
+
+	assert.ErrorIs(t, service.Report(ctx, "bad1", "r1", "192.0.2.1", "boring", ""), ErrInvalidReport)
+	assert.ErrorIs(t, service.Report(ctx, "bad1", "r1", "192.0.2.1", ReasonSpam, strings.Repeat("x", MaxDetailsLength+1)), ErrInvalidReport)
+	assert.ErrorIs(t, service.Report(ctx, "missing", "r1", "192.0.2.1", ReasonSpam, ""), ErrNotFound)
+
+	// A repeated report is accepted but not counted twice, even from a newly minted anonymous user.
+	require.NoError(t, service.Report(ctx, "bad1", "r1", "192.0.2.1", ReasonPhishing, "fake bank login"))
+	require.NoError(t, service.Report(ctx, "bad1", "r2", "192.0.2.1", ReasonPhishing, ""))
+	require.NoError(t, service.Report(ctx, "fine", "account", "192.0.2.1", ReasonSpam, ""))
+	require.NoError(t, service.Report(ctx, "fine", "account", "198.51.100.7", ReasonSpam, ""))
+	queue, err := service.Queue(ctx, repository.ReportStatusOpen)
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+	assert.Equal(t, "owner", queue[0].OwnerID)
+	assert.Equal(t, "ip:192.0.2.1", queue[0].ReporterID)
+	assert.Equal(t, "account", queue[1].ReporterID)
+
+	// Dismissed reports do not count towards the thresholds.
+	resolved, err := service.Dismiss(ctx, "fine", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, 1, resolved)
+	_, err = service.Dismiss(ctx, "fine", "admin")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	resolved, err = service.Takedown(ctx, "bad1", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, 1, resolved)
+	takenDown, err := repo.IsURLTakenDown(ctx, "bad1")
+	require.NoError(t, err)
+	assert.True(t, takenDown)
+	flagged, err := repo.GetFlaggedUsers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, flagged)
+
+	require.NoError(t, service.Report(ctx, "bad2", "r3", "192.0.2.1", ReasonMalware, ""))
+	_, err = service.Takedown(ctx, "bad2", "admin")
+	require.NoError(t, err)
+	flagged, err = repo.GetFlaggedUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.Equal(t, "owner", flagged[0].UserID)
+	assert.Equal(t, "2 links taken down", flagged[0].Reason)
+
+	_, err = service.Takedown(ctx, "missing", "admin")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
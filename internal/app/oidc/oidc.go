@@ -0,0 +1,408 @@
+// Package oidc provides OpenID Connect single sign-on using the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// jwksRefreshInterval is the minimum interval between two JWKS fetches triggered by an unknown key ID.
+const jwksRefreshInterval = time.Minute
+
+// clockSkew is the tolerated clock difference between the provider and the service.
+const clockSkew = time.Minute
+
+// ErrInvalidIDToken is returned when an ID token is malformed, badly signed, expired or issued for another client.
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// Config holds the OpenID Connect client configuration.
+type Config struct {
+	// IssuerURL is the provider issuer URL the discovery document is fetched from.
+	IssuerURL string
+	// ClientID is the client ID registered with the provider.
+	ClientID string
+	// ClientSecret is the optional client secret. Public clients rely on PKCE only.
+	ClientSecret string
+	// RedirectURL is the callback URL registered with the provider.
+	RedirectURL string
+	// Scopes are the requested scopes. "openid" is always requested.
+	Scopes []string
+}
+
+// Claims are the ID token claims used by the service.
+type Claims struct {
+	// Issuer is the provider issuer URL.
+	Issuer string `json:"iss"`
+	// Subject is the provider's user identifier.
+	Subject string `json:"sub"`
+	// Audience is the client ID or client IDs the token was issued for.
+	Audience audience `json:"aud"`
+	// ExpiresAt is the expiry time as a Unix timestamp.
+	ExpiresAt int64 `json:"exp"`
+	// IssuedAt is the issue time as a Unix timestamp.
+	IssuedAt int64 `json:"iat"`
+	// Nonce is the nonce sent in the authentication request.
+	Nonce string `json:"nonce"`
+	// Email is the optional email address of the user.
+	Email string `json:"email,omitempty"`
+}
+
+// audience is the `aud` claim, which is either a string or an array of strings.
+type audience []string
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// contains reports whether the audience includes the client ID.
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// discoveryDocument is the subset of the provider metadata used by the client.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey is an RSA public key of a JWKS document.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// Provider is an OpenID Connect relying party for a single identity provider.
+// Provider metadata is discovered on first use and the signing keys are cached.
+type Provider struct {
+	// config is the client configuration.
+	config Config
+	// client is the HTTP client used to talk to the provider.
+	client *http.Client
+	// mu guards the fields below.
+	mu sync.Mutex
+	// metadata is the discovered provider metadata, nil until discovered.
+	metadata *discoveryDocument
+	// keys are the provider signing keys by key ID.
+	keys map[string]*rsa.PublicKey
+	// keysFetchedAt is the time the signing keys were last fetched.
+	keysFetchedAt time.Time
+	// now returns the current time.
+	now func() time.Time
+}
+
+// NewProvider creates a new Provider. A nil client selects a client with a 10 second timeout.
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{
+		config: cfg,
+		client: client,
+		keys:   map[string]*rsa.PublicKey{},
+		now:    time.Now,
+	}
+}
+
+// NewPKCEVerifier returns a random PKCE code verifier.
+func NewPKCEVerifier() (string, error) {
+	return randomString(32)
+}
+
+// NewState returns a random value usable as the `state` or `nonce` parameter.
+func NewState() (string, error) {
+	return randomString(16)
+}
+
+// PKCEChallenge returns the S256 code challenge of a code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// UserID maps an ID token subject to a stable shortener user ID, scoped by issuer.
+func UserID(issuer string, subject string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(issuer+"#"+subject)).String()
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to for authentication.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := []string{"openid"}
+	for _, s := range p.config.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {PKCEChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified claims of the issued ID token.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &tokenResp); err != nil {
+		return Claims{}, fmt.Errorf("redeeming authorization code: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return Claims{}, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+	return p.VerifyIDToken(ctx, tokenResp.IDToken, nonce)
+}
+
+// VerifyIDToken checks the ID token signature against the provider JWKS and its issuer, audience,
+// validity period and nonce, and returns its claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed header", ErrInvalidIDToken)
+	}
+	if header.Algorithm != "RS256" {
+		return Claims{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidIDToken, header.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+	key, err := p.signingKey(ctx, metadata, header.KeyID)
+	if err != nil {
+		return Claims{}, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return Claims{}, fmt.Errorf("%w: signature mismatch", ErrInvalidIDToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed claims", ErrInvalidIDToken)
+	}
+	now := p.now()
+	switch {
+	case claims.Issuer != metadata.Issuer:
+		return Claims{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.Audience.contains(p.config.ClientID):
+		return Claims{}, fmt.Errorf("%w: issued for another client", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	case now.Add(-clockSkew).Unix() >= claims.ExpiresAt:
+		return Claims{}, fmt.Errorf("%w: token expired", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// discover fetches and caches the provider metadata.
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata discoveryDocument
+	if err := p.doJSON(req, &metadata); err != nil {
+		return nil, fmt.Errorf("discovering OpenID provider: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(p.config.IssuerURL, "/") {
+		return nil, fmt.Errorf("discovering OpenID provider: issuer %q does not match %q", metadata.Issuer, p.config.IssuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovering OpenID provider: incomplete provider metadata")
+	}
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// signingKey returns the provider key with the key ID, refetching the JWKS if the key is unknown.
+func (p *Provider) signingKey(ctx context.Context, metadata *discoveryDocument, keyID string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[keyID]; ok {
+		return key, nil
+	}
+	if !p.keysFetchedAt.IsZero() && p.now().Sub(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidIDToken, keyID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.doJSON(req, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching provider keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("fetching provider keys: %w", err)
+		}
+		keys[jwk.KeyID] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidIDToken, keyID)
+	}
+	return key, nil
+}
+
+// doJSON sends the request and decodes a successful JSON response into v.
+func (p *Provider) doJSON(req *http.Request, v any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Redacted())
+	}
+	return json.Unmarshal(body, v)
+}
+
+// publicKey converts the JWK into an RSA public key.
+func (k jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: invalid modulus", k.KeyID)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("key %q: invalid exponent", k.KeyID)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// decodeSegment decodes a base64url token segment into a value.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// randomString returns n random bytes encoded as base64url.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,116 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/oidc"
+	"github.com/gennadis/shorturl/internal/app/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noRedirectClient returns the first response instead of following redirects.
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	idp := oidctest.NewProvider("shorturl")
+	defer idp.Close()
+
+	ctx := context.Background()
+	provider := oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.Issuer(),
+		ClientID:    "shorturl",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+		Scopes:      []string{"email"},
+	}, nil)
+
+	verifier, err := oidc.NewPKCEVerifier()
+	require.NoError(t, err)
+	authURL, err := provider.AuthCodeURL(ctx, "state1", "nonce1", verifier)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(authURL, idp.Issuer()+"/authorize?"))
+	assert.Contains(t, authURL, "scope=openid+email")
+	assert.Contains(t, authURL, "code_challenge="+oidc.PKCEChallenge(verifier))
+
+	resp, err := noRedirectClient.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "state1", callback.Query().Get("state"))
+	code := callback.Query().Get("code")
+
+	_, err = provider.Exchange(ctx, code, "wrong-verifier", "nonce1")
+	assert.Error(t, err, "the authorization code must not be redeemable without the PKCE verifier")
+
+	// The failed attempt consumed the code, so start over.
+	resp, err = noRedirectClient.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	callback, err = url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	claims, err := provider.Exchange(ctx, callback.Query().Get("code"), verifier, "nonce1")
+	require.NoError(t, err)
+	assert.Equal(t, idp.Subject, claims.Subject)
+	assert.Equal(t, idp.Email, claims.Email)
+	assert.Equal(t, oidc.UserID(idp.Issuer(), idp.Subject), oidc.UserID(claims.Issuer, claims.Subject))
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	idp := oidctest.NewProvider("shorturl")
+	defer idp.Close()
+	other := oidctest.NewProvider("shorturl")
+	defer other.Close()
+
+	provider := oidc.NewProvider(oidc.Config{IssuerURL: idp.Issuer(), ClientID: "shorturl"}, nil)
+	now := time.Now()
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   idp.Issuer(),
+			"sub":   "user1",
+			"aud":   []string{"shorturl", "other-client"},
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+			"nonce": "nonce1",
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := validClaims()
+		claims[key] = value
+		return claims
+	}
+
+	claims, err := provider.VerifyIDToken(context.Background(), idp.SignIDToken(validClaims()), "nonce1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", claims.Subject)
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "Malformed", token: "not.a-token"},
+		{name: "Other audience", token: idp.SignIDToken(with("aud", "other-client"))},
+		{name: "Other issuer", token: idp.SignIDToken(with("iss", other.Issuer()))},
+		{name: "Expired", token: idp.SignIDToken(with("exp", now.Add(-time.Hour).Unix()))},
+		{name: "Nonce mismatch", token: idp.SignIDToken(with("nonce", "replayed"))},
+		{name: "Missing subject", token: idp.SignIDToken(with("sub", ""))},
+		{name: "Signed by another provider", token: other.SignIDToken(validClaims())},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(context.Background(), tc.token, "nonce1")
+			assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Package policy provides a destination policy engine that checks URLs against allow and deny rules.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDestinationDenied is returned when a destination URL is rejected by the policy.
+var ErrDestinationDenied = errors.New("destination denied by policy")
+
+// Violation reasons.
+const (
+	ReasonSelfReference = "self_reference"
+	ReasonDenied        = "denied"
+	ReasonNotAllowed    = "not_allowed"
+	ReasonMalformed     = "malformed"
+)
+
+// defaultReloadInterval is the interval at which rule files are checked for changes when none is configured.
+const defaultReloadInterval = time.Second * 10
+
+// Violation describes why a destination URL was rejected by the policy.
+type Violation struct {
+	// Reason is a machine-readable rejection reason.
+	Reason string
+	// Rule is the rule that matched the destination, if any.
+	Rule string
+}
+
+// Error returns the error message.
+func (v *Violation) Error() string {
+	switch v.Reason {
+	case ReasonSelfReference:
+		return "destination points back to this service"
+	case ReasonNotAllowed:
+		return "destination is not on the allow list"
+	case ReasonDenied:
+		return fmt.Sprintf("destination matches deny rule %q", v.Rule)
+	default:
+		return "destination could not be checked"
+	}
+}
+
+// Unwrap allows errors.Is(err, ErrDestinationDenied) checks.
+func (v *Violation) Unwrap() error {
+	return ErrDestinationDenied
+}
+
+// ruleFile is a rule list loaded from a local file.
+type ruleFile struct {
+	// path is the file path. Empty means the list is not configured.
+	path string
+	// modTime is the modification time of the file when it was last loaded.
+	modTime time.Time
+	// rules are the parsed rules.
+	rules *RuleSet
+}
+
+// load (re)reads the rule file if it was modified since the last load.
+// It reports whether the rules were reloaded.
+func (rf *ruleFile) load() (bool, error) {
+	if rf.path == "" {
+		return false, nil
+	}
+	info, err := os.Stat(rf.path)
+	if err != nil {
+		return false, err
+	}
+	if rf.rules != nil && info.ModTime().Equal(rf.modTime) {
+		return false, nil
+	}
+
+	file, err := os.Open(rf.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	rules, err := ParseRules(file)
+	if err != nil {
+		return false, fmt.Errorf("parsing %s: %w", rf.path, err)
+	}
+	rf.rules = rules
+	rf.modTime = info.ModTime()
+	return true, nil
+}
+
+// Engine checks destination URLs against allow and deny lists and the service's own base URL.
+type Engine struct {
+	// selfHost is the host[:port] of the service base URL, used to prevent redirect loops.
+	selfHost string
+	// reloadInterval is the interval at which rule files are checked for changes.
+	reloadInterval time.Duration
+	// allow is the allow list. If it has rules, only matching destinations are accepted.
+	allow ruleFile
+	// deny is the deny list. Matching destinations are rejected.
+	deny ruleFile
+	// mu is a read-write mutex to synchronize access to the rule lists.
+	mu sync.RWMutex
+}
+
+// NewEngine creates a new Engine that loads the allow and deny lists from the given files.
+// Either path may be empty. Destinations pointing at baseURL are always rejected.
+func NewEngine(allowPath string, denyPath string, baseURL string, reloadInterval time.Duration) (*Engine, error) {
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+	e := &Engine{
+		reloadInterval: reloadInterval,
+		allow:          ruleFile{path: allowPath},
+		deny:           ruleFile{path: denyPath},
+	}
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		e.selfHost = hostWithPort(u)
+	}
+
+	if _, err := e.allow.load(); err != nil {
+		return nil, fmt.Errorf("loading allow list: %w", err)
+	}
+	if _, err := e.deny.load(); err != nil {
+		return nil, fmt.Errorf("loading deny list: %w", err)
+	}
+	return e, nil
+}
+
+// Check returns a *Violation if the destination URL is rejected by the policy.
+func (e *Engine) Check(destination string) error {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return &Violation{Reason: ReasonMalformed}
+	}
+
+	if e.selfHost != "" && hostWithPort(u) == e.selfHost {
+		return &Violation{Reason: ReasonSelfReference}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if rule, ok := e.deny.rules.Match(u); ok {
+		return &Violation{Reason: ReasonDenied, Rule: rule}
+	}
+	if e.allow.rules.Len() > 0 {
+		if _, ok := e.allow.rules.Match(u); !ok {
+			return &Violation{Reason: ReasonNotAllowed}
+		}
+	}
+	return nil
+}
+
+// Reload re-reads any rule file that was modified since it was last loaded.
+// If a file fails to load, the previously loaded rules stay in effect.
+func (e *Engine) Reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for _, rf := range []*ruleFile{&e.allow, &e.deny} {
+		reloaded, err := rf.load()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if reloaded {
+			slog.Info("destination policy reloaded", slog.String("file", rf.path), slog.Int("rules", rf.rules.Len()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run starts watching the rule files for changes, reloading them at regular intervals.
+// It returns a WaitGroup that can be used to wait for the watcher to finish.
+func (e *Engine) Run(ctx context.Context) *sync.WaitGroup {
+	ticker := time.NewTicker(e.reloadInterval)
+	wg := &sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.Reload(); err != nil {
+					slog.Error("destination policy reload", slog.Any("error", err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return wg
+}
+
+// hostWithPort returns the lower-case host of the URL with its effective port.
+func hostWithPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return strings.ToLower(u.Hostname()) + ":" + port
+}
@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRules(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestParseRules(t *testing.T) {
+	_, err := ParseRules(strings.NewReader("example.com\nregex:(\n"))
+	assert.ErrorContains(t, err, "line 2")
+
+	rs, err := ParseRules(strings.NewReader(`
+# comment
+example.com
+*.corp.example
+bücher.example
+10.0.0.0/8
+cidr:2001:db8::/32
+192.168.1.1
+regex:^https://[^/]+/phish
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 7, rs.Len())
+}
+
+func TestEngine_Check(t *testing.T) {
+	dir := t.TempDir()
+	allowPath := filepath.Join(dir, "allow.txt")
+	denyPath := filepath.Join(dir, "deny.txt")
+	writeRules(t, allowPath, "*.corp.example\ncorp.example\n10.0.0.0/8\n", time.Now())
+	writeRules(t, denyPath, "evil.corp.example\nregex:/login$\n10.1.2.3\n", time.Now())
+
+	engine, err := NewEngine(allowPath, denyPath, "http://localhost:8080", time.Minute)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		destination    string
+		expectedReason string
+	}{
+		{name: "Allowed domain", destination: "https://corp.example/a"},
+		{name: "Allowed wildcard subdomain", destination: "https://wiki.corp.example/page"},
+		{name: "Allowed IP literal", destination: "http://10.9.8.7/"},
+		{name: "Not on allow list", destination: "https://example.org/", expectedReason: ReasonNotAllowed},
+		{name: "Wildcard does not match lookalike", destination: "https://evilcorp.example/", expectedReason: ReasonNotAllowed},
+		{name: "Denied domain", destination: "https://evil.corp.example/", expectedReason: ReasonDenied},
+		{name: "Denied regex", destination: "https://wiki.corp.example/login", expectedReason: ReasonDenied},
+		{name: "Denied IP", destination: "http://10.1.2.3/", expectedReason: ReasonDenied},
+		{name: "Self reference", destination: "http://localhost:8080/abc123", expectedReason: ReasonSelfReference},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := engine.Check(tc.destination)
+			if tc.expectedReason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var violation *Violation
+			require.True(t, errors.As(err, &violation), "expected Violation, got %v", err)
+			assert.Equal(t, tc.expectedReason, violation.Reason)
+			assert.ErrorIs(t, err, ErrDestinationDenied)
+		})
+	}
+}
+
+func TestEngine_NoLists(t *testing.T) {
+	engine, err := NewEngine("", "", "https://sho.rt", time.Minute)
+	require.NoError(t, err)
+
+	assert.NoError(t, engine.Check("https://example.com/"))
+	assert.ErrorIs(t, engine.Check("https://SHO.RT:443/abc"), ErrDestinationDenied)
+	assert.NoError(t, engine.Check("https://sho.rt:8443/abc"))
+}
+
+func TestEngine_Reload(t *testing.T) {
+	denyPath := filepath.Join(t.TempDir(), "deny.txt")
+	start := time.Now().Add(-time.Hour)
+	writeRules(t, denyPath, "bad.example\n", start)
+
+	engine, err := NewEngine("", denyPath, "", time.Minute)
+	require.NoError(t, err)
+	assert.Error(t, engine.Check("https://bad.example/"))
+	assert.NoError(t, engine.Check("https://worse.example/"))
+
+	writeRules(t, denyPath, "worse.example\n", start.Add(time.Minute))
+	require.NoError(t, engine.Reload())
+	assert.NoError(t, engine.Check("https://bad.example/"))
+	assert.Error(t, engine.Check("https://worse.example/"))
+
+	// A broken file keeps the previous rules in effect.
+	writeRules(t, denyPath, "regex:(\n", start.Add(time.Minute*2))
+	assert.Error(t, engine.Reload())
+	assert.Error(t, engine.Check("https://worse.example/"))
+}
+
+func TestNewEngine_MissingFile(t *testing.T) {
+	_, err := NewEngine(filepath.Join(t.TempDir(), "missing.txt"), "", "", time.Minute)
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+// Package policy provides parsing and matching of destination allow and deny rules.
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// regexPrefix marks a rule line as a regular expression matched against the full destination URL.
+const regexPrefix = "regex:"
+
+// cidrPrefix optionally marks a rule line as a CIDR matched against IP-literal hosts.
+const cidrPrefix = "cidr:"
+
+// RuleSet is a parsed list of domain, regex and CIDR rules.
+type RuleSet struct {
+	// domains are exact lower-case ASCII domain names.
+	domains map[string]bool
+	// wildcards are lower-case ASCII domain suffixes (with the leading dot) from `*.` patterns.
+	wildcards []string
+	// regexps are expressions matched against the full destination URL.
+	regexps []*regexp.Regexp
+	// networks are IP networks matched against IP-literal hosts.
+	networks []*net.IPNet
+}
+
+// Len returns the number of rules in the set.
+func (rs *RuleSet) Len() int {
+	if rs == nil {
+		return 0
+	}
+	return len(rs.domains) + len(rs.wildcards) + len(rs.regexps) + len(rs.networks)
+}
+
+// ParseRules reads rules from r, one per line. Empty lines and lines starting with `#` are ignored.
+// A line is either `regex:<expression>`, `cidr:<network>`, a bare IP address or CIDR,
+// a domain name such as `example.com`, or a wildcard such as `*.example.com` matching all subdomains.
+func ParseRules(r io.Reader) (*RuleSet, error) {
+	rs := &RuleSet{domains: make(map[string]bool)}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := rs.addRule(line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// addRule parses a single rule line and adds it to the set.
+func (rs *RuleSet) addRule(line string) error {
+	switch {
+	case strings.HasPrefix(line, regexPrefix):
+		re, err := regexp.Compile(strings.TrimPrefix(line, regexPrefix))
+		if err != nil {
+			return fmt.Errorf("invalid regex rule %q: %w", line, err)
+		}
+		rs.regexps = append(rs.regexps, re)
+		return nil
+	case strings.HasPrefix(line, cidrPrefix):
+		network, err := parseNetwork(strings.TrimPrefix(line, cidrPrefix))
+		if err != nil {
+			return fmt.Errorf("invalid cidr rule %q: %w", line, err)
+		}
+		rs.networks = append(rs.networks, network)
+		return nil
+	}
+
+	if network, err := parseNetwork(line); err == nil {
+		rs.networks = append(rs.networks, network)
+		return nil
+	}
+
+	wildcard := strings.HasPrefix(line, "*.")
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(strings.TrimPrefix(line, "*."), "."))
+	if err != nil || domain == "" {
+		return fmt.Errorf("invalid domain rule %q", line)
+	}
+	domain = strings.ToLower(domain)
+	if wildcard {
+		rs.wildcards = append(rs.wildcards, "."+domain)
+	} else {
+		rs.domains[domain] = true
+	}
+	return nil
+}
+
+// parseNetwork parses a CIDR or a bare IP address into an IP network.
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", s)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Match reports whether the destination URL matches any rule of the set and returns the matched rule.
+func (rs *RuleSet) Match(u *url.URL) (string, bool) {
+	if rs == nil {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range rs.networks {
+			if network.Contains(ip) {
+				return network.String(), true
+			}
+		}
+	} else {
+		if rs.domains[host] {
+			return host, true
+		}
+		for _, suffix := range rs.wildcards {
+			if strings.HasSuffix(host, suffix) {
+				return "*" + suffix, true
+			}
+		}
+	}
+
+	full := u.String()
+	for _, re := range rs.regexps {
+		if re.MatchString(full) {
+			return regexPrefix + re.String(), true
+		}
+	}
+	return "", false
+}
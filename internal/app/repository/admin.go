@@ -0,0 +1,160 @@
+// Package repository provides the entities used by operators to moderate links and users.
+package repository
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBlockedUserNotExist is returned when unblocking a user who is not blocked.
+var ErrBlockedUserNotExist = errors.New("blocked user does not exist")
+
+// URLFilter selects URLs across all users. Empty fields match any URL.
+type URLFilter struct {
+	// Slug matches URLs with exactly this slug.
+	Slug string
+	// OriginalURL matches URLs whose original URL contains this text, ignoring case.
+	OriginalURL string
+	// UserID matches URLs owned by this user.
+	UserID string
+	// Limit is the maximum number of results. Zero means no limit.
+	Limit int
+	// Offset is the number of results to skip.
+	Offset int
+}
+
+// matches reports whether the URL is selected by the filter.
+func (f URLFilter) matches(u URL) bool {
+	return (f.Slug == "" || u.Slug == f.Slug) &&
+		(f.OriginalURL == "" || strings.Contains(strings.ToLower(u.OriginalURL), strings.ToLower(f.OriginalURL))) &&
+		(f.UserID == "" || u.UserID == f.UserID)
+}
+
+// UserURLCount represents the number of URLs owned by a user.
+type UserURLCount struct {
+	// UserID is the ID of the user.
+	UserID string `json:"userID"`
+	// Total is the number of URLs owned by the user, including deleted and disabled ones.
+	Total int `json:"total"`
+	// Deleted is the number of deleted URLs.
+	Deleted int `json:"deleted"`
+	// Disabled is the number of URLs disabled by an operator.
+	Disabled int `json:"disabled"`
+}
+
+// BlockedUser represents a user blocked by an operator. Blocked users cannot create URLs,
+// and their URLs do not resolve.
+type BlockedUser struct {
+	// UserID is the ID of the blocked user.
+	UserID string `json:"userID"`
+	// Reason is the optional operator note on why the user was blocked.
+	Reason string `json:"reason,omitempty"`
+	// BlockedBy is the ID of the operator who blocked the user.
+	BlockedBy string `json:"blockedBy"`
+	// BlockedAt is the time the user was blocked.
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+// searchURLs returns the page of URLs selected by the filter, in storage order.
+func searchURLs(urls []URL, filter URLFilter) []URL {
+	found := []URL{}
+	skipped := 0
+	for _, u := range urls {
+		if !filter.matches(u) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		if filter.Limit > 0 && len(found) == filter.Limit {
+			break
+		}
+		found = append(found, u)
+	}
+	return found
+}
+
+// countURLsByUser returns the page of per-user counts of the URLs selected by the filter,
+// ordered by the total count, descending.
+func countURLsByUser(urls []URL, filter URLFilter) []UserURLCount {
+	index := make(map[string]int)
+	counts := []UserURLCount{}
+	for _, u := range urls {
+		if !filter.matches(u) {
+			continue
+		}
+		i, ok := index[u.UserID]
+		if !ok {
+			i = len(counts)
+			index[u.UserID] = i
+			counts = append(counts, UserURLCount{UserID: u.UserID})
+		}
+		counts[i].Total++
+		if u.IsDeleted {
+			counts[i].Deleted++
+		}
+		if u.IsDisabled {
+			counts[i].Disabled++
+		}
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Total != counts[j].Total {
+			return counts[i].Total > counts[j].Total
+		}
+		return counts[i].UserID < counts[j].UserID
+	})
+	if filter.Offset >= len(counts) {
+		return []UserURLCount{}
+	}
+	counts = counts[filter.Offset:]
+	if filter.Limit > 0 && len(counts) > filter.Limit {
+		counts = counts[:filter.Limit]
+	}
+	return counts
+}
+
+// blockUser adds or replaces the block of a user.
+func blockUser(blocked []BlockedUser, user BlockedUser) []BlockedUser {
+	for i, b := range blocked {
+		if b.UserID == user.UserID {
+			blocked[i] = user
+			return blocked
+		}
+	}
+	return append(blocked, user)
+}
+
+// unblockUser removes the block of a user.
+func unblockUser(blocked []BlockedUser, userID string) ([]BlockedUser, error) {
+	for i, b := range blocked {
+		if b.UserID == userID {
+			return append(blocked[:i], blocked[i+1:]...), nil
+		}
+	}
+	return blocked, ErrBlockedUserNotExist
+}
+
+// mergeBlockedUser carries the block of one user ID over to another, keeping an existing block of the other.
+func mergeBlockedUser(blocked []BlockedUser, fromUserID string, toUserID string) []BlockedUser {
+	for _, b := range blocked {
+		if b.UserID == fromUserID && !isUserBlocked(blocked, toUserID) {
+			b.UserID = toUserID
+			return append(blocked, b)
+		}
+	}
+	return blocked
+}
+
+// isUserBlocked reports whether the user is blocked.
+func isUserBlocked(blocked []BlockedUser, userID string) bool {
+	for _, b := range blocked {
+		if b.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAdminRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testAdminLifecycle(t, repo)
+		})
+	}
+
+	t.Run("file moderation survives reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		if blocked, _ := reloaded.IsUserBlocked(context.Background(), "spammer"); !blocked {
+			t.Errorf("Expected user to stay blocked")
+		}
+		if blocked, _ := reloaded.IsUserBlocked(context.Background(), "account"); !blocked {
+			t.Errorf("Expected merged user to stay blocked")
+		}
+		if u, _ := reloaded.GetBySlug(context.Background(), "spam1"); !u.IsDisabled {
+			t.Errorf("Expected URL to stay disabled")
+		}
+	})
+}
+
+func testAdminLifecycle(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	urls := []URL{
+		{Slug: "spam1", OriginalURL: "https://Spam.example.com/1", UserID: "spammer"},
+		{Slug: "spam2", OriginalURL: "https://spam.example.com/2", UserID: "spammer", IsDeleted: true},
+		{Slug: "good1", OriginalURL: "https://example.org/1", UserID: "user"},
+	}
+	if err := repo.AddMany(ctx, urls); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		filter   URLFilter
+		expected []string
+	}{
+		{name: "all", filter: URLFilter{}, expected: []string{"spam1", "spam2", "good1"}},
+		{name: "by slug", filter: URLFilter{Slug: "good1"}, expected: []string{"good1"}},
+		{name: "by target ignoring case", filter: URLFilter{OriginalURL: "SPAM.example"}, expected: []string{"spam1", "spam2"}},
+		{name: "by owner", filter: URLFilter{UserID: "user"}, expected: []string{"good1"}},
+		{name: "paged", filter: URLFilter{Limit: 1, Offset: 1}, expected: []string{"spam2"}},
+		{name: "no match", filter: URLFilter{Slug: "spam1", UserID: "user"}, expected: []string{}},
+	}
+	for _, tc := range testCases {
+		found, err := repo.SearchURLs(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		slugs := []string{}
+		for _, u := range found {
+			slugs = append(slugs, u.Slug)
+		}
+		if len(slugs) != len(tc.expected) {
+			t.Errorf("%s: expected %v, got: %v", tc.name, tc.expected, slugs)
+			continue
+		}
+		for i := range slugs {
+			if slugs[i] != tc.expected[i] {
+				t.Errorf("%s: expected %v, got: %v", tc.name, tc.expected, slugs)
+				break
+			}
+		}
+	}
+
+	if err := repo.SetURLDisabled(ctx, "spam1", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.SetURLDisabled(ctx, "missing", true); !errors.Is(err, ErrURLNotExsit) {
+		t.Errorf("Expected error: %v, got: %v", ErrURLNotExsit, err)
+	}
+	counts, err := repo.CountURLsByUser(ctx, URLFilter{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedCounts := []UserURLCount{
+		{UserID: "spammer", Total: 2, Deleted: 1, Disabled: 1},
+		{UserID: "user", Total: 1},
+	}
+	if len(counts) != len(expectedCounts) || counts[0] != expectedCounts[0] || counts[1] != expectedCounts[1] {
+		t.Errorf("Expected counts: %+v, got: %+v", expectedCounts, counts)
+	}
+
+	if err := repo.BlockUser(ctx, BlockedUser{UserID: "spammer", Reason: "spam", BlockedBy: "admin", BlockedAt: time.Now()}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.BlockUser(ctx, BlockedUser{UserID: "user", BlockedBy: "admin", BlockedAt: time.Now()}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.UnblockUser(ctx, "user"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.UnblockUser(ctx, "user"); !errors.Is(err, ErrBlockedUserNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrBlockedUserNotExist, err)
+	}
+	if blocked, _ := repo.IsUserBlocked(ctx, "user"); blocked {
+		t.Errorf("Expected user to be unblocked")
+	}
+	blocked, err := repo.GetBlockedUsers(ctx)
+	if err != nil || len(blocked) != 1 || blocked[0].UserID != "spammer" || blocked[0].Reason != "spam" {
+		t.Errorf("Unexpected blocked users: %+v (error: %v)", blocked, err)
+	}
+
+	if err := repo.MergeUser(ctx, "spammer", "account"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if blocked, _ := repo.IsUserBlocked(ctx, "account"); !blocked {
+		t.Errorf("Expected the block to be carried over on merge")
+	}
+	if u, _ := repo.GetBySlug(ctx, "spam1"); u.UserID != "account" {
+		t.Errorf("Expected URL to be merged, got owner: %s", u.UserID)
+	}
+}
@@ -0,0 +1,78 @@
+// Package repository provides the API key entity used by programmatic clients.
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrAPIKeyNotExist is returned when an API key does not exist or is not owned by the user.
+var ErrAPIKeyNotExist = errors.New("API key does not exist")
+
+// API key scopes. A key without scopes is allowed to do anything its owner can.
+const (
+	// ScopeRead allows listing the owner's URLs.
+	ScopeRead = "read"
+	// ScopeShorten allows shortening URLs.
+	ScopeShorten = "shorten"
+	// ScopeDelete allows deleting the owner's URLs.
+	ScopeDelete = "delete"
+)
+
+// APIKey represents a long-lived API key. Only the hash of the key is stored.
+type APIKey struct {
+	// ID is the unique identifier of the key.
+	ID string `json:"id"`
+	// UserID is the ID of the user who owns the key.
+	UserID string `json:"userID"`
+	// Label is the user-provided key description.
+	Label string `json:"label"`
+	// Prefix is the first characters of the key, shown to help users recognize it.
+	Prefix string `json:"prefix"`
+	// Hash is the hex-encoded SHA-256 hash of the key.
+	Hash string `json:"hash"`
+	// Scopes restricts what the key can be used for. Empty means unrestricted.
+	Scopes []string `json:"scopes,omitempty"`
+	// CreatedAt is the key creation time.
+	CreatedAt time.Time `json:"createdAt"`
+	// LastUsedAt is the time the key was last used to authenticate a request.
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	// RevokedAt is the time the key was revoked.
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 hash an API key is stored and looked up by.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsValidScope reports whether the scope is a known API key scope.
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeShorten, ScopeDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRevoked reports whether the key was revoked.
+func (k APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// HasScope reports whether the key may be used for the scope.
+func (k APIKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAPIKey_HasScope(t *testing.T) {
+	testCases := []struct {
+		name     string
+		scopes   []string
+		scope    string
+		expected bool
+	}{
+		{name: "Unscoped key", scopes: nil, scope: ScopeDelete, expected: true},
+		{name: "Granted scope", scopes: []string{ScopeRead, ScopeShorten}, scope: ScopeShorten, expected: true},
+		{name: "Missing scope", scopes: []string{ScopeRead}, scope: ScopeDelete, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key := APIKey{Scopes: tc.scopes}
+			if got := key.HasScope(tc.scope); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAPIKeyRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testAPIKeyLifecycle(t, repo)
+		})
+	}
+
+	t.Run("file keys survive reload", func(t *testing.T) {
+		if _, err := os.Stat(filename + apiKeysFileSuffix); err != nil {
+			t.Fatalf("Expected API keys file: %v", err)
+		}
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		key, err := reloaded.GetAPIKeyByHash(context.Background(), HashAPIKey("secret-key"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if key.Label != "renamed" || !key.IsRevoked() || key.LastUsedAt == nil {
+			t.Errorf("Unexpected reloaded key: %+v", key)
+		}
+	})
+}
+
+func testAPIKeyLifecycle(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	key := APIKey{
+		ID:        "key1",
+		UserID:    "user1",
+		Label:     "ci",
+		Prefix:    "shk_abcdefgh",
+		Hash:      HashAPIKey("secret-key"),
+		Scopes:    []string{ScopeShorten},
+		CreatedAt: now,
+	}
+	if err := repo.AddAPIKey(ctx, key); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found, err := repo.GetAPIKeyByHash(ctx, HashAPIKey("secret-key"))
+	if err != nil || found.ID != key.ID {
+		t.Fatalf("Expected key %s, got %+v (error: %v)", key.ID, found, err)
+	}
+	if _, err := repo.GetAPIKeyByHash(ctx, HashAPIKey("other-key")); !errors.Is(err, ErrAPIKeyNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrAPIKeyNotExist, err)
+	}
+
+	if err := repo.UpdateAPIKeyLabel(ctx, "user2", key.ID, "stolen"); !errors.Is(err, ErrAPIKeyNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrAPIKeyNotExist, err)
+	}
+	if err := repo.UpdateAPIKeyLabel(ctx, "user1", key.ID, "renamed"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.TouchAPIKey(ctx, key.ID, now.Add(time.Minute)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.RevokeAPIKey(ctx, "user2", key.ID, now); !errors.Is(err, ErrAPIKeyNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrAPIKeyNotExist, err)
+	}
+	if err := repo.RevokeAPIKey(ctx, "user1", key.ID, now.Add(2*time.Minute)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.RevokeAPIKey(ctx, "user1", key.ID, now.Add(time.Hour)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	keys, err := repo.GetAPIKeysByUser(ctx, "user1")
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d (error: %v)", len(keys), err)
+	}
+	got := keys[0]
+	if got.Label != "renamed" {
+		t.Errorf("Expected label renamed, got %s", got.Label)
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("Unexpected last used time: %v", got.LastUsedAt)
+	}
+	if got.RevokedAt == nil || !got.RevokedAt.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("Expected revocation time to be kept, got %v", got.RevokedAt)
+	}
+
+	if keys, _ := repo.GetAPIKeysByUser(ctx, "user2"); len(keys) != 0 {
+		t.Errorf("Expected no keys for other user, got %d", len(keys))
+	}
+}
@@ -0,0 +1,158 @@
+// Package repository provides the audit event entity of the append-only audit log.
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Audit actions.
+const (
+	// AuditActionShorten records a URL shortened by a user.
+	AuditActionShorten = "shorten"
+	// AuditActionBatchShorten records URLs shortened by a user in one batch.
+	AuditActionBatchShorten = "batch_shorten"
+	// AuditActionDeleteRequest records URLs a user requested to delete.
+	AuditActionDeleteRequest = "delete_request"
+	// AuditActionDelete records URLs deleted by the background deleter on behalf of a user.
+	AuditActionDelete = "delete"
+	// AuditActionEdit records a URL destination changed by a user.
+	AuditActionEdit = "edit"
+	// AuditActionAdminDisable records a URL disabled by an operator.
+	AuditActionAdminDisable = "admin_disable"
+	// AuditActionAdminEnable records a URL re-enabled by an operator.
+	AuditActionAdminEnable = "admin_enable"
+	// AuditActionAdminDelete records a URL deleted by an operator.
+	AuditActionAdminDelete = "admin_delete"
+	// AuditActionAdminBlock records a user blocked by an operator.
+	AuditActionAdminBlock = "admin_block"
+	// AuditActionAdminUnblock records a user unblocked by an operator.
+	AuditActionAdminUnblock = "admin_unblock"
+	// AuditActionAdminTakedown records a reported URL taken down by an operator.
+	AuditActionAdminTakedown = "admin_takedown"
+	// AuditActionAdminDismiss records the reports on a URL dismissed by an operator.
+	AuditActionAdminDismiss = "admin_dismiss"
+)
+
+// AuditEvent represents an entry of the append-only audit log.
+type AuditEvent struct {
+	// ID is the unique identifier of the event.
+	ID string `json:"id"`
+	// Time is the time the action was performed.
+	Time time.Time `json:"time"`
+	// Actor is the ID of the user who performed the action.
+	Actor string `json:"actor"`
+	// Action is the performed action, e.g. "delete".
+	Action string `json:"action"`
+	// Slugs are the slugs of the URLs the action targeted.
+	Slugs []string `json:"slugs,omitempty"`
+	// WorkspaceID is the ID of the workspace the targeted URLs belong to.
+	WorkspaceID string `json:"workspaceID,omitempty"`
+	// TargetUserID is the ID of the user the action targeted, e.g. a blocked user.
+	TargetUserID string `json:"targetUserID,omitempty"`
+	// SourceIP is the IP address of the client that requested the action.
+	SourceIP string `json:"sourceIP,omitempty"`
+	// RequestID is the ID of the HTTP request that requested the action.
+	RequestID string `json:"requestID,omitempty"`
+}
+
+// AuditFilter narrows an audit log query. Empty fields match every event.
+type AuditFilter struct {
+	// Actor matches events performed by the user.
+	Actor string
+	// Action matches events of the action.
+	Action string
+	// Slug matches events targeting the URL.
+	Slug string
+	// Since matches events at or after the time.
+	Since time.Time
+	// Until matches events before the time.
+	Until time.Time
+	// Limit is the maximum number of events returned. Zero returns all events.
+	Limit int
+	// Offset is the number of matching events skipped.
+	Offset int
+}
+
+// matches reports whether the audit event satisfies the filter, ignoring its limit and offset.
+func (f AuditFilter) matches(e AuditEvent) bool {
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if f.Slug == "" {
+		return true
+	}
+	for _, s := range e.Slugs {
+		if s == f.Slug {
+			return true
+		}
+	}
+	return false
+}
+
+// searchAuditEvents returns the events satisfying the filter in the order they were recorded.
+func searchAuditEvents(events []AuditEvent, filter AuditFilter) []AuditEvent {
+	found := []AuditEvent{}
+	skipped := 0
+	for _, e := range events {
+		if !filter.matches(e) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		found = append(found, e)
+		if filter.Limit > 0 && len(found) == filter.Limit {
+			break
+		}
+	}
+	return found
+}
+
+// readNDJSONAuditFile reads the audit events of a newline-delimited JSON file.
+// A missing file holds no events.
+func readNDJSONAuditFile(filename string) ([]AuditEvent, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []AuditEvent{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events := []AuditEvent{}
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		var e AuditEvent
+		if err := decoder.Decode(&e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// appendNDJSONAuditFile appends an audit event to a newline-delimited JSON file.
+func appendNDJSONAuditFile(filename string, e AuditEvent) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(e)
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testAuditLog(t, repo)
+		})
+	}
+
+	t.Run("file audit log survives reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		if events, _ := reloaded.GetAuditEvents(context.Background(), AuditFilter{}); len(events) != 3 || events[2].RequestID != "req-3" {
+			t.Errorf("Expected 3 audit events in order, got: %+v", events)
+		}
+	})
+}
+
+func testAuditLog(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []AuditEvent{
+		{ID: "1", Time: start, Actor: "user", Action: AuditActionShorten, Slugs: []string{"a"}, RequestID: "req-1"},
+		{ID: "2", Time: start.Add(time.Hour), Actor: "user", Action: AuditActionDelete, Slugs: []string{"a", "b"}, RequestID: "req-2"},
+		{ID: "3", Time: start.Add(2 * time.Hour), Actor: "admin", Action: AuditActionAdminBlock, TargetUserID: "user", RequestID: "req-3"},
+	}
+	for _, e := range events {
+		if err := repo.AddAuditEvent(ctx, e); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		filter   AuditFilter
+		expected []string
+	}{
+		{name: "All events", filter: AuditFilter{}, expected: []string{"1", "2", "3"}},
+		{name: "By actor", filter: AuditFilter{Actor: "user"}, expected: []string{"1", "2"}},
+		{name: "By action", filter: AuditFilter{Action: AuditActionDelete}, expected: []string{"2"}},
+		{name: "By slug", filter: AuditFilter{Slug: "b"}, expected: []string{"2"}},
+		{name: "By time range", filter: AuditFilter{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)}, expected: []string{"2"}},
+		{name: "Paginated", filter: AuditFilter{Limit: 1, Offset: 1}, expected: []string{"2"}},
+	}
+	for _, tc := range testCases {
+		found, err := repo.GetAuditEvents(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		ids := make([]string, 0, len(found))
+		for _, e := range found {
+			ids = append(ids, e.ID)
+		}
+		if len(ids) != len(tc.expected) {
+			t.Errorf("%s: expected events %v, got %v", tc.name, tc.expected, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tc.expected[i] {
+				t.Errorf("%s: expected events %v, got %v", tc.name, tc.expected, ids)
+				break
+			}
+		}
+	}
+}
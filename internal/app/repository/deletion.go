@@ -0,0 +1,156 @@
+// Package repository provides the deletion job entity tracking asynchronous URL deletions.
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDeletionJobNotExist is returned when a deletion job does not exist.
+var ErrDeletionJobNotExist = errors.New("deletion job does not exist")
+
+// Deletion job statuses.
+const (
+	// DeletionStatusPending marks a job or a slug waiting for the background deleter.
+	DeletionStatusPending = "pending"
+	// DeletionStatusCompleted marks a deleted slug, or a job with no pending slugs left.
+	DeletionStatusCompleted = "completed"
+	// DeletionStatusFailed marks a slug that could not be deleted.
+	DeletionStatusFailed = "failed"
+)
+
+// Reasons a slug of a deletion job failed.
+const (
+	// DeletionReasonNotFound marks a slug that does not exist or is not owned by the requester.
+	DeletionReasonNotFound = "not_found"
+	// DeletionReasonInternal marks a slug whose deletion failed in the repository.
+	DeletionReasonInternal = "internal_error"
+)
+
+// DeletionJob represents a request to delete URLs, tracked until the background deleter settles every slug.
+type DeletionJob struct {
+	// ID is the unique identifier of the job.
+	ID string `json:"id"`
+	// UserID is the ID of the user who requested the deletion.
+	UserID string `json:"userID"`
+	// WorkspaceID is the ID of the workspace the URLs belong to. Empty for the user's own URLs.
+	WorkspaceID string `json:"workspaceID,omitempty"`
+	// SourceIP is the IP address of the client that requested the deletion, recorded in the audit log.
+	SourceIP string `json:"sourceIP,omitempty"`
+	// RequestID is the ID of the HTTP request that requested the deletion, recorded in the audit log.
+	RequestID string `json:"requestID,omitempty"`
+	// CreatedAt is the time the deletion was requested.
+	CreatedAt time.Time `json:"createdAt"`
+	// Items are the outcomes of the requested slugs, in request order.
+	Items []DeletionJobItem `json:"items"`
+}
+
+// DeletionJobItem represents the outcome of deleting a single slug of a job.
+type DeletionJobItem struct {
+	// JobID is the ID of the job the slug belongs to.
+	JobID string `json:"jobID"`
+	// Slug is the slug requested to be deleted.
+	Slug string `json:"slug"`
+	// Status is the deletion status: pending, completed or failed.
+	Status string `json:"status"`
+	// Reason is the machine-readable reason a slug failed.
+	Reason string `json:"reason,omitempty"`
+	// UpdatedAt is the time the status last changed.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Status returns the job status: pending while any slug is pending, completed afterwards.
+func (j DeletionJob) Status() string {
+	for _, item := range j.Items {
+		if item.Status == DeletionStatusPending {
+			return DeletionStatusPending
+		}
+	}
+	return DeletionStatusCompleted
+}
+
+// deleteRequest returns the request deleting a slug of the job.
+func (j DeletionJob) deleteRequest(slug string) DeleteRequest {
+	return DeleteRequest{
+		Slug:        slug,
+		UserID:      j.UserID,
+		WorkspaceID: j.WorkspaceID,
+		SourceIP:    j.SourceIP,
+		RequestID:   j.RequestID,
+		JobID:       j.ID,
+	}
+}
+
+// finishedBefore reports whether the job has no pending slugs left and was last updated before the time.
+func (j DeletionJob) finishedBefore(before time.Time) bool {
+	if !j.CreatedAt.Before(before) {
+		return false
+	}
+	for _, item := range j.Items {
+		if item.Status == DeletionStatusPending || !item.UpdatedAt.Before(before) {
+			return false
+		}
+	}
+	return true
+}
+
+// deletionJobStore keeps the deletion jobs of the memory and file repositories.
+// Callers synchronize access to it.
+type deletionJobStore struct {
+	// Jobs is a slice of deletion jobs, oldest first.
+	Jobs []DeletionJob `json:"jobs"`
+}
+
+// job returns a deletion job by its ID.
+func (s *deletionJobStore) job(jobID string) (DeletionJob, error) {
+	for _, j := range s.Jobs {
+		if j.ID == jobID {
+			j.Items = append([]DeletionJobItem(nil), j.Items...)
+			return j, nil
+		}
+	}
+	return DeletionJob{}, ErrDeletionJobNotExist
+}
+
+// pendingDeletions returns the delete requests of the pending slugs, oldest job first.
+func (s *deletionJobStore) pendingDeletions() []DeleteRequest {
+	delReqs := []DeleteRequest{}
+	for _, j := range s.Jobs {
+		for _, item := range j.Items {
+			if item.Status == DeletionStatusPending {
+				delReqs = append(delReqs, j.deleteRequest(item.Slug))
+			}
+		}
+	}
+	return delReqs
+}
+
+// prune removes the jobs finished before the time and returns their number.
+func (s *deletionJobStore) prune(before time.Time) int {
+	kept := s.Jobs[:0]
+	for _, j := range s.Jobs {
+		if !j.finishedBefore(before) {
+			kept = append(kept, j)
+		}
+	}
+	pruned := len(s.Jobs) - len(kept)
+	clear(s.Jobs[len(kept):])
+	s.Jobs = kept
+	return pruned
+}
+
+// updateItems replaces the items of their jobs with their updated versions. Items of unknown jobs are ignored.
+func (s *deletionJobStore) updateItems(items []DeletionJobItem) {
+	for _, item := range items {
+		for i := range s.Jobs {
+			if s.Jobs[i].ID != item.JobID {
+				continue
+			}
+			for k := range s.Jobs[i].Items {
+				if s.Jobs[i].Items[k].Slug == item.Slug {
+					s.Jobs[i].Items[k] = item
+				}
+			}
+		}
+	}
+}
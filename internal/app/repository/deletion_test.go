@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeletionJobRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testDeletionJobs(t, repo)
+		})
+	}
+
+	t.Run("file deletion jobs survive reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		job, err := reloaded.GetDeletionJob(context.Background(), "j1")
+		if err != nil || job.Status() != DeletionStatusCompleted || job.Items[1].Reason != DeletionReasonNotFound {
+			t.Errorf("Expected completed job j1, got: %+v (error: %v)", job, err)
+		}
+	})
+}
+
+func testDeletionJobs(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	job := DeletionJob{
+		ID:        "j1",
+		UserID:    "user",
+		RequestID: "req-1",
+		CreatedAt: now,
+		Items: []DeletionJobItem{
+			{JobID: "j1", Slug: "a", Status: DeletionStatusPending, UpdatedAt: now},
+			{JobID: "j1", Slug: "b", Status: DeletionStatusPending, UpdatedAt: now},
+		},
+	}
+	if err := repo.AddDeletionJob(ctx, job); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := repo.GetDeletionJob(ctx, "missing"); !errors.Is(err, ErrDeletionJobNotExist) {
+		t.Errorf("Expected ErrDeletionJobNotExist, got: %v", err)
+	}
+
+	err := repo.UpdateDeletionJobItems(ctx, []DeletionJobItem{
+		{JobID: "j1", Slug: "a", Status: DeletionStatusCompleted, UpdatedAt: now.Add(time.Second)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got, err := repo.GetDeletionJob(ctx, "j1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Status() != DeletionStatusPending || got.Items[0].Status != DeletionStatusCompleted || got.Items[1].Status != DeletionStatusPending {
+		t.Errorf("Expected pending job with slug a completed, got: %+v", got)
+	}
+	pending, err := repo.GetPendingDeletions(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pending) != 1 || pending[0] != (DeleteRequest{Slug: "b", UserID: "user", RequestID: "req-1", JobID: "j1"}) {
+		t.Errorf("Expected the pending deletion of slug b, got: %+v", pending)
+	}
+
+	err = repo.UpdateDeletionJobItems(ctx, []DeletionJobItem{
+		{JobID: "j1", Slug: "b", Status: DeletionStatusFailed, Reason: DeletionReasonNotFound, UpdatedAt: now.Add(time.Second)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, _ := repo.GetDeletionJob(ctx, "j1"); got.Status() != DeletionStatusCompleted || got.Items[1].Reason != DeletionReasonNotFound {
+		t.Errorf("Expected completed job with slug b not found, got: %+v", got)
+	}
+	if pending, _ := repo.GetPendingDeletions(ctx); len(pending) != 0 {
+		t.Errorf("Expected no pending deletions, got: %+v", pending)
+	}
+
+	// Only the jobs finished before the cutoff are pruned; j1 was last updated at the cutoff.
+	old := now.Add(-time.Hour)
+	for _, j := range []DeletionJob{
+		{ID: "j2", UserID: "user", CreatedAt: old, Items: []DeletionJobItem{{JobID: "j2", Slug: "c", Status: DeletionStatusCompleted, UpdatedAt: old}}},
+		{ID: "j3", UserID: "user", CreatedAt: old, Items: []DeletionJobItem{{JobID: "j3", Slug: "d", Status: DeletionStatusPending, UpdatedAt: old}}},
+	} {
+		if err := repo.AddDeletionJob(ctx, j); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if pruned, err := repo.PruneDeletionJobs(ctx, now.Add(time.Second)); err != nil || pruned != 1 {
+		t.Errorf("Expected 1 pruned job, got: %d (error: %v)", pruned, err)
+	}
+	if _, err := repo.GetDeletionJob(ctx, "j2"); !errors.Is(err, ErrDeletionJobNotExist) {
+		t.Errorf("Expected j2 to be pruned, got: %v", err)
+	}
+	for _, id := range []string{"j1", "j3"} {
+		if _, err := repo.GetDeletionJob(ctx, id); err != nil {
+			t.Errorf("Expected %s to be kept, got: %v", id, err)
+		}
+	}
+}
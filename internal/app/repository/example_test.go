@@ -33,7 +33,7 @@ func ExampleMemoryRepository_DeleteMany() {
 	url := NewURL("exampleSlug", "http://example.com", "user1", false)
 	_ = repo.Add(ctx, *url)
 
-	err := repo.DeleteMany(ctx, []DeleteRequest{{Slug: "exampleSlug", UserID: "user1"}})
+	_, err := repo.DeleteMany(ctx, []DeleteRequest{{Slug: "exampleSlug", UserID: "user1"}})
 	if err != nil {
 		fmt.Println("Error deleting URL:", err)
 		return
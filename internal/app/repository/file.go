@@ -4,10 +4,40 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
 	"sync"
+	"time"
 )
 
+// apiKeysFileSuffix is appended to the storage file name to get the file API keys are stored in.
+const apiKeysFileSuffix = ".keys.json"
+
+// usersFileSuffix is appended to the storage file name to get the file user accounts are stored in.
+const usersFileSuffix = ".users.json"
+
+// sessionsFileSuffix is appended to the storage file name to get the file revoked sessions are stored in.
+const sessionsFileSuffix = ".sessions.json"
+
+// workspacesFileSuffix is appended to the storage file name to get the file workspaces, members and invitations are stored in.
+const workspacesFileSuffix = ".workspaces.json"
+
+// blockedUsersFileSuffix is appended to the storage file name to get the file blocked users are stored in.
+const blockedUsersFileSuffix = ".blocked.json"
+
+// moderationFileSuffix is appended to the storage file name to get the file abuse reports, flagged users and URL takedowns are stored in.
+const moderationFileSuffix = ".moderation.json"
+
+// auditFileSuffix is appended to the storage file name to get the newline-delimited JSON file the audit log is appended to.
+const auditFileSuffix = ".audit.ndjson"
+
+// webhooksFileSuffix is appended to the storage file name to get the file webhooks and their deliveries are stored in.
+const webhooksFileSuffix = ".webhooks.json"
+
+// deletionJobsFileSuffix is appended to the storage file name to get the file deletion jobs are stored in.
+const deletionJobsFileSuffix = ".deletions.json"
+
 // Ensure FileRepository implements the IRepository interface.
 var _ IRepository = (*FileRepository)(nil)
 
@@ -17,6 +47,24 @@ type FileRepository struct {
 	filename string
 	// urls is a slice of URLs managed by the repository.
 	urls []URL
+	// apiKeys is a slice of API keys managed by the repository.
+	apiKeys []APIKey
+	// users is a slice of user accounts managed by the repository.
+	users []User
+	// revokedSessions is a slice of revoked sessions that have not expired yet.
+	revokedSessions []RevokedSession
+	// workspaces keeps the workspaces, their members and invitations.
+	workspaces workspaceStore
+	// blockedUsers is a slice of users blocked by an operator.
+	blockedUsers []BlockedUser
+	// moderation keeps the abuse reports, flagged users and URL takedowns.
+	moderation moderationStore
+	// auditEvents is the append-only audit log.
+	auditEvents []AuditEvent
+	// webhooks keeps the webhooks and their deliveries.
+	webhooks webhookStore
+	// deletionJobs keeps the deletion jobs.
+	deletionJobs deletionJobStore
 	// mu is a read-write mutex to synchronize access to the URLs.
 	mu sync.RWMutex
 }
@@ -27,11 +75,42 @@ func NewFileRepository(filename string) (*FileRepository, error) {
 	fs := &FileRepository{
 		filename: filename,
 		urls:     []URL{},
+		apiKeys:  []APIKey{},
+		users:    []User{},
 	}
 
 	if err := fs.loadData(); err != nil {
 		return nil, err
 	}
+	if err := readJSONFile(fs.filename+apiKeysFileSuffix, &fs.apiKeys); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(fs.filename+usersFileSuffix, &fs.users); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(fs.filename+sessionsFileSuffix, &fs.revokedSessions); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(fs.filename+workspacesFileSuffix, &fs.workspaces); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(fs.filename+blockedUsersFileSuffix, &fs.blockedUsers); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(fs.filename+moderationFileSuffix, &fs.moderation); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(fs.filename+webhooksFileSuffix, &fs.webhooks); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(fs.filename+deletionJobsFileSuffix, &fs.deletionJobs); err != nil {
+		return nil, err
+	}
+	auditEvents, err := readNDJSONAuditFile(fs.filename + auditFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	fs.auditEvents = auditEvents
 	return fs, nil
 }
 
@@ -64,6 +143,20 @@ func (fr *FileRepository) AddMany(ctx context.Context, urls []URL) error {
 	return nil
 }
 
+// AddManyKeepExisting adds the URLs whose original URL is not stored yet in a single write.
+// It returns the stored URLs of the others, keyed by original URL.
+func (fr *FileRepository) AddManyKeepExisting(ctx context.Context, urls []URL) (map[string]URL, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	var existing map[string]URL
+	fr.urls, existing = addKeepingExisting(fr.urls, urls)
+	if err := fr.saveData(); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
 // GetBySlug retrieves a URL by its slug. It returns an error if the URL does not exist.
 func (fr *FileRepository) GetBySlug(ctx context.Context, slug string) (URL, error) {
 	fr.mu.RLock()
@@ -77,14 +170,14 @@ func (fr *FileRepository) GetBySlug(ctx context.Context, slug string) (URL, erro
 	return URL{}, ErrURLNotExsit
 }
 
-// GetByUser retrieves all URLs associated with a user. It returns an error if no URLs are found.
+// GetByUser retrieves all URLs associated with a user, excluding workspace URLs. It returns an error if no URLs are found.
 func (fr *FileRepository) GetByUser(ctx context.Context, userID string) ([]URL, error) {
 	fr.mu.RLock()
 	defer fr.mu.RUnlock()
 
 	var userURLs []URL
 	for _, u := range fr.urls {
-		if u.UserID == userID {
+		if u.UserID == userID && u.WorkspaceID == "" {
 			userURLs = append(userURLs, u)
 		}
 	}
@@ -95,6 +188,24 @@ func (fr *FileRepository) GetByUser(ctx context.Context, userID string) ([]URL,
 	return userURLs, nil
 }
 
+// GetByWorkspace retrieves all URLs belonging to a workspace. It returns an error if no URLs are found.
+func (fr *FileRepository) GetByWorkspace(ctx context.Context, workspaceID string) ([]URL, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	var workspaceURLs []URL
+	for _, u := range fr.urls {
+		if u.WorkspaceID == workspaceID {
+			workspaceURLs = append(workspaceURLs, u)
+		}
+	}
+
+	if len(workspaceURLs) == 0 {
+		return nil, ErrURLNotExsit
+	}
+	return workspaceURLs, nil
+}
+
 // GetByOriginalURL retrieves a URL by its original URL. It returns an error if the URL does not exist.
 func (fr *FileRepository) GetByOriginalURL(ctx context.Context, originalURL string) (URL, error) {
 	fr.mu.RLock()
@@ -121,19 +232,54 @@ func (fr *FileRepository) GetServiceStats(ctx context.Context) (urlsCount int, u
 	return urlsCount, len(usersMap), nil
 }
 
+// UpdateOriginalURL changes the original URL a slug points to.
+// It returns an error if the slug does not exist or another URL already has the original URL.
+func (fr *FileRepository) UpdateOriginalURL(ctx context.Context, slug string, originalURL string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	index := -1
+	for i, u := range fr.urls {
+		switch {
+		case u.Slug == slug:
+			index = i
+		case u.OriginalURL == originalURL:
+			return ErrURLDuplicate
+		}
+	}
+	if index < 0 {
+		return ErrURLNotExsit
+	}
+	fr.urls[index].OriginalURL = originalURL
+	return fr.saveData()
+}
+
 // DeleteMany marks multiple URLs as deleted based on the provided delete requests.
-func (fr *FileRepository) DeleteMany(ctx context.Context, delReqs []DeleteRequest) error {
-	fr.mu.RLock()
-	defer fr.mu.RUnlock()
+// It returns the requests that matched a URL.
+func (fr *FileRepository) DeleteMany(ctx context.Context, delReqs []DeleteRequest) ([]DeleteRequest, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
 
+	deleted := []DeleteRequest{}
 	for _, dr := range delReqs {
+		matched := false
 		for i, u := range fr.urls {
-			if u.Slug == dr.Slug && u.UserID == dr.UserID {
+			if dr.matches(u) {
 				fr.urls[i].IsDeleted = true
+				matched = true
 			}
 		}
+		if matched {
+			deleted = append(deleted, dr)
+		}
 	}
-	return nil
+	if len(deleted) == 0 {
+		return deleted, nil
+	}
+	if err := fr.saveData(); err != nil {
+		return nil, err
+	}
+	return deleted, nil
 }
 
 // Ping checks the connection to the repository
@@ -141,6 +287,12 @@ func (fr *FileRepository) Ping(ctx context.Context) error {
 	return nil
 }
 
+// Close releases the resources of the repository. It does nothing for FileRepository,
+// which saves its data to the file on every change.
+func (fr *FileRepository) Close() error {
+	return nil
+}
+
 // loadData loads the URLs from the file into memory. If the file is empty, it initializes an empty slice of URLs.
 // It returns an error if opening the file, getting file information, or decoding the JSON data fails.
 func (fr *FileRepository) loadData() error {
@@ -183,3 +335,626 @@ func (fr *FileRepository) saveData() error {
 	}
 	return nil
 }
+
+// AddAPIKey adds a new API key to the repository.
+func (fr *FileRepository) AddAPIKey(ctx context.Context, key APIKey) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.apiKeys = append(fr.apiKeys, key)
+	return writeJSONFile(fr.filename+apiKeysFileSuffix, fr.apiKeys)
+}
+
+// GetAPIKeysByUser retrieves the API keys owned by a user, including revoked ones.
+func (fr *FileRepository) GetAPIKeysByUser(ctx context.Context, userID string) ([]APIKey, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	userKeys := []APIKey{}
+	for _, k := range fr.apiKeys {
+		if k.UserID == userID {
+			userKeys = append(userKeys, k)
+		}
+	}
+	return userKeys, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of the key. It returns an error if the key does not exist.
+func (fr *FileRepository) GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	for _, k := range fr.apiKeys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotExist
+}
+
+// UpdateAPIKeyLabel changes the label of an API key owned by a user. It returns an error if the key does not exist.
+func (fr *FileRepository) UpdateAPIKeyLabel(ctx context.Context, userID string, keyID string, label string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for i, k := range fr.apiKeys {
+		if k.ID == keyID && k.UserID == userID {
+			fr.apiKeys[i].Label = label
+			return writeJSONFile(fr.filename+apiKeysFileSuffix, fr.apiKeys)
+		}
+	}
+	return ErrAPIKeyNotExist
+}
+
+// RevokeAPIKey marks an API key owned by a user as revoked. It returns an error if the key does not exist.
+func (fr *FileRepository) RevokeAPIKey(ctx context.Context, userID string, keyID string, revokedAt time.Time) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for i, k := range fr.apiKeys {
+		if k.ID == keyID && k.UserID == userID {
+			if k.RevokedAt != nil {
+				return nil
+			}
+			fr.apiKeys[i].RevokedAt = &revokedAt
+			return writeJSONFile(fr.filename+apiKeysFileSuffix, fr.apiKeys)
+		}
+	}
+	return ErrAPIKeyNotExist
+}
+
+// TouchAPIKey records the time an API key was last used. It returns an error if the key does not exist.
+func (fr *FileRepository) TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for i, k := range fr.apiKeys {
+		if k.ID == keyID {
+			fr.apiKeys[i].LastUsedAt = &usedAt
+			return writeJSONFile(fr.filename+apiKeysFileSuffix, fr.apiKeys)
+		}
+	}
+	return ErrAPIKeyNotExist
+}
+
+// AddUser adds a new user account to the repository. It returns an error if the ID, email or username is taken.
+func (fr *FileRepository) AddUser(ctx context.Context, user User) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for _, u := range fr.users {
+		if user.conflictsWith(u) {
+			return ErrUserDuplicate
+		}
+	}
+	fr.users = append(fr.users, user)
+	return writeJSONFile(fr.filename+usersFileSuffix, fr.users)
+}
+
+// GetUserByID retrieves a user account by its ID. It returns an error if the user does not exist.
+func (fr *FileRepository) GetUserByID(ctx context.Context, userID string) (User, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	for _, u := range fr.users {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return User{}, ErrUserNotExist
+}
+
+// GetUserByLogin retrieves a user account by its email or username. It returns an error if the user does not exist.
+func (fr *FileRepository) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	for _, u := range fr.users {
+		if u.hasLogin(login) {
+			return u, nil
+		}
+	}
+	return User{}, ErrUserNotExist
+}
+
+// MergeUser transfers the URLs, API keys, webhooks, workspace memberships and abuse reports of one user ID to another.
+// A block of the user is carried over, so its links stay taken down.
+func (fr *FileRepository) MergeUser(ctx context.Context, fromUserID string, toUserID string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for i, u := range fr.urls {
+		if u.UserID == fromUserID {
+			fr.urls[i].UserID = toUserID
+		}
+	}
+	for i, k := range fr.apiKeys {
+		if k.UserID == fromUserID {
+			fr.apiKeys[i].UserID = toUserID
+		}
+	}
+	fr.webhooks.mergeUser(fromUserID, toUserID)
+	fr.workspaces.mergeUser(fromUserID, toUserID)
+	fr.moderation.mergeUser(fromUserID, toUserID)
+	fr.blockedUsers = mergeBlockedUser(fr.blockedUsers, fromUserID, toUserID)
+	if err := fr.saveData(); err != nil {
+		return err
+	}
+	if err := writeJSONFile(fr.filename+apiKeysFileSuffix, fr.apiKeys); err != nil {
+		return err
+	}
+	if err := writeJSONFile(fr.filename+webhooksFileSuffix, fr.webhooks); err != nil {
+		return err
+	}
+	if err := writeJSONFile(fr.filename+workspacesFileSuffix, fr.workspaces); err != nil {
+		return err
+	}
+	if err := writeJSONFile(fr.filename+blockedUsersFileSuffix, fr.blockedUsers); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+moderationFileSuffix, fr.moderation)
+}
+
+// RevokeSession records a session as revoked until its expiry, pruning revocations of expired sessions.
+func (fr *FileRepository) RevokeSession(ctx context.Context, session RevokedSession) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.revokedSessions = append(pruneRevokedSessions(fr.revokedSessions, time.Now()), session)
+	return writeJSONFile(fr.filename+sessionsFileSuffix, fr.revokedSessions)
+}
+
+// IsSessionRevoked reports whether a session was revoked.
+func (fr *FileRepository) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	for _, s := range fr.revokedSessions {
+		if s.SessionID == sessionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddWorkspace adds a new workspace together with its first owner.
+func (fr *FileRepository) AddWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.workspaces.add(workspace, owner)
+	return writeJSONFile(fr.filename+workspacesFileSuffix, fr.workspaces)
+}
+
+// GetWorkspacesByUser retrieves the workspaces a user is a member of, with the user's role.
+func (fr *FileRepository) GetWorkspacesByUser(ctx context.Context, userID string) ([]WorkspaceMembership, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.workspaces.membershipsOf(userID), nil
+}
+
+// GetWorkspaceMember retrieves the membership of a user in a workspace. It returns an error if the user is not a member.
+func (fr *FileRepository) GetWorkspaceMember(ctx context.Context, workspaceID string, userID string) (WorkspaceMember, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.workspaces.member(workspaceID, userID)
+}
+
+// GetWorkspaceMembers retrieves the members of a workspace.
+func (fr *FileRepository) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.workspaces.members(workspaceID), nil
+}
+
+// UpdateWorkspaceMemberRole changes the role of a workspace member. It returns an error if the user is not a member.
+func (fr *FileRepository) UpdateWorkspaceMemberRole(ctx context.Context, workspaceID string, userID string, role string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := fr.workspaces.updateRole(workspaceID, userID, role); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+workspacesFileSuffix, fr.workspaces)
+}
+
+// RemoveWorkspaceMember removes a member from a workspace. It returns an error if the user is not a member.
+func (fr *FileRepository) RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := fr.workspaces.removeMember(workspaceID, userID); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+workspacesFileSuffix, fr.workspaces)
+}
+
+// AddWorkspaceInvitation adds a new workspace invitation.
+func (fr *FileRepository) AddWorkspaceInvitation(ctx context.Context, invitation WorkspaceInvitation) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.workspaces.Invitations = append(fr.workspaces.Invitations, invitation)
+	return writeJSONFile(fr.filename+workspacesFileSuffix, fr.workspaces)
+}
+
+// GetWorkspaceInvitations retrieves the invitations of a workspace, including accepted and expired ones.
+func (fr *FileRepository) GetWorkspaceInvitations(ctx context.Context, workspaceID string) ([]WorkspaceInvitation, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.workspaces.invitations(workspaceID), nil
+}
+
+// DeleteWorkspaceInvitation deletes an invitation of a workspace. It returns an error if the invitation does not exist.
+func (fr *FileRepository) DeleteWorkspaceInvitation(ctx context.Context, workspaceID string, invitationID string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := fr.workspaces.deleteInvitation(workspaceID, invitationID); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+workspacesFileSuffix, fr.workspaces)
+}
+
+// AcceptWorkspaceInvitation marks a pending invitation as accepted and adds the user to its workspace.
+// It returns an error if no pending invitation has the token hash or the user already is a member.
+func (fr *FileRepository) AcceptWorkspaceInvitation(ctx context.Context, tokenHash string, userID string, acceptedAt time.Time) (WorkspaceMember, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	member, err := fr.workspaces.acceptInvitation(tokenHash, userID, acceptedAt)
+	if err != nil {
+		return WorkspaceMember{}, err
+	}
+	return member, writeJSONFile(fr.filename+workspacesFileSuffix, fr.workspaces)
+}
+
+// SearchURLs retrieves the URLs of all users selected by the filter.
+func (fr *FileRepository) SearchURLs(ctx context.Context, filter URLFilter) ([]URL, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return searchURLs(fr.urls, filter), nil
+}
+
+// CountURLsByUser counts the URLs selected by the filter per owner, ordered by the total count.
+func (fr *FileRepository) CountURLsByUser(ctx context.Context, filter URLFilter) ([]UserURLCount, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return countURLsByUser(fr.urls, filter), nil
+}
+
+// SetURLDisabled disables or re-enables a URL. It returns an error if the URL does not exist.
+func (fr *FileRepository) SetURLDisabled(ctx context.Context, slug string, disabled bool) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	for i, u := range fr.urls {
+		if u.Slug == slug {
+			fr.urls[i].IsDisabled = disabled
+			return fr.saveData()
+		}
+	}
+	return ErrURLNotExsit
+}
+
+// BlockUser blocks a user, replacing an existing block.
+func (fr *FileRepository) BlockUser(ctx context.Context, user BlockedUser) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.blockedUsers = blockUser(fr.blockedUsers, user)
+	return writeJSONFile(fr.filename+blockedUsersFileSuffix, fr.blockedUsers)
+}
+
+// UnblockUser removes the block of a user. It returns an error if the user is not blocked.
+func (fr *FileRepository) UnblockUser(ctx context.Context, userID string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	var err error
+	if fr.blockedUsers, err = unblockUser(fr.blockedUsers, userID); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+blockedUsersFileSuffix, fr.blockedUsers)
+}
+
+// IsUserBlocked reports whether a user is blocked.
+func (fr *FileRepository) IsUserBlocked(ctx context.Context, userID string) (bool, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return isUserBlocked(fr.blockedUsers, userID), nil
+}
+
+// GetBlockedUsers retrieves all blocked users.
+func (fr *FileRepository) GetBlockedUsers(ctx context.Context) ([]BlockedUser, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return append([]BlockedUser{}, fr.blockedUsers...), nil
+}
+
+// AddAbuseReport adds a new abuse report. It returns an error if the reporter already has an open report on the URL.
+func (fr *FileRepository) AddAbuseReport(ctx context.Context, report AbuseReport) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := fr.moderation.addReport(report); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+moderationFileSuffix, fr.moderation)
+}
+
+// GetAbuseReports retrieves the abuse reports with the status, or all reports for an empty status, oldest first.
+func (fr *FileRepository) GetAbuseReports(ctx context.Context, status string) ([]AbuseReport, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.moderation.reports(status), nil
+}
+
+// ResolveAbuseReports sets the status of the open reports on a URL and returns their number.
+func (fr *FileRepository) ResolveAbuseReports(ctx context.Context, slug string, status string, reviewedBy string, reviewedAt time.Time) (int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	resolved := fr.moderation.resolveReports(slug, status, reviewedBy, reviewedAt)
+	if resolved == 0 {
+		return 0, nil
+	}
+	return resolved, writeJSONFile(fr.filename+moderationFileSuffix, fr.moderation)
+}
+
+// GetAbuseStats summarizes the abuse reports against the URLs of a user.
+func (fr *FileRepository) GetAbuseStats(ctx context.Context, ownerID string) (AbuseStats, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.moderation.stats(ownerID), nil
+}
+
+// FlagUser flags a user as a repeat offender, keeping an existing flag.
+func (fr *FileRepository) FlagUser(ctx context.Context, user FlaggedUser) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.moderation.flag(user)
+	return writeJSONFile(fr.filename+moderationFileSuffix, fr.moderation)
+}
+
+// GetFlaggedUsers retrieves all flagged users.
+func (fr *FileRepository) GetFlaggedUsers(ctx context.Context) ([]FlaggedUser, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return append([]FlaggedUser{}, fr.moderation.Flagged...), nil
+}
+
+// TakeDownURL records a URL takedown, keeping an existing one.
+func (fr *FileRepository) TakeDownURL(ctx context.Context, takedown URLTakedown) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.moderation.isTakenDown(takedown.Slug) {
+		return nil
+	}
+	fr.moderation.takeDown(takedown)
+	return writeJSONFile(fr.filename+moderationFileSuffix, fr.moderation)
+}
+
+// IsURLTakenDown reports whether a URL is taken down.
+func (fr *FileRepository) IsURLTakenDown(ctx context.Context, slug string) (bool, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.moderation.isTakenDown(slug), nil
+}
+
+// AddAuditEvent appends an event to the audit log and its file.
+func (fr *FileRepository) AddAuditEvent(ctx context.Context, event AuditEvent) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := appendNDJSONAuditFile(fr.filename+auditFileSuffix, event); err != nil {
+		return err
+	}
+	fr.auditEvents = append(fr.auditEvents, event)
+	return nil
+}
+
+// GetAuditEvents retrieves the audit events satisfying the filter in the order they were recorded.
+func (fr *FileRepository) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return searchAuditEvents(fr.auditEvents, filter), nil
+}
+
+// AddClicks adds the clicks counted by slug to the URLs in a single write and returns their new counts.
+// Slugs that do not exist are left out.
+func (fr *FileRepository) AddClicks(ctx context.Context, clicks map[string]int) (map[string]int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	totals := addClicks(fr.urls, clicks)
+	if len(totals) == 0 {
+		return totals, nil
+	}
+	return totals, fr.saveData()
+}
+
+// GetURLsExpiringBetween retrieves the URLs that are not deleted and whose activation window ends within (from, to].
+func (fr *FileRepository) GetURLsExpiringBetween(ctx context.Context, from time.Time, to time.Time) ([]URL, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return expiringURLs(fr.urls, from, to), nil
+}
+
+// AddWebhook adds a new webhook.
+func (fr *FileRepository) AddWebhook(ctx context.Context, webhook Webhook) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.webhooks.Webhooks = append(fr.webhooks.Webhooks, webhook)
+	return writeJSONFile(fr.filename+webhooksFileSuffix, fr.webhooks)
+}
+
+// GetWebhook retrieves a webhook by its ID.
+func (fr *FileRepository) GetWebhook(ctx context.Context, webhookID string) (Webhook, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.webhooks.webhook(webhookID)
+}
+
+// GetWebhooksByUser retrieves all webhooks registered by a user.
+func (fr *FileRepository) GetWebhooksByUser(ctx context.Context, userID string) ([]Webhook, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.webhooks.webhooksByUser(userID), nil
+}
+
+// DeleteWebhook removes a webhook of a user. Its deliveries are kept in the delivery log.
+func (fr *FileRepository) DeleteWebhook(ctx context.Context, userID string, webhookID string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := fr.webhooks.deleteWebhook(userID, webhookID); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+webhooksFileSuffix, fr.webhooks)
+}
+
+// AddWebhookDeliveries adds new webhook deliveries.
+func (fr *FileRepository) AddWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.webhooks.Deliveries = append(fr.webhooks.Deliveries, deliveries...)
+	return writeJSONFile(fr.filename+webhooksFileSuffix, fr.webhooks)
+}
+
+// GetDueWebhookDeliveries retrieves up to limit pending deliveries due at the time, oldest first.
+func (fr *FileRepository) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.webhooks.dueDeliveries(now, limit), nil
+}
+
+// UpdateWebhookDelivery saves the status and attempts of a delivery.
+func (fr *FileRepository) UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := fr.webhooks.updateDelivery(delivery); err != nil {
+		return err
+	}
+	return writeJSONFile(fr.filename+webhooksFileSuffix, fr.webhooks)
+}
+
+// GetWebhookDeliveries retrieves up to limit deliveries to a webhook, newest first.
+func (fr *FileRepository) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.webhooks.deliveries(webhookID, limit), nil
+}
+
+// readJSONFile decodes the JSON content of a file into v. A missing or empty file leaves v unchanged.
+func readJSONFile(filename string, v any) error {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(data) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSONFile overwrites a file with the indented JSON encoding of v.
+func writeJSONFile(filename string, v any) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(v)
+}
+
+// GetExpiryCheckpoint retrieves the time up to which the expired events of URLs were queued,
+// or the zero time if they never were.
+func (fr *FileRepository) GetExpiryCheckpoint(ctx context.Context) (time.Time, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.webhooks.ExpiryCheckpoint, nil
+}
+
+// SetExpiryCheckpoint saves the time up to which the expired events of URLs were queued.
+func (fr *FileRepository) SetExpiryCheckpoint(ctx context.Context, at time.Time) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.webhooks.ExpiryCheckpoint = at
+	return writeJSONFile(fr.filename+webhooksFileSuffix, fr.webhooks)
+}
+
+// AddDeletionJob adds a new deletion job with its pending slugs.
+func (fr *FileRepository) AddDeletionJob(ctx context.Context, job DeletionJob) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	job.Items = append([]DeletionJobItem(nil), job.Items...)
+	fr.deletionJobs.Jobs = append(fr.deletionJobs.Jobs, job)
+	return writeJSONFile(fr.filename+deletionJobsFileSuffix, fr.deletionJobs)
+}
+
+// GetDeletionJob retrieves a deletion job by its ID.
+func (fr *FileRepository) GetDeletionJob(ctx context.Context, jobID string) (DeletionJob, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.deletionJobs.job(jobID)
+}
+
+// UpdateDeletionJobItems saves the status of slugs of deletion jobs.
+func (fr *FileRepository) UpdateDeletionJobItems(ctx context.Context, items []DeletionJobItem) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.deletionJobs.updateItems(items)
+	return writeJSONFile(fr.filename+deletionJobsFileSuffix, fr.deletionJobs)
+}
+
+// GetPendingDeletions retrieves the delete requests of the pending slugs of deletion jobs, oldest job first.
+func (fr *FileRepository) GetPendingDeletions(ctx context.Context) ([]DeleteRequest, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return fr.deletionJobs.pendingDeletions(), nil
+}
+
+// PruneDeletionJobs removes the deletion jobs with no pending slugs last updated before the time and returns their number.
+func (fr *FileRepository) PruneDeletionJobs(ctx context.Context, before time.Time) (int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	pruned := fr.deletionJobs.prune(before)
+	if pruned == 0 {
+		return 0, nil
+	}
+	return pruned, writeJSONFile(fr.filename+deletionJobsFileSuffix, fr.deletionJobs)
+}
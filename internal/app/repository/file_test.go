@@ -387,7 +387,15 @@ func TestFileStore_DeleteMant(t *testing.T) {
 		t.Fatalf("Error adding initial URL: %v", err)
 	}
 
-	if err := store.DeleteMany(ctx, []DeleteRequest{{Slug: URL.Slug, UserID: URL.UserID}}); err != nil {
+	if _, err := store.DeleteMany(ctx, []DeleteRequest{{Slug: URL.Slug, UserID: URL.UserID}}); err != nil {
 		t.Errorf("Error deleting URL: %v", err)
 	}
+
+	reloaded, err := NewFileRepository(store.filename)
+	if err != nil {
+		t.Fatalf("Error reloading file store: %v", err)
+	}
+	if u, _ := reloaded.GetBySlug(ctx, URL.Slug); !u.IsDeleted {
+		t.Errorf("Expected the deletion to be saved")
+	}
 }
@@ -0,0 +1,592 @@
+// Package repository provides the repository decorator observing the calls of another repository.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Ensure InstrumentedRepository implements the IRepository interface.
+var _ IRepository = (*InstrumentedRepository)(nil)
+
+// CallObserver is notified about every call of an InstrumentedRepository: the method called,
+// its duration and the error it returned.
+type CallObserver func(method string, duration time.Duration, err error)
+
+// InstrumentedRepository wraps a repository, passing the method, duration and error of every call
+// to an observer, for instance to record metrics.
+type InstrumentedRepository struct {
+	// repo is the wrapped repository.
+	repo IRepository
+	// observe is notified about every call.
+	observe CallObserver
+}
+
+// NewInstrumentedRepository creates a new InstrumentedRepository wrapping the repository.
+func NewInstrumentedRepository(repo IRepository, observe CallObserver) *InstrumentedRepository {
+	return &InstrumentedRepository{repo: repo, observe: observe}
+}
+
+// IsDomainError reports whether err is one of the errors returned for expected outcomes,
+// such as missing or duplicate entries, rather than a failure of the storage.
+func IsDomainError(err error) bool {
+	for _, domainErr := range []error{
+		ErrURLNotExsit, ErrURLDuplicate, ErrURLDeletion, ErrInvalidSchedule,
+		ErrAPIKeyNotExist, ErrUserNotExist, ErrUserDuplicate, ErrBlockedUserNotExist,
+		ErrWorkspaceMemberNotExist, ErrWorkspaceMemberDuplicate, ErrWorkspaceInvitationNotExist,
+		ErrAbuseReportDuplicate, ErrWebhookNotExist, ErrWebhookDeliveryNotExist, ErrDeletionJobNotExist,
+	} {
+		if errors.Is(err, domainErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds a new URL to the repository.
+func (r *InstrumentedRepository) Add(ctx context.Context, url URL) error {
+	start := time.Now()
+	err := r.repo.Add(ctx, url)
+	r.observe("Add", time.Since(start), err)
+	return err
+}
+
+// AddMany adds multiple URLs to the repository.
+func (r *InstrumentedRepository) AddMany(ctx context.Context, urls []URL) error {
+	start := time.Now()
+	err := r.repo.AddMany(ctx, urls)
+	r.observe("AddMany", time.Since(start), err)
+	return err
+}
+
+// AddManyKeepExisting adds the URLs whose original URL is not stored yet and returns the stored URLs of the others.
+func (r *InstrumentedRepository) AddManyKeepExisting(ctx context.Context, urls []URL) (map[string]URL, error) {
+	start := time.Now()
+	result, err := r.repo.AddManyKeepExisting(ctx, urls)
+	r.observe("AddManyKeepExisting", time.Since(start), err)
+	return result, err
+}
+
+// GetBySlug retrieves a URL by its slug.
+func (r *InstrumentedRepository) GetBySlug(ctx context.Context, slug string) (URL, error) {
+	start := time.Now()
+	result, err := r.repo.GetBySlug(ctx, slug)
+	r.observe("GetBySlug", time.Since(start), err)
+	return result, err
+}
+
+// GetByUser retrieves URLs associated with a user, excluding workspace URLs.
+func (r *InstrumentedRepository) GetByUser(ctx context.Context, userID string) ([]URL, error) {
+	start := time.Now()
+	result, err := r.repo.GetByUser(ctx, userID)
+	r.observe("GetByUser", time.Since(start), err)
+	return result, err
+}
+
+// GetByWorkspace retrieves URLs belonging to a workspace.
+func (r *InstrumentedRepository) GetByWorkspace(ctx context.Context, workspaceID string) ([]URL, error) {
+	start := time.Now()
+	result, err := r.repo.GetByWorkspace(ctx, workspaceID)
+	r.observe("GetByWorkspace", time.Since(start), err)
+	return result, err
+}
+
+// GetByOriginalURL retrieves a URL by its original URL.
+func (r *InstrumentedRepository) GetByOriginalURL(ctx context.Context, originalURL string) (URL, error) {
+	start := time.Now()
+	result, err := r.repo.GetByOriginalURL(ctx, originalURL)
+	r.observe("GetByOriginalURL", time.Since(start), err)
+	return result, err
+}
+
+// GetServiceStats retrieves Service stats: URLs and users count.
+func (r *InstrumentedRepository) GetServiceStats(ctx context.Context) (int, int, error) {
+	start := time.Now()
+	urlsCount, usersCount, err := r.repo.GetServiceStats(ctx)
+	r.observe("GetServiceStats", time.Since(start), err)
+	return urlsCount, usersCount, err
+}
+
+// UpdateOriginalURL changes the original URL a slug points to.
+func (r *InstrumentedRepository) UpdateOriginalURL(ctx context.Context, slug string, originalURL string) error {
+	start := time.Now()
+	err := r.repo.UpdateOriginalURL(ctx, slug, originalURL)
+	r.observe("UpdateOriginalURL", time.Since(start), err)
+	return err
+}
+
+// DeleteMany marks multiple URLs as deleted and returns the requests that matched a URL. Workspace URLs are only matched by requests for their workspace.
+func (r *InstrumentedRepository) DeleteMany(ctx context.Context, delReqs []DeleteRequest) ([]DeleteRequest, error) {
+	start := time.Now()
+	result, err := r.repo.DeleteMany(ctx, delReqs)
+	r.observe("DeleteMany", time.Since(start), err)
+	return result, err
+}
+
+// Ping checks the connection to the repository.
+func (r *InstrumentedRepository) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := r.repo.Ping(ctx)
+	r.observe("Ping", time.Since(start), err)
+	return err
+}
+
+// Close releases the resources of the repository. It must not be used afterwards.
+func (r *InstrumentedRepository) Close() error {
+	start := time.Now()
+	err := r.repo.Close()
+	r.observe("Close", time.Since(start), err)
+	return err
+}
+
+// AddAPIKey adds a new API key to the repository.
+func (r *InstrumentedRepository) AddAPIKey(ctx context.Context, key APIKey) error {
+	start := time.Now()
+	err := r.repo.AddAPIKey(ctx, key)
+	r.observe("AddAPIKey", time.Since(start), err)
+	return err
+}
+
+// GetAPIKeysByUser retrieves the API keys owned by a user, including revoked ones.
+func (r *InstrumentedRepository) GetAPIKeysByUser(ctx context.Context, userID string) ([]APIKey, error) {
+	start := time.Now()
+	result, err := r.repo.GetAPIKeysByUser(ctx, userID)
+	r.observe("GetAPIKeysByUser", time.Since(start), err)
+	return result, err
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of the key.
+func (r *InstrumentedRepository) GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	start := time.Now()
+	result, err := r.repo.GetAPIKeyByHash(ctx, hash)
+	r.observe("GetAPIKeyByHash", time.Since(start), err)
+	return result, err
+}
+
+// UpdateAPIKeyLabel changes the label of an API key owned by a user.
+func (r *InstrumentedRepository) UpdateAPIKeyLabel(ctx context.Context, userID string, keyID string, label string) error {
+	start := time.Now()
+	err := r.repo.UpdateAPIKeyLabel(ctx, userID, keyID, label)
+	r.observe("UpdateAPIKeyLabel", time.Since(start), err)
+	return err
+}
+
+// RevokeAPIKey marks an API key owned by a user as revoked.
+func (r *InstrumentedRepository) RevokeAPIKey(ctx context.Context, userID string, keyID string, revokedAt time.Time) error {
+	start := time.Now()
+	err := r.repo.RevokeAPIKey(ctx, userID, keyID, revokedAt)
+	r.observe("RevokeAPIKey", time.Since(start), err)
+	return err
+}
+
+// TouchAPIKey records the time an API key was last used.
+func (r *InstrumentedRepository) TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error {
+	start := time.Now()
+	err := r.repo.TouchAPIKey(ctx, keyID, usedAt)
+	r.observe("TouchAPIKey", time.Since(start), err)
+	return err
+}
+
+// AddUser adds a new user account to the repository.
+func (r *InstrumentedRepository) AddUser(ctx context.Context, user User) error {
+	start := time.Now()
+	err := r.repo.AddUser(ctx, user)
+	r.observe("AddUser", time.Since(start), err)
+	return err
+}
+
+// GetUserByID retrieves a user account by its ID.
+func (r *InstrumentedRepository) GetUserByID(ctx context.Context, userID string) (User, error) {
+	start := time.Now()
+	result, err := r.repo.GetUserByID(ctx, userID)
+	r.observe("GetUserByID", time.Since(start), err)
+	return result, err
+}
+
+// GetUserByLogin retrieves a user account by its email or username.
+func (r *InstrumentedRepository) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	start := time.Now()
+	result, err := r.repo.GetUserByLogin(ctx, login)
+	r.observe("GetUserByLogin", time.Since(start), err)
+	return result, err
+}
+
+// MergeUser transfers the URLs, API keys, webhooks, workspace memberships and abuse reports of one user ID to another.
+// A block of the user is carried over, so its links stay taken down.
+func (r *InstrumentedRepository) MergeUser(ctx context.Context, fromUserID string, toUserID string) error {
+	start := time.Now()
+	err := r.repo.MergeUser(ctx, fromUserID, toUserID)
+	r.observe("MergeUser", time.Since(start), err)
+	return err
+}
+
+// RevokeSession records a session as revoked until its expiry.
+func (r *InstrumentedRepository) RevokeSession(ctx context.Context, session RevokedSession) error {
+	start := time.Now()
+	err := r.repo.RevokeSession(ctx, session)
+	r.observe("RevokeSession", time.Since(start), err)
+	return err
+}
+
+// IsSessionRevoked reports whether a session was revoked.
+func (r *InstrumentedRepository) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	start := time.Now()
+	result, err := r.repo.IsSessionRevoked(ctx, sessionID)
+	r.observe("IsSessionRevoked", time.Since(start), err)
+	return result, err
+}
+
+// AddWorkspace adds a new workspace together with its first owner.
+func (r *InstrumentedRepository) AddWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error {
+	start := time.Now()
+	err := r.repo.AddWorkspace(ctx, workspace, owner)
+	r.observe("AddWorkspace", time.Since(start), err)
+	return err
+}
+
+// GetWorkspacesByUser retrieves the workspaces a user is a member of, with the user's role.
+func (r *InstrumentedRepository) GetWorkspacesByUser(ctx context.Context, userID string) ([]WorkspaceMembership, error) {
+	start := time.Now()
+	result, err := r.repo.GetWorkspacesByUser(ctx, userID)
+	r.observe("GetWorkspacesByUser", time.Since(start), err)
+	return result, err
+}
+
+// GetWorkspaceMember retrieves the membership of a user in a workspace.
+func (r *InstrumentedRepository) GetWorkspaceMember(ctx context.Context, workspaceID string, userID string) (WorkspaceMember, error) {
+	start := time.Now()
+	result, err := r.repo.GetWorkspaceMember(ctx, workspaceID, userID)
+	r.observe("GetWorkspaceMember", time.Since(start), err)
+	return result, err
+}
+
+// GetWorkspaceMembers retrieves the members of a workspace.
+func (r *InstrumentedRepository) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	start := time.Now()
+	result, err := r.repo.GetWorkspaceMembers(ctx, workspaceID)
+	r.observe("GetWorkspaceMembers", time.Since(start), err)
+	return result, err
+}
+
+// UpdateWorkspaceMemberRole changes the role of a workspace member.
+func (r *InstrumentedRepository) UpdateWorkspaceMemberRole(ctx context.Context, workspaceID string, userID string, role string) error {
+	start := time.Now()
+	err := r.repo.UpdateWorkspaceMemberRole(ctx, workspaceID, userID, role)
+	r.observe("UpdateWorkspaceMemberRole", time.Since(start), err)
+	return err
+}
+
+// RemoveWorkspaceMember removes a member from a workspace.
+func (r *InstrumentedRepository) RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error {
+	start := time.Now()
+	err := r.repo.RemoveWorkspaceMember(ctx, workspaceID, userID)
+	r.observe("RemoveWorkspaceMember", time.Since(start), err)
+	return err
+}
+
+// AddWorkspaceInvitation adds a new workspace invitation.
+func (r *InstrumentedRepository) AddWorkspaceInvitation(ctx context.Context, invitation WorkspaceInvitation) error {
+	start := time.Now()
+	err := r.repo.AddWorkspaceInvitation(ctx, invitation)
+	r.observe("AddWorkspaceInvitation", time.Since(start), err)
+	return err
+}
+
+// GetWorkspaceInvitations retrieves the invitations of a workspace, including accepted and expired ones.
+func (r *InstrumentedRepository) GetWorkspaceInvitations(ctx context.Context, workspaceID string) ([]WorkspaceInvitation, error) {
+	start := time.Now()
+	result, err := r.repo.GetWorkspaceInvitations(ctx, workspaceID)
+	r.observe("GetWorkspaceInvitations", time.Since(start), err)
+	return result, err
+}
+
+// DeleteWorkspaceInvitation deletes an invitation of a workspace.
+func (r *InstrumentedRepository) DeleteWorkspaceInvitation(ctx context.Context, workspaceID string, invitationID string) error {
+	start := time.Now()
+	err := r.repo.DeleteWorkspaceInvitation(ctx, workspaceID, invitationID)
+	r.observe("DeleteWorkspaceInvitation", time.Since(start), err)
+	return err
+}
+
+// AcceptWorkspaceInvitation marks a pending invitation as accepted and adds the user to its workspace.
+func (r *InstrumentedRepository) AcceptWorkspaceInvitation(ctx context.Context, tokenHash string, userID string, acceptedAt time.Time) (WorkspaceMember, error) {
+	start := time.Now()
+	result, err := r.repo.AcceptWorkspaceInvitation(ctx, tokenHash, userID, acceptedAt)
+	r.observe("AcceptWorkspaceInvitation", time.Since(start), err)
+	return result, err
+}
+
+// SearchURLs retrieves the URLs of all users selected by the filter.
+func (r *InstrumentedRepository) SearchURLs(ctx context.Context, filter URLFilter) ([]URL, error) {
+	start := time.Now()
+	result, err := r.repo.SearchURLs(ctx, filter)
+	r.observe("SearchURLs", time.Since(start), err)
+	return result, err
+}
+
+// CountURLsByUser counts the URLs selected by the filter per owner, ordered by the total count.
+func (r *InstrumentedRepository) CountURLsByUser(ctx context.Context, filter URLFilter) ([]UserURLCount, error) {
+	start := time.Now()
+	result, err := r.repo.CountURLsByUser(ctx, filter)
+	r.observe("CountURLsByUser", time.Since(start), err)
+	return result, err
+}
+
+// SetURLDisabled disables or re-enables a URL.
+func (r *InstrumentedRepository) SetURLDisabled(ctx context.Context, slug string, disabled bool) error {
+	start := time.Now()
+	err := r.repo.SetURLDisabled(ctx, slug, disabled)
+	r.observe("SetURLDisabled", time.Since(start), err)
+	return err
+}
+
+// BlockUser blocks a user, replacing an existing block.
+func (r *InstrumentedRepository) BlockUser(ctx context.Context, user BlockedUser) error {
+	start := time.Now()
+	err := r.repo.BlockUser(ctx, user)
+	r.observe("BlockUser", time.Since(start), err)
+	return err
+}
+
+// UnblockUser removes the block of a user.
+func (r *InstrumentedRepository) UnblockUser(ctx context.Context, userID string) error {
+	start := time.Now()
+	err := r.repo.UnblockUser(ctx, userID)
+	r.observe("UnblockUser", time.Since(start), err)
+	return err
+}
+
+// IsUserBlocked reports whether a user is blocked.
+func (r *InstrumentedRepository) IsUserBlocked(ctx context.Context, userID string) (bool, error) {
+	start := time.Now()
+	result, err := r.repo.IsUserBlocked(ctx, userID)
+	r.observe("IsUserBlocked", time.Since(start), err)
+	return result, err
+}
+
+// GetBlockedUsers retrieves all blocked users.
+func (r *InstrumentedRepository) GetBlockedUsers(ctx context.Context) ([]BlockedUser, error) {
+	start := time.Now()
+	result, err := r.repo.GetBlockedUsers(ctx)
+	r.observe("GetBlockedUsers", time.Since(start), err)
+	return result, err
+}
+
+// AddAbuseReport adds a new abuse report. A reporter can only have one open report per URL.
+func (r *InstrumentedRepository) AddAbuseReport(ctx context.Context, report AbuseReport) error {
+	start := time.Now()
+	err := r.repo.AddAbuseReport(ctx, report)
+	r.observe("AddAbuseReport", time.Since(start), err)
+	return err
+}
+
+// GetAbuseReports retrieves the abuse reports with the status, or all reports for an empty status, oldest first.
+func (r *InstrumentedRepository) GetAbuseReports(ctx context.Context, status string) ([]AbuseReport, error) {
+	start := time.Now()
+	result, err := r.repo.GetAbuseReports(ctx, status)
+	r.observe("GetAbuseReports", time.Since(start), err)
+	return result, err
+}
+
+// ResolveAbuseReports sets the status of the open reports on a URL and returns their number.
+func (r *InstrumentedRepository) ResolveAbuseReports(ctx context.Context, slug string, status string, reviewedBy string, reviewedAt time.Time) (int, error) {
+	start := time.Now()
+	result, err := r.repo.ResolveAbuseReports(ctx, slug, status, reviewedBy, reviewedAt)
+	r.observe("ResolveAbuseReports", time.Since(start), err)
+	return result, err
+}
+
+// GetAbuseStats summarizes the abuse reports against the URLs of a user.
+func (r *InstrumentedRepository) GetAbuseStats(ctx context.Context, ownerID string) (AbuseStats, error) {
+	start := time.Now()
+	result, err := r.repo.GetAbuseStats(ctx, ownerID)
+	r.observe("GetAbuseStats", time.Since(start), err)
+	return result, err
+}
+
+// FlagUser flags a user as a repeat offender, keeping an existing flag.
+func (r *InstrumentedRepository) FlagUser(ctx context.Context, user FlaggedUser) error {
+	start := time.Now()
+	err := r.repo.FlagUser(ctx, user)
+	r.observe("FlagUser", time.Since(start), err)
+	return err
+}
+
+// GetFlaggedUsers retrieves all flagged users.
+func (r *InstrumentedRepository) GetFlaggedUsers(ctx context.Context) ([]FlaggedUser, error) {
+	start := time.Now()
+	result, err := r.repo.GetFlaggedUsers(ctx)
+	r.observe("GetFlaggedUsers", time.Since(start), err)
+	return result, err
+}
+
+// TakeDownURL records a URL takedown, keeping an existing one.
+func (r *InstrumentedRepository) TakeDownURL(ctx context.Context, takedown URLTakedown) error {
+	start := time.Now()
+	err := r.repo.TakeDownURL(ctx, takedown)
+	r.observe("TakeDownURL", time.Since(start), err)
+	return err
+}
+
+// IsURLTakenDown reports whether a URL is taken down.
+func (r *InstrumentedRepository) IsURLTakenDown(ctx context.Context, slug string) (bool, error) {
+	start := time.Now()
+	result, err := r.repo.IsURLTakenDown(ctx, slug)
+	r.observe("IsURLTakenDown", time.Since(start), err)
+	return result, err
+}
+
+// AddAuditEvent appends an event to the audit log. Recorded events are never changed.
+func (r *InstrumentedRepository) AddAuditEvent(ctx context.Context, event AuditEvent) error {
+	start := time.Now()
+	err := r.repo.AddAuditEvent(ctx, event)
+	r.observe("AddAuditEvent", time.Since(start), err)
+	return err
+}
+
+// GetAuditEvents retrieves the audit events satisfying the filter in the order they were recorded.
+func (r *InstrumentedRepository) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	start := time.Now()
+	result, err := r.repo.GetAuditEvents(ctx, filter)
+	r.observe("GetAuditEvents", time.Since(start), err)
+	return result, err
+}
+
+// AddClicks adds the clicks counted by slug to the URLs in a single write and returns their new counts.
+// Slugs that do not exist are left out.
+func (r *InstrumentedRepository) AddClicks(ctx context.Context, clicks map[string]int) (map[string]int, error) {
+	start := time.Now()
+	result, err := r.repo.AddClicks(ctx, clicks)
+	r.observe("AddClicks", time.Since(start), err)
+	return result, err
+}
+
+// GetURLsExpiringBetween retrieves the URLs that are not deleted and whose activation window ends within (from, to].
+func (r *InstrumentedRepository) GetURLsExpiringBetween(ctx context.Context, from time.Time, to time.Time) ([]URL, error) {
+	start := time.Now()
+	result, err := r.repo.GetURLsExpiringBetween(ctx, from, to)
+	r.observe("GetURLsExpiringBetween", time.Since(start), err)
+	return result, err
+}
+
+// AddWebhook adds a new webhook.
+func (r *InstrumentedRepository) AddWebhook(ctx context.Context, webhook Webhook) error {
+	start := time.Now()
+	err := r.repo.AddWebhook(ctx, webhook)
+	r.observe("AddWebhook", time.Since(start), err)
+	return err
+}
+
+// GetWebhook retrieves a webhook by its ID.
+func (r *InstrumentedRepository) GetWebhook(ctx context.Context, webhookID string) (Webhook, error) {
+	start := time.Now()
+	result, err := r.repo.GetWebhook(ctx, webhookID)
+	r.observe("GetWebhook", time.Since(start), err)
+	return result, err
+}
+
+// GetWebhooksByUser retrieves all webhooks registered by a user.
+func (r *InstrumentedRepository) GetWebhooksByUser(ctx context.Context, userID string) ([]Webhook, error) {
+	start := time.Now()
+	result, err := r.repo.GetWebhooksByUser(ctx, userID)
+	r.observe("GetWebhooksByUser", time.Since(start), err)
+	return result, err
+}
+
+// DeleteWebhook removes a webhook of a user. Its deliveries are kept in the delivery log.
+func (r *InstrumentedRepository) DeleteWebhook(ctx context.Context, userID string, webhookID string) error {
+	start := time.Now()
+	err := r.repo.DeleteWebhook(ctx, userID, webhookID)
+	r.observe("DeleteWebhook", time.Since(start), err)
+	return err
+}
+
+// AddWebhookDeliveries adds new webhook deliveries.
+func (r *InstrumentedRepository) AddWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	start := time.Now()
+	err := r.repo.AddWebhookDeliveries(ctx, deliveries)
+	r.observe("AddWebhookDeliveries", time.Since(start), err)
+	return err
+}
+
+// GetDueWebhookDeliveries retrieves up to limit pending deliveries due at the time, oldest first.
+func (r *InstrumentedRepository) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	start := time.Now()
+	result, err := r.repo.GetDueWebhookDeliveries(ctx, now, limit)
+	r.observe("GetDueWebhookDeliveries", time.Since(start), err)
+	return result, err
+}
+
+// UpdateWebhookDelivery saves the status and attempts of a delivery.
+func (r *InstrumentedRepository) UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	start := time.Now()
+	err := r.repo.UpdateWebhookDelivery(ctx, delivery)
+	r.observe("UpdateWebhookDelivery", time.Since(start), err)
+	return err
+}
+
+// GetWebhookDeliveries retrieves up to limit deliveries to a webhook, newest first. Zero returns all deliveries.
+func (r *InstrumentedRepository) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	start := time.Now()
+	result, err := r.repo.GetWebhookDeliveries(ctx, webhookID, limit)
+	r.observe("GetWebhookDeliveries", time.Since(start), err)
+	return result, err
+}
+
+// GetExpiryCheckpoint retrieves the time up to which the expired events of URLs were queued,
+// or the zero time if they never were.
+func (r *InstrumentedRepository) GetExpiryCheckpoint(ctx context.Context) (time.Time, error) {
+	start := time.Now()
+	result, err := r.repo.GetExpiryCheckpoint(ctx)
+	r.observe("GetExpiryCheckpoint", time.Since(start), err)
+	return result, err
+}
+
+// SetExpiryCheckpoint saves the time up to which the expired events of URLs were queued.
+func (r *InstrumentedRepository) SetExpiryCheckpoint(ctx context.Context, at time.Time) error {
+	start := time.Now()
+	err := r.repo.SetExpiryCheckpoint(ctx, at)
+	r.observe("SetExpiryCheckpoint", time.Since(start), err)
+	return err
+}
+
+// AddDeletionJob adds a new deletion job with its pending slugs.
+func (r *InstrumentedRepository) AddDeletionJob(ctx context.Context, job DeletionJob) error {
+	start := time.Now()
+	err := r.repo.AddDeletionJob(ctx, job)
+	r.observe("AddDeletionJob", time.Since(start), err)
+	return err
+}
+
+// GetDeletionJob retrieves a deletion job by its ID.
+func (r *InstrumentedRepository) GetDeletionJob(ctx context.Context, jobID string) (DeletionJob, error) {
+	start := time.Now()
+	result, err := r.repo.GetDeletionJob(ctx, jobID)
+	r.observe("GetDeletionJob", time.Since(start), err)
+	return result, err
+}
+
+// UpdateDeletionJobItems saves the status of slugs of deletion jobs.
+func (r *InstrumentedRepository) UpdateDeletionJobItems(ctx context.Context, items []DeletionJobItem) error {
+	start := time.Now()
+	err := r.repo.UpdateDeletionJobItems(ctx, items)
+	r.observe("UpdateDeletionJobItems", time.Since(start), err)
+	return err
+}
+
+// GetPendingDeletions retrieves the delete requests of the pending slugs of deletion jobs, oldest job first.
+func (r *InstrumentedRepository) GetPendingDeletions(ctx context.Context) ([]DeleteRequest, error) {
+	start := time.Now()
+	result, err := r.repo.GetPendingDeletions(ctx)
+	r.observe("GetPendingDeletions", time.Since(start), err)
+	return result, err
+}
+
+// PruneDeletionJobs removes the deletion jobs with no pending slugs last updated before the time and returns their number.
+func (r *InstrumentedRepository) PruneDeletionJobs(ctx context.Context, before time.Time) (int, error) {
+	start := time.Now()
+	result, err := r.repo.PruneDeletionJobs(ctx, before)
+	r.observe("PruneDeletionJobs", time.Since(start), err)
+	return result, err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestInstrumentedRepository(t *testing.T) {
+	type call struct {
+		method string
+		err    error
+	}
+	calls := []call{}
+	repo := NewInstrumentedRepository(NewMemoryRepository(), func(method string, duration time.Duration, err error) {
+		if duration < 0 {
+			t.Errorf("Unexpected duration of %s: %v", method, duration)
+		}
+		calls = append(calls, call{method: method, err: err})
+	})
+	ctx := context.Background()
+
+	if err := repo.Add(ctx, URL{Slug: "a", OriginalURL: "https://example.com", UserID: "user"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, err := repo.GetBySlug(ctx, "a"); err != nil || got.OriginalURL != "https://example.com" {
+		t.Fatalf("Expected the wrapped repository to be called, got %+v, %v", got, err)
+	}
+	if _, err := repo.GetBySlug(ctx, "missing"); !errors.Is(err, ErrURLNotExsit) {
+		t.Fatalf("Expected ErrURLNotExsit, got %v", err)
+	}
+
+	if len(calls) != 3 || calls[0].method != "Add" || calls[1].method != "GetBySlug" || calls[1].err != nil {
+		t.Fatalf("Unexpected calls: %+v", calls)
+	}
+	if !errors.Is(calls[2].err, ErrURLNotExsit) {
+		t.Errorf("Expected the error to be observed, got %v", calls[2].err)
+	}
+}
+
+func TestIsDomainError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Missing URL", err: ErrURLNotExsit, expected: true},
+		{name: "Wrapped duplicate", err: fmt.Errorf("adding: %w", ErrUserDuplicate), expected: true},
+		{name: "Storage failure", err: errors.New("connection refused"), expected: false},
+		{name: "No error", err: nil, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsDomainError(tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
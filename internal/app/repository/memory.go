@@ -4,6 +4,7 @@ package repository
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 // Ensure MemoryRepository implements the IRepository interface.
@@ -13,6 +14,24 @@ var _ IRepository = (*MemoryRepository)(nil)
 type MemoryRepository struct {
 	// urls is a slice of URLs managed by the repository.
 	urls []URL
+	// apiKeys is a slice of API keys managed by the repository.
+	apiKeys []APIKey
+	// users is a slice of user accounts managed by the repository.
+	users []User
+	// revokedSessions is a slice of revoked sessions that have not expired yet.
+	revokedSessions []RevokedSession
+	// workspaces keeps the workspaces, their members and invitations.
+	workspaces workspaceStore
+	// blockedUsers is a slice of users blocked by an operator.
+	blockedUsers []BlockedUser
+	// moderation keeps the abuse reports, flagged users and URL takedowns.
+	moderation moderationStore
+	// auditEvents is the append-only audit log.
+	auditEvents []AuditEvent
+	// webhooks keeps the webhooks and their deliveries.
+	webhooks webhookStore
+	// deletionJobs keeps the deletion jobs.
+	deletionJobs deletionJobStore
 	// mu is a read-write mutex to synchronize access to the URLs.
 	mu sync.RWMutex
 }
@@ -20,7 +39,9 @@ type MemoryRepository struct {
 // NewMemoryRepository creates a new MemoryRepository instance.
 func NewMemoryRepository() *MemoryRepository {
 	return &MemoryRepository{
-		urls: []URL{},
+		urls:    []URL{},
+		apiKeys: []APIKey{},
+		users:   []User{},
 	}
 }
 
@@ -50,6 +71,17 @@ func (mr *MemoryRepository) AddMany(ctx context.Context, urls []URL) error {
 	return nil
 }
 
+// AddManyKeepExisting adds the URLs whose original URL is not stored yet.
+// It returns the stored URLs of the others, keyed by original URL.
+func (mr *MemoryRepository) AddManyKeepExisting(ctx context.Context, urls []URL) (map[string]URL, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	var existing map[string]URL
+	mr.urls, existing = addKeepingExisting(mr.urls, urls)
+	return existing, nil
+}
+
 // GetBySlug retrieves a URL by its slug. It returns an error if the URL does not exist.
 func (mr *MemoryRepository) GetBySlug(ctx context.Context, slug string) (URL, error) {
 	mr.mu.RLock()
@@ -63,14 +95,14 @@ func (mr *MemoryRepository) GetBySlug(ctx context.Context, slug string) (URL, er
 	return URL{}, ErrURLNotExsit
 }
 
-// GetByUser retrieves all URLs associated with a user. It returns an error if no URLs are found.
+// GetByUser retrieves all URLs associated with a user, excluding workspace URLs. It returns an error if no URLs are found.
 func (mr *MemoryRepository) GetByUser(ctx context.Context, userID string) ([]URL, error) {
 	mr.mu.RLock()
 	defer mr.mu.RUnlock()
 
 	var userURLs []URL
 	for _, u := range mr.urls {
-		if u.UserID == userID {
+		if u.UserID == userID && u.WorkspaceID == "" {
 			userURLs = append(userURLs, u)
 		}
 	}
@@ -81,6 +113,24 @@ func (mr *MemoryRepository) GetByUser(ctx context.Context, userID string) ([]URL
 	return userURLs, nil
 }
 
+// GetByWorkspace retrieves all URLs belonging to a workspace. It returns an error if no URLs are found.
+func (mr *MemoryRepository) GetByWorkspace(ctx context.Context, workspaceID string) ([]URL, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var workspaceURLs []URL
+	for _, u := range mr.urls {
+		if u.WorkspaceID == workspaceID {
+			workspaceURLs = append(workspaceURLs, u)
+		}
+	}
+
+	if len(workspaceURLs) == 0 {
+		return nil, ErrURLNotExsit
+	}
+	return workspaceURLs, nil
+}
+
 // GetByOriginalURL retrieves a URL by its original URL. It returns an error if the URL does not exist.
 func (mr *MemoryRepository) GetByOriginalURL(ctx context.Context, originalURL string) (URL, error) {
 	mr.mu.RLock()
@@ -107,23 +157,596 @@ func (mr *MemoryRepository) GetServiceStats(ctx context.Context) (urlsCount int,
 	return urlsCount, len(usersMap), nil
 }
 
+// UpdateOriginalURL changes the original URL a slug points to.
+// It returns an error if the slug does not exist or another URL already has the original URL.
+func (mr *MemoryRepository) UpdateOriginalURL(ctx context.Context, slug string, originalURL string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	index := -1
+	for i, u := range mr.urls {
+		switch {
+		case u.Slug == slug:
+			index = i
+		case u.OriginalURL == originalURL:
+			return ErrURLDuplicate
+		}
+	}
+	if index < 0 {
+		return ErrURLNotExsit
+	}
+	mr.urls[index].OriginalURL = originalURL
+	return nil
+}
+
 // DeleteMany marks multiple URLs as deleted based on the provided delete requests.
-func (mr *MemoryRepository) DeleteMany(ctx context.Context, delReqs []DeleteRequest) error {
-	mr.mu.RLock()
-	defer mr.mu.RUnlock()
+// It returns the requests that matched a URL.
+func (mr *MemoryRepository) DeleteMany(ctx context.Context, delReqs []DeleteRequest) ([]DeleteRequest, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
 
+	deleted := []DeleteRequest{}
 	for _, dr := range delReqs {
+		matched := false
 		for i, u := range mr.urls {
-			if u.Slug == dr.Slug && u.UserID == dr.UserID {
+			if dr.matches(u) {
 				mr.urls[i].IsDeleted = true
+				matched = true
 			}
 		}
+		if matched {
+			deleted = append(deleted, dr)
+		}
 	}
-
-	return nil
+	return deleted, nil
 }
 
 // Ping checks the connection to the repository. It always returns nil for MemoryRepository.
 func (mr *MemoryRepository) Ping(ctx context.Context) error {
 	return nil
 }
+
+// Close releases the resources of the repository. It does nothing for MemoryRepository.
+func (mr *MemoryRepository) Close() error {
+	return nil
+}
+
+// AddAPIKey adds a new API key to the repository.
+func (mr *MemoryRepository) AddAPIKey(ctx context.Context, key APIKey) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.apiKeys = append(mr.apiKeys, key)
+	return nil
+}
+
+// GetAPIKeysByUser retrieves the API keys owned by a user, including revoked ones.
+func (mr *MemoryRepository) GetAPIKeysByUser(ctx context.Context, userID string) ([]APIKey, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	userKeys := []APIKey{}
+	for _, k := range mr.apiKeys {
+		if k.UserID == userID {
+			userKeys = append(userKeys, k)
+		}
+	}
+	return userKeys, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of the key. It returns an error if the key does not exist.
+func (mr *MemoryRepository) GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	for _, k := range mr.apiKeys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotExist
+}
+
+// UpdateAPIKeyLabel changes the label of an API key owned by a user. It returns an error if the key does not exist.
+func (mr *MemoryRepository) UpdateAPIKeyLabel(ctx context.Context, userID string, keyID string, label string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for i, k := range mr.apiKeys {
+		if k.ID == keyID && k.UserID == userID {
+			mr.apiKeys[i].Label = label
+			return nil
+		}
+	}
+	return ErrAPIKeyNotExist
+}
+
+// RevokeAPIKey marks an API key owned by a user as revoked. It returns an error if the key does not exist.
+func (mr *MemoryRepository) RevokeAPIKey(ctx context.Context, userID string, keyID string, revokedAt time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for i, k := range mr.apiKeys {
+		if k.ID == keyID && k.UserID == userID {
+			if k.RevokedAt == nil {
+				mr.apiKeys[i].RevokedAt = &revokedAt
+			}
+			return nil
+		}
+	}
+	return ErrAPIKeyNotExist
+}
+
+// TouchAPIKey records the time an API key was last used. It returns an error if the key does not exist.
+func (mr *MemoryRepository) TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for i, k := range mr.apiKeys {
+		if k.ID == keyID {
+			mr.apiKeys[i].LastUsedAt = &usedAt
+			return nil
+		}
+	}
+	return ErrAPIKeyNotExist
+}
+
+// AddUser adds a new user account to the repository. It returns an error if the ID, email or username is taken.
+func (mr *MemoryRepository) AddUser(ctx context.Context, user User) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for _, u := range mr.users {
+		if user.conflictsWith(u) {
+			return ErrUserDuplicate
+		}
+	}
+	mr.users = append(mr.users, user)
+	return nil
+}
+
+// GetUserByID retrieves a user account by its ID. It returns an error if the user does not exist.
+func (mr *MemoryRepository) GetUserByID(ctx context.Context, userID string) (User, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	for _, u := range mr.users {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return User{}, ErrUserNotExist
+}
+
+// GetUserByLogin retrieves a user account by its email or username. It returns an error if the user does not exist.
+func (mr *MemoryRepository) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	for _, u := range mr.users {
+		if u.hasLogin(login) {
+			return u, nil
+		}
+	}
+	return User{}, ErrUserNotExist
+}
+
+// MergeUser transfers the URLs, API keys, webhooks, workspace memberships and abuse reports of one user ID to another.
+// A block of the user is carried over, so its links stay taken down.
+func (mr *MemoryRepository) MergeUser(ctx context.Context, fromUserID string, toUserID string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for i, u := range mr.urls {
+		if u.UserID == fromUserID {
+			mr.urls[i].UserID = toUserID
+		}
+	}
+	for i, k := range mr.apiKeys {
+		if k.UserID == fromUserID {
+			mr.apiKeys[i].UserID = toUserID
+		}
+	}
+	mr.webhooks.mergeUser(fromUserID, toUserID)
+	mr.workspaces.mergeUser(fromUserID, toUserID)
+	mr.moderation.mergeUser(fromUserID, toUserID)
+	mr.blockedUsers = mergeBlockedUser(mr.blockedUsers, fromUserID, toUserID)
+	return nil
+}
+
+// RevokeSession records a session as revoked until its expiry, pruning revocations of expired sessions.
+func (mr *MemoryRepository) RevokeSession(ctx context.Context, session RevokedSession) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.revokedSessions = append(pruneRevokedSessions(mr.revokedSessions, time.Now()), session)
+	return nil
+}
+
+// IsSessionRevoked reports whether a session was revoked.
+func (mr *MemoryRepository) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	for _, s := range mr.revokedSessions {
+		if s.SessionID == sessionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddWorkspace adds a new workspace together with its first owner.
+func (mr *MemoryRepository) AddWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.workspaces.add(workspace, owner)
+	return nil
+}
+
+// GetWorkspacesByUser retrieves the workspaces a user is a member of, with the user's role.
+func (mr *MemoryRepository) GetWorkspacesByUser(ctx context.Context, userID string) ([]WorkspaceMembership, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.workspaces.membershipsOf(userID), nil
+}
+
+// GetWorkspaceMember retrieves the membership of a user in a workspace. It returns an error if the user is not a member.
+func (mr *MemoryRepository) GetWorkspaceMember(ctx context.Context, workspaceID string, userID string) (WorkspaceMember, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.workspaces.member(workspaceID, userID)
+}
+
+// GetWorkspaceMembers retrieves the members of a workspace.
+func (mr *MemoryRepository) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.workspaces.members(workspaceID), nil
+}
+
+// UpdateWorkspaceMemberRole changes the role of a workspace member. It returns an error if the user is not a member.
+func (mr *MemoryRepository) UpdateWorkspaceMemberRole(ctx context.Context, workspaceID string, userID string, role string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.workspaces.updateRole(workspaceID, userID, role)
+}
+
+// RemoveWorkspaceMember removes a member from a workspace. It returns an error if the user is not a member.
+func (mr *MemoryRepository) RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.workspaces.removeMember(workspaceID, userID)
+}
+
+// AddWorkspaceInvitation adds a new workspace invitation.
+func (mr *MemoryRepository) AddWorkspaceInvitation(ctx context.Context, invitation WorkspaceInvitation) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.workspaces.Invitations = append(mr.workspaces.Invitations, invitation)
+	return nil
+}
+
+// GetWorkspaceInvitations retrieves the invitations of a workspace, including accepted and expired ones.
+func (mr *MemoryRepository) GetWorkspaceInvitations(ctx context.Context, workspaceID string) ([]WorkspaceInvitation, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.workspaces.invitations(workspaceID), nil
+}
+
+// DeleteWorkspaceInvitation deletes an invitation of a workspace. It returns an error if the invitation does not exist.
+func (mr *MemoryRepository) DeleteWorkspaceInvitation(ctx context.Context, workspaceID string, invitationID string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.workspaces.deleteInvitation(workspaceID, invitationID)
+}
+
+// AcceptWorkspaceInvitation marks a pending invitation as accepted and adds the user to its workspace.
+// It returns an error if no pending invitation has the token hash or the user already is a member.
+func (mr *MemoryRepository) AcceptWorkspaceInvitation(ctx context.Context, tokenHash string, userID string, acceptedAt time.Time) (WorkspaceMember, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.workspaces.acceptInvitation(tokenHash, userID, acceptedAt)
+}
+
+// SearchURLs retrieves the URLs of all users selected by the filter.
+func (mr *MemoryRepository) SearchURLs(ctx context.Context, filter URLFilter) ([]URL, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return searchURLs(mr.urls, filter), nil
+}
+
+// CountURLsByUser counts the URLs selected by the filter per owner, ordered by the total count.
+func (mr *MemoryRepository) CountURLsByUser(ctx context.Context, filter URLFilter) ([]UserURLCount, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return countURLsByUser(mr.urls, filter), nil
+}
+
+// SetURLDisabled disables or re-enables a URL. It returns an error if the URL does not exist.
+func (mr *MemoryRepository) SetURLDisabled(ctx context.Context, slug string, disabled bool) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for i, u := range mr.urls {
+		if u.Slug == slug {
+			mr.urls[i].IsDisabled = disabled
+			return nil
+		}
+	}
+	return ErrURLNotExsit
+}
+
+// BlockUser blocks a user, replacing an existing block.
+func (mr *MemoryRepository) BlockUser(ctx context.Context, user BlockedUser) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.blockedUsers = blockUser(mr.blockedUsers, user)
+	return nil
+}
+
+// UnblockUser removes the block of a user. It returns an error if the user is not blocked.
+func (mr *MemoryRepository) UnblockUser(ctx context.Context, userID string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	var err error
+	mr.blockedUsers, err = unblockUser(mr.blockedUsers, userID)
+	return err
+}
+
+// IsUserBlocked reports whether a user is blocked.
+func (mr *MemoryRepository) IsUserBlocked(ctx context.Context, userID string) (bool, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return isUserBlocked(mr.blockedUsers, userID), nil
+}
+
+// GetBlockedUsers retrieves all blocked users.
+func (mr *MemoryRepository) GetBlockedUsers(ctx context.Context) ([]BlockedUser, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return append([]BlockedUser{}, mr.blockedUsers...), nil
+}
+
+// AddAbuseReport adds a new abuse report. It returns an error if the reporter already has an open report on the URL.
+func (mr *MemoryRepository) AddAbuseReport(ctx context.Context, report AbuseReport) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.moderation.addReport(report)
+}
+
+// GetAbuseReports retrieves the abuse reports with the status, or all reports for an empty status, oldest first.
+func (mr *MemoryRepository) GetAbuseReports(ctx context.Context, status string) ([]AbuseReport, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.moderation.reports(status), nil
+}
+
+// ResolveAbuseReports sets the status of the open reports on a URL and returns their number.
+func (mr *MemoryRepository) ResolveAbuseReports(ctx context.Context, slug string, status string, reviewedBy string, reviewedAt time.Time) (int, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.moderation.resolveReports(slug, status, reviewedBy, reviewedAt), nil
+}
+
+// GetAbuseStats summarizes the abuse reports against the URLs of a user.
+func (mr *MemoryRepository) GetAbuseStats(ctx context.Context, ownerID string) (AbuseStats, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.moderation.stats(ownerID), nil
+}
+
+// FlagUser flags a user as a repeat offender, keeping an existing flag.
+func (mr *MemoryRepository) FlagUser(ctx context.Context, user FlaggedUser) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.moderation.flag(user)
+	return nil
+}
+
+// GetFlaggedUsers retrieves all flagged users.
+func (mr *MemoryRepository) GetFlaggedUsers(ctx context.Context) ([]FlaggedUser, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return append([]FlaggedUser{}, mr.moderation.Flagged...), nil
+}
+
+// TakeDownURL records a URL takedown, keeping an existing one.
+func (mr *MemoryRepository) TakeDownURL(ctx context.Context, takedown URLTakedown) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.moderation.takeDown(takedown)
+	return nil
+}
+
+// IsURLTakenDown reports whether a URL is taken down.
+func (mr *MemoryRepository) IsURLTakenDown(ctx context.Context, slug string) (bool, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.moderation.isTakenDown(slug), nil
+}
+
+// AddAuditEvent appends an event to the audit log.
+func (mr *MemoryRepository) AddAuditEvent(ctx context.Context, event AuditEvent) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.auditEvents = append(mr.auditEvents, event)
+	return nil
+}
+
+// GetAuditEvents retrieves the audit events satisfying the filter in the order they were recorded.
+func (mr *MemoryRepository) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return searchAuditEvents(mr.auditEvents, filter), nil
+}
+
+// AddClicks adds the clicks counted by slug to the URLs in a single write and returns their new counts.
+// Slugs that do not exist are left out.
+func (mr *MemoryRepository) AddClicks(ctx context.Context, clicks map[string]int) (map[string]int, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return addClicks(mr.urls, clicks), nil
+}
+
+// GetURLsExpiringBetween retrieves the URLs that are not deleted and whose activation window ends within (from, to].
+func (mr *MemoryRepository) GetURLsExpiringBetween(ctx context.Context, from time.Time, to time.Time) ([]URL, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return expiringURLs(mr.urls, from, to), nil
+}
+
+// AddWebhook adds a new webhook.
+func (mr *MemoryRepository) AddWebhook(ctx context.Context, webhook Webhook) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.webhooks.Webhooks = append(mr.webhooks.Webhooks, webhook)
+	return nil
+}
+
+// GetWebhook retrieves a webhook by its ID.
+func (mr *MemoryRepository) GetWebhook(ctx context.Context, webhookID string) (Webhook, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.webhooks.webhook(webhookID)
+}
+
+// GetWebhooksByUser retrieves all webhooks registered by a user.
+func (mr *MemoryRepository) GetWebhooksByUser(ctx context.Context, userID string) ([]Webhook, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.webhooks.webhooksByUser(userID), nil
+}
+
+// DeleteWebhook removes a webhook of a user. Its deliveries are kept in the delivery log.
+func (mr *MemoryRepository) DeleteWebhook(ctx context.Context, userID string, webhookID string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.webhooks.deleteWebhook(userID, webhookID)
+}
+
+// AddWebhookDeliveries adds new webhook deliveries.
+func (mr *MemoryRepository) AddWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.webhooks.Deliveries = append(mr.webhooks.Deliveries, deliveries...)
+	return nil
+}
+
+// GetDueWebhookDeliveries retrieves up to limit pending deliveries due at the time, oldest first.
+func (mr *MemoryRepository) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.webhooks.dueDeliveries(now, limit), nil
+}
+
+// UpdateWebhookDelivery saves the status and attempts of a delivery.
+func (mr *MemoryRepository) UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.webhooks.updateDelivery(delivery)
+}
+
+// GetWebhookDeliveries retrieves up to limit deliveries to a webhook, newest first.
+func (mr *MemoryRepository) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.webhooks.deliveries(webhookID, limit), nil
+}
+
+// GetExpiryCheckpoint retrieves the time up to which the expired events of URLs were queued,
+// or the zero time if they never were.
+func (mr *MemoryRepository) GetExpiryCheckpoint(ctx context.Context) (time.Time, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.webhooks.ExpiryCheckpoint, nil
+}
+
+// SetExpiryCheckpoint saves the time up to which the expired events of URLs were queued.
+func (mr *MemoryRepository) SetExpiryCheckpoint(ctx context.Context, at time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.webhooks.ExpiryCheckpoint = at
+	return nil
+}
+
+// AddDeletionJob adds a new deletion job with its pending slugs.
+func (mr *MemoryRepository) AddDeletionJob(ctx context.Context, job DeletionJob) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	job.Items = append([]DeletionJobItem(nil), job.Items...)
+	mr.deletionJobs.Jobs = append(mr.deletionJobs.Jobs, job)
+	return nil
+}
+
+// GetDeletionJob retrieves a deletion job by its ID.
+func (mr *MemoryRepository) GetDeletionJob(ctx context.Context, jobID string) (DeletionJob, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.deletionJobs.job(jobID)
+}
+
+// UpdateDeletionJobItems saves the status of slugs of deletion jobs.
+func (mr *MemoryRepository) UpdateDeletionJobItems(ctx context.Context, items []DeletionJobItem) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.deletionJobs.updateItems(items)
+	return nil
+}
+
+// GetPendingDeletions retrieves the delete requests of the pending slugs of deletion jobs, oldest job first.
+func (mr *MemoryRepository) GetPendingDeletions(ctx context.Context) ([]DeleteRequest, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	return mr.deletionJobs.pendingDeletions(), nil
+}
+
+// PruneDeletionJobs removes the deletion jobs with no pending slugs last updated before the time and returns their number.
+func (mr *MemoryRepository) PruneDeletionJobs(ctx context.Context, before time.Time) (int, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.deletionJobs.prune(before), nil
+}
@@ -149,6 +149,37 @@ func TestMemStore_BatchAddURLs(t *testing.T) {
 	}
 }
 
+func TestMemStore_AddManyKeepExisting(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRepository()
+	if err := store.Add(ctx, URL{Slug: "stored", OriginalURL: "https://example.com/stored", UserID: "user1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	existing, err := store.AddManyKeepExisting(ctx, []URL{
+		{Slug: "new", OriginalURL: "https://example.com/new", UserID: "user2"},
+		{Slug: "again", OriginalURL: "https://example.com/stored", UserID: "user2"},
+		{Slug: "repeated", OriginalURL: "https://example.com/new", UserID: "user2"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := existing["https://example.com/stored"].Slug; got != "stored" {
+		t.Errorf("expected stored slug for a stored URL, got %q", got)
+	}
+	if got := existing["https://example.com/new"].Slug; got != "new" {
+		t.Errorf("expected the first slug of the batch for a repeated URL, got %q", got)
+	}
+	if _, err := store.GetBySlug(ctx, "new"); err != nil {
+		t.Errorf("expected new URL to be stored: %v", err)
+	}
+	for _, slug := range []string{"again", "repeated"} {
+		if _, err := store.GetBySlug(ctx, slug); !errors.Is(err, ErrURLNotExsit) {
+			t.Errorf("expected %s not to be stored, got %v", slug, err)
+		}
+	}
+}
+
 func TestMemStore_GetSlugByOriginalURL(t *testing.T) {
 	urlOne := NewURL("key1", "https://example1.com", "userID", false)
 	urlTwo := NewURL("key2", "https://example2.com", "userID", false)
@@ -276,7 +307,12 @@ func TestMemStore_DeleteMant(t *testing.T) {
 		t.Fatalf("Error adding initial URL: %v", err)
 	}
 
-	if err := store.DeleteMany(ctx, []DeleteRequest{{Slug: URL.Slug, UserID: URL.UserID}}); err != nil {
+	delReqs := []DeleteRequest{{Slug: URL.Slug, UserID: URL.UserID}, {Slug: URL.Slug, UserID: "otherUserID"}, {Slug: "missing", UserID: URL.UserID}}
+	deleted, err := store.DeleteMany(ctx, delReqs)
+	if err != nil {
 		t.Errorf("Error deleting URL: %v", err)
 	}
+	if len(deleted) != 1 || deleted[0] != delReqs[0] {
+		t.Errorf("Expected only the owner's request to match, got: %+v", deleted)
+	}
 }
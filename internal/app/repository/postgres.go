@@ -4,9 +4,12 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -33,9 +36,187 @@ func NewPostgresRepository(ctx context.Context, pgDSN string) (*PostgresReposito
 		is_deleted BOOLEAN NOT NULL DEFAULT FALSE
 	);
 	`
+	alterTableQuery := `
+	ALTER TABLE url
+	ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS not_after TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS schedule JSONB,
+	ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36),
+	ADD COLUMN IF NOT EXISTS is_disabled BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0;
+	`
 	createIndexQuery := `
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_original_url ON url (original_url);
 	`
+	createAPIKeyTableQuery := `
+	CREATE TABLE IF NOT EXISTS api_key (
+		id VARCHAR(36) PRIMARY KEY,
+		user_uuid VARCHAR(36) NOT NULL,
+		label VARCHAR(255) NOT NULL DEFAULT '',
+		prefix VARCHAR(16) NOT NULL,
+		key_hash CHAR(64) UNIQUE NOT NULL,
+		scopes TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		last_used_at TIMESTAMPTZ,
+		revoked_at TIMESTAMPTZ
+	);
+	`
+	createUserTableQuery := `
+	CREATE TABLE IF NOT EXISTS users (
+		id VARCHAR(36) PRIMARY KEY,
+		email VARCHAR(255) UNIQUE,
+		username VARCHAR(64) UNIQUE,
+		password_hash VARCHAR(255) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+	`
+	createWorkspaceTablesQuery := `
+	CREATE TABLE IF NOT EXISTS workspace (
+		id VARCHAR(36) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		created_by VARCHAR(36) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS workspace_member (
+		workspace_id VARCHAR(36) NOT NULL REFERENCES workspace (id) ON DELETE CASCADE,
+		user_uuid VARCHAR(36) NOT NULL,
+		role VARCHAR(16) NOT NULL,
+		joined_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (workspace_id, user_uuid)
+	);
+	CREATE TABLE IF NOT EXISTS workspace_invitation (
+		id VARCHAR(36) PRIMARY KEY,
+		workspace_id VARCHAR(36) NOT NULL REFERENCES workspace (id) ON DELETE CASCADE,
+		role VARCHAR(16) NOT NULL,
+		token_hash CHAR(64) UNIQUE NOT NULL,
+		invited_by VARCHAR(36) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		accepted_by VARCHAR(36),
+		accepted_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS idx_url_workspace_id ON url (workspace_id);
+	`
+	createBlockedUserTableQuery := `
+	CREATE TABLE IF NOT EXISTS blocked_user (
+		user_uuid VARCHAR(36) PRIMARY KEY,
+		reason TEXT NOT NULL DEFAULT '',
+		blocked_by VARCHAR(36) NOT NULL,
+		blocked_at TIMESTAMPTZ NOT NULL
+	);
+	`
+	createModerationTablesQuery := `
+	CREATE TABLE IF NOT EXISTS abuse_report (
+		id VARCHAR(36) PRIMARY KEY,
+		slug VARCHAR(20) NOT NULL,
+		owner_uuid VARCHAR(36) NOT NULL,
+		reason VARCHAR(32) NOT NULL,
+		details TEXT NOT NULL DEFAULT '',
+		reporter_uuid VARCHAR(36) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		reviewed_by VARCHAR(36),
+		reviewed_at TIMESTAMPTZ
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_abuse_report_open ON abuse_report (slug, reporter_uuid) WHERE status = 'open';
+	CREATE INDEX IF NOT EXISTS idx_abuse_report_owner ON abuse_report (owner_uuid);
+	ALTER TABLE abuse_report ALTER COLUMN reporter_uuid TYPE VARCHAR(64);
+	CREATE TABLE IF NOT EXISTS flagged_user (
+		user_uuid VARCHAR(36) PRIMARY KEY,
+		reason TEXT NOT NULL,
+		flagged_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS url_takedown (
+		slug VARCHAR(20) PRIMARY KEY,
+		taken_down_by VARCHAR(36) NOT NULL,
+		taken_down_at TIMESTAMPTZ NOT NULL
+	);
+	`
+	createWebhookTablesQuery := `
+	CREATE TABLE IF NOT EXISTS webhook (
+		id VARCHAR(36) PRIMARY KEY,
+		user_uuid VARCHAR(36) NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		click_threshold INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_user_uuid ON webhook (user_uuid);
+	CREATE TABLE IF NOT EXISTS webhook_delivery (
+		seq BIGSERIAL PRIMARY KEY,
+		id VARCHAR(36) UNIQUE NOT NULL,
+		webhook_id VARCHAR(36) NOT NULL,
+		event VARCHAR(32) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		last_status_code INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON webhook_delivery (next_attempt_at) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS idx_webhook_delivery_webhook_id ON webhook_delivery (webhook_id);
+	CREATE TABLE IF NOT EXISTS webhook_checkpoint (
+		name VARCHAR(32) PRIMARY KEY,
+		checked_at TIMESTAMPTZ NOT NULL
+	);
+	`
+	createAuditTableQuery := `
+	CREATE TABLE IF NOT EXISTS audit_event (
+		seq BIGSERIAL PRIMARY KEY,
+		id VARCHAR(36) UNIQUE NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		actor VARCHAR(36) NOT NULL,
+		action VARCHAR(32) NOT NULL,
+		slugs TEXT NOT NULL DEFAULT '',
+		workspace_id VARCHAR(36),
+		target_uuid VARCHAR(36),
+		source_ip VARCHAR(45),
+		request_id VARCHAR(64)
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_event_created_at ON audit_event (created_at);
+	CREATE OR REPLACE FUNCTION audit_event_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_event is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS audit_event_append_only ON audit_event;
+	CREATE TRIGGER audit_event_append_only BEFORE UPDATE OR DELETE ON audit_event
+		FOR EACH ROW EXECUTE FUNCTION audit_event_append_only();
+	`
+	createDeletionJobTablesQuery := `
+	CREATE TABLE IF NOT EXISTS deletion_job (
+		id VARCHAR(36) PRIMARY KEY,
+		user_uuid VARCHAR(36) NOT NULL,
+		workspace_id VARCHAR(36),
+		created_at TIMESTAMPTZ NOT NULL
+	);
+	ALTER TABLE deletion_job
+	ADD COLUMN IF NOT EXISTS source_ip VARCHAR(45) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS request_id VARCHAR(64) NOT NULL DEFAULT '';
+	CREATE TABLE IF NOT EXISTS deletion_job_item (
+		job_id VARCHAR(36) NOT NULL REFERENCES deletion_job (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		slug TEXT NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		reason VARCHAR(32) NOT NULL DEFAULT '',
+		updated_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (job_id, slug)
+	);
+	CREATE INDEX IF NOT EXISTS idx_deletion_job_item_pending ON deletion_job_item (job_id, position) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS idx_deletion_job_created_at ON deletion_job (created_at);
+	`
+	createRevokedSessionTableQuery := `
+	CREATE TABLE IF NOT EXISTS revoked_session (
+		session_id VARCHAR(36) PRIMARY KEY,
+		user_uuid VARCHAR(36) NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ NOT NULL
+	);
+	`
 
 	db, err := sql.Open("pgx", pgDSN)
 	if err != nil {
@@ -46,9 +227,49 @@ func NewPostgresRepository(ctx context.Context, pgDSN string) (*PostgresReposito
 		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
+	if _, err := db.ExecContext(ctx, alterTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to alter table: %w", err)
+	}
+
 	if _, err := db.ExecContext(ctx, createIndexQuery); err != nil {
 		return nil, fmt.Errorf("failed to create index: %w", err)
 	}
+
+	if _, err := db.ExecContext(ctx, createAPIKeyTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create API key table: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createUserTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create users table: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createRevokedSessionTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create revoked session table: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createWorkspaceTablesQuery); err != nil {
+		return nil, fmt.Errorf("failed to create workspace tables: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createBlockedUserTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create blocked user table: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createModerationTablesQuery); err != nil {
+		return nil, fmt.Errorf("failed to create moderation tables: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createAuditTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create audit table: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createWebhookTablesQuery); err != nil {
+		return nil, fmt.Errorf("failed to create webhook tables: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, createDeletionJobTablesQuery); err != nil {
+		return nil, fmt.Errorf("failed to create deletion job tables: %w", err)
+	}
 	return &PostgresRepository{db: db}, nil
 }
 
@@ -56,11 +277,16 @@ func NewPostgresRepository(ctx context.Context, pgDSN string) (*PostgresReposito
 func (sr *PostgresRepository) Add(ctx context.Context, url URL) error {
 	addURLQuery := `
 	INSERT INTO url
-	(slug, original_url, user_uuid, is_deleted)
-	VALUES ($1, $2, $3, $4);
+	(slug, original_url, user_uuid, is_deleted, not_before, not_after, schedule, workspace_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
 	`
 
-	_, err := sr.db.ExecContext(ctx, addURLQuery, url.Slug, url.OriginalURL, url.UserID, url.IsDeleted)
+	schedule, err := encodeSchedule(url.Schedule)
+	if err != nil {
+		return err
+	}
+
+	_, err = sr.db.ExecContext(ctx, addURLQuery, url.Slug, url.OriginalURL, url.UserID, url.IsDeleted, url.NotBefore, url.NotAfter, schedule, nullString(url.WorkspaceID))
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
@@ -76,8 +302,8 @@ func (sr *PostgresRepository) Add(ctx context.Context, url URL) error {
 func (sr *PostgresRepository) AddMany(ctx context.Context, urls []URL) error {
 	addURLsQuery := `
 	INSERT INTO url
-	(slug, original_url, user_uuid, is_deleted)
-	VALUES ($1, $2, $3, $4);
+	(slug, original_url, user_uuid, is_deleted, not_before, not_after, schedule, workspace_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
 	`
 
 	tx, err := sr.db.Begin()
@@ -99,35 +325,117 @@ func (sr *PostgresRepository) AddMany(ctx context.Context, urls []URL) error {
 	defer stmt.Close()
 
 	for _, u := range urls {
-		if _, err = stmt.ExecContext(ctx, u.Slug, u.OriginalURL, u.UserID, u.IsDeleted); err != nil {
+		var schedule any
+		if schedule, err = encodeSchedule(u.Schedule); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx, u.Slug, u.OriginalURL, u.UserID, u.IsDeleted, u.NotBefore, u.NotAfter, schedule, nullString(u.WorkspaceID)); err != nil {
 			return err
 		}
 	}
 	return tx.Commit()
 }
 
+// AddManyKeepExisting adds the URLs whose original URL is not stored yet in a single transaction.
+// It returns the stored URLs of the others, keyed by original URL.
+func (sr *PostgresRepository) AddManyKeepExisting(ctx context.Context, urls []URL) (map[string]URL, error) {
+	addURLQuery := `
+	INSERT INTO url
+	(slug, original_url, user_uuid, is_deleted, not_before, not_after, schedule, workspace_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (original_url) DO NOTHING;
+	`
+	getExistingURLQuery := `
+	SELECT slug, user_uuid, is_deleted
+	FROM url
+	WHERE original_url = $1;
+	`
+
+	tx, err := sr.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("saving multiple URLs rollback", slog.Any("error", rbErr))
+			}
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, addURLQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	existing := make(map[string]URL)
+	for _, u := range urls {
+		var schedule any
+		if schedule, err = encodeSchedule(u.Schedule); err != nil {
+			return nil, err
+		}
+		var result sql.Result
+		if result, err = stmt.ExecContext(ctx, u.Slug, u.OriginalURL, u.UserID, u.IsDeleted, u.NotBefore, u.NotAfter, schedule, nullString(u.WorkspaceID)); err != nil {
+			return nil, err
+		}
+		var affected int64
+		if affected, err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+		if affected > 0 {
+			continue
+		}
+
+		var slug, userID string
+		var isDeleted bool
+		if err = tx.QueryRowContext(ctx, getExistingURLQuery, u.OriginalURL).Scan(&slug, &userID, &isDeleted); err != nil {
+			return nil, err
+		}
+		existing[u.OriginalURL] = *NewURL(slug, u.OriginalURL, userID, isDeleted)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
 // GetBySlug retrieves a URL by its slug. It returns an error if the URL does not exist.
 func (sr *PostgresRepository) GetBySlug(ctx context.Context, slug string) (URL, error) {
 	getURLquery := `
-	SELECT slug, original_url, user_uuid, is_deleted
+	SELECT slug, original_url, user_uuid, is_deleted, not_before, not_after, schedule, workspace_id, is_disabled
 	FROM url
 	WHERE slug = $1;
 	`
 
 	var url URL
-	err := sr.db.QueryRowContext(ctx, getURLquery, slug).Scan(&url.Slug, &url.OriginalURL, &url.UserID, &url.IsDeleted)
+	var notBefore, notAfter sql.NullTime
+	var schedule, workspaceID sql.NullString
+	err := sr.db.QueryRowContext(ctx, getURLquery, slug).Scan(&url.Slug, &url.OriginalURL, &url.UserID, &url.IsDeleted, &notBefore, &notAfter, &schedule, &workspaceID, &url.IsDisabled)
 	if err != nil {
 		return URL{}, ErrURLNotExsit
 	}
+	url.WorkspaceID = workspaceID.String
+
+	if notBefore.Valid {
+		url.NotBefore = &notBefore.Time
+	}
+	if notAfter.Valid {
+		url.NotAfter = &notAfter.Time
+	}
+	if url.Schedule, err = decodeSchedule(schedule); err != nil {
+		slog.Error("decoding url schedule", slog.String("slug", slug), slog.Any("error", err))
+		return URL{}, err
+	}
 	return url, nil
 }
 
-// GetByUser retrieves all URLs associated with a user. It returns an error if no URLs are found.
+// GetByUser retrieves all URLs associated with a user, excluding workspace URLs. It returns an error if no URLs are found.
 func (sr *PostgresRepository) GetByUser(ctx context.Context, userID string) ([]URL, error) {
 	getURLsByUserQuery := `
 	SELECT slug, original_url, is_deleted
 	FROM url
-	WHERE user_uuid = $1
+	WHERE user_uuid = $1 AND workspace_id IS NULL
 	`
 
 	urls := []URL{}
@@ -160,6 +468,38 @@ func (sr *PostgresRepository) GetByUser(ctx context.Context, userID string) ([]U
 	return urls, nil
 }
 
+// GetByWorkspace retrieves all URLs belonging to a workspace. It returns an error if no URLs are found.
+func (sr *PostgresRepository) GetByWorkspace(ctx context.Context, workspaceID string) ([]URL, error) {
+	getURLsByWorkspaceQuery := `
+	SELECT slug, original_url, user_uuid, is_deleted
+	FROM url
+	WHERE workspace_id = $1
+	ORDER BY id;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, getURLsByWorkspaceQuery, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspace URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []URL
+	for rows.Next() {
+		url := URL{WorkspaceID: workspaceID}
+		if err := rows.Scan(&url.Slug, &url.OriginalURL, &url.UserID, &url.IsDeleted); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate workspace URLs: %w", err)
+	}
+	if len(urls) == 0 {
+		return nil, ErrURLNotExsit
+	}
+	return urls, nil
+}
+
 // GetByOriginalURL retrieves a URL by its original URL. It returns an error if the URL does not exist.
 func (sr *PostgresRepository) GetByOriginalURL(ctx context.Context, originalURL string) (URL, error) {
 	getURLByOriginalURLQuery := `
@@ -200,18 +540,40 @@ func (sr *PostgresRepository) GetServiceStats(ctx context.Context) (urlsCount in
 	return urlsCount, usersCount, nil
 }
 
+// UpdateOriginalURL changes the original URL a slug points to.
+// It returns an error if the slug does not exist or another URL already has the original URL.
+func (sr *PostgresRepository) UpdateOriginalURL(ctx context.Context, slug string, originalURL string) error {
+	updateOriginalURLQuery := `
+	UPDATE url
+	SET original_url = $2
+	WHERE slug = $1;
+	`
+
+	result, err := sr.db.ExecContext(ctx, updateOriginalURLQuery, slug, originalURL)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return ErrURLDuplicate
+		}
+		return fmt.Errorf("failed to update original URL: %w", err)
+	}
+	return requireAffected(result, ErrURLNotExsit)
+}
+
 // DeleteMany marks multiple URLs as deleted based on the provided delete requests.
-func (sr *PostgresRepository) DeleteMany(ctx context.Context, delReqs []DeleteRequest) error {
+// Workspace URLs are only matched by requests for their workspace, other URLs by their owner.
+// It returns the requests that matched a URL.
+func (sr *PostgresRepository) DeleteMany(ctx context.Context, delReqs []DeleteRequest) (deleted []DeleteRequest, err error) {
 	deleteURLsQuery := `
 	UPDATE url
 	SET is_deleted = True
-	WHERE slug = $1 AND user_uuid = $2;
+	WHERE slug = $1 AND COALESCE(workspace_id, '') = $3 AND ($3 <> '' OR user_uuid = $2);
 	`
 
 	tx, err := sr.db.Begin()
 	if err != nil {
 		slog.Error("multiple URLs deletion transaction start", slog.Any("error", err))
-		return err
+		return nil, err
 	}
 	defer func() {
 		if err != nil {
@@ -224,20 +586,1442 @@ func (sr *PostgresRepository) DeleteMany(ctx context.Context, delReqs []DeleteRe
 	stmt, err := tx.PrepareContext(ctx, deleteURLsQuery)
 	if err != nil {
 		slog.Error("multiple URLs deletion context preparation", slog.Any("error", err))
-		return err
+		return nil, err
 	}
 	defer stmt.Close()
 
+	deleted = []DeleteRequest{}
 	for _, dr := range delReqs {
-		if _, err = stmt.ExecContext(ctx, dr.Slug, dr.UserID); err != nil {
+		var result sql.Result
+		if result, err = stmt.ExecContext(ctx, dr.Slug, dr.UserID, dr.WorkspaceID); err != nil {
 			slog.Error("multiple URLs deletion context execution", slog.Any("error", err))
-			return err
+			return nil, err
+		}
+		var affected int64
+		if affected, err = result.RowsAffected(); err != nil {
+			slog.Error("multiple URLs deletion rows affected", slog.Any("error", err))
+			return nil, err
+		}
+		if affected > 0 {
+			deleted = append(deleted, dr)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// AddAPIKey adds a new API key to the PostgreSQL database.
+func (sr *PostgresRepository) AddAPIKey(ctx context.Context, key APIKey) error {
+	addAPIKeyQuery := `
+	INSERT INTO api_key
+	(id, user_uuid, label, prefix, key_hash, scopes, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+
+	_, err := sr.db.ExecContext(ctx, addAPIKeyQuery, key.ID, key.UserID, key.Label, key.Prefix, key.Hash, strings.Join(key.Scopes, ","), key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeysByUser retrieves the API keys owned by a user, including revoked ones.
+func (sr *PostgresRepository) GetAPIKeysByUser(ctx context.Context, userID string) ([]APIKey, error) {
+	getAPIKeysByUserQuery := `
+	SELECT id, user_uuid, label, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+	FROM api_key
+	WHERE user_uuid = $1
+	ORDER BY created_at;
+	`
+
+	keys := []APIKey{}
+	rows, err := sr.db.QueryContext(ctx, getAPIKeysByUserQuery, userID)
+	if err != nil {
+		return keys, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return keys, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of the key. It returns an error if the key does not exist.
+func (sr *PostgresRepository) GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	getAPIKeyByHashQuery := `
+	SELECT id, user_uuid, label, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+	FROM api_key
+	WHERE key_hash = $1;
+	`
+
+	key, err := scanAPIKey(sr.db.QueryRowContext(ctx, getAPIKeyByHashQuery, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotExist
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+// UpdateAPIKeyLabel changes the label of an API key owned by a user. It returns an error if the key does not exist.
+func (sr *PostgresRepository) UpdateAPIKeyLabel(ctx context.Context, userID string, keyID string, label string) error {
+	updateAPIKeyLabelQuery := `
+	UPDATE api_key
+	SET label = $3
+	WHERE id = $1 AND user_uuid = $2;
+	`
+
+	result, err := sr.db.ExecContext(ctx, updateAPIKeyLabelQuery, keyID, userID, label)
+	if err != nil {
+		return fmt.Errorf("failed to update API key label: %w", err)
+	}
+	return requireAffectedAPIKey(result)
+}
+
+// RevokeAPIKey marks an API key owned by a user as revoked. It returns an error if the key does not exist.
+func (sr *PostgresRepository) RevokeAPIKey(ctx context.Context, userID string, keyID string, revokedAt time.Time) error {
+	revokeAPIKeyQuery := `
+	UPDATE api_key
+	SET revoked_at = COALESCE(revoked_at, $3)
+	WHERE id = $1 AND user_uuid = $2;
+	`
+
+	result, err := sr.db.ExecContext(ctx, revokeAPIKeyQuery, keyID, userID, revokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return requireAffectedAPIKey(result)
+}
+
+// TouchAPIKey records the time an API key was last used. It returns an error if the key does not exist.
+func (sr *PostgresRepository) TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error {
+	touchAPIKeyQuery := `
+	UPDATE api_key
+	SET last_used_at = $2
+	WHERE id = $1;
+	`
+
+	result, err := sr.db.ExecContext(ctx, touchAPIKeyQuery, keyID, usedAt)
+	if err != nil {
+		return fmt.Errorf("failed to touch API key: %w", err)
+	}
+	return requireAffectedAPIKey(result)
+}
+
+// AddUser adds a new user account to the PostgreSQL database. It returns an error if the ID, email or username is taken.
+func (sr *PostgresRepository) AddUser(ctx context.Context, user User) error {
+	addUserQuery := `
+	INSERT INTO users
+	(id, email, username, password_hash, created_at)
+	VALUES ($1, $2, $3, $4, $5);
+	`
+
+	_, err := sr.db.ExecContext(ctx, addUserQuery, user.ID, nullString(user.Email), nullString(user.Username), user.PasswordHash, user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return ErrUserDuplicate
+		}
+		return fmt.Errorf("failed to add user: %w", err)
+	}
+	return nil
+}
+
+// GetUserByID retrieves a user account by its ID. It returns an error if the user does not exist.
+func (sr *PostgresRepository) GetUserByID(ctx context.Context, userID string) (User, error) {
+	getUserByIDQuery := `
+	SELECT id, email, username, password_hash, created_at
+	FROM users
+	WHERE id = $1;
+	`
+
+	return scanUser(sr.db.QueryRowContext(ctx, getUserByIDQuery, userID))
+}
+
+// GetUserByLogin retrieves a user account by its email or username. It returns an error if the user does not exist.
+func (sr *PostgresRepository) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	getUserByLoginQuery := `
+	SELECT id, email, username, password_hash, created_at
+	FROM users
+	WHERE email = $1 OR username = $1;
+	`
+
+	return scanUser(sr.db.QueryRowContext(ctx, getUserByLoginQuery, login))
+}
+
+// MergeUser transfers the URLs, API keys, webhooks, workspace memberships and abuse reports of one user ID to another.
+// A block of the user is carried over, so its links stay taken down.
+// Memberships in workspaces the other user already is a member of are dropped once its role is raised to the higher of the two.
+func (sr *PostgresRepository) MergeUser(ctx context.Context, fromUserID string, toUserID string) error {
+	mergeURLsQuery := `
+	UPDATE url
+	SET user_uuid = $2
+	WHERE user_uuid = $1;
+	`
+	mergeAPIKeysQuery := `
+	UPDATE api_key
+	SET user_uuid = $2
+	WHERE user_uuid = $1;
+	`
+	mergeWebhooksQuery := `
+	UPDATE webhook
+	SET user_uuid = $2
+	WHERE user_uuid = $1;
+	`
+	raiseDuplicateMembershipsQuery := `
+	UPDATE workspace_member t
+	SET role = f.role
+	FROM workspace_member f
+	WHERE t.user_uuid = $2 AND f.user_uuid = $1 AND f.workspace_id = t.workspace_id
+	AND CASE f.role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END > CASE t.role WHEN 'owner' THEN 3 WHEN 'editor' THEN 2 ELSE 1 END;
+	`
+	dropDuplicateMembershipsQuery := `
+	DELETE FROM workspace_member
+	WHERE user_uuid = $1 AND workspace_id IN (SELECT workspace_id FROM workspace_member WHERE user_uuid = $2);
+	`
+	mergeMembershipsQuery := `
+	UPDATE workspace_member
+	SET user_uuid = $2
+	WHERE user_uuid = $1;
+	`
+	mergeAbuseReportsQuery := `
+	UPDATE abuse_report
+	SET owner_uuid = $2
+	WHERE owner_uuid = $1;
+	`
+	mergeBlockedUserQuery := `
+	INSERT INTO blocked_user
+	(user_uuid, reason, blocked_by, blocked_at)
+	SELECT $2, reason, blocked_by, blocked_at FROM blocked_user WHERE user_uuid = $1
+	ON CONFLICT (user_uuid) DO NOTHING;
+	`
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("user merge rollback", slog.Any("error", rbErr))
+			}
 		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, mergeURLsQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user URLs: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, mergeAPIKeysQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user API keys: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, mergeWebhooksQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user webhooks: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, raiseDuplicateMembershipsQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user workspace memberships: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, dropDuplicateMembershipsQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user workspace memberships: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, mergeMembershipsQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user workspace memberships: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, mergeAbuseReportsQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user abuse reports: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, mergeBlockedUserQuery, fromUserID, toUserID); err != nil {
+		return fmt.Errorf("failed to merge user block: %w", err)
 	}
 	return tx.Commit()
 }
 
-// Ping checks the connection to the PostgreSQL database. It returns an error if the connection is not alive.
-func (sr *PostgresRepository) Ping(ctx context.Context) error {
-	return sr.db.PingContext(ctx)
+// RevokeSession records a session as revoked until its expiry, pruning revocations of expired sessions.
+func (sr *PostgresRepository) RevokeSession(ctx context.Context, session RevokedSession) error {
+	revokeSessionQuery := `
+	INSERT INTO revoked_session
+	(session_id, user_uuid, expires_at, revoked_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (session_id) DO NOTHING;
+	`
+	pruneRevokedSessionsQuery := `
+	DELETE FROM revoked_session
+	WHERE expires_at < NOW();
+	`
+
+	if _, err := sr.db.ExecContext(ctx, revokeSessionQuery, session.SessionID, session.UserID, session.ExpiresAt, session.RevokedAt); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if _, err := sr.db.ExecContext(ctx, pruneRevokedSessionsQuery); err != nil {
+		slog.Error("pruning revoked sessions", slog.Any("error", err))
+	}
+	return nil
+}
+
+// IsSessionRevoked reports whether a session was revoked.
+func (sr *PostgresRepository) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	isSessionRevokedQuery := `
+	SELECT EXISTS (SELECT 1 FROM revoked_session WHERE session_id = $1);
+	`
+
+	var revoked bool
+	if err := sr.db.QueryRowContext(ctx, isSessionRevokedQuery, sessionID).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check session revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// scanUser scans a users row into a User.
+func scanUser(row rowScanner) (User, error) {
+	var user User
+	var email, username sql.NullString
+	err := row.Scan(&user.ID, &email, &username, &user.PasswordHash, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotExist
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	user.Email = email.String
+	user.Username = username.String
+	return user, nil
+}
+
+// AddWorkspace adds a new workspace together with its first owner.
+func (sr *PostgresRepository) AddWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error {
+	addWorkspaceQuery := `
+	INSERT INTO workspace
+	(id, name, created_by, created_at)
+	VALUES ($1, $2, $3, $4);
+	`
+	addMemberQuery := `
+	INSERT INTO workspace_member
+	(workspace_id, user_uuid, role, joined_at)
+	VALUES ($1, $2, $3, $4);
+	`
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("workspace creation rollback", slog.Any("error", rbErr))
+			}
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, addWorkspaceQuery, workspace.ID, workspace.Name, workspace.CreatedBy, workspace.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add workspace: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, addMemberQuery, owner.WorkspaceID, owner.UserID, owner.Role, owner.JoinedAt); err != nil {
+		return fmt.Errorf("failed to add workspace owner: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetWorkspacesByUser retrieves the workspaces a user is a member of, with the user's role.
+func (sr *PostgresRepository) GetWorkspacesByUser(ctx context.Context, userID string) ([]WorkspaceMembership, error) {
+	getWorkspacesByUserQuery := `
+	SELECT w.id, w.name, w.created_by, w.created_at, m.role
+	FROM workspace w
+	JOIN workspace_member m ON m.workspace_id = w.id
+	WHERE m.user_uuid = $1
+	ORDER BY w.created_at;
+	`
+
+	memberships := []WorkspaceMembership{}
+	rows, err := sr.db.QueryContext(ctx, getWorkspacesByUserQuery, userID)
+	if err != nil {
+		return memberships, fmt.Errorf("failed to query workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m WorkspaceMembership
+		if err := rows.Scan(&m.ID, &m.Name, &m.CreatedBy, &m.CreatedAt, &m.Role); err != nil {
+			return memberships, fmt.Errorf("failed to scan workspace: %w", err)
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+// GetWorkspaceMember retrieves the membership of a user in a workspace. It returns an error if the user is not a member.
+func (sr *PostgresRepository) GetWorkspaceMember(ctx context.Context, workspaceID string, userID string) (WorkspaceMember, error) {
+	getWorkspaceMemberQuery := `
+	SELECT workspace_id, user_uuid, role, joined_at
+	FROM workspace_member
+	WHERE workspace_id = $1 AND user_uuid = $2;
+	`
+
+	var m WorkspaceMember
+	err := sr.db.QueryRowContext(ctx, getWorkspaceMemberQuery, workspaceID, userID).Scan(&m.WorkspaceID, &m.UserID, &m.Role, &m.JoinedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WorkspaceMember{}, ErrWorkspaceMemberNotExist
+	}
+	if err != nil {
+		return WorkspaceMember{}, fmt.Errorf("failed to get workspace member: %w", err)
+	}
+	return m, nil
+}
+
+// GetWorkspaceMembers retrieves the members of a workspace.
+func (sr *PostgresRepository) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	getWorkspaceMembersQuery := `
+	SELECT workspace_id, user_uuid, role, joined_at
+	FROM workspace_member
+	WHERE workspace_id = $1
+	ORDER BY joined_at;
+	`
+
+	members := []WorkspaceMember{}
+	rows, err := sr.db.QueryContext(ctx, getWorkspaceMembersQuery, workspaceID)
+	if err != nil {
+		return members, fmt.Errorf("failed to query workspace members: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m WorkspaceMember
+		if err := rows.Scan(&m.WorkspaceID, &m.UserID, &m.Role, &m.JoinedAt); err != nil {
+			return members, fmt.Errorf("failed to scan workspace member: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// UpdateWorkspaceMemberRole changes the role of a workspace member. It returns an error if the user is not a member.
+func (sr *PostgresRepository) UpdateWorkspaceMemberRole(ctx context.Context, workspaceID string, userID string, role string) error {
+	updateMemberRoleQuery := `
+	UPDATE workspace_member
+	SET role = $3
+	WHERE workspace_id = $1 AND user_uuid = $2;
+	`
+
+	result, err := sr.db.ExecContext(ctx, updateMemberRoleQuery, workspaceID, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to update workspace member role: %w", err)
+	}
+	return requireAffected(result, ErrWorkspaceMemberNotExist)
+}
+
+// RemoveWorkspaceMember removes a member from a workspace. It returns an error if the user is not a member.
+func (sr *PostgresRepository) RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error {
+	removeMemberQuery := `
+	DELETE FROM workspace_member
+	WHERE workspace_id = $1 AND user_uuid = $2;
+	`
+
+	result, err := sr.db.ExecContext(ctx, removeMemberQuery, workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace member: %w", err)
+	}
+	return requireAffected(result, ErrWorkspaceMemberNotExist)
+}
+
+// AddWorkspaceInvitation adds a new workspace invitation.
+func (sr *PostgresRepository) AddWorkspaceInvitation(ctx context.Context, invitation WorkspaceInvitation) error {
+	addInvitationQuery := `
+	INSERT INTO workspace_invitation
+	(id, workspace_id, role, token_hash, invited_by, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+
+	_, err := sr.db.ExecContext(ctx, addInvitationQuery,
+		invitation.ID, invitation.WorkspaceID, invitation.Role, invitation.TokenHash,
+		invitation.InvitedBy, invitation.CreatedAt, invitation.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add workspace invitation: %w", err)
+	}
+	return nil
+}
+
+// GetWorkspaceInvitations retrieves the invitations of a workspace, including accepted and expired ones.
+func (sr *PostgresRepository) GetWorkspaceInvitations(ctx context.Context, workspaceID string) ([]WorkspaceInvitation, error) {
+	getInvitationsQuery := `
+	SELECT id, workspace_id, role, token_hash, invited_by, created_at, expires_at, accepted_by, accepted_at
+	FROM workspace_invitation
+	WHERE workspace_id = $1
+	ORDER BY created_at;
+	`
+
+	invitations := []WorkspaceInvitation{}
+	rows, err := sr.db.QueryContext(ctx, getInvitationsQuery, workspaceID)
+	if err != nil {
+		return invitations, fmt.Errorf("failed to query workspace invitations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var inv WorkspaceInvitation
+		var acceptedBy sql.NullString
+		var acceptedAt sql.NullTime
+		if err := rows.Scan(&inv.ID, &inv.WorkspaceID, &inv.Role, &inv.TokenHash, &inv.InvitedBy, &inv.CreatedAt, &inv.ExpiresAt, &acceptedBy, &acceptedAt); err != nil {
+			return invitations, fmt.Errorf("failed to scan workspace invitation: %w", err)
+		}
+		inv.AcceptedBy = acceptedBy.String
+		if acceptedAt.Valid {
+			inv.AcceptedAt = &acceptedAt.Time
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// DeleteWorkspaceInvitation deletes an invitation of a workspace. It returns an error if the invitation does not exist.
+func (sr *PostgresRepository) DeleteWorkspaceInvitation(ctx context.Context, workspaceID string, invitationID string) error {
+	deleteInvitationQuery := `
+	DELETE FROM workspace_invitation
+	WHERE id = $1 AND workspace_id = $2;
+	`
+
+	result, err := sr.db.ExecContext(ctx, deleteInvitationQuery, invitationID, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to delete workspace invitation: %w", err)
+	}
+	return requireAffected(result, ErrWorkspaceInvitationNotExist)
+}
+
+// AcceptWorkspaceInvitation marks a pending invitation as accepted and adds the user to its workspace.
+// It returns an error if no pending invitation has the token hash or the user already is a member.
+func (sr *PostgresRepository) AcceptWorkspaceInvitation(ctx context.Context, tokenHash string, userID string, acceptedAt time.Time) (WorkspaceMember, error) {
+	acceptInvitationQuery := `
+	UPDATE workspace_invitation
+	SET accepted_by = $2, accepted_at = $3
+	WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > $3
+	RETURNING workspace_id, role;
+	`
+	addMemberQuery := `
+	INSERT INTO workspace_member
+	(workspace_id, user_uuid, role, joined_at)
+	VALUES ($1, $2, $3, $4);
+	`
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return WorkspaceMember{}, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("invitation acceptance rollback", slog.Any("error", rbErr))
+			}
+		}
+	}()
+
+	member := WorkspaceMember{UserID: userID, JoinedAt: acceptedAt}
+	err = tx.QueryRowContext(ctx, acceptInvitationQuery, tokenHash, userID, acceptedAt).Scan(&member.WorkspaceID, &member.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WorkspaceMember{}, ErrWorkspaceInvitationNotExist
+	}
+	if err != nil {
+		return WorkspaceMember{}, fmt.Errorf("failed to accept workspace invitation: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, addMemberQuery, member.WorkspaceID, member.UserID, member.Role, member.JoinedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return WorkspaceMember{}, ErrWorkspaceMemberDuplicate
+		}
+		return WorkspaceMember{}, fmt.Errorf("failed to add workspace member: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return WorkspaceMember{}, err
+	}
+	return member, nil
+}
+
+// urlFilterCondition selects the URLs matched by a URLFilter given as the $1 slug, $2 original URL pattern and $3 user arguments.
+const urlFilterCondition = `
+	($1 = '' OR slug = $1)
+	AND ($2 = '' OR original_url ILIKE '%' || $2 || '%' ESCAPE '\')
+	AND ($3 = '' OR user_uuid = $3)
+	`
+
+// SearchURLs retrieves the URLs of all users selected by the filter, in creation order.
+func (sr *PostgresRepository) SearchURLs(ctx context.Context, filter URLFilter) ([]URL, error) {
+	searchURLsQuery := `
+	SELECT slug, original_url, user_uuid, workspace_id, is_deleted, is_disabled
+	FROM url
+	WHERE` + urlFilterCondition + `
+	ORDER BY id
+	LIMIT $4 OFFSET $5;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, searchURLsQuery, filter.Slug, escapeLike(filter.OriginalURL), filter.UserID, nullLimit(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []URL{}
+	for rows.Next() {
+		var url URL
+		var workspaceID sql.NullString
+		if err := rows.Scan(&url.Slug, &url.OriginalURL, &url.UserID, &workspaceID, &url.IsDeleted, &url.IsDisabled); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		url.WorkspaceID = workspaceID.String
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+// CountURLsByUser counts the URLs selected by the filter per owner, ordered by the total count.
+func (sr *PostgresRepository) CountURLsByUser(ctx context.Context, filter URLFilter) ([]UserURLCount, error) {
+	countURLsByUserQuery := `
+	SELECT user_uuid, COUNT(*), COUNT(*) FILTER (WHERE is_deleted), COUNT(*) FILTER (WHERE is_disabled)
+	FROM url
+	WHERE` + urlFilterCondition + `
+	GROUP BY user_uuid
+	ORDER BY COUNT(*) DESC, user_uuid
+	LIMIT $4 OFFSET $5;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, countURLsByUserQuery, filter.Slug, escapeLike(filter.OriginalURL), filter.UserID, nullLimit(filter.Limit), filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count URLs by user: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []UserURLCount{}
+	for rows.Next() {
+		var c UserURLCount
+		if err := rows.Scan(&c.UserID, &c.Total, &c.Deleted, &c.Disabled); err != nil {
+			return nil, fmt.Errorf("failed to scan URL count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// SetURLDisabled disables or re-enables a URL. It returns an error if the URL does not exist.
+func (sr *PostgresRepository) SetURLDisabled(ctx context.Context, slug string, disabled bool) error {
+	setURLDisabledQuery := `
+	UPDATE url
+	SET is_disabled = $2
+	WHERE slug = $1;
+	`
+
+	result, err := sr.db.ExecContext(ctx, setURLDisabledQuery, slug, disabled)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	return requireAffected(result, ErrURLNotExsit)
+}
+
+// BlockUser blocks a user, replacing an existing block.
+func (sr *PostgresRepository) BlockUser(ctx context.Context, user BlockedUser) error {
+	blockUserQuery := `
+	INSERT INTO blocked_user
+	(user_uuid, reason, blocked_by, blocked_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_uuid) DO UPDATE
+	SET reason = EXCLUDED.reason, blocked_by = EXCLUDED.blocked_by, blocked_at = EXCLUDED.blocked_at;
+	`
+
+	if _, err := sr.db.ExecContext(ctx, blockUserQuery, user.UserID, user.Reason, user.BlockedBy, user.BlockedAt); err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+	return nil
+}
+
+// UnblockUser removes the block of a user. It returns an error if the user is not blocked.
+func (sr *PostgresRepository) UnblockUser(ctx context.Context, userID string) error {
+	unblockUserQuery := `
+	DELETE FROM blocked_user
+	WHERE user_uuid = $1;
+	`
+
+	result, err := sr.db.ExecContext(ctx, unblockUserQuery, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+	return requireAffected(result, ErrBlockedUserNotExist)
+}
+
+// IsUserBlocked reports whether a user is blocked.
+func (sr *PostgresRepository) IsUserBlocked(ctx context.Context, userID string) (bool, error) {
+	isUserBlockedQuery := `
+	SELECT EXISTS (SELECT 1 FROM blocked_user WHERE user_uuid = $1);
+	`
+
+	var blocked bool
+	if err := sr.db.QueryRowContext(ctx, isUserBlockedQuery, userID).Scan(&blocked); err != nil {
+		return false, fmt.Errorf("failed to check user block: %w", err)
+	}
+	return blocked, nil
+}
+
+// GetBlockedUsers retrieves all blocked users.
+func (sr *PostgresRepository) GetBlockedUsers(ctx context.Context) ([]BlockedUser, error) {
+	getBlockedUsersQuery := `
+	SELECT user_uuid, reason, blocked_by, blocked_at
+	FROM blocked_user
+	ORDER BY blocked_at;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, getBlockedUsersQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocked users: %w", err)
+	}
+	defer rows.Close()
+
+	blocked := []BlockedUser{}
+	for rows.Next() {
+		var b BlockedUser
+		if err := rows.Scan(&b.UserID, &b.Reason, &b.BlockedBy, &b.BlockedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocked user: %w", err)
+		}
+		blocked = append(blocked, b)
+	}
+	return blocked, rows.Err()
+}
+
+// AddAbuseReport adds a new abuse report. It returns an error if the reporter already has an open report on the URL.
+func (sr *PostgresRepository) AddAbuseReport(ctx context.Context, report AbuseReport) error {
+	addAbuseReportQuery := `
+	INSERT INTO abuse_report
+	(id, slug, owner_uuid, reason, details, reporter_uuid, created_at, status)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+
+	_, err := sr.db.ExecContext(ctx, addAbuseReportQuery, report.ID, report.Slug, report.OwnerID, report.Reason, report.Details, report.ReporterID, report.CreatedAt, report.Status)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return ErrAbuseReportDuplicate
+		}
+		return fmt.Errorf("failed to add abuse report: %w", err)
+	}
+	return nil
+}
+
+// GetAbuseReports retrieves the abuse reports with the status, or all reports for an empty status, oldest first.
+func (sr *PostgresRepository) GetAbuseReports(ctx context.Context, status string) ([]AbuseReport, error) {
+	getAbuseReportsQuery := `
+	SELECT id, slug, owner_uuid, reason, details, reporter_uuid, created_at, status, reviewed_by, reviewed_at
+	FROM abuse_report
+	WHERE $1 = '' OR status = $1
+	ORDER BY created_at;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, getAbuseReportsQuery, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query abuse reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []AbuseReport{}
+	for rows.Next() {
+		var r AbuseReport
+		var reviewedBy sql.NullString
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Slug, &r.OwnerID, &r.Reason, &r.Details, &r.ReporterID, &r.CreatedAt, &r.Status, &reviewedBy, &reviewedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan abuse report: %w", err)
+		}
+		r.ReviewedBy = reviewedBy.String
+		if reviewedAt.Valid {
+			r.ReviewedAt = &reviewedAt.Time
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// ResolveAbuseReports sets the status of the open reports on a URL and returns their number.
+func (sr *PostgresRepository) ResolveAbuseReports(ctx context.Context, slug string, status string, reviewedBy string, reviewedAt time.Time) (int, error) {
+	resolveAbuseReportsQuery := `
+	UPDATE abuse_report
+	SET status = $2, reviewed_by = $3, reviewed_at = $4
+	WHERE slug = $1 AND status = 'open';
+	`
+
+	result, err := sr.db.ExecContext(ctx, resolveAbuseReportsQuery, slug, status, reviewedBy, reviewedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve abuse reports: %w", err)
+	}
+	resolved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(resolved), nil
+}
+
+// GetAbuseStats summarizes the abuse reports against the URLs of a user.
+func (sr *PostgresRepository) GetAbuseStats(ctx context.Context, ownerID string) (AbuseStats, error) {
+	getAbuseStatsQuery := `
+	SELECT COUNT(*), COUNT(DISTINCT slug) FILTER (WHERE status = 'taken_down')
+	FROM abuse_report
+	WHERE owner_uuid = $1 AND status <> 'dismissed';
+	`
+
+	var stats AbuseStats
+	if err := sr.db.QueryRowContext(ctx, getAbuseStatsQuery, ownerID).Scan(&stats.Reports, &stats.TakenDown); err != nil {
+		return AbuseStats{}, fmt.Errorf("failed to get abuse stats: %w", err)
+	}
+	return stats, nil
+}
+
+// FlagUser flags a user as a repeat offender, keeping an existing flag.
+func (sr *PostgresRepository) FlagUser(ctx context.Context, user FlaggedUser) error {
+	flagUserQuery := `
+	INSERT INTO flagged_user
+	(user_uuid, reason, flagged_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_uuid) DO NOTHING;
+	`
+
+	if _, err := sr.db.ExecContext(ctx, flagUserQuery, user.UserID, user.Reason, user.FlaggedAt); err != nil {
+		return fmt.Errorf("failed to flag user: %w", err)
+	}
+	return nil
+}
+
+// GetFlaggedUsers retrieves all flagged users.
+func (sr *PostgresRepository) GetFlaggedUsers(ctx context.Context) ([]FlaggedUser, error) {
+	getFlaggedUsersQuery := `
+	SELECT user_uuid, reason, flagged_at
+	FROM flagged_user
+	ORDER BY flagged_at;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, getFlaggedUsersQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flagged users: %w", err)
+	}
+	defer rows.Close()
+
+	flagged := []FlaggedUser{}
+	for rows.Next() {
+		var f FlaggedUser
+		if err := rows.Scan(&f.UserID, &f.Reason, &f.FlaggedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan flagged user: %w", err)
+		}
+		flagged = append(flagged, f)
+	}
+	return flagged, rows.Err()
+}
+
+// TakeDownURL records a URL takedown, keeping an existing one.
+func (sr *PostgresRepository) TakeDownURL(ctx context.Context, takedown URLTakedown) error {
+	takeDownURLQuery := `
+	INSERT INTO url_takedown
+	(slug, taken_down_by, taken_down_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (slug) DO NOTHING;
+	`
+
+	if _, err := sr.db.ExecContext(ctx, takeDownURLQuery, takedown.Slug, takedown.TakenDownBy, takedown.TakenDownAt); err != nil {
+		return fmt.Errorf("failed to take down url: %w", err)
+	}
+	return nil
+}
+
+// IsURLTakenDown reports whether a URL is taken down.
+func (sr *PostgresRepository) IsURLTakenDown(ctx context.Context, slug string) (bool, error) {
+	isURLTakenDownQuery := `
+	SELECT EXISTS (SELECT 1 FROM url_takedown WHERE slug = $1);
+	`
+
+	var takenDown bool
+	if err := sr.db.QueryRowContext(ctx, isURLTakenDownQuery, slug).Scan(&takenDown); err != nil {
+		return false, fmt.Errorf("failed to check url takedown: %w", err)
+	}
+	return takenDown, nil
+}
+
+// AddAuditEvent appends an event to the audit log. The table rejects updates and deletes.
+func (sr *PostgresRepository) AddAuditEvent(ctx context.Context, event AuditEvent) error {
+	addAuditEventQuery := `
+	INSERT INTO audit_event
+	(id, created_at, actor, action, slugs, workspace_id, target_uuid, source_ip, request_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+
+	_, err := sr.db.ExecContext(
+		ctx, addAuditEventQuery,
+		event.ID, event.Time, event.Actor, event.Action, strings.Join(event.Slugs, ","),
+		nullString(event.WorkspaceID), nullString(event.TargetUserID), nullString(event.SourceIP), nullString(event.RequestID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add audit event: %w", err)
+	}
+	return nil
+}
+
+// GetAuditEvents retrieves the audit events satisfying the filter in the order they were recorded.
+func (sr *PostgresRepository) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	getAuditEventsQuery := `
+	SELECT id, created_at, actor, action, slugs, workspace_id, target_uuid, source_ip, request_id
+	FROM audit_event
+	WHERE ($1 = '' OR actor = $1)
+		AND ($2 = '' OR action = $2)
+		AND ($3 = '' OR ',' || slugs || ',' LIKE '%,' || $3 || ',%' ESCAPE '\')
+		AND ($4::timestamptz IS NULL OR created_at >= $4)
+		AND ($5::timestamptz IS NULL OR created_at < $5)
+	ORDER BY seq
+	LIMIT $6 OFFSET $7;
+	`
+
+	rows, err := sr.db.QueryContext(
+		ctx, getAuditEventsQuery,
+		filter.Actor, filter.Action, escapeLike(filter.Slug),
+		sql.NullTime{Time: filter.Since, Valid: !filter.Since.IsZero()},
+		sql.NullTime{Time: filter.Until, Valid: !filter.Until.IsZero()},
+		nullLimit(filter.Limit), filter.Offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var slugs string
+		var workspaceID, targetUserID, sourceIP, requestID sql.NullString
+		if err := rows.Scan(&e.ID, &e.Time, &e.Actor, &e.Action, &slugs, &workspaceID, &targetUserID, &sourceIP, &requestID); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if slugs != "" {
+			e.Slugs = strings.Split(slugs, ",")
+		}
+		e.WorkspaceID, e.TargetUserID, e.SourceIP, e.RequestID = workspaceID.String, targetUserID.String, sourceIP.String, requestID.String
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// AddClicks adds the clicks counted by slug to the URLs in a single transaction and returns their new counts.
+// Slugs that do not exist are left out.
+func (sr *PostgresRepository) AddClicks(ctx context.Context, clicks map[string]int) (totals map[string]int, err error) {
+	addClicksQuery := `
+	UPDATE url
+	SET clicks = clicks + $2
+	WHERE slug = $1
+	RETURNING clicks;
+	`
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start clicks transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("adding clicks rollback", slog.Any("error", rbErr))
+			}
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, addClicksQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare clicks query: %w", err)
+	}
+	defer stmt.Close()
+
+	totals = make(map[string]int, len(clicks))
+	for slug, n := range clicks {
+		var total int
+		if err = stmt.QueryRowContext(ctx, slug, n).Scan(&total); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = nil
+				continue
+			}
+			return nil, fmt.Errorf("failed to add clicks: %w", err)
+		}
+		totals[slug] = total
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit clicks: %w", err)
+	}
+	return totals, nil
+}
+
+// GetURLsExpiringBetween retrieves the URLs that are not deleted and whose activation window ends within (from, to].
+func (sr *PostgresRepository) GetURLsExpiringBetween(ctx context.Context, from time.Time, to time.Time) ([]URL, error) {
+	getExpiringURLsQuery := `
+	SELECT slug, original_url, user_uuid, workspace_id, not_after, clicks
+	FROM url
+	WHERE NOT is_deleted AND not_after > $1 AND not_after <= $2
+	ORDER BY not_after;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, getExpiringURLsQuery, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expiring urls: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []URL{}
+	for rows.Next() {
+		var u URL
+		var workspaceID sql.NullString
+		var notAfter time.Time
+		if err := rows.Scan(&u.Slug, &u.OriginalURL, &u.UserID, &workspaceID, &notAfter, &u.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan expiring url: %w", err)
+		}
+		u.WorkspaceID, u.NotAfter = workspaceID.String, &notAfter
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// AddWebhook adds a new webhook to the PostgreSQL database.
+func (sr *PostgresRepository) AddWebhook(ctx context.Context, webhook Webhook) error {
+	addWebhookQuery := `
+	INSERT INTO webhook
+	(id, user_uuid, url, secret, events, click_threshold, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+
+	_, err := sr.db.ExecContext(
+		ctx, addWebhookQuery,
+		webhook.ID, webhook.UserID, webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","), webhook.ClickThreshold, webhook.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook retrieves a webhook by its ID.
+func (sr *PostgresRepository) GetWebhook(ctx context.Context, webhookID string) (Webhook, error) {
+	getWebhookQuery := `
+	SELECT id, user_uuid, url, secret, events, click_threshold, created_at
+	FROM webhook
+	WHERE id = $1;
+	`
+
+	webhook, err := scanWebhook(sr.db.QueryRowContext(ctx, getWebhookQuery, webhookID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Webhook{}, ErrWebhookNotExist
+	}
+	return webhook, err
+}
+
+// GetWebhooksByUser retrieves all webhooks registered by a user.
+func (sr *PostgresRepository) GetWebhooksByUser(ctx context.Context, userID string) ([]Webhook, error) {
+	getWebhooksByUserQuery := `
+	SELECT id, user_uuid, url, secret, events, click_threshold, created_at
+	FROM webhook
+	WHERE user_uuid = $1
+	ORDER BY created_at;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, getWebhooksByUserQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook of a user. Its deliveries are kept in the delivery log.
+func (sr *PostgresRepository) DeleteWebhook(ctx context.Context, userID string, webhookID string) error {
+	deleteWebhookQuery := `
+	DELETE FROM webhook
+	WHERE id = $1 AND user_uuid = $2;
+	`
+
+	result, err := sr.db.ExecContext(ctx, deleteWebhookQuery, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return requireAffected(result, ErrWebhookNotExist)
+}
+
+// AddWebhookDeliveries adds new webhook deliveries in a single transaction.
+func (sr *PostgresRepository) AddWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	addWebhookDeliveryQuery := `
+	INSERT INTO webhook_delivery
+	(id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
+	`
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, d := range deliveries {
+		_, err := tx.ExecContext(
+			ctx, addWebhookDeliveryQuery,
+			d.ID, d.WebhookID, d.Event, []byte(d.Payload), d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.CreatedAt, d.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add webhook delivery: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDueWebhookDeliveries retrieves up to limit pending deliveries due at the time, oldest first.
+func (sr *PostgresRepository) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	getDueWebhookDeliveriesQuery := `
+	SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at
+	FROM webhook_delivery
+	WHERE status = 'pending' AND next_attempt_at <= $1
+	ORDER BY seq
+	LIMIT $2;
+	`
+
+	return sr.queryWebhookDeliveries(ctx, getDueWebhookDeliveriesQuery, now, nullLimit(limit))
+}
+
+// UpdateWebhookDelivery saves the status and attempts of a delivery.
+func (sr *PostgresRepository) UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	updateWebhookDeliveryQuery := `
+	UPDATE webhook_delivery
+	SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6, updated_at = $7
+	WHERE id = $1;
+	`
+
+	result, err := sr.db.ExecContext(
+		ctx, updateWebhookDeliveryQuery,
+		delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return requireAffected(result, ErrWebhookDeliveryNotExist)
+}
+
+// GetWebhookDeliveries retrieves up to limit deliveries to a webhook, newest first.
+func (sr *PostgresRepository) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	getWebhookDeliveriesQuery := `
+	SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at
+	FROM webhook_delivery
+	WHERE webhook_id = $1
+	ORDER BY seq DESC
+	LIMIT $2;
+	`
+
+	return sr.queryWebhookDeliveries(ctx, getWebhookDeliveriesQuery, webhookID, nullLimit(limit))
+}
+
+// expiryCheckpointName is the name of the checkpoint of the expired events of URLs.
+const expiryCheckpointName = "link.expired"
+
+// GetExpiryCheckpoint retrieves the time up to which the expired events of URLs were queued,
+// or the zero time if they never were.
+func (sr *PostgresRepository) GetExpiryCheckpoint(ctx context.Context) (time.Time, error) {
+	getExpiryCheckpointQuery := `
+	SELECT checked_at
+	FROM webhook_checkpoint
+	WHERE name = $1;
+	`
+
+	var at time.Time
+	if err := sr.db.QueryRowContext(ctx, getExpiryCheckpointQuery, expiryCheckpointName).Scan(&at); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get expiry checkpoint: %w", err)
+	}
+	return at, nil
+}
+
+// SetExpiryCheckpoint saves the time up to which the expired events of URLs were queued.
+func (sr *PostgresRepository) SetExpiryCheckpoint(ctx context.Context, at time.Time) error {
+	setExpiryCheckpointQuery := `
+	INSERT INTO webhook_checkpoint (name, checked_at)
+	VALUES ($1, $2)
+	ON CONFLICT (name) DO UPDATE SET checked_at = EXCLUDED.checked_at;
+	`
+
+	if _, err := sr.db.ExecContext(ctx, setExpiryCheckpointQuery, expiryCheckpointName, at); err != nil {
+		return fmt.Errorf("failed to set expiry checkpoint: %w", err)
+	}
+	return nil
+}
+
+// queryWebhookDeliveries runs a webhook delivery query and scans its rows.
+func (sr *PostgresRepository) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := sr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// AddDeletionJob adds a new deletion job with its pending slugs.
+func (sr *PostgresRepository) AddDeletionJob(ctx context.Context, job DeletionJob) error {
+	addDeletionJobQuery := `
+	INSERT INTO deletion_job
+	(id, user_uuid, workspace_id, source_ip, request_id, created_at)
+	VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6);
+	`
+	addDeletionJobItemQuery := `
+	INSERT INTO deletion_job_item
+	(job_id, position, slug, status, reason, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6);
+	`
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, addDeletionJobQuery, job.ID, job.UserID, job.WorkspaceID, job.SourceIP, job.RequestID, job.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add deletion job: %w", err)
+	}
+	for i, item := range job.Items {
+		if _, err := tx.ExecContext(ctx, addDeletionJobItemQuery, job.ID, i, item.Slug, item.Status, item.Reason, item.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to add deletion job item: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDeletionJob retrieves a deletion job by its ID.
+func (sr *PostgresRepository) GetDeletionJob(ctx context.Context, jobID string) (DeletionJob, error) {
+	getDeletionJobQuery := `
+	SELECT id, user_uuid, COALESCE(workspace_id, ''), source_ip, request_id, created_at
+	FROM deletion_job
+	WHERE id = $1;
+	`
+	getDeletionJobItemsQuery := `
+	SELECT job_id, slug, status, reason, updated_at
+	FROM deletion_job_item
+	WHERE job_id = $1
+	ORDER BY position;
+	`
+
+	var job DeletionJob
+	err := sr.db.QueryRowContext(ctx, getDeletionJobQuery, jobID).Scan(&job.ID, &job.UserID, &job.WorkspaceID, &job.SourceIP, &job.RequestID, &job.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DeletionJob{}, ErrDeletionJobNotExist
+	}
+	if err != nil {
+		return DeletionJob{}, fmt.Errorf("failed to get deletion job: %w", err)
+	}
+
+	rows, err := sr.db.QueryContext(ctx, getDeletionJobItemsQuery, jobID)
+	if err != nil {
+		return DeletionJob{}, fmt.Errorf("failed to query deletion job items: %w", err)
+	}
+	defer rows.Close()
+
+	job.Items = []DeletionJobItem{}
+	for rows.Next() {
+		var item DeletionJobItem
+		if err := rows.Scan(&item.JobID, &item.Slug, &item.Status, &item.Reason, &item.UpdatedAt); err != nil {
+			return DeletionJob{}, fmt.Errorf("failed to scan deletion job item: %w", err)
+		}
+		job.Items = append(job.Items, item)
+	}
+	return job, rows.Err()
+}
+
+// UpdateDeletionJobItems saves the status of slugs of deletion jobs.
+func (sr *PostgresRepository) UpdateDeletionJobItems(ctx context.Context, items []DeletionJobItem) error {
+	updateDeletionJobItemQuery := `
+	UPDATE deletion_job_item
+	SET status = $3, reason = $4, updated_at = $5
+	WHERE job_id = $1 AND slug = $2;
+	`
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, updateDeletionJobItemQuery, item.JobID, item.Slug, item.Status, item.Reason, item.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to update deletion job item: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetPendingDeletions retrieves the delete requests of the pending slugs of deletion jobs, oldest job first.
+func (sr *PostgresRepository) GetPendingDeletions(ctx context.Context) ([]DeleteRequest, error) {
+	getPendingDeletionsQuery := `
+	SELECT i.slug, j.user_uuid, COALESCE(j.workspace_id, ''), j.source_ip, j.request_id, j.id
+	FROM deletion_job_item i
+	JOIN deletion_job j ON j.id = i.job_id
+	WHERE i.status = 'pending'
+	ORDER BY j.created_at, i.job_id, i.position;
+	`
+
+	rows, err := sr.db.QueryContext(ctx, getPendingDeletionsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending deletions: %w", err)
+	}
+	defer rows.Close()
+
+	delReqs := []DeleteRequest{}
+	for rows.Next() {
+		var dr DeleteRequest
+		if err := rows.Scan(&dr.Slug, &dr.UserID, &dr.WorkspaceID, &dr.SourceIP, &dr.RequestID, &dr.JobID); err != nil {
+			return nil, fmt.Errorf("failed to scan pending deletion: %w", err)
+		}
+		delReqs = append(delReqs, dr)
+	}
+	return delReqs, rows.Err()
+}
+
+// PruneDeletionJobs removes the deletion jobs with no pending slugs last updated before the time and returns their number.
+func (sr *PostgresRepository) PruneDeletionJobs(ctx context.Context, before time.Time) (int, error) {
+	pruneDeletionJobsQuery := `
+	DELETE FROM deletion_job j
+	WHERE j.created_at < $1
+	AND NOT EXISTS (
+		SELECT 1 FROM deletion_job_item i
+		WHERE i.job_id = j.id AND (i.status = 'pending' OR i.updated_at >= $1)
+	);
+	`
+
+	result, err := sr.db.ExecContext(ctx, pruneDeletionJobsQuery, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune deletion jobs: %w", err)
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pruned deletion jobs: %w", err)
+	}
+	return int(pruned), nil
+}
+
+// scanWebhook scans a webhook row.
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var webhook Webhook
+	var events string
+	if err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret, &events, &webhook.ClickThreshold, &webhook.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, err
+		}
+		return Webhook{}, fmt.Errorf("failed to scan webhook: %w", err)
+	}
+	if events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+	return webhook, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, so that it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// nullLimit converts a zero limit into a NULL LIMIT value, which does not limit the results.
+func nullLimit(limit int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(limit), Valid: limit > 0}
+}
+
+// nullString converts an empty string into a NULL column value.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAPIKey scans an api_key row into an APIKey.
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.UserID, &key.Label, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return APIKey{}, err
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+// requireAffectedAPIKey returns ErrAPIKeyNotExist if the statement did not affect any API key.
+func requireAffectedAPIKey(result sql.Result) error {
+	return requireAffected(result, ErrAPIKeyNotExist)
+}
+
+// requireAffected returns notExistErr if the statement did not affect any row.
+func requireAffected(result sql.Result, notExistErr error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notExistErr
+	}
+	return nil
+}
+
+// encodeSchedule converts a schedule into a JSONB column value, returning nil for a missing schedule.
+func encodeSchedule(schedule *Schedule) (any, error) {
+	if schedule == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schedule: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeSchedule converts a nullable JSONB column value into a schedule.
+func decodeSchedule(value sql.NullString) (*Schedule, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	var schedule Schedule
+	if err := json.Unmarshal([]byte(value.String), &schedule); err != nil {
+		return nil, fmt.Errorf("failed to decode schedule: %w", err)
+	}
+	return &schedule, nil
+}
+
+// Ping checks the connection to the PostgreSQL database. It returns an error if the connection is not alive.
+func (sr *PostgresRepository) Ping(ctx context.Context) error {
+	return sr.db.PingContext(ctx)
+}
+
+// Close closes the connections to the PostgreSQL database.
+func (sr *PostgresRepository) Close() error {
+	return sr.db.Close()
 }
@@ -3,11 +3,16 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func setupMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
@@ -31,7 +36,7 @@ func TestPostgresRepository_Add(t *testing.T) {
 	}
 
 	mock.ExpectExec("INSERT INTO url").
-		WithArgs(url.Slug, url.OriginalURL, url.UserID, url.IsDeleted).
+		WithArgs(url.Slug, url.OriginalURL, url.UserID, url.IsDeleted, nil, nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := repo.Add(context.Background(), url)
@@ -69,7 +74,7 @@ func TestPostgresRepository_AddMany(t *testing.T) {
 
 	for _, u := range urls {
 		stmt.ExpectExec().
-			WithArgs(u.Slug, u.OriginalURL, u.UserID, u.IsDeleted).
+			WithArgs(u.Slug, u.OriginalURL, u.UserID, u.IsDeleted, nil, nil, nil, nil).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 	}
 
@@ -85,6 +90,42 @@ func TestPostgresRepository_AddMany(t *testing.T) {
 	}
 }
 
+func TestPostgresRepository_AddManyKeepExisting(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	urls := []URL{
+		{Slug: "new_slug", OriginalURL: "http://example.com/new", UserID: "test_user"},
+		{Slug: "dup_slug", OriginalURL: "http://example.com/stored", UserID: "test_user"},
+	}
+
+	mock.ExpectBegin()
+	stmt := mock.ExpectPrepare("INSERT INTO url")
+	stmt.ExpectExec().
+		WithArgs(urls[0].Slug, urls[0].OriginalURL, urls[0].UserID, false, nil, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	stmt.ExpectExec().
+		WithArgs(urls[1].Slug, urls[1].OriginalURL, urls[1].UserID, false, nil, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT slug, user_uuid, is_deleted FROM url").
+		WithArgs(urls[1].OriginalURL).
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "user_uuid", "is_deleted"}).AddRow("stored_slug", "other_user", false))
+	mock.ExpectCommit()
+
+	existing, err := repo.AddManyKeepExisting(context.Background(), urls)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(existing) != 1 || existing[urls[1].OriginalURL].Slug != "stored_slug" {
+		t.Errorf("unexpected existing URLs: %v", existing)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestPostgresRepository_GetBySlug(t *testing.T) {
 	db, mock := setupMockDB(t)
 	defer db.Close()
@@ -98,10 +139,10 @@ func TestPostgresRepository_GetBySlug(t *testing.T) {
 		IsDeleted:   false,
 	}
 
-	rows := sqlmock.NewRows([]string{"slug", "original_url", "user_uuid", "is_deleted"}).
-		AddRow(expectedURL.Slug, expectedURL.OriginalURL, expectedURL.UserID, expectedURL.IsDeleted)
+	rows := sqlmock.NewRows([]string{"slug", "original_url", "user_uuid", "is_deleted", "not_before", "not_after", "schedule", "workspace_id", "is_disabled"}).
+		AddRow(expectedURL.Slug, expectedURL.OriginalURL, expectedURL.UserID, expectedURL.IsDeleted, nil, nil, nil, nil, false)
 
-	mock.ExpectQuery("SELECT slug, original_url, user_uuid, is_deleted").
+	mock.ExpectQuery("SELECT slug, original_url, user_uuid, is_deleted, not_before, not_after, schedule").
 		WithArgs(slug).
 		WillReturnRows(rows)
 
@@ -218,6 +259,21 @@ func TestPostgresRepository_Ping(t *testing.T) {
 	}
 }
 
+func TestPostgresRepository_Close(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	repo := PostgresRepository{db: db}
+	mock.ExpectClose()
+
+	if err := repo.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestPostgresRepository_DeleteMany(t *testing.T) {
 	db, mock := setupMockDB(t)
 	defer db.Close()
@@ -237,18 +293,22 @@ func TestPostgresRepository_DeleteMany(t *testing.T) {
 	mock.ExpectBegin()
 	stmt := mock.ExpectPrepare("UPDATE url")
 
-	for _, dr := range delReqs {
-		stmt.ExpectExec().
-			WithArgs(dr.Slug, dr.UserID).
-			WillReturnResult(sqlmock.NewResult(1, 1))
-	}
+	stmt.ExpectExec().
+		WithArgs(delReqs[0].Slug, delReqs[0].UserID, delReqs[0].WorkspaceID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	stmt.ExpectExec().
+		WithArgs(delReqs[1].Slug, delReqs[1].UserID, delReqs[1].WorkspaceID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	mock.ExpectCommit()
 
-	err := repo.DeleteMany(context.Background(), delReqs)
+	deleted, err := repo.DeleteMany(context.Background(), delReqs)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	if len(deleted) != 1 || deleted[0] != delReqs[0] {
+		t.Errorf("expected only the first request to match, got: %+v", deleted)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -291,3 +351,549 @@ func TestPostgresRepository_GetServiceStats(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestPostgresRepository_AddAPIKey(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	createdAt := time.Now()
+	key := APIKey{
+		ID:        "key1",
+		UserID:    "test_user",
+		Label:     "ci",
+		Prefix:    "shk_abcdefgh",
+		Hash:      HashAPIKey("secret"),
+		Scopes:    []string{ScopeRead, ScopeShorten},
+		CreatedAt: createdAt,
+	}
+
+	mock.ExpectExec("INSERT INTO api_key").
+		WithArgs(key.ID, key.UserID, key.Label, key.Prefix, key.Hash, "read,shorten", createdAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := repo.AddAPIKey(context.Background(), key); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_GetAPIKeyByHash(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	createdAt := time.Now()
+	hash := HashAPIKey("secret")
+
+	rows := sqlmock.NewRows([]string{"id", "user_uuid", "label", "prefix", "key_hash", "scopes", "created_at", "last_used_at", "revoked_at"}).
+		AddRow("key1", "test_user", "ci", "shk_abcdefgh", hash, "delete", createdAt, nil, createdAt)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM api_key")).WithArgs(hash).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM api_key")).WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+
+	key, err := repo.GetAPIKeyByHash(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key.ID != "key1" || !reflect.DeepEqual(key.Scopes, []string{ScopeDelete}) || key.LastUsedAt != nil || !key.IsRevoked() {
+		t.Errorf("unexpected key: %+v", key)
+	}
+
+	if _, err := repo.GetAPIKeyByHash(context.Background(), "unknown"); !errors.Is(err, ErrAPIKeyNotExist) {
+		t.Errorf("expected error %v, got %v", ErrAPIKeyNotExist, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_RevokeAPIKey(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	revokedAt := time.Now()
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE api_key")).
+		WithArgs("key1", "test_user", revokedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE api_key")).
+		WithArgs("key1", "other_user", revokedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.RevokeAPIKey(context.Background(), "test_user", "key1", revokedAt); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := repo.RevokeAPIKey(context.Background(), "other_user", "key1", revokedAt); !errors.Is(err, ErrAPIKeyNotExist) {
+		t.Errorf("expected error %v, got %v", ErrAPIKeyNotExist, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_GetUserByLogin(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	createdAt := time.Now()
+
+	rows := sqlmock.NewRows([]string{"id", "email", "username", "password_hash", "created_at"}).
+		AddRow("test_user", nil, "alice", "hash", createdAt)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users")).WithArgs("alice").WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users")).WithArgs("nobody").WillReturnError(sql.ErrNoRows)
+
+	user, err := repo.GetUserByLogin(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != "test_user" || user.Email != "" || user.Username != "alice" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	if _, err := repo.GetUserByLogin(context.Background(), "nobody"); !errors.Is(err, ErrUserNotExist) {
+		t.Errorf("expected error %v, got %v", ErrUserNotExist, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_MergeUser(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE url")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE api_key")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The account is raised to the anonymous user's role before the duplicate membership is dropped.
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE workspace_member t\n\tSET role = f.role")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM workspace_member")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE workspace_member")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE abuse_report")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO blocked_user")).
+		WithArgs("anonymous", "account").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.MergeUser(context.Background(), "anonymous", "account"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_RevokeSession(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	session := RevokedSession{SessionID: "session", UserID: "test_user", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: time.Now()}
+
+	mock.ExpectExec("INSERT INTO revoked_session").
+		WithArgs(session.SessionID, session.UserID, session.ExpiresAt, session.RevokedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM revoked_session").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM revoked_session")).WithArgs("session").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	if err := repo.RevokeSession(context.Background(), session); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	revoked, err := repo.IsSessionRevoked(context.Background(), "session")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !revoked {
+		t.Errorf("expected session to be revoked")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_AcceptWorkspaceInvitation(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	acceptedAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE workspace_invitation")).
+		WithArgs("hash", "test_user", acceptedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_id", "role"}).AddRow("workspace", RoleEditor))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO workspace_member")).
+		WithArgs("workspace", "test_user", RoleEditor, acceptedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE workspace_invitation")).
+		WithArgs("used", "test_user", acceptedAt).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	member, err := repo.AcceptWorkspaceInvitation(context.Background(), "hash", "test_user", acceptedAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if member.WorkspaceID != "workspace" || member.Role != RoleEditor {
+		t.Errorf("unexpected member: %+v", member)
+	}
+
+	if _, err := repo.AcceptWorkspaceInvitation(context.Background(), "used", "test_user", acceptedAt); !errors.Is(err, ErrWorkspaceInvitationNotExist) {
+		t.Errorf("expected error %v, got %v", ErrWorkspaceInvitationNotExist, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_UpdateWorkspaceMemberRole(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE workspace_member")).
+		WithArgs("workspace", "test_user", RoleViewer).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE workspace_member")).
+		WithArgs("workspace", "stranger", RoleViewer).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.UpdateWorkspaceMemberRole(context.Background(), "workspace", "test_user", RoleViewer); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := repo.UpdateWorkspaceMemberRole(context.Background(), "workspace", "stranger", RoleViewer); !errors.Is(err, ErrWorkspaceMemberNotExist) {
+		t.Errorf("expected error %v, got %v", ErrWorkspaceMemberNotExist, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_SearchURLs(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM url")).
+		WithArgs("", `100\%`, "test_user", sql.NullInt64{Int64: 10, Valid: true}, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "original_url", "user_uuid", "workspace_id", "is_deleted", "is_disabled"}).
+			AddRow("test_slug", "http://example.com/100%", "test_user", nil, false, true))
+
+	urls, err := repo.SearchURLs(context.Background(), URLFilter{OriginalURL: "100%", UserID: "test_user", Limit: 10, Offset: 20})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(urls) != 1 || urls[0].Slug != "test_slug" || !urls[0].IsDisabled {
+		t.Errorf("unexpected URLs: %+v", urls)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_UnblockUser(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM blocked_user")).
+		WithArgs("test_user").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM blocked_user")).
+		WithArgs("stranger").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.UnblockUser(context.Background(), "test_user"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := repo.UnblockUser(context.Background(), "stranger"); !errors.Is(err, ErrBlockedUserNotExist) {
+		t.Errorf("expected error %v, got %v", ErrBlockedUserNotExist, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_AddAbuseReport(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	report := AbuseReport{ID: "report", Slug: "test_slug", OwnerID: "owner", Reason: "phishing", ReporterID: "reporter", CreatedAt: time.Now(), Status: ReportStatusOpen}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO abuse_report")).
+		WithArgs(report.ID, report.Slug, report.OwnerID, report.Reason, report.Details, report.ReporterID, report.CreatedAt, report.Status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO abuse_report")).
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
+
+	if err := repo.AddAbuseReport(context.Background(), report); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := repo.AddAbuseReport(context.Background(), report); !errors.Is(err, ErrAbuseReportDuplicate) {
+		t.Errorf("expected error %v, got %v", ErrAbuseReportDuplicate, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_TakeDownURL(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	takedown := URLTakedown{Slug: "test_slug", TakenDownBy: "admin", TakenDownAt: time.Now()}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO url_takedown")).
+		WithArgs(takedown.Slug, takedown.TakenDownBy, takedown.TakenDownAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM url_takedown WHERE slug = $1)")).
+		WithArgs("test_slug").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	if err := repo.TakeDownURL(context.Background(), takedown); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if takenDown, err := repo.IsURLTakenDown(context.Background(), "test_slug"); err != nil || !takenDown {
+		t.Errorf("expected test_slug to be taken down, got %v (error: %v)", takenDown, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_AuditLog(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	event := AuditEvent{ID: "event", Time: time.Now(), Actor: "user", Action: AuditActionDelete, Slugs: []string{"a", "b"}, SourceIP: "192.0.2.1", RequestID: "req"}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_event")).
+		WithArgs(event.ID, event.Time, event.Actor, event.Action, "a,b", nil, nil, event.SourceIP, event.RequestID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM audit_event")).
+		WithArgs("user", "", `a\_b`, nil, nil, nil, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "actor", "action", "slugs", "workspace_id", "target_uuid", "source_ip", "request_id"}).
+			AddRow(event.ID, event.Time, event.Actor, event.Action, "a,b", nil, nil, event.SourceIP, event.RequestID))
+
+	if err := repo.AddAuditEvent(context.Background(), event); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	events, err := repo.GetAuditEvents(context.Background(), AuditFilter{Actor: "user", Slug: "a_b"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || !reflect.DeepEqual(events[0], event) {
+		t.Errorf("expected %+v, got %+v", event, events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_Webhooks(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	now := time.Now()
+	delivery := WebhookDelivery{ID: "d1", WebhookID: "w1", Event: "link.created", Payload: []byte(`{"id":"d1"}`), Status: DeliveryStatusPending, NextAttemptAt: now, CreatedAt: now, UpdatedAt: now}
+	columns := []string{"id", "webhook_id", "event", "payload", "status", "attempts", "next_attempt_at", "last_status_code", "last_error", "created_at", "updated_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE url")).
+		ExpectQuery().
+		WithArgs("slug", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"clicks"}).AddRow(3))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta("UPDATE url")).
+		ExpectQuery().
+		WithArgs("missing", 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM webhook")).
+		WithArgs("w1", "other").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM webhook_delivery")).
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(delivery.ID, delivery.WebhookID, delivery.Event, []byte(delivery.Payload), delivery.Status, 0, now, 0, "", now, now))
+
+	if totals, err := repo.AddClicks(context.Background(), map[string]int{"slug": 2}); err != nil || totals["slug"] != 3 {
+		t.Errorf("expected 3 clicks, got %v, %v", totals, err)
+	}
+	if totals, err := repo.AddClicks(context.Background(), map[string]int{"missing": 1}); err != nil || len(totals) != 0 {
+		t.Errorf("expected missing slugs to be left out, got %v, %v", totals, err)
+	}
+	if err := repo.DeleteWebhook(context.Background(), "other", "w1"); !errors.Is(err, ErrWebhookNotExist) {
+		t.Errorf("expected ErrWebhookNotExist, got %v", err)
+	}
+	due, err := repo.GetDueWebhookDeliveries(context.Background(), now, 10)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(due) != 1 || !reflect.DeepEqual(due[0], delivery) {
+		t.Errorf("expected %+v, got %+v", delivery, due)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_ExpiryCheckpoint(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM webhook_checkpoint")).
+		WithArgs("link.expired").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_checkpoint")).
+		WithArgs("link.expired", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM webhook_checkpoint")).
+		WithArgs("link.expired").
+		WillReturnRows(sqlmock.NewRows([]string{"checked_at"}).AddRow(now))
+
+	if checkpoint, err := repo.GetExpiryCheckpoint(context.Background()); err != nil || !checkpoint.IsZero() {
+		t.Errorf("expected no checkpoint, got %v, %v", checkpoint, err)
+	}
+	if err := repo.SetExpiryCheckpoint(context.Background(), now); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if checkpoint, err := repo.GetExpiryCheckpoint(context.Background()); err != nil || !checkpoint.Equal(now) {
+		t.Errorf("expected checkpoint %v, got %v, %v", now, checkpoint, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_DeletionJobs(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	now := time.Now()
+	job := DeletionJob{
+		ID:        "j1",
+		UserID:    "user",
+		SourceIP:  "192.0.2.1",
+		RequestID: "req-1",
+		CreatedAt: now,
+		Items:     []DeletionJobItem{{JobID: "j1", Slug: "a", Status: DeletionStatusPending, UpdatedAt: now}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO deletion_job\n")).
+		WithArgs(job.ID, job.UserID, job.WorkspaceID, job.SourceIP, job.RequestID, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO deletion_job_item")).
+		WithArgs(job.ID, 0, "a", DeletionStatusPending, "", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM deletion_job\n")).
+		WithArgs("j1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_uuid", "workspace_id", "source_ip", "request_id", "created_at"}).AddRow("j1", "user", "", "192.0.2.1", "req-1", now))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM deletion_job_item")).
+		WithArgs("j1").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "slug", "status", "reason", "updated_at"}).AddRow("j1", "a", DeletionStatusPending, "", now))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM deletion_job\n")).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM deletion_job_item i")).
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "user_uuid", "workspace_id", "source_ip", "request_id", "id"}).AddRow("a", "user", "", "192.0.2.1", "req-1", "j1"))
+
+	if err := repo.AddDeletionJob(context.Background(), job); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	got, err := repo.GetDeletionJob(context.Background(), "j1")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, job) {
+		t.Errorf("expected %+v, got %+v", job, got)
+	}
+	if _, err := repo.GetDeletionJob(context.Background(), "missing"); !errors.Is(err, ErrDeletionJobNotExist) {
+		t.Errorf("expected ErrDeletionJobNotExist, got %v", err)
+	}
+	pending, err := repo.GetPendingDeletions(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if expected := []DeleteRequest{{Slug: "a", UserID: "user", SourceIP: "192.0.2.1", RequestID: "req-1", JobID: "j1"}}; !reflect.DeepEqual(pending, expected) {
+		t.Errorf("expected %+v, got %+v", expected, pending)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRepository_PruneDeletionJobs(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	repo := PostgresRepository{db: db}
+	before := time.Now()
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM deletion_job j")).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if pruned, err := repo.PruneDeletionJobs(context.Background(), before); err != nil || pruned != 3 {
+		t.Errorf("expected 3 pruned jobs, got %d (error: %v)", pruned, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,176 @@
+// Package repository provides the abuse report, flagged user and URL takedown entities of the moderation workflow.
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAbuseReportDuplicate is returned when a reporter already has an open report on a URL.
+var ErrAbuseReportDuplicate = errors.New("abuse report already exists")
+
+// Abuse report statuses.
+const (
+	// ReportStatusOpen marks a report waiting in the moderation queue.
+	ReportStatusOpen = "open"
+	// ReportStatusDismissed marks a report reviewed without action.
+	ReportStatusDismissed = "dismissed"
+	// ReportStatusTakenDown marks a report that led to the URL being taken down.
+	ReportStatusTakenDown = "taken_down"
+)
+
+// AbuseReport represents a report of a URL leading to abusive content.
+// Reports are kept after review as a record of moderation decisions.
+type AbuseReport struct {
+	// ID is the unique identifier of the report.
+	ID string `json:"id"`
+	// Slug is the slug of the reported URL.
+	Slug string `json:"slug"`
+	// OwnerID is the ID of the user who owned the URL when it was reported.
+	OwnerID string `json:"ownerID"`
+	// Reason is the report category, e.g. "phishing".
+	Reason string `json:"reason"`
+	// Details is the optional free-text description given by the reporter.
+	Details string `json:"details,omitempty"`
+	// ReporterID identifies who filed the report: the ID of a registered account,
+	// or "ip:" followed by the client IP address for anonymous reporters.
+	ReporterID string `json:"reporterID"`
+	// CreatedAt is the time the report was filed.
+	CreatedAt time.Time `json:"createdAt"`
+	// Status is the review status: open, dismissed or taken_down.
+	Status string `json:"status"`
+	// ReviewedBy is the ID of the operator who reviewed the report.
+	ReviewedBy string `json:"reviewedBy,omitempty"`
+	// ReviewedAt is the time the report was reviewed.
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+}
+
+// AbuseStats summarizes the abuse reports against the URLs of a user.
+type AbuseStats struct {
+	// Reports is the number of open or upheld reports against the user's URLs.
+	Reports int
+	// TakenDown is the number of the user's URLs taken down after a report.
+	TakenDown int
+}
+
+// FlaggedUser represents a user flagged as a repeat offender for operators to review.
+type FlaggedUser struct {
+	// UserID is the ID of the flagged user.
+	UserID string `json:"userID"`
+	// Reason describes the threshold the user crossed.
+	Reason string `json:"reason"`
+	// FlaggedAt is the time the user was flagged.
+	FlaggedAt time.Time `json:"flaggedAt"`
+}
+
+// URLTakedown records a URL taken down by an operator after abuse reports.
+// It is kept apart from the disabled flag of the URL, so re-enabling the URL does not lift the takedown.
+type URLTakedown struct {
+	// Slug is the slug of the URL taken down.
+	Slug string `json:"slug"`
+	// TakenDownBy is the ID of the operator who took the URL down.
+	TakenDownBy string `json:"takenDownBy"`
+	// TakenDownAt is the time the URL was taken down.
+	TakenDownAt time.Time `json:"takenDownAt"`
+}
+
+// moderationStore keeps the abuse reports and flagged users of the memory and file repositories.
+// Callers synchronize access to it.
+type moderationStore struct {
+	// Reports is a slice of abuse reports.
+	Reports []AbuseReport `json:"reports"`
+	// Flagged is a slice of flagged users.
+	Flagged []FlaggedUser `json:"flagged"`
+	// Takedowns is a slice of URL takedowns.
+	Takedowns []URLTakedown `json:"takedowns"`
+}
+
+// addReport adds a report unless the reporter already has an open report on the URL.
+func (s *moderationStore) addReport(report AbuseReport) error {
+	for _, r := range s.Reports {
+		if r.Slug == report.Slug && r.ReporterID == report.ReporterID && r.Status == ReportStatusOpen {
+			return ErrAbuseReportDuplicate
+		}
+	}
+	s.Reports = append(s.Reports, report)
+	return nil
+}
+
+// reports returns the reports with the status, or all reports for an empty status, oldest first.
+func (s *moderationStore) reports(status string) []AbuseReport {
+	reports := []AbuseReport{}
+	for _, r := range s.Reports {
+		if status == "" || r.Status == status {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+// resolveReports sets the status of the open reports on a URL and returns their number.
+func (s *moderationStore) resolveReports(slug string, status string, reviewedBy string, reviewedAt time.Time) int {
+	resolved := 0
+	for i, r := range s.Reports {
+		if r.Slug == slug && r.Status == ReportStatusOpen {
+			s.Reports[i].Status = status
+			s.Reports[i].ReviewedBy = reviewedBy
+			s.Reports[i].ReviewedAt = &reviewedAt
+			resolved++
+		}
+	}
+	return resolved
+}
+
+// stats returns the abuse stats of a URL owner.
+func (s *moderationStore) stats(ownerID string) AbuseStats {
+	var stats AbuseStats
+	takenDown := make(map[string]bool)
+	for _, r := range s.Reports {
+		if r.OwnerID != ownerID || r.Status == ReportStatusDismissed {
+			continue
+		}
+		stats.Reports++
+		if r.Status == ReportStatusTakenDown {
+			takenDown[r.Slug] = true
+		}
+	}
+	stats.TakenDown = len(takenDown)
+	return stats
+}
+
+// flag flags a user unless they already are flagged.
+func (s *moderationStore) flag(user FlaggedUser) {
+	for _, f := range s.Flagged {
+		if f.UserID == user.UserID {
+			return
+		}
+	}
+	s.Flagged = append(s.Flagged, user)
+}
+
+// takeDown records a URL takedown unless the URL already is taken down.
+func (s *moderationStore) takeDown(takedown URLTakedown) {
+	if s.isTakenDown(takedown.Slug) {
+		return
+	}
+	s.Takedowns = append(s.Takedowns, takedown)
+}
+
+// isTakenDown reports whether a URL is taken down.
+func (s *moderationStore) isTakenDown(slug string) bool {
+	for _, t := range s.Takedowns {
+		if t.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeUser transfers the reports against the URLs of one user ID to another.
+func (s *moderationStore) mergeUser(fromUserID string, toUserID string) {
+	for i, r := range s.Reports {
+		if r.OwnerID == fromUserID {
+			s.Reports[i].OwnerID = toUserID
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAbuseReportRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testAbuseReportLifecycle(t, repo)
+		})
+	}
+
+	t.Run("file reports survive reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		if reports, _ := reloaded.GetAbuseReports(context.Background(), ReportStatusTakenDown); len(reports) != 2 {
+			t.Errorf("Expected 2 taken down reports, got: %+v", reports)
+		}
+		if flagged, _ := reloaded.GetFlaggedUsers(context.Background()); len(flagged) != 1 {
+			t.Errorf("Expected 1 flagged user, got: %+v", flagged)
+		}
+		if takenDown, _ := reloaded.IsURLTakenDown(context.Background(), "bad"); !takenDown {
+			t.Error("Expected the takedown to survive reload")
+		}
+	})
+}
+
+func testAbuseReportLifecycle(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	now := time.Now()
+	reports := []AbuseReport{
+		{ID: "1", Slug: "bad", OwnerID: "anonymous", Reason: "phishing", ReporterID: "r1", CreatedAt: now, Status: ReportStatusOpen},
+		{ID: "2", Slug: "bad", OwnerID: "anonymous", Reason: "malware", ReporterID: "r2", CreatedAt: now, Status: ReportStatusOpen},
+		{ID: "3", Slug: "fine", OwnerID: "anonymous", Reason: "spam", ReporterID: "r1", CreatedAt: now, Status: ReportStatusOpen},
+	}
+	for _, r := range reports {
+		if err := repo.AddAbuseReport(ctx, r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	duplicate := AbuseReport{ID: "4", Slug: "bad", OwnerID: "anonymous", ReporterID: "r1", Status: ReportStatusOpen}
+	if err := repo.AddAbuseReport(ctx, duplicate); !errors.Is(err, ErrAbuseReportDuplicate) {
+		t.Errorf("Expected error: %v, got: %v", ErrAbuseReportDuplicate, err)
+	}
+
+	if resolved, err := repo.ResolveAbuseReports(ctx, "bad", ReportStatusTakenDown, "admin", now); err != nil || resolved != 2 {
+		t.Errorf("Expected 2 resolved reports, got: %d (error: %v)", resolved, err)
+	}
+	if resolved, err := repo.ResolveAbuseReports(ctx, "fine", ReportStatusDismissed, "admin", now); err != nil || resolved != 1 {
+		t.Errorf("Expected 1 resolved report, got: %d (error: %v)", resolved, err)
+	}
+	if open, _ := repo.GetAbuseReports(ctx, ReportStatusOpen); len(open) != 0 {
+		t.Errorf("Expected empty queue, got: %+v", open)
+	}
+	if all, _ := repo.GetAbuseReports(ctx, ""); len(all) != 3 || all[0].ReviewedBy != "admin" || all[0].ReviewedAt == nil {
+		t.Errorf("Expected reviewed reports to be kept, got: %+v", all)
+	}
+
+	if err := repo.MergeUser(ctx, "anonymous", "account"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stats, err := repo.GetAbuseStats(ctx, "account")
+	if err != nil || stats != (AbuseStats{Reports: 2, TakenDown: 1}) {
+		t.Errorf("Unexpected stats: %+v (error: %v)", stats, err)
+	}
+
+	for _, reason := range []string{"first", "second"} {
+		if err := repo.FlagUser(ctx, FlaggedUser{UserID: "account", Reason: reason, FlaggedAt: now}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if flagged, _ := repo.GetFlaggedUsers(ctx); len(flagged) != 1 || flagged[0].Reason != "first" {
+		t.Errorf("Expected the first flag to be kept, got: %+v", flagged)
+	}
+
+	for _, by := range []string{"admin", "other"} {
+		if err := repo.TakeDownURL(ctx, URLTakedown{Slug: "bad", TakenDownBy: by, TakenDownAt: now}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if takenDown, err := repo.IsURLTakenDown(ctx, "bad"); err != nil || !takenDown {
+		t.Errorf("Expected bad to be taken down, got: %v (error: %v)", takenDown, err)
+	}
+	if takenDown, _ := repo.IsURLTakenDown(ctx, "fine"); takenDown {
+		t.Error("Expected fine not to be taken down")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/gennadis/shorturl/internal/app/config"
 )
@@ -24,6 +25,22 @@ type DeleteRequest struct {
 	Slug string
 	// UserID is the ID of the user who owns the URL.
 	UserID string
+	// WorkspaceID is the ID of the workspace the URL belongs to. Empty for the user's own URLs.
+	WorkspaceID string
+	// SourceIP is the IP address of the client that requested the deletion, recorded in the audit log.
+	SourceIP string
+	// RequestID is the ID of the HTTP request that requested the deletion, recorded in the audit log.
+	RequestID string
+	// JobID is the ID of the deletion job tracking the request. Empty for untracked deletions.
+	JobID string
+}
+
+// matches reports whether the request targets the URL: workspace URLs by their workspace, other URLs by their owner.
+func (dr DeleteRequest) matches(u URL) bool {
+	if u.Slug != dr.Slug || u.WorkspaceID != dr.WorkspaceID {
+		return false
+	}
+	return dr.WorkspaceID != "" || u.UserID == dr.UserID
 }
 
 // URL represents a shortened URL entity.
@@ -32,10 +49,41 @@ type URL struct {
 	Slug string `json:"slug"`
 	// OriginalURL is the original long URL.
 	OriginalURL string `json:"originalURL"`
-	// UserID is the ID of the user who owns the URL.
+	// UserID is the ID of the user who owns the URL. For workspace URLs, it is the ID of the member who created it.
 	UserID string `json:"userID"`
+	// WorkspaceID is the optional ID of the workspace the URL belongs to.
+	WorkspaceID string `json:"workspaceID,omitempty"`
 	// IsDeleted indicates if the URL is marked as deleted.
 	IsDeleted bool `json:"isDeleted"`
+	// IsDisabled indicates if the URL was disabled by an operator.
+	IsDisabled bool `json:"isDisabled,omitempty"`
+	// NotBefore is the optional time before which the URL does not resolve.
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	// NotAfter is the optional time from which the URL no longer resolves.
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+	// Schedule is the optional recurring weekly schedule the URL resolves within.
+	Schedule *Schedule `json:"schedule,omitempty"`
+	// Clicks is the number of times the URL was followed.
+	Clicks int `json:"clicks,omitempty"`
+}
+
+// addKeepingExisting appends the URLs whose original URL is not stored yet, including by earlier URLs of the batch.
+// It returns the updated URLs and the stored URLs of the others, keyed by original URL.
+func addKeepingExisting(stored []URL, urls []URL) ([]URL, map[string]URL) {
+	byOriginalURL := make(map[string]URL, len(stored))
+	for _, u := range stored {
+		byOriginalURL[u.OriginalURL] = u
+	}
+	existing := make(map[string]URL)
+	for _, u := range urls {
+		if storedURL, ok := byOriginalURL[u.OriginalURL]; ok {
+			existing[u.OriginalURL] = storedURL
+			continue
+		}
+		byOriginalURL[u.OriginalURL] = u
+		stored = append(stored, u)
+	}
+	return stored, existing
 }
 
 // NewURL creates a new URL instance.
@@ -54,18 +102,152 @@ type IRepository interface {
 	Add(ctx context.Context, url URL) error
 	// AddMany adds multiple URLs to the repository.
 	AddMany(ctx context.Context, urls []URL) error
+	// AddManyKeepExisting adds the URLs whose original URL is not stored yet and returns the stored URLs of the others.
+	AddManyKeepExisting(ctx context.Context, urls []URL) (existing map[string]URL, err error)
 	// GetBySlug retrieves a URL by its slug.
 	GetBySlug(ctx context.Context, slug string) (URL, error)
-	// GetByUser retrieves URLs associated with a user.
+	// GetByUser retrieves URLs associated with a user, excluding workspace URLs.
 	GetByUser(ctx context.Context, userID string) ([]URL, error)
+	// GetByWorkspace retrieves URLs belonging to a workspace.
+	GetByWorkspace(ctx context.Context, workspaceID string) ([]URL, error)
 	// GetByOriginalURL retrieves a URL by its original URL.
 	GetByOriginalURL(ctx context.Context, originalURL string) (URL, error)
 	// GetServiceStats retrieves Service stats: URLs and users count.
 	GetServiceStats(ctx context.Context) (urlsCount int, usersCount int, err error)
-	// DeleteMany marks multiple URLs as deleted.
-	DeleteMany(ctx context.Context, delReqs []DeleteRequest) error
+	// UpdateOriginalURL changes the original URL a slug points to.
+	UpdateOriginalURL(ctx context.Context, slug string, originalURL string) error
+	// DeleteMany marks multiple URLs as deleted and returns the requests that matched a URL.
+	// Workspace URLs are only matched by requests for their workspace.
+	DeleteMany(ctx context.Context, delReqs []DeleteRequest) (deleted []DeleteRequest, err error)
 	// Ping checks the connection to the repository.
 	Ping(ctx context.Context) error
+	// Close releases the resources of the repository. It must not be used afterwards.
+	Close() error
+
+	// AddAPIKey adds a new API key to the repository.
+	AddAPIKey(ctx context.Context, key APIKey) error
+	// GetAPIKeysByUser retrieves the API keys owned by a user, including revoked ones.
+	GetAPIKeysByUser(ctx context.Context, userID string) ([]APIKey, error)
+	// GetAPIKeyByHash retrieves an API key by the hash of the key.
+	GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	// UpdateAPIKeyLabel changes the label of an API key owned by a user.
+	UpdateAPIKeyLabel(ctx context.Context, userID string, keyID string, label string) error
+	// RevokeAPIKey marks an API key owned by a user as revoked.
+	RevokeAPIKey(ctx context.Context, userID string, keyID string, revokedAt time.Time) error
+	// TouchAPIKey records the time an API key was last used.
+	TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error
+
+	// AddUser adds a new user account to the repository.
+	AddUser(ctx context.Context, user User) error
+	// GetUserByID retrieves a user account by its ID.
+	GetUserByID(ctx context.Context, userID string) (User, error)
+	// GetUserByLogin retrieves a user account by its email or username.
+	GetUserByLogin(ctx context.Context, login string) (User, error)
+	// MergeUser transfers the URLs, API keys, webhooks, workspace memberships and abuse reports of one user ID to another.
+	// A block of the user is carried over, so its links stay taken down.
+	MergeUser(ctx context.Context, fromUserID string, toUserID string) error
+
+	// RevokeSession records a session as revoked until its expiry.
+	RevokeSession(ctx context.Context, session RevokedSession) error
+	// IsSessionRevoked reports whether a session was revoked.
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+
+	// AddWorkspace adds a new workspace together with its first owner.
+	AddWorkspace(ctx context.Context, workspace Workspace, owner WorkspaceMember) error
+	// GetWorkspacesByUser retrieves the workspaces a user is a member of, with the user's role.
+	GetWorkspacesByUser(ctx context.Context, userID string) ([]WorkspaceMembership, error)
+	// GetWorkspaceMember retrieves the membership of a user in a workspace.
+	GetWorkspaceMember(ctx context.Context, workspaceID string, userID string) (WorkspaceMember, error)
+	// GetWorkspaceMembers retrieves the members of a workspace.
+	GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error)
+	// UpdateWorkspaceMemberRole changes the role of a workspace member.
+	UpdateWorkspaceMemberRole(ctx context.Context, workspaceID string, userID string, role string) error
+	// RemoveWorkspaceMember removes a member from a workspace.
+	RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error
+	// AddWorkspaceInvitation adds a new workspace invitation.
+	AddWorkspaceInvitation(ctx context.Context, invitation WorkspaceInvitation) error
+	// GetWorkspaceInvitations retrieves the invitations of a workspace, including accepted and expired ones.
+	GetWorkspaceInvitations(ctx context.Context, workspaceID string) ([]WorkspaceInvitation, error)
+	// DeleteWorkspaceInvitation deletes an invitation of a workspace.
+	DeleteWorkspaceInvitation(ctx context.Context, workspaceID string, invitationID string) error
+	// AcceptWorkspaceInvitation marks a pending invitation as accepted and adds the user to its workspace.
+	AcceptWorkspaceInvitation(ctx context.Context, tokenHash string, userID string, acceptedAt time.Time) (WorkspaceMember, error)
+
+	// SearchURLs retrieves the URLs of all users selected by the filter.
+	SearchURLs(ctx context.Context, filter URLFilter) ([]URL, error)
+	// CountURLsByUser counts the URLs selected by the filter per owner, ordered by the total count.
+	CountURLsByUser(ctx context.Context, filter URLFilter) ([]UserURLCount, error)
+	// SetURLDisabled disables or re-enables a URL.
+	SetURLDisabled(ctx context.Context, slug string, disabled bool) error
+	// BlockUser blocks a user, replacing an existing block.
+	BlockUser(ctx context.Context, user BlockedUser) error
+	// UnblockUser removes the block of a user.
+	UnblockUser(ctx context.Context, userID string) error
+	// IsUserBlocked reports whether a user is blocked.
+	IsUserBlocked(ctx context.Context, userID string) (bool, error)
+	// GetBlockedUsers retrieves all blocked users.
+	GetBlockedUsers(ctx context.Context) ([]BlockedUser, error)
+
+	// AddAbuseReport adds a new abuse report. A reporter can only have one open report per URL.
+	AddAbuseReport(ctx context.Context, report AbuseReport) error
+	// GetAbuseReports retrieves the abuse reports with the status, or all reports for an empty status, oldest first.
+	GetAbuseReports(ctx context.Context, status string) ([]AbuseReport, error)
+	// ResolveAbuseReports sets the status of the open reports on a URL and returns their number.
+	ResolveAbuseReports(ctx context.Context, slug string, status string, reviewedBy string, reviewedAt time.Time) (int, error)
+	// GetAbuseStats summarizes the abuse reports against the URLs of a user.
+	GetAbuseStats(ctx context.Context, ownerID string) (AbuseStats, error)
+	// FlagUser flags a user as a repeat offender, keeping an existing flag.
+	FlagUser(ctx context.Context, user FlaggedUser) error
+	// GetFlaggedUsers retrieves all flagged users.
+	GetFlaggedUsers(ctx context.Context) ([]FlaggedUser, error)
+	// TakeDownURL records a URL takedown, keeping an existing one.
+	TakeDownURL(ctx context.Context, takedown URLTakedown) error
+	// IsURLTakenDown reports whether a URL is taken down.
+	IsURLTakenDown(ctx context.Context, slug string) (bool, error)
+
+	// AddAuditEvent appends an event to the audit log. Recorded events are never changed.
+	AddAuditEvent(ctx context.Context, event AuditEvent) error
+	// GetAuditEvents retrieves the audit events satisfying the filter in the order they were recorded.
+	GetAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+
+	// AddClicks adds the clicks counted by slug to the URLs in a single write and returns their new counts.
+	// Slugs that do not exist are left out.
+	AddClicks(ctx context.Context, clicks map[string]int) (map[string]int, error)
+	// GetURLsExpiringBetween retrieves the URLs that are not deleted and whose activation window ends within (from, to].
+	GetURLsExpiringBetween(ctx context.Context, from time.Time, to time.Time) ([]URL, error)
+
+	// AddWebhook adds a new webhook.
+	AddWebhook(ctx context.Context, webhook Webhook) error
+	// GetWebhook retrieves a webhook by its ID.
+	GetWebhook(ctx context.Context, webhookID string) (Webhook, error)
+	// GetWebhooksByUser retrieves all webhooks registered by a user.
+	GetWebhooksByUser(ctx context.Context, userID string) ([]Webhook, error)
+	// DeleteWebhook removes a webhook of a user. Its deliveries are kept in the delivery log.
+	DeleteWebhook(ctx context.Context, userID string, webhookID string) error
+	// AddWebhookDeliveries adds new webhook deliveries.
+	AddWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+	// GetDueWebhookDeliveries retrieves up to limit pending deliveries due at the time, oldest first.
+	GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	// UpdateWebhookDelivery saves the status and attempts of a delivery.
+	UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error
+	// GetWebhookDeliveries retrieves up to limit deliveries to a webhook, newest first. Zero returns all deliveries.
+	GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error)
+	// GetExpiryCheckpoint retrieves the time up to which the expired events of URLs were queued,
+	// or the zero time if they never were.
+	GetExpiryCheckpoint(ctx context.Context) (time.Time, error)
+	// SetExpiryCheckpoint saves the time up to which the expired events of URLs were queued.
+	SetExpiryCheckpoint(ctx context.Context, at time.Time) error
+
+	// AddDeletionJob adds a new deletion job with its pending slugs.
+	AddDeletionJob(ctx context.Context, job DeletionJob) error
+	// GetDeletionJob retrieves a deletion job by its ID.
+	GetDeletionJob(ctx context.Context, jobID string) (DeletionJob, error)
+	// UpdateDeletionJobItems saves the status of slugs of deletion jobs.
+	UpdateDeletionJobItems(ctx context.Context, items []DeletionJobItem) error
+	// GetPendingDeletions retrieves the delete requests of the pending slugs of deletion jobs, oldest job first.
+	GetPendingDeletions(ctx context.Context) ([]DeleteRequest, error)
+	// PruneDeletionJobs removes the deletion jobs with no pending slugs last updated before the time and returns their number.
+	PruneDeletionJobs(ctx context.Context, before time.Time) (int, error)
 }
 
 // NewRepository creates a new repository based on the provided configuration.
@@ -0,0 +1,123 @@
+// Package repository provides activation window and schedule handling for stored URLs.
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned when a link schedule or activation window is malformed.
+var ErrInvalidSchedule = errors.New("invalid link schedule")
+
+// weekdays maps lower-case weekday names and abbreviations to time.Weekday values.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Schedule represents a recurring weekly activation schedule of a URL.
+type Schedule struct {
+	// Weekdays are the days of week the URL is active on. Empty means every day.
+	// A window wrapping past midnight belongs to the day it starts on.
+	Weekdays []string `json:"weekdays,omitempty"`
+	// StartHour is the hour of day (0-23) the URL becomes active, inclusive.
+	StartHour int `json:"startHour"`
+	// EndHour is the hour of day (1-24) the URL stops being active, exclusive.
+	// If EndHour is less than StartHour the window wraps past midnight.
+	// If EndHour equals StartHour the URL is active all day.
+	EndHour int `json:"endHour"`
+	// TimeZone is the IANA time zone name the schedule is evaluated in. Empty means UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Validate checks that the schedule weekdays, hours and time zone are well-formed.
+func (s Schedule) Validate() error {
+	for _, d := range s.Weekdays {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("%w: unknown weekday %q", ErrInvalidSchedule, d)
+		}
+	}
+	if s.StartHour < 0 || s.StartHour > 23 {
+		return fmt.Errorf("%w: start hour %d out of range 0-23", ErrInvalidSchedule, s.StartHour)
+	}
+	if s.EndHour < 0 || s.EndHour > 24 {
+		return fmt.Errorf("%w: end hour %d out of range 0-24", ErrInvalidSchedule, s.EndHour)
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidSchedule, s.TimeZone)
+	}
+	return nil
+}
+
+// IsActiveAt reports whether the schedule allows access at the given time.
+// The hours of a window wrapping past midnight are matched against the weekday the window started on,
+// so a Friday 22:00-02:00 window is active early on Saturday but not early on Friday.
+func (s Schedule) IsActiveAt(t time.Time) bool {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	hour := local.Hour()
+
+	var inWindow bool
+	day := local.Weekday()
+	switch {
+	case s.StartHour == s.EndHour:
+		inWindow = true
+	case s.StartHour < s.EndHour:
+		inWindow = hour >= s.StartHour && hour < s.EndHour
+	case hour < s.EndHour:
+		// The window started on the previous day.
+		inWindow = true
+		day = (day + 6) % 7
+	default:
+		inWindow = hour >= s.StartHour
+	}
+	if !inWindow {
+		return false
+	}
+
+	if len(s.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range s.Weekdays {
+		if wd, ok := weekdays[strings.ToLower(d)]; ok && wd == day {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateActivation checks that the URL activation window and schedule are consistent.
+func (u URL) ValidateActivation() error {
+	if u.NotBefore != nil && u.NotAfter != nil && !u.NotBefore.Before(*u.NotAfter) {
+		return fmt.Errorf("%w: not_before must be earlier than not_after", ErrInvalidSchedule)
+	}
+	if u.Schedule != nil {
+		return u.Schedule.Validate()
+	}
+	return nil
+}
+
+// IsActiveAt reports whether the URL may be resolved at the given time,
+// taking its activation window and recurring schedule into account.
+func (u URL) IsActiveAt(t time.Time) bool {
+	if u.NotBefore != nil && t.Before(*u.NotBefore) {
+		return false
+	}
+	if u.NotAfter != nil && !t.Before(*u.NotAfter) {
+		return false
+	}
+	if u.Schedule != nil && !u.Schedule.IsActiveAt(t) {
+		return false
+	}
+	return true
+}
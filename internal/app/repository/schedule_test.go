@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedule_IsActiveAt(t *testing.T) {
+	// 2024-06-03 is a Monday.
+	monday10UTC := time.Date(2024, time.June, 3, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		schedule Schedule
+		at       time.Time
+		expected bool
+	}{
+		{
+			name:     "All day every day",
+			schedule: Schedule{},
+			at:       monday10UTC,
+			expected: true,
+		},
+		{
+			name:     "Within working hours",
+			schedule: Schedule{Weekdays: []string{"mon", "Tue"}, StartHour: 9, EndHour: 18},
+			at:       monday10UTC,
+			expected: true,
+		},
+		{
+			name:     "Outside working hours",
+			schedule: Schedule{StartHour: 11, EndHour: 18},
+			at:       monday10UTC,
+			expected: false,
+		},
+		{
+			name:     "Wrong weekday",
+			schedule: Schedule{Weekdays: []string{"saturday", "sunday"}},
+			at:       monday10UTC,
+			expected: false,
+		},
+		{
+			name:     "Window wrapping past midnight",
+			schedule: Schedule{StartHour: 22, EndHour: 11},
+			at:       monday10UTC,
+			expected: true,
+		},
+		{
+			name:     "Overnight window after midnight belongs to the previous day",
+			schedule: Schedule{Weekdays: []string{"fri"}, StartHour: 22, EndHour: 2},
+			at:       time.Date(2024, time.June, 8, 1, 0, 0, 0, time.UTC), // Saturday 01:00
+			expected: true,
+		},
+		{
+			name:     "Overnight window before midnight",
+			schedule: Schedule{Weekdays: []string{"fri"}, StartHour: 22, EndHour: 2},
+			at:       time.Date(2024, time.June, 7, 23, 0, 0, 0, time.UTC), // Friday 23:00
+			expected: true,
+		},
+		{
+			name:     "Overnight window not started the previous day",
+			schedule: Schedule{Weekdays: []string{"fri"}, StartHour: 22, EndHour: 2},
+			at:       time.Date(2024, time.June, 7, 1, 0, 0, 0, time.UTC), // Friday 01:00
+			expected: false,
+		},
+		{
+			name:     "Overnight window from Saturday into Sunday",
+			schedule: Schedule{Weekdays: []string{"sat"}, StartHour: 22, EndHour: 2},
+			at:       time.Date(2024, time.June, 9, 1, 0, 0, 0, time.UTC), // Sunday 01:00
+			expected: true,
+		},
+		{
+			name:     "Evaluated in time zone",
+			schedule: Schedule{StartHour: 9, EndHour: 12, TimeZone: "America/New_York"},
+			at:       monday10UTC,
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.schedule.IsActiveAt(tc.at); got != tc.expected {
+				t.Errorf("Expected IsActiveAt to be %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestURL_IsActiveAt(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	testCases := []struct {
+		name     string
+		url      URL
+		expected bool
+	}{
+		{
+			name:     "No activation window",
+			url:      URL{},
+			expected: true,
+		},
+		{
+			name:     "Embargoed",
+			url:      URL{NotBefore: &future},
+			expected: false,
+		},
+		{
+			name:     "Expired",
+			url:      URL{NotAfter: &past},
+			expected: false,
+		},
+		{
+			name:     "Within window",
+			url:      URL{NotBefore: &past, NotAfter: &future},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.url.IsActiveAt(now); got != tc.expected {
+				t.Errorf("Expected IsActiveAt to be %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestURL_ValidateActivation(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	testCases := []struct {
+		name        string
+		url         URL
+		expectError bool
+	}{
+		{
+			name:        "Valid window",
+			url:         URL{NotBefore: &now, NotAfter: &later},
+			expectError: false,
+		},
+		{
+			name:        "Inverted window",
+			url:         URL{NotBefore: &later, NotAfter: &now},
+			expectError: true,
+		},
+		{
+			name:        "Unknown weekday",
+			url:         URL{Schedule: &Schedule{Weekdays: []string{"someday"}}},
+			expectError: true,
+		},
+		{
+			name:        "Hour out of range",
+			url:         URL{Schedule: &Schedule{StartHour: 25}},
+			expectError: true,
+		},
+		{
+			name:        "Unknown time zone",
+			url:         URL{Schedule: &Schedule{TimeZone: "Mars/Olympus_Mons"}},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.url.ValidateActivation()
+			if tc.expectError && !errors.Is(err, ErrInvalidSchedule) {
+				t.Errorf("Expected ErrInvalidSchedule, got %v", err)
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+// Package repository provides the revoked session entity used to invalidate session cookies server-side.
+package repository
+
+import "time"
+
+// RevokedSession represents a session revoked before its expiry, e.g. by logging out.
+// It is kept until the session would have expired anyway.
+type RevokedSession struct {
+	// SessionID is the ID of the revoked session.
+	SessionID string `json:"sessionID"`
+	// UserID is the ID of the user the session belonged to.
+	UserID string `json:"userID"`
+	// ExpiresAt is the time the session would have expired.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// RevokedAt is the revocation time.
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+// pruneRevokedSessions returns the revoked sessions that have not expired yet.
+func pruneRevokedSessions(sessions []RevokedSession, now time.Time) []RevokedSession {
+	kept := sessions[:0]
+	for _, s := range sessions {
+		if s.ExpiresAt.After(now) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
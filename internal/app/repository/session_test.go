@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevokedSessionRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			sessions := []RevokedSession{
+				{SessionID: "active", UserID: "user", ExpiresAt: now.Add(time.Hour), RevokedAt: now},
+				{SessionID: "expired", UserID: "user", ExpiresAt: now.Add(-time.Hour), RevokedAt: now},
+				{SessionID: "other", UserID: "user", ExpiresAt: now.Add(time.Hour), RevokedAt: now},
+			}
+			for _, s := range sessions {
+				if err := repo.RevokeSession(ctx, s); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			expected := map[string]bool{"active": true, "other": true, "expired": false, "unknown": false}
+			for id, want := range expected {
+				revoked, err := repo.IsSessionRevoked(ctx, id)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if revoked != want {
+					t.Errorf("Expected session %q revoked: %t, got: %t", id, want, revoked)
+				}
+			}
+		})
+	}
+
+	t.Run("file revocations survive reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		if revoked, err := reloaded.IsSessionRevoked(context.Background(), "active"); err != nil || !revoked {
+			t.Errorf("Expected session to stay revoked, got: %t (error: %v)", revoked, err)
+		}
+	})
+}
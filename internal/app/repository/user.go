@@ -0,0 +1,39 @@
+// Package repository provides the registered user account entity.
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUserNotExist is returned when a user account does not exist.
+var ErrUserNotExist = errors.New("user does not exist")
+
+// ErrUserDuplicate is returned when a user account with the same ID, email or username already exists.
+var ErrUserDuplicate = errors.New("user already exists")
+
+// User represents a registered user account. Its ID is the user ID links and API keys are owned by.
+type User struct {
+	// ID is the user ID.
+	ID string `json:"id"`
+	// Email is the optional lowercase email address the user logs in with.
+	Email string `json:"email,omitempty"`
+	// Username is the optional username the user logs in with.
+	Username string `json:"username,omitempty"`
+	// PasswordHash is the password hash.
+	PasswordHash string `json:"passwordHash"`
+	// CreatedAt is the registration time.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// conflictsWith reports whether the user shares its ID, email or username with another user.
+func (u User) conflictsWith(other User) bool {
+	return u.ID == other.ID ||
+		(u.Email != "" && u.Email == other.Email) ||
+		(u.Username != "" && u.Username == other.Username)
+}
+
+// hasLogin reports whether the login is the user's email or username.
+func (u User) hasLogin(login string) bool {
+	return login != "" && (u.Email == login || u.Username == login)
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUserRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testUserLifecycle(t, repo)
+		})
+	}
+
+	t.Run("file users and merged links survive reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		if _, err := reloaded.GetUserByLogin(context.Background(), "alice"); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if urls, err := reloaded.GetByUser(context.Background(), "account"); err != nil || len(urls) != 1 {
+			t.Errorf("Expected 1 merged URL, got %d (error: %v)", len(urls), err)
+		}
+	})
+}
+
+func testUserLifecycle(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	user := User{ID: "account", Email: "alice@example.com", Username: "alice", PasswordHash: "hash", CreatedAt: time.Now()}
+	if err := repo.AddUser(ctx, user); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	duplicates := []User{
+		{ID: "account"},
+		{ID: "other", Email: "alice@example.com"},
+		{ID: "other", Username: "alice"},
+	}
+	for _, d := range duplicates {
+		if err := repo.AddUser(ctx, d); !errors.Is(err, ErrUserDuplicate) {
+			t.Errorf("Expected error: %v, got: %v", ErrUserDuplicate, err)
+		}
+	}
+	if err := repo.AddUser(ctx, User{ID: "email-only", Email: "bob@example.com"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.AddUser(ctx, User{ID: "username-only", Username: "carol"}); err != nil {
+		t.Errorf("Users without email should not conflict, got: %v", err)
+	}
+
+	for _, login := range []string{"alice@example.com", "alice"} {
+		found, err := repo.GetUserByLogin(ctx, login)
+		if err != nil || found.ID != user.ID {
+			t.Errorf("Expected user %s for login %s, got %+v (error: %v)", user.ID, login, found, err)
+		}
+	}
+	if _, err := repo.GetUserByLogin(ctx, "nobody"); !errors.Is(err, ErrUserNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrUserNotExist, err)
+	}
+	if _, err := repo.GetUserByID(ctx, "anonymous"); !errors.Is(err, ErrUserNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrUserNotExist, err)
+	}
+
+	if err := repo.Add(ctx, *NewURL("anon", "https://example.com/anon", "anonymous", false)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.AddAPIKey(ctx, APIKey{ID: "anon-key", UserID: "anonymous", Hash: HashAPIKey("anon-key")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.MergeUser(ctx, "anonymous", user.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if urls, err := repo.GetByUser(ctx, user.ID); err != nil || len(urls) != 1 {
+		t.Errorf("Expected 1 merged URL, got %d (error: %v)", len(urls), err)
+	}
+	if keys, _ := repo.GetAPIKeysByUser(ctx, user.ID); len(keys) != 1 {
+		t.Errorf("Expected 1 merged API key, got %d", len(keys))
+	}
+	if _, err := repo.GetByUser(ctx, "anonymous"); !errors.Is(err, ErrURLNotExsit) {
+		t.Errorf("Expected error: %v, got: %v", ErrURLNotExsit, err)
+	}
+}
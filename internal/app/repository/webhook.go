@@ -0,0 +1,182 @@
+// Package repository provides the webhook and webhook delivery entities.
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrWebhookNotExist is returned when a webhook does not exist or belongs to another user.
+var ErrWebhookNotExist = errors.New("webhook does not exist")
+
+// ErrWebhookDeliveryNotExist is returned when a webhook delivery does not exist.
+var ErrWebhookDeliveryNotExist = errors.New("webhook delivery does not exist")
+
+// Webhook delivery statuses.
+const (
+	// DeliveryStatusPending marks a delivery waiting for its first attempt or a retry.
+	DeliveryStatusPending = "pending"
+	// DeliveryStatusSucceeded marks a delivery acknowledged by the endpoint.
+	DeliveryStatusSucceeded = "succeeded"
+	// DeliveryStatusDead marks a delivery that failed all its attempts.
+	DeliveryStatusDead = "dead"
+)
+
+// Webhook represents an endpoint registered by a user to receive events about their URLs.
+type Webhook struct {
+	// ID is the unique identifier of the webhook.
+	ID string `json:"id"`
+	// UserID is the ID of the user who registered the webhook.
+	UserID string `json:"userID"`
+	// URL is the endpoint events are posted to.
+	URL string `json:"url"`
+	// Secret is the key deliveries are signed with.
+	Secret string `json:"secret"`
+	// Events are the events the webhook is subscribed to.
+	Events []string `json:"events"`
+	// ClickThreshold is the number of clicks on a URL that triggers the click threshold event.
+	ClickThreshold int `json:"clickThreshold,omitempty"`
+	// CreatedAt is the time the webhook was registered.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookDelivery represents an event delivery to a webhook and its attempts.
+type WebhookDelivery struct {
+	// ID is the unique identifier of the delivery.
+	ID string `json:"id"`
+	// WebhookID is the ID of the webhook the event is delivered to.
+	WebhookID string `json:"webhookID"`
+	// Event is the delivered event, e.g. "link.created".
+	Event string `json:"event"`
+	// Payload is the JSON body posted to the webhook.
+	Payload json.RawMessage `json:"payload"`
+	// Status is the delivery status: pending, succeeded or dead.
+	Status string `json:"status"`
+	// Attempts is the number of delivery attempts made.
+	Attempts int `json:"attempts"`
+	// NextAttemptAt is the time of the next attempt of a pending delivery.
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	// LastStatusCode is the HTTP status code of the last attempt, or zero if no response was received.
+	LastStatusCode int `json:"lastStatusCode,omitempty"`
+	// LastError describes the failure of the last attempt.
+	LastError string `json:"lastError,omitempty"`
+	// CreatedAt is the time the event occurred.
+	CreatedAt time.Time `json:"createdAt"`
+	// UpdatedAt is the time of the last attempt.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// webhookStore keeps the webhooks and their deliveries of the memory and file repositories.
+// Callers synchronize access to it.
+type webhookStore struct {
+	// Webhooks is a slice of registered webhooks.
+	Webhooks []Webhook `json:"webhooks"`
+	// Deliveries is a slice of webhook deliveries, oldest first.
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	// ExpiryCheckpoint is the time up to which the expired events of URLs were queued.
+	ExpiryCheckpoint time.Time `json:"expiryCheckpoint"`
+}
+
+// webhooksByUser returns the webhooks registered by a user.
+func (s *webhookStore) webhooksByUser(userID string) []Webhook {
+	webhooks := []Webhook{}
+	for _, w := range s.Webhooks {
+		if w.UserID == userID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks
+}
+
+// webhook returns a webhook by its ID.
+func (s *webhookStore) webhook(webhookID string) (Webhook, error) {
+	for _, w := range s.Webhooks {
+		if w.ID == webhookID {
+			return w, nil
+		}
+	}
+	return Webhook{}, ErrWebhookNotExist
+}
+
+// deleteWebhook removes a webhook of a user, keeping its deliveries.
+func (s *webhookStore) deleteWebhook(userID string, webhookID string) error {
+	for i, w := range s.Webhooks {
+		if w.ID == webhookID && w.UserID == userID {
+			s.Webhooks = append(s.Webhooks[:i], s.Webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return ErrWebhookNotExist
+}
+
+// mergeUser transfers the webhooks of one user ID to another. Their deliveries follow them by webhook ID.
+func (s *webhookStore) mergeUser(fromUserID string, toUserID string) {
+	for i := range s.Webhooks {
+		if s.Webhooks[i].UserID == fromUserID {
+			s.Webhooks[i].UserID = toUserID
+		}
+	}
+}
+
+// dueDeliveries returns up to limit pending deliveries due at the time, oldest first.
+func (s *webhookStore) dueDeliveries(now time.Time, limit int) []WebhookDelivery {
+	due := []WebhookDelivery{}
+	for _, d := range s.Deliveries {
+		if d.Status == DeliveryStatusPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+			if limit > 0 && len(due) == limit {
+				break
+			}
+		}
+	}
+	return due
+}
+
+// updateDelivery replaces a delivery with its updated version.
+func (s *webhookStore) updateDelivery(delivery WebhookDelivery) error {
+	for i, d := range s.Deliveries {
+		if d.ID == delivery.ID {
+			s.Deliveries[i] = delivery
+			return nil
+		}
+	}
+	return ErrWebhookDeliveryNotExist
+}
+
+// deliveries returns up to limit deliveries to a webhook, newest first.
+func (s *webhookStore) deliveries(webhookID string, limit int) []WebhookDelivery {
+	found := []WebhookDelivery{}
+	for i := len(s.Deliveries) - 1; i >= 0; i-- {
+		if s.Deliveries[i].WebhookID == webhookID {
+			found = append(found, s.Deliveries[i])
+			if limit > 0 && len(found) == limit {
+				break
+			}
+		}
+	}
+	return found
+}
+
+// addClicks adds the clicks counted by slug to the URLs and returns their new counts.
+func addClicks(urls []URL, clicks map[string]int) map[string]int {
+	totals := make(map[string]int, len(clicks))
+	for i, u := range urls {
+		if n, ok := clicks[u.Slug]; ok {
+			urls[i].Clicks += n
+			totals[u.Slug] = urls[i].Clicks
+		}
+	}
+	return totals
+}
+
+// expiringURLs returns the URLs that are not deleted and whose activation window ends within (from, to].
+func expiringURLs(urls []URL, from time.Time, to time.Time) []URL {
+	expiring := []URL{}
+	for _, u := range urls {
+		if !u.IsDeleted && u.NotAfter != nil && u.NotAfter.After(from) && !u.NotAfter.After(to) {
+			expiring = append(expiring, u)
+		}
+	}
+	return expiring
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWebhookRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testWebhooks(t, repo)
+		})
+	}
+
+	t.Run("file webhooks survive reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		if webhooks, _ := reloaded.GetWebhooksByUser(context.Background(), "user"); len(webhooks) != 1 || webhooks[0].ID != "w2" {
+			t.Errorf("Expected webhook w2, got: %+v", webhooks)
+		}
+		if deliveries, _ := reloaded.GetWebhookDeliveries(context.Background(), "w1", 0); len(deliveries) != 2 || deliveries[0].Status != DeliveryStatusSucceeded {
+			t.Errorf("Expected 2 deliveries, newest succeeded, got: %+v", deliveries)
+		}
+		if url, _ := reloaded.GetBySlug(context.Background(), "slug"); url.Clicks != 4 {
+			t.Errorf("Expected 4 clicks, got: %d", url.Clicks)
+		}
+		if checkpoint, _ := reloaded.GetExpiryCheckpoint(context.Background()); checkpoint.IsZero() {
+			t.Error("Expected the expiry checkpoint to survive reload")
+		}
+		if webhooks, _ := reloaded.GetWebhooksByUser(context.Background(), "account"); len(webhooks) != 1 || webhooks[0].ID != "w3" {
+			t.Errorf("Expected merged webhook w3, got: %+v", webhooks)
+		}
+	})
+}
+
+func testWebhooks(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, w := range []Webhook{
+		{ID: "w1", UserID: "user", URL: "https://example.com/1", Secret: "secret", Events: []string{"link.created"}, CreatedAt: now},
+		{ID: "w2", UserID: "user", URL: "https://example.com/2", Secret: "secret", Events: []string{"link.deleted"}, CreatedAt: now},
+	} {
+		if err := repo.AddWebhook(ctx, w); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := repo.DeleteWebhook(ctx, "other", "w1"); !errors.Is(err, ErrWebhookNotExist) {
+		t.Errorf("Expected ErrWebhookNotExist deleting another user's webhook, got: %v", err)
+	}
+
+	deliveries := []WebhookDelivery{
+		{ID: "d1", WebhookID: "w1", Event: "link.created", Payload: []byte(`{}`), Status: DeliveryStatusPending, NextAttemptAt: now, CreatedAt: now},
+		{ID: "d2", WebhookID: "w1", Event: "link.created", Payload: []byte(`{}`), Status: DeliveryStatusPending, NextAttemptAt: now.Add(time.Minute), CreatedAt: now},
+	}
+	if err := repo.AddWebhookDeliveries(ctx, deliveries); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if due, _ := repo.GetDueWebhookDeliveries(ctx, now, 10); len(due) != 1 || due[0].ID != "d1" {
+		t.Errorf("Expected due delivery d1, got: %+v", due)
+	}
+
+	deliveries[1].Status = DeliveryStatusSucceeded
+	deliveries[1].Attempts = 1
+	if err := repo.UpdateWebhookDelivery(ctx, deliveries[1]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.UpdateWebhookDelivery(ctx, WebhookDelivery{ID: "missing"}); !errors.Is(err, ErrWebhookDeliveryNotExist) {
+		t.Errorf("Expected ErrWebhookDeliveryNotExist, got: %v", err)
+	}
+	if due, _ := repo.GetDueWebhookDeliveries(ctx, now.Add(time.Hour), 10); len(due) != 1 || due[0].ID != "d1" {
+		t.Errorf("Expected only d1 due after d2 succeeded, got: %+v", due)
+	}
+
+	// Deliveries are kept when their webhook is removed.
+	if err := repo.DeleteWebhook(ctx, "user", "w1"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := repo.GetWebhook(ctx, "w1"); !errors.Is(err, ErrWebhookNotExist) {
+		t.Errorf("Expected ErrWebhookNotExist, got: %v", err)
+	}
+	if log, _ := repo.GetWebhookDeliveries(ctx, "w1", 0); len(log) != 2 || log[0].ID != "d2" {
+		t.Errorf("Expected deliveries d2 and d1, got: %+v", log)
+	}
+
+	notAfter := now.Add(time.Hour)
+	if err := repo.Add(ctx, URL{Slug: "slug", OriginalURL: "https://example.com", UserID: "user", NotAfter: &notAfter}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		if totals, err := repo.AddClicks(ctx, map[string]int{"slug": 2}); err != nil || totals["slug"] != 2*i {
+			t.Errorf("Expected %d clicks, got: %v, %v", 2*i, totals, err)
+		}
+	}
+	if totals, err := repo.AddClicks(ctx, map[string]int{"missing": 1}); err != nil || len(totals) != 0 {
+		t.Errorf("Expected missing slugs to be left out, got: %v, %v", totals, err)
+	}
+	if expiring, _ := repo.GetURLsExpiringBetween(ctx, now, notAfter); len(expiring) != 1 || expiring[0].Slug != "slug" {
+		t.Errorf("Expected expiring url slug, got: %+v", expiring)
+	}
+	if expiring, _ := repo.GetURLsExpiringBetween(ctx, notAfter, notAfter.Add(time.Hour)); len(expiring) != 0 {
+		t.Errorf("Expected no expiring urls, got: %+v", expiring)
+	}
+
+	if checkpoint, err := repo.GetExpiryCheckpoint(ctx); err != nil || !checkpoint.IsZero() {
+		t.Errorf("Expected no expiry checkpoint, got: %v, %v", checkpoint, err)
+	}
+	if err := repo.SetExpiryCheckpoint(ctx, now); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if checkpoint, _ := repo.GetExpiryCheckpoint(ctx); !checkpoint.Equal(now) {
+		t.Errorf("Expected expiry checkpoint %v, got: %v", now, checkpoint)
+	}
+
+	// Merging a user moves its webhooks along with their deliveries.
+	if err := repo.AddWebhook(ctx, Webhook{ID: "w3", UserID: "anonymous", URL: "https://example.com/3", Secret: "secret", Events: []string{"link.created"}, CreatedAt: now}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.AddWebhookDeliveries(ctx, []WebhookDelivery{{ID: "d3", WebhookID: "w3", Event: "link.created", Payload: []byte(`{}`), Status: DeliveryStatusPending, NextAttemptAt: now, CreatedAt: now}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.MergeUser(ctx, "anonymous", "account"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if webhooks, _ := repo.GetWebhooksByUser(ctx, "anonymous"); len(webhooks) != 0 {
+		t.Errorf("Expected no webhooks left after merge, got: %+v", webhooks)
+	}
+	if webhooks, _ := repo.GetWebhooksByUser(ctx, "account"); len(webhooks) != 1 || webhooks[0].ID != "w3" {
+		t.Errorf("Expected merged webhook w3, got: %+v", webhooks)
+	}
+	if log, _ := repo.GetWebhookDeliveries(ctx, "w3", 0); len(log) != 1 || log[0].ID != "d3" {
+		t.Errorf("Expected merged delivery d3, got: %+v", log)
+	}
+}
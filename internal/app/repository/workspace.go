@@ -0,0 +1,262 @@
+// Package repository provides the workspace entities used for shared link ownership.
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrWorkspaceMemberNotExist is returned when a user is not a member of a workspace.
+var ErrWorkspaceMemberNotExist = errors.New("workspace member does not exist")
+
+// ErrWorkspaceMemberDuplicate is returned when adding a user who already is a member of a workspace.
+var ErrWorkspaceMemberDuplicate = errors.New("workspace member already exists")
+
+// ErrWorkspaceInvitationNotExist is returned when an invitation does not exist, was already accepted or has expired.
+var ErrWorkspaceInvitationNotExist = errors.New("workspace invitation does not exist")
+
+// Workspace member roles, from the most to the least privileged.
+const (
+	// RoleOwner may manage members and invitations in addition to everything an editor can do.
+	RoleOwner = "owner"
+	// RoleEditor may create, edit and delete the workspace links.
+	RoleEditor = "editor"
+	// RoleViewer may list the workspace links and members.
+	RoleViewer = "viewer"
+)
+
+// roleRanks orders the workspace roles by privilege.
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// Workspace represents a group of users sharing the ownership of links.
+type Workspace struct {
+	// ID is the unique identifier of the workspace.
+	ID string `json:"id"`
+	// Name is the user-provided workspace name.
+	Name string `json:"name"`
+	// CreatedBy is the ID of the user who created the workspace.
+	CreatedBy string `json:"createdBy"`
+	// CreatedAt is the workspace creation time.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WorkspaceMember represents the membership of a user in a workspace.
+type WorkspaceMember struct {
+	// WorkspaceID is the ID of the workspace.
+	WorkspaceID string `json:"workspaceID"`
+	// UserID is the ID of the member.
+	UserID string `json:"userID"`
+	// Role is the member role: owner, editor or viewer.
+	Role string `json:"role"`
+	// JoinedAt is the time the user joined the workspace.
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// WorkspaceMembership is a workspace together with the role of a member in it.
+type WorkspaceMembership struct {
+	Workspace
+	// Role is the member role in the workspace.
+	Role string `json:"role"`
+}
+
+// WorkspaceInvitation represents an invitation to join a workspace. Only the hash of the invitation token is stored.
+type WorkspaceInvitation struct {
+	// ID is the unique identifier of the invitation.
+	ID string `json:"id"`
+	// WorkspaceID is the ID of the workspace the invitation is for.
+	WorkspaceID string `json:"workspaceID"`
+	// Role is the role granted on acceptance.
+	Role string `json:"role"`
+	// TokenHash is the hex-encoded SHA-256 hash of the invitation token.
+	TokenHash string `json:"tokenHash"`
+	// InvitedBy is the ID of the member who created the invitation.
+	InvitedBy string `json:"invitedBy"`
+	// CreatedAt is the invitation creation time.
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt is the time the invitation can no longer be accepted.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// AcceptedBy is the ID of the user who accepted the invitation.
+	AcceptedBy string `json:"acceptedBy,omitempty"`
+	// AcceptedAt is the time the invitation was accepted.
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+}
+
+// HashInvitationToken returns the hex-encoded SHA-256 hash an invitation token is stored and looked up by.
+func HashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsValidRole reports whether the role is a known workspace role.
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// HasRole reports whether the member role grants at least the privileges of the given role.
+func (m WorkspaceMember) HasRole(role string) bool {
+	return roleRanks[m.Role] >= roleRanks[role]
+}
+
+// isPending reports whether the invitation can still be accepted.
+func (i WorkspaceInvitation) isPending(now time.Time) bool {
+	return i.AcceptedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// workspaceStore keeps the workspaces, members and invitations of the memory and file repositories.
+// Callers synchronize access to it.
+type workspaceStore struct {
+	// Workspaces is a slice of workspaces.
+	Workspaces []Workspace `json:"workspaces"`
+	// Members is a slice of workspace memberships.
+	Members []WorkspaceMember `json:"members"`
+	// Invitations is a slice of workspace invitations.
+	Invitations []WorkspaceInvitation `json:"invitations"`
+}
+
+// add adds a workspace together with its first owner.
+func (s *workspaceStore) add(workspace Workspace, owner WorkspaceMember) {
+	s.Workspaces = append(s.Workspaces, workspace)
+	s.Members = append(s.Members, owner)
+}
+
+// membershipsOf returns the workspaces a user is a member of, with the user's role.
+func (s *workspaceStore) membershipsOf(userID string) []WorkspaceMembership {
+	memberships := []WorkspaceMembership{}
+	for _, m := range s.Members {
+		if m.UserID != userID {
+			continue
+		}
+		for _, w := range s.Workspaces {
+			if w.ID == m.WorkspaceID {
+				memberships = append(memberships, WorkspaceMembership{Workspace: w, Role: m.Role})
+			}
+		}
+	}
+	return memberships
+}
+
+// memberIndex returns the index of a membership, or -1 if the user is not a member of the workspace.
+func (s *workspaceStore) memberIndex(workspaceID string, userID string) int {
+	for i, m := range s.Members {
+		if m.WorkspaceID == workspaceID && m.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// member returns the membership of a user in a workspace.
+func (s *workspaceStore) member(workspaceID string, userID string) (WorkspaceMember, error) {
+	i := s.memberIndex(workspaceID, userID)
+	if i < 0 {
+		return WorkspaceMember{}, ErrWorkspaceMemberNotExist
+	}
+	return s.Members[i], nil
+}
+
+// members returns the members of a workspace.
+func (s *workspaceStore) members(workspaceID string) []WorkspaceMember {
+	members := []WorkspaceMember{}
+	for _, m := range s.Members {
+		if m.WorkspaceID == workspaceID {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// updateRole changes the role of a workspace member.
+func (s *workspaceStore) updateRole(workspaceID string, userID string, role string) error {
+	i := s.memberIndex(workspaceID, userID)
+	if i < 0 {
+		return ErrWorkspaceMemberNotExist
+	}
+	s.Members[i].Role = role
+	return nil
+}
+
+// removeMember removes a member from a workspace.
+func (s *workspaceStore) removeMember(workspaceID string, userID string) error {
+	i := s.memberIndex(workspaceID, userID)
+	if i < 0 {
+		return ErrWorkspaceMemberNotExist
+	}
+	s.Members = append(s.Members[:i], s.Members[i+1:]...)
+	return nil
+}
+
+// invitations returns the invitations of a workspace.
+func (s *workspaceStore) invitations(workspaceID string) []WorkspaceInvitation {
+	invitations := []WorkspaceInvitation{}
+	for _, inv := range s.Invitations {
+		if inv.WorkspaceID == workspaceID {
+			invitations = append(invitations, inv)
+		}
+	}
+	return invitations
+}
+
+// deleteInvitation deletes an invitation of a workspace.
+func (s *workspaceStore) deleteInvitation(workspaceID string, invitationID string) error {
+	for i, inv := range s.Invitations {
+		if inv.ID == invitationID && inv.WorkspaceID == workspaceID {
+			s.Invitations = append(s.Invitations[:i], s.Invitations[i+1:]...)
+			return nil
+		}
+	}
+	return ErrWorkspaceInvitationNotExist
+}
+
+// acceptInvitation marks a pending invitation as accepted and adds the user to its workspace.
+func (s *workspaceStore) acceptInvitation(tokenHash string, userID string, acceptedAt time.Time) (WorkspaceMember, error) {
+	for i, inv := range s.Invitations {
+		if inv.TokenHash != tokenHash || !inv.isPending(acceptedAt) {
+			continue
+		}
+		if s.memberIndex(inv.WorkspaceID, userID) >= 0 {
+			return WorkspaceMember{}, ErrWorkspaceMemberDuplicate
+		}
+		member := WorkspaceMember{WorkspaceID: inv.WorkspaceID, UserID: userID, Role: inv.Role, JoinedAt: acceptedAt}
+		s.Invitations[i].AcceptedBy = userID
+		s.Invitations[i].AcceptedAt = &acceptedAt
+		s.Members = append(s.Members, member)
+		return member, nil
+	}
+	return WorkspaceMember{}, ErrWorkspaceInvitationNotExist
+}
+
+// mergeUser transfers the memberships of one user ID to another.
+// Memberships in workspaces the other user already is a member of are dropped once the other user's role
+// is raised to the higher of the two, so a workspace never loses its owner.
+func (s *workspaceStore) mergeUser(fromUserID string, toUserID string) {
+	joined := make(map[string]int)
+	for i, m := range s.Members {
+		if m.UserID == toUserID {
+			joined[m.WorkspaceID] = i
+		}
+	}
+	for _, m := range s.Members {
+		if i, ok := joined[m.WorkspaceID]; ok && m.UserID == fromUserID && roleRanks[m.Role] > roleRanks[s.Members[i].Role] {
+			s.Members[i].Role = m.Role
+		}
+	}
+
+	members := make([]WorkspaceMember, 0, len(s.Members))
+	for _, m := range s.Members {
+		if m.UserID == fromUserID {
+			if _, ok := joined[m.WorkspaceID]; ok {
+				continue
+			}
+			m.UserID = toUserID
+		}
+		members = append(members, m)
+	}
+	s.Members = members
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorkspaceMember_HasRole(t *testing.T) {
+	testCases := []struct {
+		role     string
+		required string
+		expected bool
+	}{
+		{role: RoleOwner, required: RoleEditor, expected: true},
+		{role: RoleEditor, required: RoleEditor, expected: true},
+		{role: RoleViewer, required: RoleEditor, expected: false},
+		{role: RoleViewer, required: RoleViewer, expected: true},
+		{role: "unknown", required: RoleViewer, expected: false},
+	}
+	for _, tc := range testCases {
+		member := WorkspaceMember{Role: tc.role}
+		if got := member.HasRole(tc.required); got != tc.expected {
+			t.Errorf("Expected %s to have %s: %t, got: %t", tc.role, tc.required, tc.expected, got)
+		}
+	}
+}
+
+func TestWorkspaceRepositories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "storage.json")
+	fileRepo, err := NewFileRepository(filename)
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			testWorkspaceLifecycle(t, repo)
+		})
+	}
+
+	t.Run("file workspaces survive reload", func(t *testing.T) {
+		reloaded, err := NewFileRepository(filename)
+		if err != nil {
+			t.Fatalf("Error reloading file store: %v", err)
+		}
+		member, err := reloaded.GetWorkspaceMember(context.Background(), "team", "account")
+		if err != nil || member.Role != RoleEditor {
+			t.Errorf("Expected merged editor membership, got: %+v (error: %v)", member, err)
+		}
+		if urls, err := reloaded.GetByWorkspace(context.Background(), "team"); err != nil || urls[0].OriginalURL != "https://example.com/edited" {
+			t.Errorf("Expected edited workspace URL, got: %+v (error: %v)", urls, err)
+		}
+	})
+}
+
+func TestWorkspaceRepositories_MergeKeepsOwner(t *testing.T) {
+	fileRepo, err := NewFileRepository(filepath.Join(t.TempDir(), "storage.json"))
+	if err != nil {
+		t.Fatalf("Error creating file store: %v", err)
+	}
+
+	repos := map[string]IRepository{
+		"memory": NewMemoryRepository(),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			workspace := Workspace{ID: "solo", Name: "Solo", CreatedBy: "anonymous", CreatedAt: now}
+			if err := repo.AddWorkspace(ctx, workspace, WorkspaceMember{WorkspaceID: "solo", UserID: "anonymous", Role: RoleOwner, JoinedAt: now}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			invitation := WorkspaceInvitation{ID: "inv", WorkspaceID: "solo", Role: RoleViewer, TokenHash: HashInvitationToken("token"), ExpiresAt: now.Add(time.Hour)}
+			if err := repo.AddWorkspaceInvitation(ctx, invitation); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if _, err := repo.AcceptWorkspaceInvitation(ctx, invitation.TokenHash, "account", now); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// The only owner merged into a viewer of the same workspace leaves the account as its owner.
+			if err := repo.MergeUser(ctx, "anonymous", "account"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			members, _ := repo.GetWorkspaceMembers(ctx, "solo")
+			if len(members) != 1 || members[0].UserID != "account" || members[0].Role != RoleOwner {
+				t.Errorf("Expected the account as the only owner, got: %+v", members)
+			}
+		})
+	}
+}
+
+func testWorkspaceLifecycle(t *testing.T, repo IRepository) {
+	ctx := context.Background()
+	now := time.Now()
+	workspace := Workspace{ID: "team", Name: "Marketing", CreatedBy: "owner", CreatedAt: now}
+	if err := repo.AddWorkspace(ctx, workspace, WorkspaceMember{WorkspaceID: "team", UserID: "owner", Role: RoleOwner, JoinedAt: now}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	invitation := WorkspaceInvitation{
+		ID:          "invitation",
+		WorkspaceID: "team",
+		Role:        RoleEditor,
+		TokenHash:   HashInvitationToken("token"),
+		InvitedBy:   "owner",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+	expired := WorkspaceInvitation{ID: "expired", WorkspaceID: "team", Role: RoleEditor, TokenHash: HashInvitationToken("old"), ExpiresAt: now.Add(-time.Hour)}
+	for _, inv := range []WorkspaceInvitation{invitation, expired} {
+		if err := repo.AddWorkspaceInvitation(ctx, inv); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if _, err := repo.AcceptWorkspaceInvitation(ctx, HashInvitationToken("old"), "anonymous", now); !errors.Is(err, ErrWorkspaceInvitationNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrWorkspaceInvitationNotExist, err)
+	}
+	member, err := repo.AcceptWorkspaceInvitation(ctx, invitation.TokenHash, "anonymous", now)
+	if err != nil || member.Role != RoleEditor {
+		t.Fatalf("Unexpected member: %+v (error: %v)", member, err)
+	}
+	if _, err := repo.AcceptWorkspaceInvitation(ctx, invitation.TokenHash, "someone", now); !errors.Is(err, ErrWorkspaceInvitationNotExist) {
+		t.Errorf("Expected accepted invitation to be single-use, got: %v", err)
+	}
+	invitations, _ := repo.GetWorkspaceInvitations(ctx, "team")
+	if len(invitations) != 2 || invitations[0].AcceptedBy != "anonymous" {
+		t.Errorf("Unexpected invitations: %+v", invitations)
+	}
+	if err := repo.DeleteWorkspaceInvitation(ctx, "team", "expired"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.DeleteWorkspaceInvitation(ctx, "other", "invitation"); !errors.Is(err, ErrWorkspaceInvitationNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrWorkspaceInvitationNotExist, err)
+	}
+
+	// Workspace URLs are listed and deleted by workspace, not by their creator.
+	urls := []URL{
+		{Slug: "ws1", OriginalURL: "https://example.com/ws1", UserID: "anonymous", WorkspaceID: "team"},
+		{Slug: "own", OriginalURL: "https://example.com/own", UserID: "anonymous"},
+	}
+	if err := repo.AddMany(ctx, urls); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if own, err := repo.GetByUser(ctx, "anonymous"); err != nil || len(own) != 1 || own[0].Slug != "own" {
+		t.Errorf("Expected only the personal URL, got: %+v (error: %v)", own, err)
+	}
+	if _, err := repo.DeleteMany(ctx, []DeleteRequest{{Slug: "ws1", UserID: "anonymous"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if u, _ := repo.GetBySlug(ctx, "ws1"); u.IsDeleted {
+		t.Errorf("Expected workspace URL to survive a personal deletion request")
+	}
+	if err := repo.UpdateOriginalURL(ctx, "ws1", "https://example.com/own"); !errors.Is(err, ErrURLDuplicate) {
+		t.Errorf("Expected error: %v, got: %v", ErrURLDuplicate, err)
+	}
+	if err := repo.UpdateOriginalURL(ctx, "missing", "https://example.com/new"); !errors.Is(err, ErrURLNotExsit) {
+		t.Errorf("Expected error: %v, got: %v", ErrURLNotExsit, err)
+	}
+	if err := repo.UpdateOriginalURL(ctx, "ws1", "https://example.com/edited"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Merging the anonymous identity into an account moves its membership.
+	if err := repo.MergeUser(ctx, "anonymous", "account"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := repo.GetWorkspaceMember(ctx, "team", "anonymous"); !errors.Is(err, ErrWorkspaceMemberNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrWorkspaceMemberNotExist, err)
+	}
+	memberships, _ := repo.GetWorkspacesByUser(ctx, "account")
+	if len(memberships) != 1 || memberships[0].Name != "Marketing" || memberships[0].Role != RoleEditor {
+		t.Errorf("Unexpected memberships: %+v", memberships)
+	}
+
+	if err := repo.UpdateWorkspaceMemberRole(ctx, "team", "owner", RoleViewer); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.RemoveWorkspaceMember(ctx, "team", "owner"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := repo.RemoveWorkspaceMember(ctx, "team", "owner"); !errors.Is(err, ErrWorkspaceMemberNotExist) {
+		t.Errorf("Expected error: %v, got: %v", ErrWorkspaceMemberNotExist, err)
+	}
+	if members, _ := repo.GetWorkspaceMembers(ctx, "team"); len(members) != 1 {
+		t.Errorf("Expected 1 member, got: %+v", members)
+	}
+
+	if _, err := repo.DeleteMany(ctx, []DeleteRequest{{Slug: "ws1", UserID: "account", WorkspaceID: "team"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if u, _ := repo.GetBySlug(ctx, "ws1"); !u.IsDeleted {
+		t.Errorf("Expected workspace URL to be deleted")
+	}
+}
@@ -0,0 +1,172 @@
+// Package validator provides validation and canonicalization of destination URLs.
+package validator
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// MaxURLLength is the maximum length of a destination URL, matching the `original_url VARCHAR(2048)` column.
+const MaxURLLength = 2048
+
+// defaultSchemes is the scheme allowlist used when none is configured.
+var defaultSchemes = []string{"http", "https"}
+
+// defaultPorts maps URL schemes to the ports omitted during canonicalization.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// ValidationError describes why a destination URL was rejected.
+type ValidationError struct {
+	// Reason is a machine-readable rejection reason.
+	Reason string
+	// Message is a human-readable explanation of the rejection.
+	Message string
+}
+
+// Error returns the error message.
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Validation error reasons.
+const (
+	ReasonEmpty            = "empty"
+	ReasonTooLong          = "too_long"
+	ReasonInvalidCharacter = "invalid_character"
+	ReasonMalformed        = "malformed"
+	ReasonNotAbsolute      = "not_absolute"
+	ReasonSchemeNotAllowed = "scheme_not_allowed"
+	ReasonUserInfo         = "userinfo_not_allowed"
+	ReasonInvalidHost      = "invalid_host"
+	ReasonInvalidPort      = "invalid_port"
+)
+
+// newValidationError creates a ValidationError with a formatted message.
+func newValidationError(reason string, format string, args ...any) *ValidationError {
+	return &ValidationError{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validator validates and canonicalizes destination URLs.
+type Validator struct {
+	// schemes is the set of allowed lower-case URL schemes.
+	schemes map[string]bool
+}
+
+// NewValidator creates a new Validator allowing the given schemes.
+// If no schemes are provided, only http and https are allowed.
+func NewValidator(allowedSchemes []string) *Validator {
+	if len(allowedSchemes) == 0 {
+		allowedSchemes = defaultSchemes
+	}
+	v := &Validator{schemes: make(map[string]bool, len(allowedSchemes))}
+	for _, s := range allowedSchemes {
+		v.schemes[strings.ToLower(strings.TrimSpace(s))] = true
+	}
+	return v
+}
+
+// Normalize validates a raw destination URL and returns its canonical form.
+// Canonicalization lower-cases the scheme and host, converts internationalized
+// host names to punycode, drops default ports and a root path followed by nothing else.
+// The path, query and fragment keep their escaping, so the destination is unchanged.
+// It returns a *ValidationError if the URL is not acceptable.
+func (v *Validator) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", newValidationError(ReasonEmpty, "url is empty")
+	}
+	if len(raw) > MaxURLLength {
+		return "", newValidationError(ReasonTooLong, "url is longer than %d characters", MaxURLLength)
+	}
+	for _, r := range raw {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return "", newValidationError(ReasonInvalidCharacter, "url contains whitespace or control characters")
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", newValidationError(ReasonMalformed, "url could not be parsed")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "" && !v.schemes[u.Scheme] {
+		return "", newValidationError(ReasonSchemeNotAllowed, "url scheme %q is not allowed", u.Scheme)
+	}
+	if !u.IsAbs() || u.Opaque != "" || u.Host == "" {
+		return "", newValidationError(ReasonNotAbsolute, "url must be absolute and include a host")
+	}
+	if u.User != nil {
+		return "", newValidationError(ReasonUserInfo, "url must not contain user credentials")
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port != "" {
+		if !isValidPort(port) {
+			return "", newValidationError(ReasonInvalidPort, "url port %q is invalid", port)
+		}
+		if defaultPorts[u.Scheme] == port {
+			port = ""
+		}
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "/" && u.RawPath == "" && u.RawQuery == "" && !u.ForceQuery && u.Fragment == "" {
+		u.Path = ""
+	}
+
+	normalized := u.String()
+	if len(normalized) > MaxURLLength {
+		return "", newValidationError(ReasonTooLong, "url is longer than %d characters", MaxURLLength)
+	}
+	return normalized, nil
+}
+
+// normalizeHost lower-cases the host and converts internationalized domain names to punycode.
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", newValidationError(ReasonInvalidHost, "url host is empty")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", newValidationError(ReasonInvalidHost, "url host %q is invalid", host)
+	}
+	return strings.ToLower(ascii), nil
+}
+
+// isValidPort reports whether the port is a decimal number in the 1-65535 range.
+func isValidPort(port string) bool {
+	n := 0
+	for _, r := range port {
+		if r < '0' || r > '9' {
+			return false
+		}
+		n = n*10 + int(r-'0')
+		if n > 65535 {
+			return false
+		}
+	}
+	return n > 0
+}
@@ -0,0 +1,170 @@
+package validator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_Normalize(t *testing.T) {
+	testCases := []struct {
+		name           string
+		allowedSchemes []string
+		rawURL         string
+		expectedURL    string
+		expectedReason string
+	}{
+		{
+			name:        "Already canonical",
+			rawURL:      "https://example.com/path?q=1",
+			expectedURL: "https://example.com/path?q=1",
+		},
+		{
+			name:        "Surrounding whitespace",
+			rawURL:      "  https://example.com/path\n",
+			expectedURL: "https://example.com/path",
+		},
+		{
+			name:        "Case and default port",
+			rawURL:      "HTTP://Example.COM:80/",
+			expectedURL: "http://example.com",
+		},
+		{
+			name:        "Non-default port kept",
+			rawURL:      "https://example.com:8443/a",
+			expectedURL: "https://example.com:8443/a",
+		},
+		{
+			name:        "Internationalized domain name",
+			rawURL:      "https://bücher.example/katalog",
+			expectedURL: "https://xn--bcher-kva.example/katalog",
+		},
+		{
+			name:        "Encoded slash kept",
+			rawURL:      "http://ex.com/a%2Fb",
+			expectedURL: "http://ex.com/a%2Fb",
+		},
+		{
+			name:        "Encoded reserved character in path parameter kept",
+			rawURL:      "http://ex.com/a;b=%3B",
+			expectedURL: "http://ex.com/a;b=%3B",
+		},
+		{
+			name:        "Lower-case escape kept",
+			rawURL:      "http://ex.com/a%2fb",
+			expectedURL: "http://ex.com/a%2fb",
+		},
+		{
+			name:        "Encoded unreserved character kept",
+			rawURL:      "http://ex.com/%7Euser",
+			expectedURL: "http://ex.com/%7Euser",
+		},
+		{
+			name:        "Path case kept",
+			rawURL:      "HTTPS://Ex.COM/A/B",
+			expectedURL: "https://ex.com/A/B",
+		},
+		{
+			name:        "Encoded query kept",
+			rawURL:      "http://ex.com/?q=a%26b&next=%2Fhome",
+			expectedURL: "http://ex.com/?q=a%26b&next=%2Fhome",
+		},
+		{
+			name:        "Encoded fragment kept",
+			rawURL:      "http://ex.com/p#a%2Fb",
+			expectedURL: "http://ex.com/p#a%2Fb",
+		},
+		{
+			name:        "Empty query keeps root path",
+			rawURL:      "http://ex.com/?",
+			expectedURL: "http://ex.com/?",
+		},
+		{
+			name:        "Root path before query kept",
+			rawURL:      "http://ex.com/?q=1",
+			expectedURL: "http://ex.com/?q=1",
+		},
+		{
+			name:        "IPv6 literal with default port",
+			rawURL:      "http://[2001:DB8::1]:80/x",
+			expectedURL: "http://[2001:db8::1]/x",
+		},
+		{
+			name:           "Empty",
+			rawURL:         "   ",
+			expectedReason: ReasonEmpty,
+		},
+		{
+			name:           "Too long",
+			rawURL:         "https://example.com/" + strings.Repeat("a", MaxURLLength),
+			expectedReason: ReasonTooLong,
+		},
+		{
+			name:           "Inner whitespace",
+			rawURL:         "https://example.com/a b",
+			expectedReason: ReasonInvalidCharacter,
+		},
+		{
+			name:           "Javascript scheme",
+			rawURL:         "javascript:alert(1)",
+			expectedReason: ReasonSchemeNotAllowed,
+		},
+		{
+			name:           "Relative path",
+			rawURL:         "/some/path",
+			expectedReason: ReasonNotAbsolute,
+		},
+		{
+			name:           "Missing host",
+			rawURL:         "https:///path",
+			expectedReason: ReasonNotAbsolute,
+		},
+		{
+			name:           "Garbage",
+			rawURL:         "%%%",
+			expectedReason: ReasonMalformed,
+		},
+		{
+			name:           "Scheme not allowed",
+			rawURL:         "ftp://example.com/file",
+			expectedReason: ReasonSchemeNotAllowed,
+		},
+		{
+			name:           "Configured scheme allowed",
+			allowedSchemes: []string{"https", "ftp"},
+			rawURL:         "FTP://example.com/file",
+			expectedURL:    "ftp://example.com/file",
+		},
+		{
+			name:           "User credentials",
+			rawURL:         "https://bank.example@evil.example/",
+			expectedReason: ReasonUserInfo,
+		},
+		{
+			name:           "Invalid port",
+			rawURL:         "https://example.com:99999/",
+			expectedReason: ReasonInvalidPort,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NewValidator(tc.allowedSchemes)
+			normalized, err := v.Normalize(tc.rawURL)
+
+			if tc.expectedReason != "" {
+				var validationErr *ValidationError
+				assert.True(t, errors.As(err, &validationErr), "expected ValidationError, got %v", err)
+				if validationErr != nil {
+					assert.Equal(t, tc.expectedReason, validationErr.Reason)
+					assert.NotEmpty(t, validationErr.Message)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedURL, normalized)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Package webhooks provides the buffered counting of URL clicks feeding the click threshold events.
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/gennadis/shorturl/internal/app/repository"
+)
+
+// ClickJobName is the name of the scheduler job flushing the counted clicks.
+const ClickJobName = "webhook-clicks"
+
+// defaultClickFlushInterval is the default interval the counted clicks are flushed at.
+const defaultClickFlushInterval = 5 * time.Second
+
+// pendingClicks are the clicks counted on a URL since the last flush.
+type pendingClicks struct {
+	url    repository.URL
+	clicks int
+}
+
+// ClickCounter counts the clicks on URLs in memory and flushes them to the repository periodically,
+// so redirects never wait for a write. Clicks on URLs whose owner has no click threshold webhook are dropped
+// at the flush: the counts only feed the click threshold events.
+type ClickCounter struct {
+	service  *Service
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]pendingClicks
+}
+
+// NewClickCounter creates a click counter queueing the click threshold events with the service.
+// The clicks are flushed at the interval once registered with a scheduler.
+func NewClickCounter(service *Service, interval time.Duration) *ClickCounter {
+	if interval <= 0 {
+		interval = defaultClickFlushInterval
+	}
+	return &ClickCounter{service: service, interval: interval, pending: map[string]pendingClicks{}}
+}
+
+// Add counts a click on the URL.
+func (c *ClickCounter) Add(u repository.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := c.pending[u.Slug]
+	c.pending[u.Slug] = pendingClicks{url: u, clicks: p.clicks + 1}
+}
+
+// Register registers the flushes of the counted clicks as a periodic job of the scheduler.
+// The clicks left are flushed on shutdown.
+func (c *ClickCounter) Register(s *jobs.Scheduler) error {
+	return s.Every(ClickJobName, c.Flush, jobs.Options{
+		Interval:   c.interval,
+		OnShutdown: c.Flush,
+	})
+}
+
+// Flush adds the counted clicks to the URLs of owners with click threshold webhooks in a single write,
+// and queues the click threshold events they trigger. The clicks are counted again if the write fails.
+func (c *ClickCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[string]pendingClicks{}
+	c.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	counting := map[string]bool{}
+	clicks := map[string]int{}
+	for slug, p := range pending {
+		owner := p.url.UserID
+		if _, ok := counting[owner]; !ok {
+			hasThreshold, err := c.service.hasClickThreshold(ctx, owner)
+			if err != nil {
+				c.restore(pending)
+				return err
+			}
+			counting[owner] = hasThreshold
+		}
+		if counting[owner] {
+			clicks[slug] = p.clicks
+		}
+	}
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	totals, err := c.service.repo.AddClicks(ctx, clicks)
+	if err != nil {
+		c.restore(pending)
+		return fmt.Errorf("adding clicks: %w", err)
+	}
+	for slug, total := range totals {
+		if err := c.service.LinkClicked(ctx, pending[slug].url, total-clicks[slug], total); err != nil {
+			slog.Error("queueing click threshold event", slog.String("slug", slug), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// restore counts the clicks of a failed flush again.
+func (c *ClickCounter) restore(pending map[string]pendingClicks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for slug, p := range pending {
+		p.clicks += c.pending[slug].clicks
+		c.pending[slug] = p
+	}
+}
+
+// hasClickThreshold reports whether the user has a webhook subscribed to the click threshold event.
+func (s *Service) hasClickThreshold(ctx context.Context, userID string) (bool, error) {
+	webhooks, err := s.repo.GetWebhooksByUser(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("getting webhooks: %w", err)
+	}
+	for _, w := range webhooks {
+		if w.ClickThreshold > 0 && slices.Contains(w.Events, EventLinkClickThreshold) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,290 @@
+// Package webhooks provides the background worker delivering webhook events.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/gennadis/shorturl/internal/app/repository"
+)
+
+// Default dispatcher settings, used for zero DispatcherConfig fields.
+const (
+	defaultMaxAttempts    = 8
+	defaultInitialBackoff = time.Second * 10
+	defaultMaxBackoff     = time.Hour
+	defaultPollInterval   = time.Second * 5
+	defaultTimeout        = time.Second * 10
+	defaultBatchSize      = 100
+	defaultConcurrency    = 8
+)
+
+// DeliveryJobName is the name of the scheduler job attempting the due webhook deliveries.
+const DeliveryJobName = "webhook-deliveries"
+
+// ExpiryJobName is the name of the scheduler job queueing the expired events of URLs.
+const ExpiryJobName = "webhook-expiry"
+
+// maxLastErrorLen is the maximum length of the failure description kept in the delivery log.
+const maxLastErrorLen = 512
+
+// DispatcherConfig configures the delivery attempts of a Dispatcher. Zero fields take default values.
+type DispatcherConfig struct {
+	// MaxAttempts is the number of attempts after which a failing delivery is dead-lettered.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles with every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// PollInterval is the interval due deliveries and expired URLs are checked at.
+	PollInterval time.Duration
+	// Timeout is the timeout of a single delivery attempt.
+	Timeout time.Duration
+	// BatchSize is the maximum number of deliveries attempted per poll.
+	BatchSize int
+	// Concurrency is the number of webhooks delivered to at once.
+	Concurrency int
+}
+
+// withDefaults returns the configuration with default values for its zero fields.
+func (c DispatcherConfig) withDefaults() DispatcherConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultConcurrency
+	}
+	return c
+}
+
+// Dispatcher delivers the queued webhook events and queues the expired events of URLs once registered with a scheduler.
+// Deliveries are made at least once; receivers discard repeats by the delivery ID.
+type Dispatcher struct {
+	// service queues the expired events.
+	service *Service
+	// repo stores the webhooks and their deliveries.
+	repo repository.IRepository
+	// client posts the deliveries.
+	client *http.Client
+	// cfg configures the delivery attempts.
+	cfg DispatcherConfig
+	// now returns the current time.
+	now func() time.Time
+	// lastExpiryCheck is the time up to which the expired events were queued.
+	// It is only accessed by the expiry job, whose runs never overlap.
+	lastExpiryCheck time.Time
+}
+
+// NewDispatcher creates a new Dispatcher delivering the events queued by the service.
+// Deliveries only connect to public addresses unless the service allows private networks.
+func NewDispatcher(service *Service, cfg DispatcherConfig) *Dispatcher {
+	cfg = cfg.withDefaults()
+	return &Dispatcher{
+		service: service,
+		repo:    service.repo,
+		client:  newDeliveryClient(cfg.Timeout, service.AllowPrivateNetworks),
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// Register registers the deliveries of the due events and the queueing of the expired events of URLs
+// as periodic jobs of the scheduler, both run at the poll interval.
+// The expired events are queued from the stored checkpoint, so URLs expiring while the process is down are not missed.
+func (d *Dispatcher) Register(ctx context.Context, s *jobs.Scheduler) error {
+	d.lastExpiryCheck = d.expiryCheckpoint(ctx)
+	err := s.Every(DeliveryJobName, func(ctx context.Context) error {
+		d.DeliverDue(ctx)
+		return nil
+	}, jobs.Options{Interval: d.cfg.PollInterval})
+	if err != nil {
+		return err
+	}
+	return s.Every(ExpiryJobName, d.queueExpired, jobs.Options{Interval: d.cfg.PollInterval})
+}
+
+// queueExpired queues the events of the URLs expired since the last check and moves the stored checkpoint.
+// The check is repeated from the same time by the next run if queueing fails.
+func (d *Dispatcher) queueExpired(ctx context.Context) error {
+	now := d.now()
+	if err := d.service.LinksExpired(ctx, d.lastExpiryCheck, now); err != nil {
+		return fmt.Errorf("queueing expired link events: %w", err)
+	}
+	d.lastExpiryCheck = now
+	if err := d.repo.SetExpiryCheckpoint(ctx, now.UTC()); err != nil {
+		slog.Error("saving expiry checkpoint", slog.Any("error", err))
+	}
+	return nil
+}
+
+// expiryCheckpoint returns the time up to which the expired events were queued by a previous run,
+// or the current time on the first run.
+func (d *Dispatcher) expiryCheckpoint(ctx context.Context) time.Time {
+	checkpoint, err := d.repo.GetExpiryCheckpoint(ctx)
+	if err != nil {
+		slog.Error("getting expiry checkpoint", slog.Any("error", err))
+	}
+	if checkpoint.IsZero() {
+		return d.now()
+	}
+	return checkpoint
+}
+
+// DeliverDue attempts the pending deliveries that are due and returns the number of attempts made.
+// The webhooks are delivered to by a bounded pool of workers, so a slow endpoint only holds up its own deliveries.
+// The deliveries to a webhook are attempted in order, and the ones following a failed attempt wait for the next poll.
+func (d *Dispatcher) DeliverDue(ctx context.Context) int {
+	deliveries, err := d.repo.GetDueWebhookDeliveries(ctx, d.now(), d.cfg.BatchSize)
+	if err != nil {
+		slog.Error("getting due webhook deliveries", slog.Any("error", err))
+		return 0
+	}
+
+	webhookIDs := []string{}
+	byWebhook := map[string][]repository.WebhookDelivery{}
+	for _, delivery := range deliveries {
+		if _, ok := byWebhook[delivery.WebhookID]; !ok {
+			webhookIDs = append(webhookIDs, delivery.WebhookID)
+		}
+		byWebhook[delivery.WebhookID] = append(byWebhook[delivery.WebhookID], delivery)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		attempts int
+	)
+	workers := make(chan struct{}, d.cfg.Concurrency)
+	for _, webhookID := range webhookIDs {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(queued []repository.WebhookDelivery) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			made := 0
+			for _, delivery := range queued {
+				made++
+				if !d.attempt(ctx, delivery) {
+					break
+				}
+			}
+			mu.Lock()
+			attempts += made
+			mu.Unlock()
+		}(byWebhook[webhookID])
+	}
+	wg.Wait()
+	return attempts
+}
+
+// attempt posts a delivery to its webhook and records the outcome:
+// success, a retry after exponential backoff, or the dead-letter state once the attempts are exhausted.
+// It reports whether the following deliveries to the webhook can be attempted, which is not the case after a failed post.
+func (d *Dispatcher) attempt(ctx context.Context, delivery repository.WebhookDelivery) bool {
+	now := d.now()
+	delivery.Attempts++
+	delivery.UpdatedAt = now.UTC()
+
+	webhook, err := d.repo.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		// Deliveries to removed webhooks are dead-lettered at once.
+		delivery.Status = repository.DeliveryStatusDead
+		delivery.LastError = fmt.Sprintf("webhook unavailable: %v", err)
+		d.save(ctx, delivery)
+		return true
+	}
+
+	statusCode, err := d.post(ctx, webhook, delivery, now)
+	delivery.LastStatusCode = statusCode
+	switch {
+	case err == nil:
+		delivery.Status = repository.DeliveryStatusSucceeded
+		delivery.LastError = ""
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = repository.DeliveryStatusDead
+		delivery.LastError = truncate(err.Error(), maxLastErrorLen)
+		slog.Warn("webhook delivery dead-lettered", slog.String("delivery", delivery.ID), slog.String("webhook", webhook.ID), slog.Any("error", err))
+	default:
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts)).UTC()
+		delivery.LastError = truncate(err.Error(), maxLastErrorLen)
+		slog.Debug("webhook delivery failed", slog.String("delivery", delivery.ID), slog.Int("attempts", delivery.Attempts), slog.Any("error", err))
+	}
+	d.save(ctx, delivery)
+	return err == nil
+}
+
+// post sends a signed delivery and returns the response status code. Non-2xx responses, redirects included, are errors.
+func (d *Dispatcher) post(ctx context.Context, webhook repository.Webhook, delivery repository.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the retry following the given number of attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
+
+// save records the outcome of a delivery attempt.
+func (d *Dispatcher) save(ctx context.Context, delivery repository.WebhookDelivery) {
+	if err := d.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		slog.Error("saving webhook delivery", slog.String("delivery", delivery.ID), slog.Any("error", err))
+	}
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,293 @@
+// Package webhooks provides user-registered webhooks notified about events on their URLs.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/google/uuid"
+)
+
+// Events webhooks can subscribe to.
+const (
+	// EventLinkCreated is sent when a URL is shortened.
+	EventLinkCreated = "link.created"
+	// EventLinkDeleted is sent when a URL deletion is applied.
+	EventLinkDeleted = "link.deleted"
+	// EventLinkExpired is sent when the activation window of a URL ends.
+	EventLinkExpired = "link.expired"
+	// EventLinkClickThreshold is sent when the clicks on a URL reach the click threshold of the webhook.
+	EventLinkClickThreshold = "link.click_threshold"
+)
+
+// Headers of webhook deliveries.
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of the delivery, `sha256=<hex>`.
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader carries the Unix time the delivery was signed at.
+	TimestampHeader = "X-Webhook-Timestamp"
+	// EventHeader carries the delivered event.
+	EventHeader = "X-Webhook-Event"
+	// DeliveryHeader carries the delivery ID, which receivers use to discard repeated deliveries.
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// secretPrefix identifies generated webhook secrets.
+const secretPrefix = "whsec_"
+
+// minSecretLength is the minimum length of a secret chosen by the user.
+const minSecretLength = 16
+
+// validEvents is the set of events webhooks can subscribe to.
+var validEvents = []string{EventLinkCreated, EventLinkDeleted, EventLinkExpired, EventLinkClickThreshold}
+
+// ErrNotFound is returned when a webhook does not exist or belongs to another user.
+var ErrNotFound = errors.New("webhook not found")
+
+// ErrInvalidWebhook is returned for an invalid endpoint URL, secret, event list or click threshold.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// LinkPayload describes the URL an event is about.
+type LinkPayload struct {
+	Slug        string     `json:"slug"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	WorkspaceID string     `json:"workspace_id,omitempty"`
+	Clicks      int        `json:"clicks,omitempty"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+}
+
+// Payload is the JSON body posted to webhooks.
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Link      LinkPayload `json:"link"`
+}
+
+// Service registers webhooks and queues deliveries of the events on the URLs of their users.
+type Service struct {
+	// repo stores the webhooks and their deliveries.
+	repo repository.IRepository
+	// baseURL is the base of the short URLs in payloads.
+	baseURL string
+	// now returns the current time.
+	now func() time.Time
+	// AllowPrivateNetworks allows webhook endpoints on loopback, private and link-local addresses.
+	// It is meant for development only: such endpoints let users probe internal services.
+	AllowPrivateNetworks bool
+}
+
+// NewService creates a new webhook Service.
+func NewService(repo repository.IRepository, baseURL string) *Service {
+	return &Service{repo: repo, baseURL: baseURL, now: time.Now}
+}
+
+// IsValidEvent reports whether webhooks can subscribe to the event.
+func IsValidEvent(event string) bool {
+	return slices.Contains(validEvents, event)
+}
+
+// Register registers a webhook of a user. An empty secret is generated, and an empty event list subscribes to all events.
+// Endpoints on localhost or non-public IP addresses are rejected unless private networks are allowed.
+// A click threshold is required to subscribe to the click threshold event.
+func (s *Service) Register(ctx context.Context, userID string, endpoint string, secret string, events []string, clickThreshold int) (repository.Webhook, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return repository.Webhook{}, fmt.Errorf("%w: endpoint must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if !s.AllowPrivateNetworks {
+		if err := checkEndpointHost(u.Hostname()); err != nil {
+			return repository.Webhook{}, fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+		}
+	}
+	if secret == "" {
+		if secret, err = generateSecret(); err != nil {
+			return repository.Webhook{}, err
+		}
+	} else if len(secret) < minSecretLength {
+		return repository.Webhook{}, fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minSecretLength)
+	}
+	for _, e := range events {
+		if !IsValidEvent(e) {
+			return repository.Webhook{}, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+	if clickThreshold < 0 || (clickThreshold == 0 && slices.Contains(events, EventLinkClickThreshold)) {
+		return repository.Webhook{}, fmt.Errorf("%w: invalid click threshold", ErrInvalidWebhook)
+	}
+	events = slices.Clone(events)
+	if len(events) == 0 {
+		events = slices.Clone(validEvents)
+	}
+	slices.Sort(events)
+
+	webhook := repository.Webhook{
+		ID:             uuid.NewString(),
+		UserID:         userID,
+		URL:            endpoint,
+		Secret:         secret,
+		Events:         slices.Compact(events),
+		ClickThreshold: clickThreshold,
+		CreatedAt:      s.now().UTC(),
+	}
+	if err := s.repo.AddWebhook(ctx, webhook); err != nil {
+		return repository.Webhook{}, fmt.Errorf("saving webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// List returns the webhooks of a user.
+func (s *Service) List(ctx context.Context, userID string) ([]repository.Webhook, error) {
+	return s.repo.GetWebhooksByUser(ctx, userID)
+}
+
+// Delete removes a webhook of a user.
+func (s *Service) Delete(ctx context.Context, userID string, webhookID string) error {
+	if err := s.repo.DeleteWebhook(ctx, userID, webhookID); err != nil {
+		if errors.Is(err, repository.ErrWebhookNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Deliveries returns up to limit deliveries to a webhook of a user, newest first.
+func (s *Service) Deliveries(ctx context.Context, userID string, webhookID string, limit int) ([]repository.WebhookDelivery, error) {
+	webhook, err := s.repo.GetWebhook(ctx, webhookID)
+	if errors.Is(err, repository.ErrWebhookNotExist) || (err == nil && webhook.UserID != userID) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetWebhookDeliveries(ctx, webhookID, limit)
+}
+
+// LinkCreated queues the created event of a URL.
+func (s *Service) LinkCreated(ctx context.Context, u repository.URL) error {
+	return s.publish(ctx, EventLinkCreated, u, nil)
+}
+
+// LinkClicked queues the click threshold event of a URL for the webhooks whose threshold the clicks reached
+// going from the from to the to count.
+func (s *Service) LinkClicked(ctx context.Context, u repository.URL, from int, to int) error {
+	u.Clicks = to
+	return s.publish(ctx, EventLinkClickThreshold, u, func(w repository.Webhook) bool {
+		return w.ClickThreshold > from && w.ClickThreshold <= to
+	})
+}
+
+// LinksExpired queues the expired events of the URLs whose activation window ended within (from, to].
+func (s *Service) LinksExpired(ctx context.Context, from time.Time, to time.Time) error {
+	urls, err := s.repo.GetURLsExpiringBetween(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("getting expiring urls: %w", err)
+	}
+	for _, u := range urls {
+		if err := s.publish(ctx, EventLinkExpired, u, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyDeleted queues the deleted events of the applied URL deletions. It implements deleter.DeleteNotifier.
+func (s *Service) NotifyDeleted(ctx context.Context, delReqs []repository.DeleteRequest) error {
+	for _, dr := range delReqs {
+		u, err := s.repo.GetBySlug(ctx, dr.Slug)
+		if err != nil || !u.IsDeleted || u.WorkspaceID != dr.WorkspaceID || (dr.WorkspaceID == "" && u.UserID != dr.UserID) {
+			continue
+		}
+		if err := s.publish(ctx, EventLinkDeleted, u, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish queues a delivery of the event to each webhook of the URL owner subscribed to it and accepted by the filter.
+func (s *Service) publish(ctx context.Context, event string, u repository.URL, filter func(repository.Webhook) bool) error {
+	webhooks, err := s.repo.GetWebhooksByUser(ctx, u.UserID)
+	if err != nil {
+		return fmt.Errorf("getting webhooks: %w", err)
+	}
+
+	now := s.now().UTC()
+	deliveries := []repository.WebhookDelivery{}
+	for _, w := range webhooks {
+		if !slices.Contains(w.Events, event) || (filter != nil && !filter(w)) {
+			continue
+		}
+		payload := Payload{
+			ID:        uuid.NewString(),
+			Event:     event,
+			CreatedAt: now,
+			Link: LinkPayload{
+				Slug:        u.Slug,
+				ShortURL:    s.baseURL + "/" + u.Slug,
+				OriginalURL: u.OriginalURL,
+				WorkspaceID: u.WorkspaceID,
+				Clicks:      u.Clicks,
+				NotAfter:    u.NotAfter,
+			},
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("marshalling webhook payload: %w", err)
+		}
+		deliveries = append(deliveries, repository.WebhookDelivery{
+			ID:            payload.ID,
+			WebhookID:     w.ID,
+			Event:         event,
+			Payload:       body,
+			Status:        repository.DeliveryStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := s.repo.AddWebhookDeliveries(ctx, deliveries); err != nil {
+		return fmt.Errorf("queueing webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the signature of a delivery body sent at the Unix timestamp: `sha256=` and the hex-encoded
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature of a delivery body sent at the Unix timestamp is valid for the secret.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// generateSecret returns a random webhook secret.
+func generateSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating webhook secret: %w", err)
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
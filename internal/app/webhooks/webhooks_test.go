@@ -0,0 +1,386 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/jobs"
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a local webhook endpoint recording the deliveries with a valid signature.
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	status   int
+	payloads []Payload
+	invalid  int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if !Verify(rc.secret, timestamp, body, r.Header.Get(SignatureHeader)) {
+		rc.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var payload Payload
+	_ = json.Unmarshal(body, &payload)
+	if payload.ID != r.Header.Get(DeliveryHeader) || payload.Event != r.Header.Get(EventHeader) {
+		rc.invalid++
+	}
+	rc.payloads = append(rc.payloads, payload)
+	w.WriteHeader(rc.status)
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(repository.NewMemoryRepository(), "http://localhost:8080")
+
+	testCases := []struct {
+		name           string
+		endpoint       string
+		secret         string
+		events         []string
+		clickThreshold int
+		wantErr        bool
+		wantEvents     []string
+	}{
+		{name: "Defaults to all events", endpoint: "https://example.com/hook", wantEvents: []string{EventLinkClickThreshold, EventLinkCreated, EventLinkDeleted, EventLinkExpired}},
+		{name: "Deduplicated events", endpoint: "https://example.com/hook", events: []string{EventLinkDeleted, EventLinkCreated, EventLinkDeleted}, wantEvents: []string{EventLinkCreated, EventLinkDeleted}},
+		{name: "Relative endpoint", endpoint: "/hook", wantErr: true},
+		{name: "Unsupported scheme", endpoint: "ftp://example.com/hook", wantErr: true},
+		{name: "Short secret", endpoint: "https://example.com/hook", secret: "short", wantErr: true},
+		{name: "Unknown event", endpoint: "https://example.com/hook", events: []string{"link.updated"}, wantErr: true},
+		{name: "Click threshold event without threshold", endpoint: "https://example.com/hook", events: []string{EventLinkClickThreshold}, wantErr: true},
+		{name: "Negative threshold", endpoint: "https://example.com/hook", clickThreshold: -1, wantErr: true},
+		{name: "Loopback endpoint", endpoint: "http://127.0.0.1:8080/hook", wantErr: true},
+		{name: "Localhost endpoint", endpoint: "http://localhost/hook", wantErr: true},
+		{name: "Private endpoint", endpoint: "http://10.0.0.5/hook", wantErr: true},
+		{name: "Link-local endpoint", endpoint: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "IPv6 loopback endpoint", endpoint: "http://[::1]/hook", wantErr: true},
+		{name: "IPv4-mapped private endpoint", endpoint: "http://[::ffff:192.168.0.1]/hook", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			webhook, err := service.Register(ctx, "user", tc.endpoint, tc.secret, tc.events, tc.clickThreshold)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidWebhook)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantEvents, webhook.Events)
+			assert.Regexp(t, "^whsec_[0-9a-f]{48}$", webhook.Secret)
+		})
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, "http://localhost:8080")
+	service.AllowPrivateNetworks = true
+	rc := &receiver{secret: "0123456789abcdef", status: http.StatusOK}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	webhook, err := service.Register(ctx, "user", server.URL, rc.secret, nil, 2)
+	require.NoError(t, err)
+	_, err = service.Register(ctx, "other", server.URL, rc.secret, nil, 0)
+	require.NoError(t, err)
+
+	notAfter := time.Now().Add(time.Minute)
+	url := repository.URL{Slug: "slug", OriginalURL: "https://example.com", UserID: "user", NotAfter: &notAfter}
+	require.NoError(t, repo.Add(ctx, url))
+	require.NoError(t, service.LinkCreated(ctx, url))
+
+	// Only the flush reaching the threshold is an event.
+	clicks := NewClickCounter(service, 0)
+	for _, n := range []int{1, 1, 2} {
+		for i := 0; i < n; i++ {
+			clicks.Add(url)
+		}
+		require.NoError(t, clicks.Flush(ctx))
+	}
+	require.NoError(t, service.LinksExpired(ctx, notAfter.Add(-time.Second), notAfter))
+	require.NoError(t, service.LinksExpired(ctx, notAfter, notAfter.Add(time.Second)))
+
+	// Requests for URLs that were not deleted are not events.
+	delReqs := []repository.DeleteRequest{{Slug: url.Slug, UserID: "user"}}
+	require.NoError(t, service.NotifyDeleted(ctx, delReqs))
+	_, err = repo.DeleteMany(ctx, delReqs)
+	require.NoError(t, err)
+	require.NoError(t, service.NotifyDeleted(ctx, delReqs))
+
+	dispatcher := NewDispatcher(service, DispatcherConfig{})
+	assert.Equal(t, 4, dispatcher.DeliverDue(ctx))
+	assert.Zero(t, dispatcher.DeliverDue(ctx))
+
+	require.Len(t, rc.payloads, 4)
+	assert.Zero(t, rc.invalid)
+	events := []string{}
+	for _, p := range rc.payloads {
+		events = append(events, p.Event)
+		assert.Equal(t, "http://localhost:8080/slug", p.Link.ShortURL)
+	}
+	assert.Equal(t, []string{EventLinkCreated, EventLinkClickThreshold, EventLinkExpired, EventLinkDeleted}, events)
+	assert.Equal(t, 2, rc.payloads[1].Link.Clicks)
+
+	deliveries, err := service.Deliveries(ctx, "user", webhook.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 4)
+	for _, d := range deliveries {
+		assert.Equal(t, repository.DeliveryStatusSucceeded, d.Status)
+		assert.Equal(t, http.StatusOK, d.LastStatusCode)
+	}
+	_, err = service.Deliveries(ctx, "other", webhook.ID, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClickCounter(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, "http://localhost:8080")
+	_, err := service.Register(ctx, "user", "https://example.com/hook", "", []string{EventLinkClickThreshold}, 3)
+	require.NoError(t, err)
+	_, err = service.Register(ctx, "other", "https://example.com/hook", "", []string{EventLinkCreated}, 0)
+	require.NoError(t, err)
+
+	counted := repository.URL{Slug: "counted", OriginalURL: "https://example.com", UserID: "user"}
+	skipped := repository.URL{Slug: "skipped", OriginalURL: "https://example.org", UserID: "other"}
+	require.NoError(t, repo.Add(ctx, counted))
+	require.NoError(t, repo.Add(ctx, skipped))
+
+	// A single flush passing the threshold is one event; clicks of owners without click threshold webhooks are dropped.
+	clicks := NewClickCounter(service, 0)
+	for i := 0; i < 5; i++ {
+		clicks.Add(counted)
+		clicks.Add(skipped)
+	}
+	require.NoError(t, clicks.Flush(ctx))
+	require.NoError(t, clicks.Flush(ctx))
+
+	u, err := repo.GetBySlug(ctx, counted.Slug)
+	require.NoError(t, err)
+	assert.Equal(t, 5, u.Clicks)
+	u, err = repo.GetBySlug(ctx, skipped.Slug)
+	require.NoError(t, err)
+	assert.Zero(t, u.Clicks)
+
+	due, err := repo.GetDueWebhookDeliveries(ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, EventLinkClickThreshold, due[0].Event)
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, "http://localhost:8080")
+	service.AllowPrivateNetworks = true
+	rc := &receiver{secret: "0123456789abcdef", status: http.StatusInternalServerError}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	webhook, err := service.Register(ctx, "user", server.URL, rc.secret, []string{EventLinkCreated}, 0)
+	require.NoError(t, err)
+	require.NoError(t, service.LinkCreated(ctx, repository.URL{Slug: "slug", OriginalURL: "https://example.com", UserID: "user"}))
+
+	now := time.Now()
+	dispatcher := NewDispatcher(service, DispatcherConfig{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second})
+	dispatcher.now = func() time.Time { return now }
+
+	// Failed attempts are retried after 1s, 2s and then the 3s cap, and dead-lettered after the last one.
+	for i, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		require.Equal(t, 1, dispatcher.DeliverDue(ctx), "attempt %d", i+1)
+		deliveries, err := service.Deliveries(ctx, "user", webhook.ID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, repository.DeliveryStatusPending, deliveries[0].Status)
+		assert.Equal(t, i+1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].LastStatusCode)
+		assert.Equal(t, now.Add(delay).UTC(), deliveries[0].NextAttemptAt)
+
+		assert.Zero(t, dispatcher.DeliverDue(ctx), "retried before backoff")
+		now = now.Add(delay)
+	}
+	require.Equal(t, 1, dispatcher.DeliverDue(ctx))
+	deliveries, err := service.Deliveries(ctx, "user", webhook.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, repository.DeliveryStatusDead, deliveries[0].Status)
+	assert.Equal(t, 4, deliveries[0].Attempts)
+	assert.Len(t, rc.payloads, 4)
+
+	now = now.Add(time.Hour)
+	assert.Zero(t, dispatcher.DeliverDue(ctx))
+
+	// Pending deliveries to removed webhooks are dead-lettered without an attempt.
+	require.NoError(t, service.Delete(ctx, "user", webhook.ID))
+	webhook, err = service.Register(ctx, "user", server.URL, rc.secret, []string{EventLinkCreated}, 0)
+	require.NoError(t, err)
+	require.NoError(t, service.LinkCreated(ctx, repository.URL{Slug: "slug", OriginalURL: "https://example.com", UserID: "user"}))
+	require.NoError(t, service.Delete(ctx, "user", webhook.ID))
+	require.Equal(t, 1, dispatcher.DeliverDue(ctx))
+	assert.Len(t, rc.payloads, 4)
+	assert.ErrorIs(t, service.Delete(ctx, "user", webhook.ID), ErrNotFound)
+}
+
+func TestDispatcher_SlowEndpoint(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, "http://localhost:8080")
+	service.AllowPrivateNetworks = true
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer slow.Close()
+	rc := &receiver{secret: "0123456789abcdef", status: http.StatusOK}
+	fast := httptest.NewServer(rc)
+	defer fast.Close()
+
+	_, err := service.Register(ctx, "slow", slow.URL, "", []string{EventLinkCreated}, 0)
+	require.NoError(t, err)
+	_, err = service.Register(ctx, "fast", fast.URL, rc.secret, []string{EventLinkCreated}, 0)
+	require.NoError(t, err)
+	for _, slug := range []string{"first", "second"} {
+		require.NoError(t, service.LinkCreated(ctx, repository.URL{Slug: slug, OriginalURL: "https://example.com", UserID: "slow"}))
+		require.NoError(t, service.LinkCreated(ctx, repository.URL{Slug: slug, OriginalURL: "https://example.com", UserID: "fast"}))
+	}
+
+	// The fast endpoint is delivered to while the slow one is still answering.
+	dispatcher := NewDispatcher(service, DispatcherConfig{Concurrency: 2})
+	attempts := make(chan int)
+	go func() { attempts <- dispatcher.DeliverDue(ctx) }()
+	assert.Eventually(t, func() bool {
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		return len(rc.payloads) == 2
+	}, time.Second, 10*time.Millisecond)
+	close(release)
+
+	// The second delivery to the failing endpoint waits for the next poll.
+	assert.Equal(t, 3, <-attempts)
+}
+
+func TestDispatcher_ExpiryCheckpoint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, "http://localhost:8080")
+	service.AllowPrivateNetworks = true
+	rc := &receiver{secret: "0123456789abcdef", status: http.StatusOK}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	_, err := service.Register(ctx, "user", server.URL, rc.secret, []string{EventLinkExpired}, 0)
+	require.NoError(t, err)
+
+	// The link expired while the process was down, after the checkpoint of the previous run.
+	checkpoint := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(-time.Minute)
+	require.NoError(t, repo.SetExpiryCheckpoint(ctx, checkpoint))
+	require.NoError(t, repo.Add(ctx, repository.URL{Slug: "slug", OriginalURL: "https://example.com", UserID: "user", NotAfter: &notAfter}))
+
+	scheduler := jobs.NewScheduler()
+	require.NoError(t, NewDispatcher(service, DispatcherConfig{PollInterval: 10 * time.Millisecond}).Register(ctx, scheduler))
+	wg := scheduler.Run(ctx)
+	assert.Eventually(t, func() bool {
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		return len(rc.payloads) == 1 && rc.payloads[0].Event == EventLinkExpired
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	saved, err := repo.GetExpiryCheckpoint(context.Background())
+	require.NoError(t, err)
+	assert.True(t, saved.After(notAfter), "expected the checkpoint to move past the expiry, got %v", saved)
+}
+
+func TestDispatcher_ForbiddenAddresses(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, "http://localhost:8080")
+	rc := &receiver{secret: "0123456789abcdef", status: http.StatusOK}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	// A webhook on a name resolving to a private address is refused when dialed, not only when registered.
+	require.NoError(t, repo.AddWebhook(ctx, repository.Webhook{ID: "internal", UserID: "user", URL: server.URL, Secret: rc.secret, Events: []string{EventLinkCreated}}))
+	require.NoError(t, service.LinkCreated(ctx, repository.URL{Slug: "slug", OriginalURL: "https://example.com", UserID: "user"}))
+
+	dispatcher := NewDispatcher(service, DispatcherConfig{})
+	require.Equal(t, 1, dispatcher.DeliverDue(ctx))
+	assert.Empty(t, rc.payloads)
+	deliveries, err := service.Deliveries(ctx, "user", "internal", 1)
+	require.NoError(t, err)
+	assert.Equal(t, repository.DeliveryStatusPending, deliveries[0].Status)
+	assert.Zero(t, deliveries[0].LastStatusCode)
+	assert.Contains(t, deliveries[0].LastError, ErrForbiddenAddress.Error())
+}
+
+func TestDispatcher_DoesNotFollowRedirects(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, "http://localhost:8080")
+	service.AllowPrivateNetworks = true
+	rc := &receiver{secret: "0123456789abcdef", status: http.StatusOK}
+	target := httptest.NewServer(rc)
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirect.Close()
+
+	webhook, err := service.Register(ctx, "user", redirect.URL, rc.secret, []string{EventLinkCreated}, 0)
+	require.NoError(t, err)
+	require.NoError(t, service.LinkCreated(ctx, repository.URL{Slug: "slug", OriginalURL: "https://example.com", UserID: "user"}))
+
+	dispatcher := NewDispatcher(service, DispatcherConfig{})
+	require.Equal(t, 1, dispatcher.DeliverDue(ctx))
+	assert.Empty(t, rc.payloads)
+	deliveries, err := service.Deliveries(ctx, "user", webhook.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, repository.DeliveryStatusPending, deliveries[0].Status)
+	assert.Equal(t, http.StatusFound, deliveries[0].LastStatusCode)
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	testCases := []struct {
+		addr     string
+		expected bool
+	}{
+		{addr: "93.184.216.34", expected: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", expected: true},
+		{addr: "127.0.0.1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "255.255.255.255"},
+		{addr: "224.0.0.1"},
+		{addr: "::1"},
+		{addr: "fe80::1"},
+		{addr: "fd00::1"},
+		{addr: "::ffff:127.0.0.1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isPublicAddr(netip.MustParseAddr(tc.addr)))
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Package workspaces provides workspaces with members, roles and invitations for shared link ownership.
+package workspaces
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/google/uuid"
+)
+
+// DefaultInvitationTTL is the invitation lifetime used when none is configured.
+const DefaultInvitationTTL = 7 * 24 * time.Hour
+
+// MaxNameLength is the maximum length of a workspace name.
+const MaxNameLength = 255
+
+// invitationTokenPrefix makes invitation tokens recognizable.
+const invitationTokenPrefix = "shi_"
+
+// ErrNotFound is returned when a workspace does not exist or the user is not a member of it.
+var ErrNotFound = errors.New("workspace not found")
+
+// ErrForbidden is returned when the role of a member does not allow the operation.
+var ErrForbidden = errors.New("workspace role does not allow the operation")
+
+// ErrLastOwner is returned when an operation would leave a workspace without an owner.
+var ErrLastOwner = errors.New("workspace must keep at least one owner")
+
+// ErrInvalidName is returned when a workspace name is empty or too long.
+var ErrInvalidName = errors.New("invalid workspace name")
+
+// ErrInvalidRole is returned for an unknown role.
+var ErrInvalidRole = errors.New("invalid workspace role")
+
+// Service manages workspaces and authorizes members.
+type Service struct {
+	// repo stores the workspaces, members and invitations.
+	repo repository.IRepository
+	// invitationTTL is the lifetime of new invitations.
+	invitationTTL time.Duration
+	// now returns the current time.
+	now func() time.Time
+}
+
+// NewService creates a new workspace Service. A zero invitation TTL selects DefaultInvitationTTL.
+func NewService(repo repository.IRepository, invitationTTL time.Duration) *Service {
+	if invitationTTL <= 0 {
+		invitationTTL = DefaultInvitationTTL
+	}
+	return &Service{repo: repo, invitationTTL: invitationTTL, now: time.Now}
+}
+
+// Create creates a workspace owned by the user.
+func (s *Service) Create(ctx context.Context, userID string, name string) (repository.WorkspaceMembership, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MaxNameLength {
+		return repository.WorkspaceMembership{}, ErrInvalidName
+	}
+
+	now := s.now().UTC()
+	workspace := repository.Workspace{ID: uuid.NewString(), Name: name, CreatedBy: userID, CreatedAt: now}
+	owner := repository.WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID, Role: repository.RoleOwner, JoinedAt: now}
+	if err := s.repo.AddWorkspace(ctx, workspace, owner); err != nil {
+		return repository.WorkspaceMembership{}, fmt.Errorf("creating workspace: %w", err)
+	}
+	return repository.WorkspaceMembership{Workspace: workspace, Role: owner.Role}, nil
+}
+
+// List returns the workspaces the user is a member of.
+func (s *Service) List(ctx context.Context, userID string) ([]repository.WorkspaceMembership, error) {
+	return s.repo.GetWorkspacesByUser(ctx, userID)
+}
+
+// Authorize returns the membership of the user in the workspace if their role grants at least the required role.
+// Non-members get ErrNotFound, so that workspace IDs are not revealed to them.
+func (s *Service) Authorize(ctx context.Context, workspaceID string, userID string, role string) (repository.WorkspaceMember, error) {
+	member, err := s.repo.GetWorkspaceMember(ctx, workspaceID, userID)
+	if errors.Is(err, repository.ErrWorkspaceMemberNotExist) {
+		return repository.WorkspaceMember{}, ErrNotFound
+	}
+	if err != nil {
+		return repository.WorkspaceMember{}, err
+	}
+	if !member.HasRole(role) {
+		return repository.WorkspaceMember{}, ErrForbidden
+	}
+	return member, nil
+}
+
+// Members returns the members of the workspace. Any member may list them.
+func (s *Service) Members(ctx context.Context, workspaceID string, userID string) ([]repository.WorkspaceMember, error) {
+	if _, err := s.Authorize(ctx, workspaceID, userID, repository.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetWorkspaceMembers(ctx, workspaceID)
+}
+
+// ChangeRole changes the role of a member. Only owners may change roles, and the last owner cannot be demoted.
+func (s *Service) ChangeRole(ctx context.Context, workspaceID string, userID string, memberID string, role string) error {
+	if !repository.IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	if _, err := s.Authorize(ctx, workspaceID, userID, repository.RoleOwner); err != nil {
+		return err
+	}
+	if role != repository.RoleOwner {
+		if err := s.keepOwner(ctx, workspaceID, memberID); err != nil {
+			return err
+		}
+	}
+	return s.repo.UpdateWorkspaceMemberRole(ctx, workspaceID, memberID, role)
+}
+
+// RemoveMember removes a member from the workspace. Owners may remove anyone; other members may only leave.
+// The last owner cannot be removed.
+func (s *Service) RemoveMember(ctx context.Context, workspaceID string, userID string, memberID string) error {
+	role := repository.RoleOwner
+	if memberID == userID {
+		role = repository.RoleViewer
+	}
+	if _, err := s.Authorize(ctx, workspaceID, userID, role); err != nil {
+		return err
+	}
+	if err := s.keepOwner(ctx, workspaceID, memberID); err != nil {
+		return err
+	}
+	return s.repo.RemoveWorkspaceMember(ctx, workspaceID, memberID)
+}
+
+// Invite creates an invitation granting the role and returns it with its token.
+// Only owners may invite. The token is only available at creation time.
+func (s *Service) Invite(ctx context.Context, workspaceID string, userID string, role string) (repository.WorkspaceInvitation, string, error) {
+	if !repository.IsValidRole(role) {
+		return repository.WorkspaceInvitation{}, "", ErrInvalidRole
+	}
+	if _, err := s.Authorize(ctx, workspaceID, userID, repository.RoleOwner); err != nil {
+		return repository.WorkspaceInvitation{}, "", err
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return repository.WorkspaceInvitation{}, "", err
+	}
+	now := s.now().UTC()
+	invitation := repository.WorkspaceInvitation{
+		ID:          uuid.NewString(),
+		WorkspaceID: workspaceID,
+		Role:        role,
+		TokenHash:   repository.HashInvitationToken(token),
+		InvitedBy:   userID,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.invitationTTL),
+	}
+	if err := s.repo.AddWorkspaceInvitation(ctx, invitation); err != nil {
+		return repository.WorkspaceInvitation{}, "", fmt.Errorf("saving invitation: %w", err)
+	}
+	return invitation, token, nil
+}
+
+// Invitations returns the invitations of the workspace. Only owners may list them.
+func (s *Service) Invitations(ctx context.Context, workspaceID string, userID string) ([]repository.WorkspaceInvitation, error) {
+	if _, err := s.Authorize(ctx, workspaceID, userID, repository.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.GetWorkspaceInvitations(ctx, workspaceID)
+}
+
+// RevokeInvitation deletes an invitation of the workspace. Only owners may revoke invitations.
+func (s *Service) RevokeInvitation(ctx context.Context, workspaceID string, userID string, invitationID string) error {
+	if _, err := s.Authorize(ctx, workspaceID, userID, repository.RoleOwner); err != nil {
+		return err
+	}
+	return s.repo.DeleteWorkspaceInvitation(ctx, workspaceID, invitationID)
+}
+
+// Accept adds the user to the workspace of a pending invitation with the role it grants.
+func (s *Service) Accept(ctx context.Context, userID string, token string) (repository.WorkspaceMember, error) {
+	return s.repo.AcceptWorkspaceInvitation(ctx, repository.HashInvitationToken(token), userID, s.now().UTC())
+}
+
+// keepOwner returns ErrLastOwner if the member is the only owner of the workspace.
+func (s *Service) keepOwner(ctx context.Context, workspaceID string, memberID string) error {
+	members, err := s.repo.GetWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	owners, isOwner := 0, false
+	for _, m := range members {
+		if m.Role == repository.RoleOwner {
+			owners++
+			isOwner = isOwner || m.UserID == memberID
+		}
+	}
+	if isOwner && owners == 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// generateInvitationToken returns a new random invitation token.
+func generateInvitationToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating invitation token: %w", err)
+	}
+	return invitationTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,72 @@
+package workspaces
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gennadis/shorturl/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	service := NewService(repo, time.Hour)
+
+	_, err := service.Create(ctx, "owner", "  ")
+	assert.ErrorIs(t, err, ErrInvalidName)
+	_, err = service.Create(ctx, "owner", strings.Repeat("x", MaxNameLength+1))
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	workspace, err := service.Create(ctx, "owner", " Marketing ")
+	require.NoError(t, err)
+	assert.Equal(t, "Marketing", workspace.Name)
+	assert.Equal(t, repository.RoleOwner, workspace.Role)
+
+	// Only owners invite; invitations grant their role once.
+	_, _, err = service.Invite(ctx, workspace.ID, "owner", "admin")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	_, _, err = service.Invite(ctx, workspace.ID, "stranger", repository.RoleViewer)
+	assert.ErrorIs(t, err, ErrNotFound)
+	invitation, token, err := service.Invite(ctx, workspace.ID, "owner", repository.RoleEditor)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, invitationTokenPrefix))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), invitation.ExpiresAt, time.Minute)
+
+	member, err := service.Accept(ctx, "editor", token)
+	require.NoError(t, err)
+	assert.Equal(t, repository.RoleEditor, member.Role)
+	_, err = service.Accept(ctx, "someone", token)
+	assert.ErrorIs(t, err, repository.ErrWorkspaceInvitationNotExist)
+
+	_, _, err = service.Invite(ctx, workspace.ID, "editor", repository.RoleViewer)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = service.Invitations(ctx, workspace.ID, "editor")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	members, err := service.Members(ctx, workspace.ID, "editor")
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+
+	_, err = service.Authorize(ctx, workspace.ID, "editor", repository.RoleEditor)
+	assert.NoError(t, err)
+	_, err = service.Authorize(ctx, workspace.ID, "editor", repository.RoleOwner)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// The last owner can neither be demoted nor removed.
+	assert.ErrorIs(t, service.ChangeRole(ctx, workspace.ID, "owner", "owner", repository.RoleViewer), ErrLastOwner)
+	assert.ErrorIs(t, service.RemoveMember(ctx, workspace.ID, "owner", "owner"), ErrLastOwner)
+	assert.ErrorIs(t, service.ChangeRole(ctx, workspace.ID, "editor", "editor", repository.RoleOwner), ErrForbidden)
+	require.NoError(t, service.ChangeRole(ctx, workspace.ID, "owner", "editor", repository.RoleOwner))
+	require.NoError(t, service.RemoveMember(ctx, workspace.ID, "owner", "owner"))
+
+	// Members may leave on their own, except for the last owner.
+	assert.ErrorIs(t, service.RemoveMember(ctx, workspace.ID, "editor", "editor"), ErrLastOwner)
+	memberships, err := service.List(ctx, "editor")
+	require.NoError(t, err)
+	require.Len(t, memberships, 1)
+	assert.Equal(t, repository.RoleOwner, memberships[0].Role)
+}